@@ -1,11 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/download"
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/consistency"
 
 	"github.com/urfave/cli/v2"
 )
@@ -84,6 +89,100 @@ func main() {
 
 				},
 			},
+			{
+				Name:     "consistency",
+				HelpName: "consistency",
+				Usage:    "Compare live orders with a paper wallet simulation of the same candles",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "pair",
+						Aliases:  []string{"p"},
+						Usage:    "eg. BTCUSDT",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "timeframe",
+						Aliases:  []string{"t"},
+						Usage:    "eg. 1h",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "candles",
+						Aliases:  []string{"c"},
+						Usage:    "eg. ./btc.csv",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "orders",
+						Aliases:  []string{"o"},
+						Usage:    "live bot database, eg. ./ninjabot.db",
+						Required: true,
+					},
+					&cli.Float64Flag{
+						Name:     "balance",
+						Aliases:  []string{"b"},
+						Usage:    "initial quote balance, eg. 1000",
+						Required: true,
+					},
+					&cli.Float64Flag{
+						Name:  "maker",
+						Usage: "maker fee, eg. 0.001",
+					},
+					&cli.Float64Flag{
+						Name:  "taker",
+						Usage: "taker fee, eg. 0.001",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					pair, timeframe := c.String("pair"), c.String("timeframe")
+					_, quote := exchange.SplitAssetQuote(pair)
+
+					feed, err := exchange.NewCSVFeed(timeframe, exchange.PairFeed{
+						Pair:      pair,
+						File:      c.String("candles"),
+						Timeframe: timeframe,
+					})
+					if err != nil {
+						return err
+					}
+
+					candles, err := feed.CandlesByPeriod(c.Context, pair, timeframe, time.Time{}, time.Now())
+					if err != nil {
+						return err
+					}
+
+					db, err := storage.FromFile(c.String("orders"))
+					if err != nil {
+						return err
+					}
+
+					orders, err := db.Orders(storage.WithPair(pair))
+					if err != nil {
+						return err
+					}
+
+					live := make([]model.Order, 0, len(orders))
+					for _, order := range orders {
+						live = append(live, *order)
+					}
+
+					checker := consistency.NewChecker(quote,
+						consistency.WithFees(c.Float64("maker"), c.Float64("taker")),
+						consistency.WithPaperWalletOptions(exchange.WithPaperAsset(quote, c.Float64("balance"))),
+					)
+
+					report, err := checker.Check(c.Context, candles, live)
+					if err != nil {
+						return err
+					}
+
+					fmt.Print(report.String())
+					if !report.Consistent() {
+						return fmt.Errorf("%d discrepancies found", len(report.Discrepancies))
+					}
+					return nil
+				},
+			},
 		},
 	}
 
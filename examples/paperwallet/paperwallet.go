@@ -58,6 +58,8 @@ func main() {
 		exchange.WithPaperFee(0.001, 0.001),
 		exchange.WithPaperAsset("USDT", 10000),
 		exchange.WithDataFeed(binance),
+		// compare simulated fills with the real price, with 0.1% of tolerance
+		exchange.WithPaperQuoteValidator(0.001),
 	)
 
 	// initializing my strategy
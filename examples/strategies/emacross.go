@@ -46,8 +46,6 @@ func (e CrossEMA) Indicators(df *ninjabot.Dataframe) []strategy.ChartIndicator {
 }
 
 func (e *CrossEMA) OnCandle(df *ninjabot.Dataframe, broker service.Broker) {
-	closePrice := df.Close.Last(0)
-
 	assetPosition, quotePosition, err := broker.Position(df.Pair)
 	if err != nil {
 		log.Error(err)
@@ -57,8 +55,7 @@ func (e *CrossEMA) OnCandle(df *ninjabot.Dataframe, broker service.Broker) {
 	if quotePosition >= 10 && // minimum quote position to trade
 		df.Metadata["ema8"].Crossover(df.Metadata["sma21"]) { // trade signal (EMA8 > SMA21)
 
-		amount := quotePosition / closePrice // calculate amount of asset to buy
-		_, err := broker.CreateOrderMarket(ninjabot.SideTypeBuy, df.Pair, amount)
+		_, err := broker.BuyPercent(df.Pair, 1) // buy with all the available quote
 		if err != nil {
 			log.Error(err)
 		}
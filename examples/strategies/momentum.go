@@ -0,0 +1,43 @@
+package strategies
+
+import (
+	"github.com/rodrigo-brito/ninjabot"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// Momentum buys when the price rises 5% over the warmup period and sells when it falls 5%.
+// The timeframe, warmup and indicators come from strategy.Base, only OnCandle is implemented.
+type Momentum struct {
+	strategy.Base
+}
+
+func NewMomentum() *Momentum {
+	return &Momentum{Base: strategy.Base{Interval: "4h", Warmup: 10}}
+}
+
+func (m *Momentum) OnCandle(df *ninjabot.Dataframe, broker service.Broker) {
+	assetPosition, quotePosition, err := broker.Position(df.Pair)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	change := df.Close.Last(0) / df.Close.Last(m.Warmup-1)
+
+	if quotePosition >= 10 && change > 1.05 { // minimum quote position to trade
+		_, err := broker.BuyPercent(df.Pair, 1) // buy with all the available quote
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	if assetPosition > 0 && change < 0.95 {
+		_, err := broker.CreateOrderMarket(ninjabot.SideTypeSell, df.Pair, assetPosition)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// Aggregated is a feed decorator that provides timeframes not offered by the exchange (e.g. 2h, 6h).
+// Candles are fetched in a supported base timeframe and aggregated client-side, only complete
+// candles aligned with the target period are emitted. Other timeframes are delegated to the wrapped exchange.
+type Aggregated struct {
+	service.Exchange
+	baseTimeframe string
+	timeframes    map[string]int
+	location      *time.Location
+}
+
+// NewAggregated creates a feed that builds each target timeframe from candles of baseTimeframe.
+// Target timeframes must be a multiple of the base timeframe and split a day in equal periods.
+func NewAggregated(exchange service.Exchange, baseTimeframe string, timeframes ...string) (*Aggregated, error) {
+	baseDuration, err := str2duration.ParseDuration(baseTimeframe)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregated := &Aggregated{
+		Exchange:      exchange,
+		baseTimeframe: baseTimeframe,
+		timeframes:    make(map[string]int),
+	}
+
+	for _, timeframe := range timeframes {
+		duration, err := str2duration.ParseDuration(timeframe)
+		if err != nil {
+			return nil, err
+		}
+
+		if duration <= baseDuration || duration%baseDuration != 0 {
+			return nil, fmt.Errorf("invalid timeframe: %s is not a multiple of %s", timeframe, baseTimeframe)
+		}
+
+		// validate period alignment
+		if _, err := isLastCandlePeriod(time.Time{}, baseTimeframe, timeframe, nil); err != nil {
+			return nil, err
+		}
+
+		aggregated.timeframes[timeframe] = int(duration / baseDuration)
+	}
+
+	return aggregated, nil
+}
+
+// SetTimezone aligns the aggregated periods with the clock of the location, e.g. daily candles start at
+// the midnight of the exchange timezone. Candle times remain in UTC, default is UTC.
+func (a *Aggregated) SetTimezone(loc *time.Location) {
+	a.location = loc
+}
+
+func (a *Aggregated) CandlesByPeriod(ctx context.Context, pair, timeframe string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	if _, ok := a.timeframes[timeframe]; !ok {
+		return a.Exchange.CandlesByPeriod(ctx, pair, timeframe, start, end)
+	}
+
+	candles, err := a.Exchange.CandlesByPeriod(ctx, pair, a.baseTimeframe, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.aggregate(candles, timeframe)
+}
+
+func (a *Aggregated) CandlesByLimit(ctx context.Context, pair, timeframe string, limit int) ([]model.Candle, error) {
+	factor, ok := a.timeframes[timeframe]
+	if !ok {
+		return a.Exchange.CandlesByLimit(ctx, pair, timeframe, limit)
+	}
+
+	// an extra period is requested to cover the unaligned candles at the beginning
+	candles, err := a.Exchange.CandlesByLimit(ctx, pair, a.baseTimeframe, (limit+1)*factor)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := a.aggregate(candles, timeframe)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+
+	return result, nil
+}
+
+func (a *Aggregated) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle, chan error) {
+	if _, ok := a.timeframes[timeframe]; !ok {
+		return a.Exchange.CandlesSubscription(ctx, pair, timeframe)
+	}
+
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	baseCandles, baseErrors := a.Exchange.CandlesSubscription(ctx, pair, a.baseTimeframe)
+
+	go func() {
+		defer close(cerr)
+		defer close(ccandle)
+
+		var (
+			partial *model.Candle
+			merged  int // candles merged in the partial candle
+		)
+		for {
+			select {
+			case candle, ok := <-baseCandles:
+				if !ok {
+					return
+				}
+
+				if !candle.Complete {
+					continue
+				}
+
+				aggregated, err := a.next(partial, merged, candle, timeframe)
+				if err != nil {
+					cerr <- err
+					continue
+				}
+
+				if aggregated == nil || !aggregated.Complete {
+					partial = aggregated
+					if partial != nil {
+						merged++
+					}
+					continue
+				}
+
+				partial = nil
+				merged = 0
+				ccandle <- *aggregated
+			case err, ok := <-baseErrors:
+				if !ok {
+					baseErrors = nil
+					continue
+				}
+				cerr <- err
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
+
+// next appends a complete base candle to the partial candle of the current period, with merged candles.
+// It returns nil while waiting for the first candle aligned with the target period.
+func (a *Aggregated) next(partial *model.Candle, merged int, candle model.Candle,
+	timeframe string) (*model.Candle, error) {
+	last, err := isLastCandlePeriod(candle.Time, a.baseTimeframe, timeframe, a.location)
+	if err != nil {
+		return nil, err
+	}
+
+	if partial != nil {
+		candle = mergeCandle(*partial, candle, merged)
+	} else if first, err := isFistCandlePeriod(candle.Time, a.baseTimeframe, timeframe,
+		a.location); err != nil || !first {
+		return nil, err
+	}
+
+	candle.Complete = last
+	return &candle, nil
+}
+
+func (a *Aggregated) aggregate(candles []model.Candle, timeframe string) ([]model.Candle, error) {
+	complete := make([]model.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if candle.Complete {
+			complete = append(complete, candle)
+		}
+	}
+
+	resampled, err := resampleCandles(complete, a.baseTimeframe, timeframe, a.location)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Candle, 0, len(resampled))
+	for _, candle := range resampled {
+		if candle.Complete {
+			result = append(result, candle)
+		}
+	}
+
+	return result, nil
+}
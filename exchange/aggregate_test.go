@@ -0,0 +1,150 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+// hourly candles from 2021-01-01 23:00 to 2021-01-02 04:00, the first one is not aligned with 2h periods
+func hourlyCandles() []model.Candle {
+	start := time.Date(2021, 1, 1, 23, 0, 0, 0, time.UTC)
+	candles := make([]model.Candle, 0)
+	for i := 0; i < 6; i++ {
+		candles = append(candles, model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Open:     float64(100 + i),
+			Close:    float64(101 + i),
+			High:     float64(110 + i),
+			Low:      float64(90 - i),
+			Volume:   float64(i + 1),
+			Complete: true,
+		})
+	}
+	return candles
+}
+
+func TestNewAggregated(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		_, err := NewAggregated(mocks.NewExchange(t), "1h", "2h", "6h")
+		require.NoError(t, err)
+	})
+
+	t.Run("not multiple of base", func(t *testing.T) {
+		_, err := NewAggregated(mocks.NewExchange(t), "1h", "90m")
+		require.Error(t, err)
+	})
+
+	t.Run("not aligned with day", func(t *testing.T) {
+		_, err := NewAggregated(mocks.NewExchange(t), "1h", "5h")
+		require.EqualError(t, err, "invalid timeframe: 5h")
+	})
+}
+
+func TestAggregated_CandlesByPeriod(t *testing.T) {
+	start := time.Date(2021, 1, 1, 23, 0, 0, 0, time.UTC)
+	end := start.Add(6 * time.Hour)
+
+	exc := mocks.NewExchange(t)
+	exc.EXPECT().CandlesByPeriod(mock.Anything, "BTCUSDT", "1h", start, end).Return(hourlyCandles(), nil)
+
+	feed, err := NewAggregated(exc, "1h", "2h")
+	require.NoError(t, err)
+
+	candles, err := feed.CandlesByPeriod(context.Background(), "BTCUSDT", "2h", start, end)
+	require.NoError(t, err)
+	require.Len(t, candles, 2)
+
+	// first candle aligned to 00:00, the 23:00 candle is discarded
+	require.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), candles[0].Time)
+	require.Equal(t, 101.0, candles[0].Open)
+	require.Equal(t, 103.0, candles[0].Close)
+	require.Equal(t, 112.0, candles[0].High)
+	require.Equal(t, 88.0, candles[0].Low)
+	require.Equal(t, 5.0, candles[0].Volume)
+	require.True(t, candles[0].Complete)
+
+	require.Equal(t, time.Date(2021, 1, 2, 2, 0, 0, 0, time.UTC), candles[1].Time)
+	require.Equal(t, 103.0, candles[1].Open)
+	require.Equal(t, 105.0, candles[1].Close)
+	require.Equal(t, 9.0, candles[1].Volume)
+}
+
+func TestAggregated_CandlesByLimit(t *testing.T) {
+	exc := mocks.NewExchange(t)
+	exc.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1h", 4).Return(hourlyCandles(), nil)
+
+	feed, err := NewAggregated(exc, "1h", "2h")
+	require.NoError(t, err)
+
+	candles, err := feed.CandlesByLimit(context.Background(), "BTCUSDT", "2h", 1)
+	require.NoError(t, err)
+	require.Len(t, candles, 1)
+	require.Equal(t, time.Date(2021, 1, 2, 2, 0, 0, 0, time.UTC), candles[0].Time)
+}
+
+func TestAggregated_CandlesSubscription(t *testing.T) {
+	t.Run("aggregated timeframe", func(t *testing.T) {
+		baseCandles := make(chan model.Candle)
+		baseErrors := make(chan error)
+
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().CandlesSubscription(mock.Anything, "BTCUSDT", "1h").Return(baseCandles, baseErrors)
+
+		feed, err := NewAggregated(exc, "1h", "2h")
+		require.NoError(t, err)
+
+		go func() {
+			for _, candle := range hourlyCandles() {
+				// partial updates must be ignored
+				partial := candle
+				partial.Complete = false
+				partial.High = 1000
+				baseCandles <- partial
+				baseCandles <- candle
+			}
+			close(baseCandles)
+			close(baseErrors)
+		}()
+
+		ccandle, _ := feed.CandlesSubscription(context.Background(), "BTCUSDT", "2h")
+		candles := make([]model.Candle, 0)
+		for candle := range ccandle {
+			candles = append(candles, candle)
+		}
+
+		require.Len(t, candles, 2)
+		require.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), candles[0].Time)
+		require.Equal(t, 101.0, candles[0].Open)
+		require.Equal(t, 103.0, candles[0].Close)
+		require.Equal(t, 112.0, candles[0].High)
+		require.Equal(t, 88.0, candles[0].Low)
+		require.Equal(t, 5.0, candles[0].Volume)
+		require.True(t, candles[0].Complete)
+
+		require.Equal(t, time.Date(2021, 1, 2, 2, 0, 0, 0, time.UTC), candles[1].Time)
+		require.Equal(t, 9.0, candles[1].Volume)
+	})
+
+	t.Run("native timeframe is delegated", func(t *testing.T) {
+		ccandle := make(chan model.Candle)
+		cerr := make(chan error)
+
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().CandlesSubscription(mock.Anything, "BTCUSDT", "4h").Return(ccandle, cerr)
+
+		feed, err := NewAggregated(exc, "1h", "2h")
+		require.NoError(t, err)
+
+		resultCandle, resultErr := feed.CandlesSubscription(context.Background(), "BTCUSDT", "4h")
+		require.Equal(t, ccandle, resultCandle)
+		require.Equal(t, cerr, resultErr)
+	})
+}
@@ -3,11 +3,12 @@ package exchange
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/adshao/go-binance/v2"
-	"github.com/adshao/go-binance/v2/common"
 	"github.com/jpillora/backoff"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -20,8 +21,14 @@ type Binance struct {
 	ctx        context.Context
 	client     *binance.Client
 	assetsInfo map[string]model.AssetInfo
+	rounding   RoundingMode
+	candles    model.CandlePolicy
+	quote      QuoteMode
 	HeikinAshi bool
 	Testnet    bool
+	gapFill    bool
+	proxy      string
+	limiter    *weightLimiter
 
 	APIKey    string
 	APISecret string
@@ -29,6 +36,17 @@ type Binance struct {
 	MetadataFetchers []MetadataFetchers
 }
 
+// QuoteMode is the price returned by LastQuote of a live exchange
+type QuoteMode int
+
+const (
+	// QuoteLastTrade is the price of the last trade of the pair, in real time
+	QuoteLastTrade QuoteMode = iota
+	// QuoteLastCandle is the close of the last complete candle of 1 minute, stable within the minute but up to a
+	// minute behind the market
+	QuoteLastCandle
+)
+
 type BinanceOption func(*Binance)
 
 // WithBinanceCredentials will set Binance credentials
@@ -54,6 +72,57 @@ func WithMetadataFetcher(fetcher MetadataFetchers) BinanceOption {
 	}
 }
 
+// WithBinanceRounding sets how order quantities and prices are adjusted to the step size, default is RoundingFloor
+func WithBinanceRounding(mode RoundingMode) BinanceOption {
+	return func(b *Binance) {
+		b.rounding = mode
+	}
+}
+
+// WithBinanceCandlePolicy sets how candles with zero, negative or NaN prices are handled, default is
+// model.CandlePolicySkip. With model.CandlePolicyError, the invalid candles are discarded and the error
+// is sent to the subscription or returned by the candle requests.
+func WithBinanceCandlePolicy(policy model.CandlePolicy) BinanceOption {
+	return func(b *Binance) {
+		b.candles = policy
+	}
+}
+
+// WithBinanceGapFill fills the candles missed while the websocket is disconnected. On reconnection, the
+// candles between the last complete candle and now are fetched and emitted before resuming the stream.
+func WithBinanceGapFill() BinanceOption {
+	return func(b *Binance) {
+		b.gapFill = true
+	}
+}
+
+// WithBinanceQuoteMode sets the price returned by LastQuote, default is QuoteLastTrade
+func WithBinanceQuoteMode(mode QuoteMode) BinanceOption {
+	return func(b *Binance) {
+		b.quote = mode
+	}
+}
+
+// WithBinanceProxy sends the requests of the REST API through a proxy, e.g. http://127.0.0.1:1087. Without it,
+// the proxy of the HTTP_PROXY and HTTPS_PROXY environment variables is used, if any. The websocket streams
+// always use the environment proxy.
+func WithBinanceProxy(proxy string) BinanceOption {
+	return func(b *Binance) {
+		b.proxy = proxy
+	}
+}
+
+// WithBinanceRateLimit limits the request weight sent to the API per minute, e.g. to bootstrap long candle
+// histories without reaching the limit of the exchange and getting the IP banned. The candle requests, the order
+// queries and the order creations wait for the weight, or until their context is canceled. Default is no limit.
+func WithBinanceRateLimit(weightPerMinute int) BinanceOption {
+	return func(b *Binance) {
+		if weightPerMinute > 0 {
+			b.limiter = newWeightLimiter(weightPerMinute)
+		}
+	}
+}
+
 // WithTestNet activate Bianance testnet
 func WithTestNet() BinanceOption {
 	return func(b *Binance) {
@@ -70,6 +139,14 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 	}
 
 	exchange.client = binance.NewClient(exchange.APIKey, exchange.APISecret)
+	if exchange.proxy != "" {
+		client, err := proxyClient(exchange.proxy)
+		if err != nil {
+			return nil, err
+		}
+		exchange.client.HTTPClient = client
+	}
+
 	err := exchange.client.NewPingService().Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("binance ping fail: %w", err)
@@ -97,6 +174,10 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 					tradeLimits.StepSize, _ = strconv.ParseFloat(filter["stepSize"].(string), 64)
 				}
 
+				if typ == string(binance.SymbolFilterTypeMinNotional) {
+					tradeLimits.MinNotional, _ = strconv.ParseFloat(filter["minNotional"].(string), 64)
+				}
+
 				if typ == string(binance.SymbolFilterTypePriceFilter) {
 					tradeLimits.MinPrice, _ = strconv.ParseFloat(filter["minPrice"].(string), 64)
 					tradeLimits.MaxPrice, _ = strconv.ParseFloat(filter["maxPrice"].(string), 64)
@@ -112,12 +193,80 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 	return exchange, nil
 }
 
+// proxyClient returns an HTTP client that sends the requests through the proxy
+func proxyClient(proxy string) (*http.Client, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %s: %w", proxy, err)
+	}
+	if proxyURL.Scheme == "" || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %s: scheme and host are required", proxy)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}, nil
+}
+
+// LastQuote returns the current price of a pair, used to size the orders of live strategies. By default, it is
+// the price of the last trade. With QuoteLastCandle, it is the close of the last complete candle of 1 minute, the
+// candle in formation is ignored, see WithBinanceQuoteMode.
 func (b *Binance) LastQuote(ctx context.Context, pair string) (float64, error) {
-	candles, err := b.CandlesByLimit(ctx, pair, "1m", 1)
-	if err != nil || len(candles) < 1 {
+	if b.quote == QuoteLastCandle {
+		return b.lastCandleClose(ctx, pair)
+	}
+
+	prices, err := b.client.NewListPricesService().Symbol(pair).Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(prices) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoMarketData, pair)
+	}
+
+	return strconv.ParseFloat(prices[0].Price, 64)
+}
+
+// lastCandleClose returns the close of the last 1m candle closed before now
+func (b *Binance) lastCandleClose(ctx context.Context, pair string) (float64, error) {
+	klines, err := b.client.NewKlinesService().Symbol(pair).Interval("1m").Limit(2).Do(ctx)
+	if err != nil {
 		return 0, err
 	}
-	return candles[0].Close, nil
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for i := len(klines) - 1; i >= 0; i-- {
+		if klines[i].CloseTime < now {
+			return strconv.ParseFloat(klines[i].Close, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrNoMarketData, pair)
+}
+
+// BestBidAsk returns the best bid and ask prices of the order book of a pair
+func (b *Binance) BestBidAsk(ctx context.Context, pair string) (bid, ask float64, err error) {
+	tickers, err := b.client.NewListBookTickersService().Symbol(pair).Do(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(tickers) == 0 {
+		return 0, 0, fmt.Errorf("%w: %s", ErrInvalidAsset, pair)
+	}
+
+	bid, err = strconv.ParseFloat(tickers[0].BidPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ask, err = strconv.ParseFloat(tickers[0].AskPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return bid, ask, nil
 }
 
 func (b *Binance) AssetsInfo(pair string) model.AssetInfo {
@@ -150,6 +299,10 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 		return nil, err
 	}
 
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return nil, err
+	}
+
 	ocoOrder, err := b.client.NewCreateOCOService().
 		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
@@ -160,7 +313,7 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 		Symbol(pair).
 		Do(b.ctx)
 	if err != nil {
-		return nil, err
+		return nil, maintenanceError(err)
 	}
 
 	orders := make([]model.Order, 0, len(ocoOrder.Orders))
@@ -190,21 +343,26 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 	return orders, nil
 }
 
-func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+func (b *Binance) CreateOrderStop(side model.SideType, pair string, quantity float64,
+	limit float64) (model.Order, error) {
 	err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return model.Order{}, err
+	}
+
 	order, err := b.client.NewCreateOrderService().Symbol(pair).
 		Type(binance.OrderTypeStopLoss).
 		TimeInForce(binance.TimeInForceTypeGTC).
-		Side(binance.SideTypeSell).
+		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
 		Price(b.formatPrice(pair, limit)).
 		Do(b.ctx)
 	if err != nil {
-		return model.Order{}, err
+		return model.Order{}, maintenanceError(err)
 	}
 
 	price, _ := strconv.ParseFloat(order.Price, 64)
@@ -225,36 +383,48 @@ func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64)
 
 func (b *Binance) formatPrice(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
+		value = roundToStep(value, info.TickSize, info.QuotePrecision, b.rounding)
 	}
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *Binance) formatQuantity(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, value)
+		value = roundToStep(value, info.StepSize, info.BaseAssetPrecision, b.rounding)
 	}
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 	quantity float64, limit float64) (model.Order, error) {
+	return b.CreateOrderLimitTIF(side, pair, quantity, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF creates a limit order with the given time in force. For IOC and FOK orders,
+// the returned quantity is the executed quantity, and an order expired after a partial fill is returned
+// filled, see `model.Order.Executed`.
+func (b *Binance) CreateOrderLimitTIF(side model.SideType, pair string,
+	quantity float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
 
 	err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return model.Order{}, err
+	}
+
 	order, err := b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeLimit).
-		TimeInForce(binance.TimeInForceTypeGTC).
+		TimeInForce(binance.TimeInForceType(tif)).
 		Side(binance.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
 		Price(b.formatPrice(pair, limit)).
 		Do(b.ctx)
 	if err != nil {
-		return model.Order{}, err
+		return model.Order{}, maintenanceError(err)
 	}
 
 	price, err := strconv.ParseFloat(order.Price, 64)
@@ -262,11 +432,22 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, err
 	}
 
-	quantity, err = strconv.ParseFloat(order.OrigQuantity, 64)
+	executedQuantity := order.OrigQuantity
+	if tif != model.TimeInForceGTC {
+		executedQuantity = order.ExecutedQuantity
+	}
+
+	quantity, err = strconv.ParseFloat(executedQuantity, 64)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	cost, _ := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
+	if cost > 0 && executed > 0 {
+		price = cost / executed
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
@@ -277,7 +458,10 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
-	}, nil
+
+		ExecutedQuantity: executed,
+		ExecutedCost:     cost,
+	}.Executed(), nil
 }
 
 func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity float64) (model.Order, error) {
@@ -286,6 +470,10 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		return model.Order{}, err
 	}
 
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return model.Order{}, err
+	}
+
 	order, err := b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
@@ -294,7 +482,7 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		NewOrderRespType(binance.NewOrderRespTypeFULL).
 		Do(b.ctx)
 	if err != nil {
-		return model.Order{}, err
+		return model.Order{}, maintenanceError(err)
 	}
 
 	cost, err := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
@@ -320,12 +508,43 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 	}, nil
 }
 
+// CreateOrderMarketTagged creates a market order annotated with a tag, the tag is not sent to the exchange
+func (b *Binance) CreateOrderMarketTagged(side model.SideType, pair string, quantity float64,
+	tag string) (model.Order, error) {
+	order, err := b.CreateOrderMarket(side, pair, quantity)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order.Tag = tag
+	return order, nil
+}
+
+// BuyPercent creates a market order to buy with a fraction of the free quote balance, see PercentQuantity
+func (b *Binance) BuyPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(b, model.SideTypeBuy, pair, percent)
+}
+
+// SellPercent creates a market order to sell a fraction of the free asset balance, see PercentQuantity
+func (b *Binance) SellPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(b, model.SideTypeSell, pair, percent)
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order, see ClosePosition
+func (b *Binance) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	return ClosePosition(b, pair, fraction)
+}
+
 func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quantity float64) (model.Order, error) {
 	err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return model.Order{}, err
+	}
+
 	order, err := b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
@@ -334,7 +553,7 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		NewOrderRespType(binance.NewOrderRespTypeFULL).
 		Do(b.ctx)
 	if err != nil {
-		return model.Order{}, err
+		return model.Order{}, maintenanceError(err)
 	}
 
 	cost, err := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
@@ -361,11 +580,15 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 }
 
 func (b *Binance) Cancel(order model.Order) error {
+	if err := b.limiter.wait(b.ctx, weightOrder); err != nil {
+		return err
+	}
+
 	_, err := b.client.NewCancelOrderService().
 		Symbol(order.Pair).
 		OrderID(order.ExchangeID).
 		Do(b.ctx)
-	return err
+	return maintenanceError(err)
 }
 
 func (b *Binance) Orders(pair string, limit int) ([]model.Order, error) {
@@ -375,7 +598,7 @@ func (b *Binance) Orders(pair string, limit int) ([]model.Order, error) {
 		Do(b.ctx)
 
 	if err != nil {
-		return nil, err
+		return nil, maintenanceError(err)
 	}
 
 	orders := make([]model.Order, 0)
@@ -386,13 +609,17 @@ func (b *Binance) Orders(pair string, limit int) ([]model.Order, error) {
 }
 
 func (b *Binance) Order(pair string, id int64) (model.Order, error) {
+	if err := b.limiter.wait(b.ctx, weightQueryOrder); err != nil {
+		return model.Order{}, err
+	}
+
 	order, err := b.client.NewGetOrderService().
 		Symbol(pair).
 		OrderID(id).
 		Do(b.ctx)
 
 	if err != nil {
-		return model.Order{}, err
+		return model.Order{}, maintenanceError(err)
 	}
 
 	return newOrder(order), nil
@@ -401,7 +628,8 @@ func (b *Binance) Order(pair string, id int64) (model.Order, error) {
 func newOrder(order *binance.Order) model.Order {
 	var price float64
 	cost, _ := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
-	quantity, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	quantity := executed
 	if cost > 0 && quantity > 0 {
 		price = cost / quantity
 	} else {
@@ -419,13 +647,16 @@ func newOrder(order *binance.Order) model.Order {
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
-	}
+
+		ExecutedQuantity: executed,
+		ExecutedCost:     cost,
+	}.Executed()
 }
 
 func (b *Binance) Account() (model.Account, error) {
 	acc, err := b.client.NewGetAccountService().Do(b.ctx)
 	if err != nil {
-		return model.Account{}, err
+		return model.Account{}, maintenanceError(err)
 	}
 
 	balances := make([]model.Balance, 0)
@@ -466,6 +697,46 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 	ccandle := make(chan model.Candle)
 	cerr := make(chan error)
 	ha := model.NewHeikinAshi()
+	sanitizer := newCandleSanitizer(b.candles)
+
+	var gap *candleGap
+	if b.gapFill {
+		var err error
+		gap, err = newCandleGap(b.klinesByPeriod, period)
+		if err != nil {
+			log.Errorf("binance: gap fill disabled for %s: %v", pair, err)
+		}
+	}
+
+	process := func(candle model.Candle) {
+		if gap != nil && !gap.accept(candle) {
+			return
+		}
+
+		candle, ok, err := sanitizer.apply(candle)
+		if err != nil {
+			cerr <- err
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		if candle.Complete && b.HeikinAshi {
+			candle = candle.ToHeikinAshi(ha)
+		}
+
+		if candle.Complete {
+			// fetch aditional data if needed
+			for _, fetcher := range b.MetadataFetchers {
+				key, value := fetcher(pair, candle.Time)
+				candle.Metadata[key] = value
+			}
+		}
+
+		ccandle <- candle
+	}
 
 	go func() {
 		ba := &backoff.Backoff{
@@ -476,22 +747,7 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 		for {
 			done, _, err := binance.WsKlineServe(pair, period, func(event *binance.WsKlineEvent) {
 				ba.Reset()
-				candle := CandleFromWsKline(pair, event.Kline)
-
-				if candle.Complete && b.HeikinAshi {
-					candle = candle.ToHeikinAshi(ha)
-				}
-
-				if candle.Complete {
-					// fetch aditional data if needed
-					for _, fetcher := range b.MetadataFetchers {
-						key, value := fetcher(pair, candle.Time)
-						candle.Metadata[key] = value
-					}
-				}
-
-				ccandle <- candle
-
+				process(CandleFromWsKline(pair, event.Kline))
 			}, func(err error) {
 				cerr <- err
 			})
@@ -510,6 +766,17 @@ func (b *Binance) CandlesSubscription(ctx context.Context, pair, period string)
 			case <-done:
 				time.Sleep(ba.Duration())
 			}
+
+			// emit the candles missed while disconnected before resuming the stream
+			if gap != nil {
+				candles, err := gap.missing(ctx, pair, period, time.Now())
+				if err != nil {
+					cerr <- err
+				}
+				for _, candle := range candles {
+					process(candle)
+				}
+			}
 		}
 	}()
 
@@ -521,6 +788,10 @@ func (b *Binance) CandlesByLimit(ctx context.Context, pair, period string, limit
 	klineService := b.client.NewKlinesService()
 	ha := model.NewHeikinAshi()
 
+	if err := b.limiter.wait(ctx, weightKlines); err != nil {
+		return nil, err
+	}
+
 	data, err := klineService.Symbol(pair).
 		Interval(period).
 		Limit(limit + 1).
@@ -530,8 +801,21 @@ func (b *Binance) CandlesByLimit(ctx context.Context, pair, period string, limit
 		return nil, err
 	}
 
+	// discard last candle, because it is incomplete
+	if len(data) > 0 {
+		data = data[:len(data)-1]
+	}
+
+	sanitizer := newCandleSanitizer(b.candles)
 	for _, d := range data {
-		candle := CandleFromKline(pair, *d)
+		candle, ok, err := sanitizer.apply(CandleFromKline(pair, *d))
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
 
 		if b.HeikinAshi {
 			candle = candle.ToHeikinAshi(ha)
@@ -540,29 +824,29 @@ func (b *Binance) CandlesByLimit(ctx context.Context, pair, period string, limit
 		candles = append(candles, candle)
 	}
 
-	// discard last candle, because it is incomplete
-	return candles[:len(candles)-1], nil
+	return candles, nil
 }
 
 func (b *Binance) CandlesByPeriod(ctx context.Context, pair, period string,
 	start, end time.Time) ([]model.Candle, error) {
 
-	candles := make([]model.Candle, 0)
-	klineService := b.client.NewKlinesService()
-	ha := model.NewHeikinAshi()
-
-	data, err := klineService.Symbol(pair).
-		Interval(period).
-		StartTime(start.UnixNano() / int64(time.Millisecond)).
-		EndTime(end.UnixNano() / int64(time.Millisecond)).
-		Do(ctx)
-
+	data, err := b.klinesByPeriod(ctx, pair, period, start, end)
 	if err != nil {
 		return nil, err
 	}
 
+	candles := make([]model.Candle, 0, len(data))
+	ha := model.NewHeikinAshi()
+	sanitizer := newCandleSanitizer(b.candles)
 	for _, d := range data {
-		candle := CandleFromKline(pair, *d)
+		candle, ok, err := sanitizer.apply(d)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
 
 		if b.HeikinAshi {
 			candle = candle.ToHeikinAshi(ha)
@@ -574,6 +858,71 @@ func (b *Binance) CandlesByPeriod(ctx context.Context, pair, period string,
 	return candles, nil
 }
 
+// klinesByPeriod returns the candles of a period as received from the exchange
+func (b *Binance) klinesByPeriod(ctx context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	return paginateKlines(ctx, start, end, func(start time.Time) ([]model.Candle, error) {
+		if err := b.limiter.wait(ctx, weightKlines); err != nil {
+			return nil, err
+		}
+
+		data, err := b.client.NewKlinesService().Symbol(pair).
+			Interval(period).
+			StartTime(start.UnixNano() / int64(time.Millisecond)).
+			EndTime(end.UnixNano() / int64(time.Millisecond)).
+			Limit(klinesPageLimit).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		candles := make([]model.Candle, 0, len(data))
+		for _, d := range data {
+			candles = append(candles, CandleFromKline(pair, *d))
+		}
+		return candles, nil
+	})
+}
+
+// klinesPageLimit is the maximum number of candles in a response of the klines endpoint
+const klinesPageLimit = 1000
+
+// paginateKlines fetches the candles of a period in pages of klinesPageLimit candles, each page starts at
+// the last candle of the previous one, which is not repeated
+func paginateKlines(ctx context.Context, start, end time.Time,
+	fetch func(start time.Time) ([]model.Candle, error)) ([]model.Candle, error) {
+
+	var candles []model.Candle
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, err := fetch(start)
+		if err != nil {
+			return nil, fmt.Errorf("klines from %s: %w", start.Format(time.RFC3339), err)
+		}
+
+		for _, candle := range page {
+			if len(candles) == 0 || candle.Time.After(candles[len(candles)-1].Time) {
+				candles = append(candles, candle)
+			}
+		}
+
+		// a partial page is the end of the period, a page without new candles too
+		if len(page) < klinesPageLimit {
+			return candles, nil
+		}
+
+		last := page[len(page)-1].Time
+		if !last.After(start) || !last.Before(end) {
+			return candles, nil
+		}
+		start = last
+	}
+}
+
 func CandleFromKline(pair string, k binance.Kline) model.Candle {
 	t := time.Unix(0, k.OpenTime*int64(time.Millisecond))
 	candle := model.Candle{Pair: pair, Time: t, UpdatedAt: t}
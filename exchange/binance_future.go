@@ -35,8 +35,11 @@ type BinanceFuture struct {
 	ctx        context.Context
 	client     *futures.Client
 	assetsInfo map[string]model.AssetInfo
+	rounding   RoundingMode
+	candles    model.CandlePolicy
 	HeikinAshi bool
 	Testnet    bool
+	gapFill    bool
 
 	APIKey    string
 	APISecret string
@@ -54,6 +57,13 @@ func WithBinanceFuturesHeikinAshiCandle() BinanceFutureOption {
 	}
 }
 
+// WithBinanceFutureGapFill fills the candles missed while the websocket is disconnected, see WithBinanceGapFill
+func WithBinanceFutureGapFill() BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.gapFill = true
+	}
+}
+
 // WithBinanceFutureCredentials will set the credentials for Binance Futures
 func WithBinanceFutureCredentials(key, secret string) BinanceFutureOption {
 	return func(b *BinanceFuture) {
@@ -62,6 +72,31 @@ func WithBinanceFutureCredentials(key, secret string) BinanceFutureOption {
 	}
 }
 
+// WithBinanceFutureRounding sets how order quantities and prices are adjusted to the step size,
+// default is RoundingFloor
+func WithBinanceFutureRounding(mode RoundingMode) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.rounding = mode
+	}
+}
+
+// WithBinanceFutureCandlePolicy sets how candles with zero, negative or NaN prices are handled, default is
+// model.CandlePolicySkip. With model.CandlePolicyError, the invalid candles are discarded and the error
+// is sent to the subscription or returned by the candle requests.
+func WithBinanceFutureCandlePolicy(policy model.CandlePolicy) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.candles = policy
+	}
+}
+
+// WithBinanceFutureMetadataFetcher will execute a function after receive a new candle and include additional
+// information to candle's metadata, e.g. the funding rates of FundingRates.Fetcher
+func WithBinanceFutureMetadataFetcher(fetcher MetadataFetchers) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.MetadataFetchers = append(b.MetadataFetchers, fetcher)
+	}
+}
+
 // WithBinanceFutureLeverage will set the leverage for a pair
 func WithBinanceFutureLeverage(pair string, leverage int, marginType MarginType) BinanceFutureOption {
 	return func(b *BinanceFuture) {
@@ -124,6 +159,10 @@ func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*Bin
 					tradeLimits.StepSize, _ = strconv.ParseFloat(filter["stepSize"].(string), 64)
 				}
 
+				if typ == string(binance.SymbolFilterTypeMinNotional) {
+					tradeLimits.MinNotional, _ = strconv.ParseFloat(filter["notional"].(string), 64)
+				}
+
 				if typ == string(binance.SymbolFilterTypePriceFilter) {
 					tradeLimits.MinPrice, _ = strconv.ParseFloat(filter["minPrice"].(string), 64)
 					tradeLimits.MaxPrice, _ = strconv.ParseFloat(filter["maxPrice"].(string), 64)
@@ -147,6 +186,30 @@ func (b *BinanceFuture) LastQuote(ctx context.Context, pair string) (float64, er
 	return candles[0].Close, nil
 }
 
+// BestBidAsk returns the best bid and ask prices of the order book of a pair
+func (b *BinanceFuture) BestBidAsk(ctx context.Context, pair string) (bid, ask float64, err error) {
+	tickers, err := b.client.NewListBookTickersService().Symbol(pair).Do(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(tickers) == 0 {
+		return 0, 0, fmt.Errorf("%w: %s", ErrInvalidAsset, pair)
+	}
+
+	bid, err = strconv.ParseFloat(tickers[0].BidPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ask, err = strconv.ParseFloat(tickers[0].AskPrice, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return bid, ask, nil
+}
+
 func (b *BinanceFuture) AssetsInfo(pair string) model.AssetInfo {
 	return b.assetsInfo[pair]
 }
@@ -173,7 +236,8 @@ func (b *BinanceFuture) CreateOrderOCO(_ model.SideType, _ string,
 	panic("not implemented")
 }
 
-func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+func (b *BinanceFuture) CreateOrderStop(side model.SideType, pair string, quantity float64,
+	limit float64) (model.Order, error) {
 	err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
@@ -182,7 +246,7 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 	order, err := b.client.NewCreateOrderService().Symbol(pair).
 		Type(futures.OrderTypeStopMarket).
 		TimeInForce(futures.TimeInForceTypeGTC).
-		Side(futures.SideTypeSell).
+		Side(futures.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
 		Price(b.formatPrice(pair, limit)).
 		Do(b.ctx)
@@ -208,20 +272,28 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 
 func (b *BinanceFuture) formatPrice(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
+		value = roundToStep(value, info.TickSize, info.QuotePrecision, b.rounding)
 	}
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *BinanceFuture) formatQuantity(pair string, value float64) string {
 	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, value)
+		value = roundToStep(value, info.StepSize, info.BaseAssetPrecision, b.rounding)
 	}
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 	quantity float64, limit float64) (model.Order, error) {
+	return b.CreateOrderLimitTIF(side, pair, quantity, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF creates a limit order with the given time in force. For IOC and FOK orders,
+// the returned quantity is the executed quantity, and an order expired after a partial fill is returned
+// filled, see `model.Order.Executed`.
+func (b *BinanceFuture) CreateOrderLimitTIF(side model.SideType, pair string,
+	quantity float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
 
 	err := b.validate(pair, quantity)
 	if err != nil {
@@ -231,7 +303,7 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 	order, err := b.client.NewCreateOrderService().
 		Symbol(pair).
 		Type(futures.OrderTypeLimit).
-		TimeInForce(futures.TimeInForceTypeGTC).
+		TimeInForce(futures.TimeInForceType(tif)).
 		Side(futures.SideType(side)).
 		Quantity(b.formatQuantity(pair, quantity)).
 		Price(b.formatPrice(pair, limit)).
@@ -245,11 +317,22 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, err
 	}
 
-	quantity, err = strconv.ParseFloat(order.OrigQuantity, 64)
+	executedQuantity := order.OrigQuantity
+	if tif != model.TimeInForceGTC {
+		executedQuantity = order.ExecutedQuantity
+	}
+
+	quantity, err = strconv.ParseFloat(executedQuantity, 64)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	cost, _ := strconv.ParseFloat(order.CumQuote, 64)
+	if cost > 0 && executed > 0 {
+		price = cost / executed
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.UpdateTime*int64(time.Millisecond)),
@@ -260,7 +343,10 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
-	}, nil
+
+		ExecutedQuantity: executed,
+		ExecutedCost:     cost,
+	}.Executed(), nil
 }
 
 func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quantity float64) (model.Order, error) {
@@ -303,6 +389,33 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 	}, nil
 }
 
+// CreateOrderMarketTagged creates a market order annotated with a tag, the tag is not sent to the exchange
+func (b *BinanceFuture) CreateOrderMarketTagged(side model.SideType, pair string, quantity float64,
+	tag string) (model.Order, error) {
+	order, err := b.CreateOrderMarket(side, pair, quantity)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order.Tag = tag
+	return order, nil
+}
+
+// BuyPercent creates a market order to buy with a fraction of the free quote balance, see PercentQuantity
+func (b *BinanceFuture) BuyPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(b, model.SideTypeBuy, pair, percent)
+}
+
+// SellPercent creates a market order to sell a fraction of the free asset balance, see PercentQuantity
+func (b *BinanceFuture) SellPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(b, model.SideTypeSell, pair, percent)
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order, see ClosePosition
+func (b *BinanceFuture) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	return ClosePosition(b, pair, fraction)
+}
+
 func (b *BinanceFuture) CreateOrderMarketQuote(_ model.SideType, _ string, _ float64) (model.Order, error) {
 	panic("not implemented")
 }
@@ -351,7 +464,8 @@ func newFutureOrder(order *futures.Order) model.Order {
 		err   error
 	)
 	cost, _ := strconv.ParseFloat(order.CumQuote, 64)
-	quantity, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	quantity := executed
 	if cost > 0 && quantity > 0 {
 		price = cost / quantity
 	} else {
@@ -371,7 +485,10 @@ func newFutureOrder(order *futures.Order) model.Order {
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
-	}
+
+		ExecutedQuantity: executed,
+		ExecutedCost:     cost,
+	}.Executed()
 }
 
 func (b *BinanceFuture) Account() (model.Account, error) {
@@ -446,6 +563,46 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 	ccandle := make(chan model.Candle)
 	cerr := make(chan error)
 	ha := model.NewHeikinAshi()
+	sanitizer := newCandleSanitizer(b.candles)
+
+	var gap *candleGap
+	if b.gapFill {
+		var err error
+		gap, err = newCandleGap(b.klinesByPeriod, period)
+		if err != nil {
+			log.Errorf("binance: gap fill disabled for %s: %v", pair, err)
+		}
+	}
+
+	process := func(candle model.Candle) {
+		if gap != nil && !gap.accept(candle) {
+			return
+		}
+
+		candle, ok, err := sanitizer.apply(candle)
+		if err != nil {
+			cerr <- err
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		if candle.Complete && b.HeikinAshi {
+			candle = candle.ToHeikinAshi(ha)
+		}
+
+		if candle.Complete {
+			// fetch aditional data if needed
+			for _, fetcher := range b.MetadataFetchers {
+				key, value := fetcher(pair, candle.Time)
+				candle.Metadata[key] = value
+			}
+		}
+
+		ccandle <- candle
+	}
 
 	go func() {
 		ba := &backoff.Backoff{
@@ -456,36 +613,77 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 		for {
 			done, _, err := futures.WsKlineServe(pair, period, func(event *futures.WsKlineEvent) {
 				ba.Reset()
-				candle := FutureCandleFromWsKline(pair, event.Kline)
+				process(FutureCandleFromWsKline(pair, event.Kline))
+			}, func(err error) {
+				cerr <- err
+			})
+			if err != nil {
+				cerr <- err
+				close(cerr)
+				close(ccandle)
+				return
+			}
 
-				if candle.Complete && b.HeikinAshi {
-					candle = candle.ToHeikinAshi(ha)
-				}
+			select {
+			case <-ctx.Done():
+				close(cerr)
+				close(ccandle)
+				return
+			case <-done:
+				time.Sleep(ba.Duration())
+			}
 
-				if candle.Complete {
-					// fetch aditional data if needed
-					for _, fetcher := range b.MetadataFetchers {
-						key, value := fetcher(pair, candle.Time)
-						candle.Metadata[key] = value
-					}
+			// emit the candles missed while disconnected before resuming the stream
+			if gap != nil {
+				candles, err := gap.missing(ctx, pair, period, time.Now())
+				if err != nil {
+					cerr <- err
+				}
+				for _, candle := range candles {
+					process(candle)
 				}
+			}
+		}
+	}()
 
-				ccandle <- candle
+	return ccandle, cerr
+}
 
+// MarkPriceSubscription streams the mark price of a contract from the mark price stream of Binance Futures,
+// updated every second. The stream is reconnected until the context is canceled.
+func (b *BinanceFuture) MarkPriceSubscription(ctx context.Context, pair string) (chan float64, chan error) {
+	cprice := make(chan float64)
+	cerr := make(chan error)
+
+	go func() {
+		ba := &backoff.Backoff{
+			Min: 100 * time.Millisecond,
+			Max: 1 * time.Second,
+		}
+
+		for {
+			done, _, err := futures.WsMarkPriceServe(pair, func(event *futures.WsMarkPriceEvent) {
+				ba.Reset()
+				price, err := strconv.ParseFloat(event.MarkPrice, 64)
+				if err != nil {
+					cerr <- err
+					return
+				}
+				cprice <- price
 			}, func(err error) {
 				cerr <- err
 			})
 			if err != nil {
 				cerr <- err
 				close(cerr)
-				close(ccandle)
+				close(cprice)
 				return
 			}
 
 			select {
 			case <-ctx.Done():
 				close(cerr)
-				close(ccandle)
+				close(cprice)
 				return
 			case <-done:
 				time.Sleep(ba.Duration())
@@ -493,7 +691,7 @@ func (b *BinanceFuture) CandlesSubscription(ctx context.Context, pair, period st
 		}
 	}()
 
-	return ccandle, cerr
+	return cprice, cerr
 }
 
 func (b *BinanceFuture) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
@@ -510,8 +708,21 @@ func (b *BinanceFuture) CandlesByLimit(ctx context.Context, pair, period string,
 		return nil, err
 	}
 
+	// discard last candle, because it is incomplete
+	if len(data) > 0 {
+		data = data[:len(data)-1]
+	}
+
+	sanitizer := newCandleSanitizer(b.candles)
 	for _, d := range data {
-		candle := FutureCandleFromKline(pair, *d)
+		candle, ok, err := sanitizer.apply(FutureCandleFromKline(pair, *d))
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
 
 		if b.HeikinAshi {
 			candle = candle.ToHeikinAshi(ha)
@@ -520,29 +731,29 @@ func (b *BinanceFuture) CandlesByLimit(ctx context.Context, pair, period string,
 		candles = append(candles, candle)
 	}
 
-	// discard last candle, because it is incomplete
-	return candles[:len(candles)-1], nil
+	return candles, nil
 }
 
 func (b *BinanceFuture) CandlesByPeriod(ctx context.Context, pair, period string,
 	start, end time.Time) ([]model.Candle, error) {
 
-	candles := make([]model.Candle, 0)
-	klineService := b.client.NewKlinesService()
-	ha := model.NewHeikinAshi()
-
-	data, err := klineService.Symbol(pair).
-		Interval(period).
-		StartTime(start.UnixNano() / int64(time.Millisecond)).
-		EndTime(end.UnixNano() / int64(time.Millisecond)).
-		Do(ctx)
-
+	data, err := b.klinesByPeriod(ctx, pair, period, start, end)
 	if err != nil {
 		return nil, err
 	}
 
+	candles := make([]model.Candle, 0, len(data))
+	ha := model.NewHeikinAshi()
+	sanitizer := newCandleSanitizer(b.candles)
 	for _, d := range data {
-		candle := FutureCandleFromKline(pair, *d)
+		candle, ok, err := sanitizer.apply(d)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
 
 		if b.HeikinAshi {
 			candle = candle.ToHeikinAshi(ha)
@@ -554,6 +765,29 @@ func (b *BinanceFuture) CandlesByPeriod(ctx context.Context, pair, period string
 	return candles, nil
 }
 
+// klinesByPeriod returns the candles of a period as received from the exchange
+func (b *BinanceFuture) klinesByPeriod(ctx context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	return paginateKlines(ctx, start, end, func(start time.Time) ([]model.Candle, error) {
+		data, err := b.client.NewKlinesService().Symbol(pair).
+			Interval(period).
+			StartTime(start.UnixNano() / int64(time.Millisecond)).
+			EndTime(end.UnixNano() / int64(time.Millisecond)).
+			Limit(klinesPageLimit).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		candles := make([]model.Candle, 0, len(data))
+		for _, d := range data {
+			candles = append(candles, FutureCandleFromKline(pair, *d))
+		}
+		return candles, nil
+	})
+}
+
 func FutureCandleFromKline(pair string, k futures.Kline) model.Candle {
 	var err error
 	t := time.Unix(0, k.OpenTime*int64(time.Millisecond))
@@ -1,9 +1,17 @@
 package exchange
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/adshao/go-binance/v2"
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -51,3 +59,159 @@ func TestFormatQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestBinance_BestBidAsk(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v3/ticker/bookTicker", r.URL.Path)
+		if r.URL.Query().Get("symbol") != "BTCUSDT" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","bidPrice":"30000.10","bidQty":"1.5",` +
+			`"askPrice":"30000.20","askQty":"2.0"}`))
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+	exchange := Binance{client: client}
+
+	bid, ask, err := exchange.BestBidAsk(context.Background(), "BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 30000.10, bid)
+	require.Equal(t, 30000.20, ask)
+
+	_, _, err = exchange.BestBidAsk(context.Background(), "INVALID")
+	require.Error(t, err)
+}
+
+func TestBinance_LastQuote(t *testing.T) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/ticker/price":
+			_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","price":"30010.50"}`))
+		case "/api/v3/klines":
+			require.Equal(t, "1m", r.URL.Query().Get("interval"))
+			// the last candle is in formation
+			_, _ = w.Write([]byte(fmt.Sprintf(`[[%d,"29990","30020","29980","30000","10",%d,"0",1,"0","0","0"],`+
+				`[%d,"30000","30020","29990","30010","5",%d,"0",1,"0","0","0"]]`,
+				now-90000, now-30001, now-30000, now+29999)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+
+	t.Run("last trade", func(t *testing.T) {
+		exchange := Binance{client: client}
+		quote, err := exchange.LastQuote(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 30010.50, quote)
+	})
+
+	t.Run("last complete candle", func(t *testing.T) {
+		exchange := Binance{client: client, quote: QuoteLastCandle}
+		quote, err := exchange.LastQuote(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 30000.0, quote)
+	})
+}
+
+func TestBinance_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the proxy receives the request to the API host
+		require.Equal(t, "api.binance.invalid", r.Host)
+		require.Equal(t, "/api/v3/ticker/price", r.URL.Path)
+		_, _ = w.Write([]byte(`{"symbol":"BTCUSDT","price":"30010.50"}`))
+	}))
+	defer proxy.Close()
+
+	httpClient, err := proxyClient(proxy.URL)
+	require.NoError(t, err)
+
+	client := binance.NewClient("", "")
+	client.BaseURL = "http://api.binance.invalid"
+	client.HTTPClient = httpClient
+	exchange := Binance{client: client}
+
+	quote, err := exchange.LastQuote(context.Background(), "BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 30010.50, quote)
+
+	_, err = proxyClient("127.0.0.1:1087")
+	require.Error(t, err)
+}
+
+func TestBinance_CandlesByPeriod(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2499 * time.Minute)
+	const minute = int64(time.Minute / time.Millisecond)
+
+	var mtx sync.Mutex
+	var pages []int64
+	failAt := -1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v3/klines", r.URL.Path)
+		query := r.URL.Query()
+		from, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+		to, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		mtx.Lock()
+		pages = append(pages, from)
+		fail := len(pages)-1 == failAt
+		mtx.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"code":-1000,"msg":"An unknown error occurred."}`))
+			return
+		}
+
+		// the fake exchange returns the candles of the period, capped by the limit
+		var klines []string
+		for openTime := from; openTime <= to && len(klines) < limit; openTime += minute {
+			klines = append(klines, fmt.Sprintf(`[%d,"1","1","1","1","1",%d,"0",1,"0","0","0"]`,
+				openTime, openTime+minute-1))
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(klines, ",") + "]"))
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+	exchange := Binance{client: client}
+
+	t.Run("pages", func(t *testing.T) {
+		candles, err := exchange.CandlesByPeriod(context.Background(), "BTCUSDT", "1m", start, end)
+		require.NoError(t, err)
+		require.Len(t, candles, 2500)
+		for i, candle := range candles {
+			require.True(t, candle.Time.Equal(start.Add(time.Duration(i)*time.Minute)), "candle %d", i)
+		}
+
+		// each page starts at the last candle of the previous one
+		first := start.UnixNano() / int64(time.Millisecond)
+		require.Equal(t, []int64{first, first + 999*minute, first + 1998*minute}, pages)
+	})
+
+	t.Run("error in a page", func(t *testing.T) {
+		pages, failAt = nil, 1
+		_, err := exchange.CandlesByPeriod(context.Background(), "BTCUSDT", "1m", start, end)
+		require.Error(t, err)
+		require.Len(t, pages, 2)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		pages, failAt = nil, -1
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := exchange.CandlesByPeriod(ctx, "BTCUSDT", "1m", start, end)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Empty(t, pages)
+	})
+}
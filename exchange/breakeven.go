@@ -0,0 +1,18 @@
+package exchange
+
+// BreakEvenPrice returns the exit price of a position that pays the fees of the entry and the exit, with the
+// given fee rate of each side (e.g. 0.001 for 0.1%). The quantity is negative for short positions. It returns
+// ErrNoPosition without position.
+func BreakEvenPrice(entry, quantity, feeRate float64) (float64, error) {
+	if quantity == 0 || entry <= 0 {
+		return 0, ErrNoPosition
+	}
+
+	if quantity > 0 {
+		// entry cost: entry*(1+fee), exit proceeds: exit*(1-fee)
+		return entry * (1 + feeRate) / (1 - feeRate), nil
+	}
+
+	// entry proceeds: entry*(1-fee), exit cost: exit*(1+fee)
+	return entry * (1 - feeRate) / (1 + feeRate), nil
+}
@@ -0,0 +1,57 @@
+package exchange
+
+import (
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// candleSanitizer applies a candle policy to the sequence of candles of a pair,
+// keeping the last valid candle to fill the next invalid ones
+type candleSanitizer struct {
+	policy   model.CandlePolicy
+	previous *model.Candle
+}
+
+func newCandleSanitizer(policy model.CandlePolicy) *candleSanitizer {
+	return &candleSanitizer{policy: policy}
+}
+
+// apply returns the candle to be used and false if it must be discarded, invalid candles are logged
+func (s *candleSanitizer) apply(candle model.Candle) (model.Candle, bool, error) {
+	result, ok, err := s.policy.Apply(candle, s.previous)
+	if !candle.Valid() {
+		action := "skipped"
+		if err != nil {
+			action = "rejected"
+		} else if ok {
+			action = "filled"
+		}
+
+		log.Warnf("[CANDLE] %s: invalid candle at %s %s: open=%f close=%f low=%f high=%f volume=%f",
+			candle.Pair, candle.Time, action, candle.Open, candle.Close, candle.Low, candle.High, candle.Volume)
+	}
+
+	if ok {
+		s.previous = &result
+	}
+
+	return result, ok, err
+}
+
+// sanitizeCandles applies the candle policy to a list of candles of the same pair
+func sanitizeCandles(policy model.CandlePolicy, candles []model.Candle) ([]model.Candle, error) {
+	sanitizer := newCandleSanitizer(policy)
+	result := make([]model.Candle, 0, len(candles))
+	for _, candle := range candles {
+		candle, ok, err := sanitizer.apply(candle)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			result = append(result, candle)
+		}
+	}
+
+	return result, nil
+}
@@ -8,23 +8,104 @@ import (
 	"math"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/samber/lo"
 	"github.com/xhit/go-str2duration/v2"
 
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
 )
 
-var ErrInsufficientData = errors.New("insufficient data")
+var (
+	ErrInsufficientData      = errors.New("insufficient data")
+	ErrIncompatibleTimeframe = errors.New("incompatible timeframe")
+)
+
+// MetadataAggregator combines the metadata value of a partial candle with the value of the next candle
+// of the same period on resample, count is the number of candles already merged in the partial candle
+type MetadataAggregator func(partial, value float64, count int) float64
+
+var (
+	// MetadataLast keeps the value of the last candle of the period, it is the default aggregator
+	MetadataLast MetadataAggregator = func(_, value float64, _ int) float64 {
+		return value
+	}
+	// MetadataSum adds the values of the period, e.g. for cumulative metrics based on volume
+	MetadataSum MetadataAggregator = func(partial, value float64, _ int) float64 {
+		return partial + value
+	}
+	// MetadataMean averages the values of the period
+	MetadataMean MetadataAggregator = func(partial, value float64, count int) float64 {
+		return partial + (value-partial)/float64(count+1)
+	}
+)
+
+var (
+	metadataAggregatorsMtx sync.RWMutex
+	metadataAggregators    = make(map[string]MetadataAggregator)
+)
+
+// SetMetadataAggregator registers how a candle metadata key is aggregated when candles are resampled
+// to a higher timeframe. Keys without an aggregator keep the value of the last candle (MetadataLast).
+func SetMetadataAggregator(key string, aggregator MetadataAggregator) {
+	metadataAggregatorsMtx.Lock()
+	defer metadataAggregatorsMtx.Unlock()
+	metadataAggregators[key] = aggregator
+}
+
+func metadataAggregator(key string) MetadataAggregator {
+	metadataAggregatorsMtx.RLock()
+	defer metadataAggregatorsMtx.RUnlock()
+	if aggregator, ok := metadataAggregators[key]; ok {
+		return aggregator
+	}
+	return MetadataLast
+}
 
 type PairFeed struct {
 	Pair       string
 	File       string
 	Timeframe  string
 	HeikinAshi bool
+
+	// CandlePolicy defines how rows with zero, negative or NaN prices are handled, default is to skip them
+	CandlePolicy model.CandlePolicy
+
+	// Timezone aligns the periods of the resampled candles, e.g. daily candles start at the midnight
+	// of the timezone. Candle times remain in UTC, default is UTC.
+	Timezone *time.Location
+
+	// MetadataFetchers include additional information in the metadata of each candle of the file,
+	// e.g. the funding rates of FundingRates.Fetcher
+	MetadataFetchers []MetadataFetchers
+
+	// LastCandle defines if the last candle of the file is complete, e.g. files exported "up to now"
+	// include the period still forming. Incomplete candles are discarded. Default is LastCandleComplete.
+	LastCandle LastCandleMode
+
+	// CheckSpacing logs a warning for each interval between candles different from the timeframe,
+	// e.g. gaps of missing candles or candles of another timeframe
+	CheckSpacing bool
+
+	// NoResample requires the timeframe of the file to match the target timeframe of the feed, instead of
+	// resampling its candles, e.g. to make sure a backtest runs on the candles of the file as they are
+	NoResample bool
 }
 
+// LastCandleMode defines how the completeness of the last candle of a CSV file is detected
+type LastCandleMode int
+
+const (
+	// LastCandleComplete considers all candles of the file complete
+	LastCandleComplete LastCandleMode = iota
+	// LastCandlePartial always considers the last candle of the file incomplete
+	LastCandlePartial
+	// LastCandleByTime considers the last candle incomplete if its period was not closed when the file is loaded
+	LastCandleByTime
+)
+
 type CSVFeed struct {
 	Feeds               map[string]PairFeed
 	CandlePairTimeFrame map[string][]model.Candle
@@ -72,6 +153,10 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 	}
 
 	for _, feed := range feeds {
+		if err := feed.validateTimeframe(targetTimeframe); err != nil {
+			return nil, err
+		}
+
 		csvFeed.Feeds[feed.Pair] = feed
 
 		csvFile, err := os.Open(feed.File)
@@ -86,6 +171,7 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 
 		var candles []model.Candle
 		ha := model.NewHeikinAshi()
+		sanitizer := newCandleSanitizer(feed.CandlePolicy)
 
 		// map each header label with its index
 		headerMap, additionalHeaders, hasCustomHeaders := parseHeaders(csvLines[0])
@@ -141,16 +227,29 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 				}
 			}
 
+			candle, ok, err := sanitizer.apply(candle)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", feed.File, err)
+			}
+
+			if !ok {
+				continue
+			}
+
 			if feed.HeikinAshi {
 				candle = candle.ToHeikinAshi(ha)
 			}
 
-			candles = append(candles, candle)
+			candles = append(candles, fetchMetadata(candle, feed.MetadataFetchers))
+		}
+
+		if err := feed.checkCandles(candles, time.Now()); err != nil {
+			return nil, err
 		}
 
 		csvFeed.CandlePairTimeFrame[csvFeed.feedTimeframeKey(feed.Pair, feed.Timeframe)] = candles
 
-		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe)
+		err = csvFeed.resample(feed.Pair, feed.Timeframe, targetTimeframe, feed.Timezone)
 		if err != nil {
 			return nil, err
 		}
@@ -159,6 +258,80 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 	return csvFeed, nil
 }
 
+// validateTimeframe checks that the candles of the file can feed the target timeframe: the same timeframe or,
+// with resampling, a multiple of the timeframe of the file, e.g. a 1h file for a 4h strategy, but not for 15m
+func (f PairFeed) validateTimeframe(targetTimeframe string) error {
+	if f.Timeframe == targetTimeframe {
+		return nil
+	}
+
+	if f.NoResample {
+		return fmt.Errorf("%w: %s: file timeframe %s does not match %s and resampling is disabled",
+			ErrIncompatibleTimeframe, f.Pair, f.Timeframe, targetTimeframe)
+	}
+
+	source, err := str2duration.ParseDuration(f.Timeframe)
+	if err != nil || source <= 0 {
+		return fmt.Errorf("%w: %s: invalid file timeframe %s", ErrIncompatibleTimeframe, f.Pair, f.Timeframe)
+	}
+
+	target, err := str2duration.ParseDuration(targetTimeframe)
+	if err != nil || target <= 0 {
+		return fmt.Errorf("%w: %s: invalid timeframe %s", ErrIncompatibleTimeframe, f.Pair, targetTimeframe)
+	}
+
+	if target < source || target%source != 0 {
+		return fmt.Errorf("%w: %s: file timeframe %s can not be resampled to %s, it must be a multiple of %s",
+			ErrIncompatibleTimeframe, f.Pair, f.Timeframe, targetTimeframe, f.Timeframe)
+	}
+
+	return nil
+}
+
+// checkCandles marks the last candle as incomplete according to the LastCandle mode and logs irregular
+// intervals between candles, if CheckSpacing is enabled
+func (f PairFeed) checkCandles(candles []model.Candle, now time.Time) error {
+	if len(candles) == 0 || (f.LastCandle == LastCandleComplete && !f.CheckSpacing) {
+		return nil
+	}
+
+	duration, err := str2duration.ParseDuration(f.Timeframe)
+	if err != nil {
+		return err
+	}
+
+	last := &candles[len(candles)-1]
+	switch f.LastCandle {
+	case LastCandlePartial:
+		last.Complete = false
+	case LastCandleByTime:
+		last.Complete = !last.Time.Add(duration).After(now)
+	}
+
+	if !last.Complete {
+		log.Warnf("[CSV] %s: last candle at %s is incomplete, it will be discarded", f.Pair, last.Time)
+	}
+
+	if f.CheckSpacing {
+		for _, t := range irregularIntervals(candles, duration) {
+			log.Warnf("[CSV] %s: irregular interval before the candle at %s, expected %s", f.Pair, t, f.Timeframe)
+		}
+	}
+
+	return nil
+}
+
+// irregularIntervals returns the time of the candles that do not start one period after the previous candle
+func irregularIntervals(candles []model.Candle, duration time.Duration) []time.Time {
+	var irregular []time.Time
+	for i := 1; i < len(candles); i++ {
+		if candles[i].Time.Sub(candles[i-1].Time) != duration {
+			irregular = append(irregular, candles[i].Time)
+		}
+	}
+	return irregular
+}
+
 func (c CSVFeed) feedTimeframeKey(pair, timeframe string) string {
 	return fmt.Sprintf("%s--%s", pair, timeframe)
 }
@@ -177,18 +350,20 @@ func (c *CSVFeed) Limit(duration time.Duration) *CSVFeed {
 	return c
 }
 
-func isFistCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (bool, error) {
+func isFistCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string, loc *time.Location) (bool, error) {
 	fromDuration, err := str2duration.ParseDuration(fromTimeframe)
 	if err != nil {
 		return false, err
 	}
 
-	prev := t.Add(-fromDuration).UTC()
+	prev := t.Add(-fromDuration)
 
-	return isLastCandlePeriod(prev, fromTimeframe, targetTimeframe)
+	return isLastCandlePeriod(prev, fromTimeframe, targetTimeframe, loc)
 }
 
-func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (bool, error) {
+// isLastCandlePeriod returns true if the candle of the source timeframe closes a period of the target
+// timeframe, the periods are aligned with the clock of the location, nil is UTC
+func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string, loc *time.Location) (bool, error) {
 	if fromTimeframe == targetTimeframe {
 		return true, nil
 	}
@@ -198,7 +373,10 @@ func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (boo
 		return false, err
 	}
 
-	next := t.Add(fromDuration).UTC()
+	if loc == nil {
+		loc = time.UTC
+	}
+	next := t.Add(fromDuration).In(loc)
 
 	switch targetTimeframe {
 	case "1m":
@@ -225,29 +403,55 @@ func isLastCandlePeriod(t time.Time, fromTimeframe, targetTimeframe string) (boo
 		return next.Minute() == 0 && next.Hour()%24 == 0 && next.Weekday() == time.Sunday, nil
 	}
 
-	return false, fmt.Errorf("invalid timeframe: %s", targetTimeframe)
+	// custom intervals (e.g. 3h, 6h) are supported when they split a day in equal periods
+	targetDuration, err := str2duration.ParseDuration(targetTimeframe)
+	if err != nil || targetDuration <= 0 || (24*time.Hour)%targetDuration != 0 {
+		return false, fmt.Errorf("invalid timeframe: %s", targetTimeframe)
+	}
+
+	// time since the midnight of the location, Truncate would align the periods with UTC
+	hour, minute, second := next.Clock()
+	elapsed := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute +
+		time.Duration(second)*time.Second + time.Duration(next.Nanosecond())
+	return elapsed%targetDuration == 0, nil
 }
 
-func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error {
+func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string, loc *time.Location) error {
 	sourceKey := c.feedTimeframeKey(pair, sourceTimeframe)
 	targetKey := c.feedTimeframeKey(pair, targetTimeframe)
 
+	candles, err := resampleCandles(c.CandlePairTimeFrame[sourceKey], sourceTimeframe, targetTimeframe, loc)
+	if err != nil {
+		return err
+	}
+
+	c.CandlePairTimeFrame[targetKey] = candles
+
+	return nil
+}
+
+// resampleCandles aggregates candles from source timeframe to target timeframe, the result starts at
+// the first candle aligned with the target period in the location and keeps the partial candles of each period
+func resampleCandles(source []model.Candle, sourceTimeframe, targetTimeframe string,
+	loc *time.Location) ([]model.Candle, error) {
+
 	var i int
-	for ; i < len(c.CandlePairTimeFrame[sourceKey]); i++ {
-		if ok, err := isFistCandlePeriod(c.CandlePairTimeFrame[sourceKey][i].Time, sourceTimeframe,
-			targetTimeframe); err != nil {
-			return err
+	for ; i < len(source); i++ {
+		if ok, err := isFistCandlePeriod(source[i].Time, sourceTimeframe,
+			targetTimeframe, loc); err != nil {
+			return nil, err
 		} else if ok {
 			break
 		}
 	}
 
 	candles := make([]model.Candle, 0)
-	for ; i < len(c.CandlePairTimeFrame[sourceKey]); i++ {
-		candle := c.CandlePairTimeFrame[sourceKey][i]
-		if last, err := isLastCandlePeriod(candle.Time, sourceTimeframe, targetTimeframe); err != nil {
-			return err
-		} else if last {
+	merged := 0 // candles merged in the current period
+	for ; i < len(source); i++ {
+		candle := source[i]
+		if last, err := isLastCandlePeriod(candle.Time, sourceTimeframe, targetTimeframe, loc); err != nil {
+			return nil, err
+		} else if last && source[i].Complete {
 			candle.Complete = true
 		} else {
 			candle.Complete = false
@@ -255,23 +459,47 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 
 		lastIndex := len(candles) - 1
 		if lastIndex >= 0 && !candles[lastIndex].Complete {
-			candle.Time = candles[lastIndex].Time
-			candle.Open = candles[lastIndex].Open
-			candle.High = math.Max(candles[lastIndex].High, candle.High)
-			candle.Low = math.Min(candles[lastIndex].Low, candle.Low)
-			candle.Volume += candles[lastIndex].Volume
+			candle = mergeCandle(candles[lastIndex], candle, merged)
+			merged++
+		} else {
+			merged = 1
 		}
 		candles = append(candles, candle)
 	}
 
 	// remove last candle if not complete
-	if !candles[len(candles)-1].Complete {
+	if len(candles) > 0 && !candles[len(candles)-1].Complete {
 		candles = candles[:len(candles)-1]
 	}
 
-	c.CandlePairTimeFrame[targetKey] = candles
+	return candles, nil
+}
 
-	return nil
+// mergeCandle appends candle to the partial candle prev of the same period, count is the number of
+// candles already merged in prev
+func mergeCandle(prev, candle model.Candle, count int) model.Candle {
+	candle.Time = prev.Time
+	candle.Open = prev.Open
+	candle.High = math.Max(prev.High, candle.High)
+	candle.Low = math.Min(prev.Low, candle.Low)
+	candle.Volume += prev.Volume
+
+	if len(prev.Metadata) > 0 || len(candle.Metadata) > 0 {
+		// the source maps are not modified, they are shared with the candles of the original timeframe
+		metadata := make(map[string]float64, len(candle.Metadata))
+		for key, value := range prev.Metadata {
+			metadata[key] = value
+		}
+		for key, value := range candle.Metadata {
+			if partial, ok := prev.Metadata[key]; ok {
+				value = metadataAggregator(key)(partial, value, count)
+			}
+			metadata[key] = value
+		}
+		candle.Metadata = metadata
+	}
+
+	return candle
 }
 
 func (c CSVFeed) CandlesByPeriod(_ context.Context, pair, timeframe string,
@@ -3,11 +3,16 @@ package exchange
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
 )
 
 func TestNewCSVFeed(t *testing.T) {
@@ -49,6 +54,162 @@ func TestNewCSVFeed(t *testing.T) {
 	})
 }
 
+func TestNewCSVFeed_CandlePolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "btc.csv")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"1619395200,100,110,90,120,10\n"+
+			"1619481600,110,0,100,120,10\n"+
+			"1619568000,110,NaN,100,120,10\n"+
+			"1619654400,120,130,110,140,10\n",
+	), 0600))
+
+	pairFeed := func(policy model.CandlePolicy) PairFeed {
+		return PairFeed{Timeframe: "1d", Pair: "BTCUSDT", File: file, CandlePolicy: policy}
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		feed, err := NewCSVFeed("1d", pairFeed(model.CandlePolicySkip))
+		require.NoError(t, err)
+
+		candles := feed.CandlePairTimeFrame["BTCUSDT--1d"]
+		require.Len(t, candles, 2)
+		require.Equal(t, 110.0, candles[0].Close)
+		require.Equal(t, 130.0, candles[1].Close)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := NewCSVFeed("1d", pairFeed(model.CandlePolicyError))
+		require.ErrorIs(t, err, model.ErrInvalidCandle)
+	})
+
+	t.Run("forward fill", func(t *testing.T) {
+		feed, err := NewCSVFeed("1d", pairFeed(model.CandlePolicyFill))
+		require.NoError(t, err)
+
+		candles := feed.CandlePairTimeFrame["BTCUSDT--1d"]
+		require.Len(t, candles, 4)
+		require.Equal(t, 110.0, candles[1].Close)
+		require.Equal(t, 110.0, candles[2].Close)
+		require.Equal(t, 130.0, candles[3].Close)
+	})
+}
+
+func TestNewCSVFeed_LastCandle(t *testing.T) {
+	writeCandles := func(t *testing.T, start time.Time) string {
+		file := filepath.Join(t.TempDir(), "btc.csv")
+		content := ""
+		for i := 0; i < 3; i++ {
+			content += fmt.Sprintf("%d,100,110,90,120,10\n", start.Add(time.Duration(i)*time.Hour).Unix())
+		}
+		require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+		return file
+	}
+
+	old := writeCandles(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	// the last candle starts in the current hour, exported "up to now"
+	recent := writeCandles(t, time.Now().UTC().Truncate(time.Hour).Add(-2*time.Hour))
+
+	tt := []struct {
+		name     string
+		file     string
+		mode     LastCandleMode
+		expected int
+	}{
+		{"complete", recent, LastCandleComplete, 3},
+		{"trailing partial candle", old, LastCandlePartial, 2},
+		{"by time with closed period", old, LastCandleByTime, 3},
+		{"by time with forming period", recent, LastCandleByTime, 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			feed, err := NewCSVFeed("1h", PairFeed{Timeframe: "1h", Pair: "BTCUSDT", File: tc.file, LastCandle: tc.mode})
+			require.NoError(t, err)
+
+			candles := feed.CandlePairTimeFrame["BTCUSDT--1h"]
+			require.Len(t, candles, tc.expected)
+			for _, candle := range candles {
+				require.True(t, candle.Complete)
+			}
+		})
+	}
+
+	t.Run("resample", func(t *testing.T) {
+		complete := func(candles []model.Candle) int {
+			return len(lo.Filter(candles, func(candle model.Candle, _ int) bool {
+				return candle.Complete
+			}))
+		}
+
+		feed, err := NewCSVFeed("3h", PairFeed{Timeframe: "1h", Pair: "BTCUSDT", File: old})
+		require.NoError(t, err)
+		require.Equal(t, 1, complete(feed.CandlePairTimeFrame["BTCUSDT--3h"]))
+
+		// the period closed by the partial candle is not complete
+		feed, err = NewCSVFeed("3h", PairFeed{Timeframe: "1h", Pair: "BTCUSDT", File: old,
+			LastCandle: LastCandlePartial})
+		require.NoError(t, err)
+		require.Equal(t, 0, complete(feed.CandlePairTimeFrame["BTCUSDT--3h"]))
+	})
+}
+
+func TestPairFeed_validateTimeframe(t *testing.T) {
+	tt := []struct {
+		name       string
+		timeframe  string
+		target     string
+		noResample bool
+		valid      bool
+	}{
+		{name: "matching", timeframe: "1h", target: "1h", valid: true},
+		{name: "matching without resampling", timeframe: "1h", target: "1h", noResample: true, valid: true},
+		{name: "resamplable", timeframe: "1h", target: "4h", valid: true},
+		{name: "resamplable custom interval", timeframe: "15m", target: "45m", valid: true},
+		{name: "resamplable week", timeframe: "1d", target: "1w", valid: true},
+		{name: "resampling disabled", timeframe: "1h", target: "4h", noResample: true},
+		{name: "smaller target", timeframe: "1h", target: "15m"},
+		{name: "not a multiple", timeframe: "2h", target: "3h"},
+		{name: "invalid target", timeframe: "1h", target: "invalid"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			feed := PairFeed{Pair: "BTCUSDT", Timeframe: tc.timeframe, NoResample: tc.noResample}
+			err := feed.validateTimeframe(tc.target)
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrIncompatibleTimeframe)
+		})
+	}
+
+	t.Run("feed", func(t *testing.T) {
+		feed, err := NewCSVFeed("15m", PairFeed{
+			Timeframe: "1h",
+			Pair:      "BTCUSDT",
+			File:      "../testdata/btc-1h-2021-05-13.csv",
+		})
+		require.ErrorIs(t, err, ErrIncompatibleTimeframe)
+		require.Nil(t, feed)
+	})
+}
+
+func TestIrregularIntervals(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := []model.Candle{
+		{Time: start},
+		{Time: start.Add(time.Hour)},
+		{Time: start.Add(3 * time.Hour)},
+		{Time: start.Add(3*time.Hour + 30*time.Minute)},
+		{Time: start.Add(4*time.Hour + 30*time.Minute)},
+	}
+
+	require.Equal(t, []time.Time{start.Add(3 * time.Hour), start.Add(3*time.Hour + 30*time.Minute)},
+		irregularIntervals(candles, time.Hour))
+	require.Empty(t, irregularIntervals(candles[:2], time.Hour))
+}
+
 func TestCSVFeed_CandlesByLimit(t *testing.T) {
 	feed, err := NewCSVFeed("1d", PairFeed{
 		Timeframe: "1d",
@@ -129,6 +290,93 @@ func TestCSVFeed_resample(t *testing.T) {
 	})
 }
 
+func TestResampleCandles_Metadata(t *testing.T) {
+	SetMetadataAggregator("sum", MetadataSum)
+	SetMetadataAggregator("mean", MetadataMean)
+	SetMetadataAggregator("last", MetadataLast)
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := make([]model.Candle, 0)
+	for i := 0; i < 8; i++ {
+		value := float64(i + 1)
+		source = append(source, model.Candle{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Close:    value,
+			Complete: true,
+			Metadata: map[string]float64{"sum": value, "mean": value, "last": value, "default": value},
+		})
+	}
+
+	candles, err := resampleCandles(source, "1h", "4h", nil)
+	require.NoError(t, err)
+
+	var complete []model.Candle
+	for _, candle := range candles {
+		if candle.Complete {
+			complete = append(complete, candle)
+		}
+	}
+	require.Len(t, complete, 2)
+
+	tt := []struct {
+		key      string
+		expected []float64
+	}{
+		{key: "sum", expected: []float64{10, 26}},
+		{key: "mean", expected: []float64{2.5, 6.5}},
+		{key: "last", expected: []float64{4, 8}},
+		{key: "default", expected: []float64{4, 8}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.key, func(t *testing.T) {
+			for i, candle := range complete {
+				require.InDelta(t, tc.expected[i], candle.Metadata[tc.key], 1e-9)
+			}
+		})
+	}
+
+	// source candles are not modified
+	for i, candle := range source {
+		require.Equal(t, float64(i+1), candle.Metadata["sum"])
+	}
+}
+
+func TestResampleCandles_Timezone(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := make([]model.Candle, 0)
+	for i := 0; i < 48; i++ {
+		source = append(source, model.Candle{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Open:     float64(i + 1),
+			Close:    float64(i + 1),
+			Complete: true,
+		})
+	}
+
+	t.Run("utc", func(t *testing.T) {
+		candles, err := resampleCandles(source, "1h", "1d", nil)
+		require.NoError(t, err)
+		require.Len(t, candles, 48)
+		require.True(t, candles[47].Complete)
+		require.Equal(t, start.Add(24*time.Hour), candles[47].Time)
+		require.Equal(t, 25.0, candles[47].Open)
+	})
+
+	t.Run("session at midnight of UTC-5", func(t *testing.T) {
+		candles, err := resampleCandles(source, "1h", "1d", time.FixedZone("EST", -5*60*60))
+		require.NoError(t, err)
+
+		// unaligned candles are discarded, the session ends at 05:00 UTC
+		require.Len(t, candles, 42)
+		require.True(t, candles[23].Complete)
+		require.Equal(t, start.Add(5*time.Hour), candles[23].Time)
+		require.Equal(t, time.UTC, candles[23].Time.Location())
+		require.Equal(t, 6.0, candles[23].Open)
+		require.Equal(t, 29.0, candles[23].Close)
+	})
+}
+
 func TestIsLastCandlePeriod(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		tt := []struct {
@@ -159,7 +407,7 @@ func TestIsLastCandlePeriod(t *testing.T) {
 
 		for _, tc := range tt {
 			t.Run(fmt.Sprintf("%s to %s", tc.sourceTimeFrame, tc.targetTimeFrame), func(t *testing.T) {
-				last, err := isLastCandlePeriod(tc.time, tc.sourceTimeFrame, tc.targetTimeFrame)
+				last, err := isLastCandlePeriod(tc.time, tc.sourceTimeFrame, tc.targetTimeFrame, nil)
 				require.NoError(t, err)
 				require.Equal(t, tc.last, last)
 			})
@@ -167,13 +415,13 @@ func TestIsLastCandlePeriod(t *testing.T) {
 	})
 
 	t.Run("invalid source", func(t *testing.T) {
-		last, err := isLastCandlePeriod(time.Now(), "invalid", "1h")
+		last, err := isLastCandlePeriod(time.Now(), "invalid", "1h", nil)
 		require.Error(t, err)
 		require.False(t, last)
 	})
 
 	t.Run("not supported interval", func(t *testing.T) {
-		last, err := isLastCandlePeriod(time.Now(), "1d", "1y")
+		last, err := isLastCandlePeriod(time.Now(), "1d", "1y", nil)
 		require.EqualError(t, err, "invalid timeframe: 1y")
 		require.False(t, last)
 	})
@@ -196,7 +444,7 @@ func TestIsFistCandlePeriod(t *testing.T) {
 
 		for _, tc := range tt {
 			t.Run(fmt.Sprintf("%s to %s", tc.sourceTimeFrame, tc.targetTimeFrame), func(t *testing.T) {
-				first, err := isFistCandlePeriod(tc.time, tc.sourceTimeFrame, tc.targetTimeFrame)
+				first, err := isFistCandlePeriod(tc.time, tc.sourceTimeFrame, tc.targetTimeFrame, nil)
 				require.NoError(t, err)
 				require.Equal(t, tc.last, first)
 			})
@@ -204,13 +452,13 @@ func TestIsFistCandlePeriod(t *testing.T) {
 	})
 
 	t.Run("invalid source", func(t *testing.T) {
-		last, err := isFistCandlePeriod(time.Now(), "invalid", "1h")
+		last, err := isFistCandlePeriod(time.Now(), "invalid", "1h", nil)
 		require.Error(t, err)
 		require.False(t, last)
 	})
 
 	t.Run("not supported interval", func(t *testing.T) {
-		last, err := isFistCandlePeriod(time.Now(), "1d", "1y")
+		last, err := isFistCandlePeriod(time.Now(), "1d", "1y", nil)
 		require.EqualError(t, err, "invalid timeframe: 1y")
 		require.False(t, last)
 	})
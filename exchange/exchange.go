@@ -15,9 +15,14 @@ import (
 )
 
 var (
-	ErrInvalidQuantity   = errors.New("invalid quantity")
-	ErrInsufficientFunds = errors.New("insufficient funds or locked")
-	ErrInvalidAsset      = errors.New("invalid asset")
+	ErrInvalidQuantity    = errors.New("invalid quantity")
+	ErrInsufficientFunds  = errors.New("insufficient funds or locked")
+	ErrInvalidAsset       = errors.New("invalid asset")
+	ErrNoMarketData       = errors.New("no market data")
+	ErrMinNotional        = errors.New("order value below the minimum notional")
+	ErrSubscriptionClosed = errors.New("subscription closed")
+	ErrShortingNotAllowed = errors.New("shorting not allowed")
+	ErrNoPosition         = errors.New("no open position")
 )
 
 type DataFeed struct {
@@ -47,6 +52,10 @@ func (o *OrderError) Error() string {
 	return fmt.Sprintf("order error: %v", o.Err)
 }
 
+func (o *OrderError) Unwrap() error {
+	return o.Err
+}
+
 type DataFeedConsumer func(model.Candle)
 
 func NewDataFeed(exchange service.Exchange) *DataFeedSubscription {
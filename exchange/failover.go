@@ -0,0 +1,248 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// Failover is a feeder that reads candles from a primary feeder and switches to a secondary feeder
+// when the primary fails, e.g. when the Binance websocket is down.
+type Failover struct {
+	primary       service.Feeder
+	secondary     service.Feeder
+	notifier      service.Notifier
+	maxErrors     int
+	retryInterval time.Duration
+}
+
+type FailoverOption func(*Failover)
+
+// WithFailoverMaxErrors sets the number of consecutive errors of the primary subscription
+// before switching to the secondary feeder, default is 3
+func WithFailoverMaxErrors(maxErrors int) FailoverOption {
+	return func(failover *Failover) {
+		failover.maxErrors = maxErrors
+	}
+}
+
+// WithFailoverRetryInterval sets the interval to subscribe again to the primary feeder
+// when its subscription is closed, default is 1 minute
+func WithFailoverRetryInterval(interval time.Duration) FailoverOption {
+	return func(failover *Failover) {
+		failover.retryInterval = interval
+	}
+}
+
+// WithFailoverNotifier sends a notification when the feed switches between the feeders, e.g. Telegram
+func WithFailoverNotifier(notifier service.Notifier) FailoverOption {
+	return func(failover *Failover) {
+		failover.notifier = notifier
+	}
+}
+
+// FailoverFeed creates a feeder that delegates to the primary feeder. Candle requests that fail in the
+// primary feeder are served by the secondary one. Subscriptions switch to the secondary feeder after
+// repeated errors of the primary and switch back as soon as the primary delivers candles again.
+func FailoverFeed(primary, secondary service.Feeder, options ...FailoverOption) *Failover {
+	failover := &Failover{
+		primary:       primary,
+		secondary:     secondary,
+		maxErrors:     3,
+		retryInterval: time.Minute,
+	}
+
+	for _, option := range options {
+		option(failover)
+	}
+
+	return failover
+}
+
+func (f *Failover) notify(message string) {
+	log.Warn(message)
+	if f.notifier != nil {
+		f.notifier.Notify(message)
+	}
+}
+
+func (f *Failover) AssetsInfo(pair string) model.AssetInfo {
+	return f.primary.AssetsInfo(pair)
+}
+
+func (f *Failover) LastQuote(ctx context.Context, pair string) (float64, error) {
+	quote, err := f.primary.LastQuote(ctx, pair)
+	if err != nil {
+		log.Warnf("failover: primary feed: %v", err)
+		return f.secondary.LastQuote(ctx, pair)
+	}
+	return quote, nil
+}
+
+func (f *Failover) CandlesByPeriod(ctx context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	candles, err := f.primary.CandlesByPeriod(ctx, pair, period, start, end)
+	if err != nil {
+		log.Warnf("failover: primary feed: %v", err)
+		return f.secondary.CandlesByPeriod(ctx, pair, period, start, end)
+	}
+	return candles, nil
+}
+
+func (f *Failover) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	candles, err := f.primary.CandlesByLimit(ctx, pair, period, limit)
+	if err != nil {
+		log.Warnf("failover: primary feed: %v", err)
+		return f.secondary.CandlesByLimit(ctx, pair, period, limit)
+	}
+	return candles, nil
+}
+
+// CandlesSubscription forwards the candles of the primary subscription. After repeated errors, or if the
+// primary subscription is closed, the candles are read from a secondary subscription until the primary
+// delivers a candle again. Candles older than the last complete candle sent are discarded, so the
+// consumer does not receive duplicates when the feeders overlap.
+func (f *Failover) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+
+	go func() {
+		defer close(cerr)
+		defer close(ccandle)
+
+		var (
+			secondaryCandles chan model.Candle
+			secondaryErrors  chan error
+			cancelSecondary  context.CancelFunc
+			retry            <-chan time.Time
+			failures         int
+			lastComplete     time.Time
+		)
+
+		primaryCandles, primaryErrors := f.primary.CandlesSubscription(ctx, pair, timeframe)
+
+		startFailover := func(reason error) {
+			if cancelSecondary != nil {
+				return
+			}
+
+			var secondaryCtx context.Context
+			secondaryCtx, cancelSecondary = context.WithCancel(ctx)
+			secondaryCandles, secondaryErrors = f.secondary.CandlesSubscription(secondaryCtx, pair, timeframe)
+			f.notify(fmt.Sprintf("[FAILOVER] %s-%s: using secondary feed: %v", pair, timeframe, reason))
+		}
+
+		stopFailover := func() {
+			if cancelSecondary == nil {
+				return
+			}
+
+			cancelSecondary()
+			go drainSubscription(secondaryCandles, secondaryErrors)
+			cancelSecondary, secondaryCandles, secondaryErrors = nil, nil, nil
+			f.notify(fmt.Sprintf("[FAILOVER] %s-%s: primary feed recovered", pair, timeframe))
+		}
+
+		defer func() {
+			if cancelSecondary != nil {
+				cancelSecondary()
+			}
+		}()
+
+		sendCandle := func(candle model.Candle) {
+			if !candle.Time.After(lastComplete) {
+				return
+			}
+
+			if candle.Complete {
+				lastComplete = candle.Time
+			}
+
+			select {
+			case ccandle <- candle:
+			case <-ctx.Done():
+			}
+		}
+
+		sendError := func(err error) {
+			select {
+			case cerr <- err:
+			case <-ctx.Done():
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case candle, ok := <-primaryCandles:
+				if !ok {
+					if ctx.Err() != nil {
+						return
+					}
+					primaryCandles, primaryErrors = nil, nil
+					retry = time.After(f.retryInterval)
+					startFailover(ErrSubscriptionClosed)
+					continue
+				}
+
+				failures = 0
+				stopFailover()
+				sendCandle(candle)
+			case err, ok := <-primaryErrors:
+				if !ok {
+					primaryErrors = nil
+					continue
+				}
+
+				failures++
+				if cancelSecondary != nil { // errors of the primary are expected while it is down
+					continue
+				}
+
+				sendError(err)
+				if failures >= f.maxErrors {
+					startFailover(err)
+				}
+			case candle, ok := <-secondaryCandles:
+				if !ok {
+					secondaryCandles = nil
+					continue
+				}
+				sendCandle(candle)
+			case err, ok := <-secondaryErrors:
+				if !ok {
+					secondaryErrors = nil
+					continue
+				}
+				sendError(err)
+			case <-retry:
+				retry = nil
+				primaryCandles, primaryErrors = f.primary.CandlesSubscription(ctx, pair, timeframe)
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
+
+// drainSubscription consumes a canceled subscription until it is closed, so its goroutine is not blocked
+func drainSubscription(candles chan model.Candle, errs chan error) {
+	for candles != nil || errs != nil {
+		select {
+		case _, ok := <-candles:
+			if !ok {
+				candles = nil
+			}
+		case _, ok := <-errs:
+			if !ok {
+				errs = nil
+			}
+		}
+	}
+}
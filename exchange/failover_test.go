@@ -0,0 +1,68 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+func TestFailover_CandlesByLimit(t *testing.T) {
+	candles := []model.Candle{{Pair: "BTCUSDT", Close: 100}}
+
+	primary := mocks.NewFeeder(t)
+	primary.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1h", 10).Return(nil, errors.New("timeout"))
+	secondary := mocks.NewFeeder(t)
+	secondary.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1h", 10).Return(candles, nil)
+
+	result, err := FailoverFeed(primary, secondary).CandlesByLimit(context.Background(), "BTCUSDT", "1h", 10)
+	require.NoError(t, err)
+	require.Equal(t, candles, result)
+}
+
+func TestFailover_CandlesSubscription(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(minute int) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(minute) * time.Minute), Complete: true}
+	}
+
+	primaryCandles, primaryErrors := make(chan model.Candle), make(chan error)
+	secondaryCandles, secondaryErrors := make(chan model.Candle), make(chan error)
+
+	primary := mocks.NewFeeder(t)
+	primary.EXPECT().CandlesSubscription(mock.Anything, "BTCUSDT", "1m").Return(primaryCandles, primaryErrors)
+	secondary := mocks.NewFeeder(t)
+	secondary.EXPECT().CandlesSubscription(mock.Anything, "BTCUSDT", "1m").Return(secondaryCandles, secondaryErrors)
+	notifier := mocks.NewNotifier(t)
+	notifier.EXPECT().Notify(mock.Anything).Times(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	feed := FailoverFeed(primary, secondary, WithFailoverMaxErrors(2), WithFailoverNotifier(notifier))
+	ccandle, cerr := feed.CandlesSubscription(ctx, "BTCUSDT", "1m")
+
+	primaryCandles <- candle(0)
+	require.Equal(t, candle(0), <-ccandle)
+
+	// repeated errors of the primary feed
+	primaryErrors <- errors.New("connection lost")
+	require.Error(t, <-cerr)
+	primaryErrors <- errors.New("connection lost")
+	require.Error(t, <-cerr)
+
+	// secondary feed, candles already sent are ignored
+	secondaryCandles <- candle(0)
+	secondaryCandles <- candle(1)
+	require.Equal(t, candle(1), <-ccandle)
+
+	// primary feed recovered
+	primaryCandles <- candle(2)
+	require.Equal(t, candle(2), <-ccandle)
+}
@@ -0,0 +1,250 @@
+package exchange
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// FundingRateMetadata is the candle metadata key of the funding rate, see FundingRates.Fetcher
+const FundingRateMetadata = "funding_rate"
+
+// binanceFundingLimit is the max number of funding rates returned by each request
+const binanceFundingLimit = 1000
+
+// FundingRate is the funding rate of a perpetual contract, applied at Time
+type FundingRate struct {
+	Time time.Time
+	Rate float64
+}
+
+// FundingRates is the history of funding rates of perpetual contracts, loaded from a CSV file or
+// from Binance Futures. It provides the funding rate of a candle as metadata, for funding-aware backtests.
+type FundingRates struct {
+	rates map[string][]FundingRate
+}
+
+func NewFundingRates() *FundingRates {
+	return &FundingRates{
+		rates: make(map[string][]FundingRate),
+	}
+}
+
+// Add includes funding rates in the history of a pair
+func (f *FundingRates) Add(pair string, rates ...FundingRate) {
+	pair = strings.ToUpper(pair)
+	history := append(f.rates[pair], rates...)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Time.Before(history[j].Time)
+	})
+	f.rates[pair] = history
+}
+
+// Rate returns the funding rate in effect at a given time, the last rate applied at or before t.
+// It returns false if the history of the pair does not cover t.
+func (f *FundingRates) Rate(pair string, t time.Time) (float64, bool) {
+	history := f.rates[strings.ToUpper(pair)]
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Time.After(t)
+	})
+	if i == 0 {
+		return 0, false
+	}
+	return history[i-1].Rate, true
+}
+
+// Fetcher returns a metadata fetcher that includes the funding rate in the candles as FundingRateMetadata,
+// e.g. with `WithMetadataFetcher` or `PairFeed.MetadataFetchers`. Candles before the history have rate zero.
+func (f *FundingRates) Fetcher() MetadataFetchers {
+	return func(pair string, t time.Time) (string, float64) {
+		rate, _ := f.Rate(pair, t)
+		return FundingRateMetadata, rate
+	}
+}
+
+// LoadCSV includes the funding rates of a CSV file in the history of a pair. Each line has the time
+// in seconds (unix timestamp) and the rate, e.g. `1609459200,0.0001`. A header line is ignored.
+func (f *FundingRates) LoadCSV(pair, file string) error {
+	csvFile, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+
+	lines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	rates := make([]FundingRate, 0, len(lines))
+	for i, line := range lines {
+		if len(line) < 2 {
+			return fmt.Errorf("%s: invalid line %d", file, i+1)
+		}
+
+		timestamp, err := strconv.ParseInt(line[0], 10, 64)
+		if err != nil {
+			if i == 0 { // header
+				continue
+			}
+			return fmt.Errorf("%s: invalid time in line %d: %w", file, i+1, err)
+		}
+
+		rate, err := strconv.ParseFloat(line[1], 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid rate in line %d: %w", file, i+1, err)
+		}
+
+		rates = append(rates, FundingRate{
+			Time: time.Unix(timestamp, 0).UTC(),
+			Rate: rate,
+		})
+	}
+
+	f.Add(pair, rates...)
+	return nil
+}
+
+// FundingRates returns the history of funding rates of a pair between start and end
+func (b *BinanceFuture) FundingRates(ctx context.Context, pair string, start, end time.Time) ([]FundingRate, error) {
+	rates := make([]FundingRate, 0)
+	for start.Before(end) {
+		data, err := b.client.NewFundingRateService().
+			Symbol(pair).
+			StartTime(start.UnixNano() / int64(time.Millisecond)).
+			EndTime(end.UnixNano() / int64(time.Millisecond)).
+			Limit(binanceFundingLimit).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range data {
+			rate, err := strconv.ParseFloat(d.FundingRate, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			rates = append(rates, FundingRate{
+				Time: time.Unix(0, d.FundingTime*int64(time.Millisecond)).UTC(),
+				Rate: rate,
+			})
+		}
+
+		if len(data) < binanceFundingLimit {
+			break
+		}
+
+		// next page after the last funding time
+		start = rates[len(rates)-1].Time.Add(time.Millisecond)
+	}
+
+	return rates, nil
+}
+
+// fetchMetadata includes the metadata of the fetchers in a candle
+func fetchMetadata(candle model.Candle, fetchers []MetadataFetchers) model.Candle {
+	if len(fetchers) == 0 {
+		return candle
+	}
+
+	if candle.Metadata == nil {
+		candle.Metadata = make(map[string]float64, len(fetchers))
+	}
+
+	for _, fetcher := range fetchers {
+		key, value := fetcher(candle.Pair, candle.Time)
+		candle.Metadata[key] = value
+	}
+
+	return candle
+}
+
+// paperFunding is the funding of a perpetual pair in the paper wallet, see WithPaperFundingRate
+type paperFunding struct {
+	rate     float64
+	interval time.Duration
+	last     time.Time
+}
+
+// WithPaperFundingRate charges the funding of the positions of a perpetual pair at each interval, e.g. 0.0001
+// every 8h, with the funding times aligned with the interval in UTC. At each funding time, the positions pay
+// their value times the rate, longs pay shorts when the rate is positive and shorts pay longs when it is
+// negative. The rate in the metadata of the candles, see FundingRates.Fetcher, overrides the given rate. The
+// payments are settled in the quote balance and included in the equity, see Funding.
+func WithPaperFundingRate(pair string, ratePerInterval float64, interval time.Duration) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		if wallet.fundingRates == nil {
+			wallet.fundingRates = make(map[string]*paperFunding)
+		}
+		wallet.fundingRates[pair] = &paperFunding{rate: ratePerInterval, interval: interval}
+	}
+}
+
+// Funding returns the net funding of each pair in its quote, negative when paid, see WithPaperFundingRate
+func (p *PaperWallet) Funding() map[string]float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	funding := make(map[string]float64, len(p.funding))
+	for pair, value := range p.funding {
+		funding[pair] = value
+	}
+	return funding
+}
+
+// settleFunding charges the funding of the position of a pair for each funding time since the previous candle,
+// with the position held before the candle and its open as the mark price
+func (p *PaperWallet) settleFunding(candle model.Candle) {
+	funding, ok := p.fundingRates[candle.Pair]
+	if !ok || funding.interval <= 0 {
+		return
+	}
+
+	fundingTime := candle.Time.Truncate(funding.interval)
+	if funding.last.IsZero() {
+		funding.last = fundingTime
+		return
+	}
+
+	if !fundingTime.After(funding.last) {
+		return
+	}
+
+	periods := float64(fundingTime.Sub(funding.last) / funding.interval)
+	funding.last = fundingTime
+
+	asset, quote := SplitAssetQuote(candle.Pair)
+	info, ok := p.assets[asset]
+	if !ok || info.Free+info.Lock == 0 {
+		return
+	}
+
+	rate := funding.rate
+	if value, ok := candle.Metadata[FundingRateMetadata]; ok {
+		rate = value
+	}
+
+	price := candle.Open
+	if price <= 0 { // candles without open
+		price = candle.Close
+	}
+
+	payment := -p.mul(p.mul(info.Free+info.Lock, price), rate*periods)
+	quoteInfo := p.quoteBalance(candle.Pair)
+	quoteInfo.Free = p.add(quoteInfo.Free, payment)
+
+	if p.funding == nil {
+		p.funding = make(map[string]float64)
+	}
+	p.funding[candle.Pair] += payment
+	log.Debugf("[FUNDING] %s: %s", candle.Pair, model.FormatValue(payment, 4, quote))
+}
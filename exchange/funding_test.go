@@ -0,0 +1,125 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestFundingRates_Rate(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	rates := NewFundingRates()
+	rates.Add("btcusdt",
+		FundingRate{Time: start.Add(8 * time.Hour), Rate: 0.0002},
+		FundingRate{Time: start, Rate: 0.0001},
+		FundingRate{Time: start.Add(16 * time.Hour), Rate: -0.0003},
+	)
+
+	tt := []struct {
+		time     time.Time
+		rate     float64
+		included bool
+	}{
+		{start.Add(-time.Minute), 0, false},
+		{start, 0.0001, true},
+		{start.Add(7*time.Hour + 59*time.Minute), 0.0001, true},
+		{start.Add(8 * time.Hour), 0.0002, true},
+		{start.Add(20 * time.Hour), -0.0003, true},
+		{start.Add(48 * time.Hour), -0.0003, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.time.String(), func(t *testing.T) {
+			rate, ok := rates.Rate("BTCUSDT", tc.time)
+			require.Equal(t, tc.included, ok)
+			require.Equal(t, tc.rate, rate)
+		})
+	}
+
+	_, ok := rates.Rate("ETHUSDT", start)
+	require.False(t, ok)
+
+	key, value := rates.Fetcher()("BTCUSDT", start.Add(9*time.Hour))
+	require.Equal(t, FundingRateMetadata, key)
+	require.Equal(t, 0.0002, value)
+}
+
+func TestFundingRates_LoadCSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "funding.csv")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"time,rate\n"+
+			"1619366400,0.0001\n"+
+			"1619395200,0.0002\n"+
+			"1619424000,0.0003\n"), 0600))
+
+	rates := NewFundingRates()
+	require.NoError(t, rates.LoadCSV("BTCUSDT", file))
+
+	feed, err := NewCSVFeed("1d", PairFeed{
+		Timeframe:        "1d",
+		Pair:             "BTCUSDT",
+		File:             "../testdata/btc-1d.csv",
+		MetadataFetchers: []MetadataFetchers{rates.Fetcher()},
+	})
+	require.NoError(t, err)
+
+	candles := feed.CandlePairTimeFrame["BTCUSDT--1d"]
+	require.Equal(t, 0.0002, candles[0].Metadata[FundingRateMetadata])
+	require.Equal(t, 0.0003, candles[1].Metadata[FundingRateMetadata])
+
+	t.Run("invalid rate", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "funding.csv")
+		require.NoError(t, os.WriteFile(file, []byte("1619366400,invalid\n"), 0600))
+		require.Error(t, NewFundingRates().LoadCSV("BTCUSDT", file))
+	})
+}
+
+func TestPaperWallet_FundingRate(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(hours int, price float64) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(hours) * time.Hour), Open: price,
+			Close: price, Low: price, High: price, Complete: true}
+	}
+
+	t.Run("long pays", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000),
+			WithPaperFundingRate("BTCUSDT", 0.001, 8*time.Hour))
+		wallet.OnCandle(candle(0, 100))
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		wallet.OnCandle(candle(7, 150))
+		require.Empty(t, wallet.Funding())
+
+		wallet.OnCandle(candle(8, 200))
+		require.InDelta(t, -0.2, wallet.Funding()["BTCUSDT"], 1e-9)
+		require.InDelta(t, 9899.8, wallet.assets["USDT"].Free, 1e-9)
+
+		equity := wallet.EquityValues()
+		require.InDelta(t, 9899.8+200, equity[len(equity)-1].Value, 1e-9)
+
+		// the rate of the candle metadata overrides the fixed rate
+		next := candle(16, 100)
+		next.Metadata = map[string]float64{FundingRateMetadata: -0.002}
+		wallet.OnCandle(next)
+		require.InDelta(t, 0, wallet.Funding()["BTCUSDT"], 1e-9)
+	})
+
+	t.Run("short receives", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000),
+			WithPaperFundingRate("BTCUSDT", 0.001, 8*time.Hour))
+		wallet.OnCandle(candle(0, 100))
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		// two funding times between the candles
+		wallet.OnCandle(candle(16, 200))
+		require.InDelta(t, 0.4, wallet.Funding()["BTCUSDT"], 1e-9)
+	})
+}
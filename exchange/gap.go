@@ -0,0 +1,74 @@
+package exchange
+
+import (
+	"context"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+type candleFetcher func(ctx context.Context, pair, period string, start, end time.Time) ([]model.Candle, error)
+
+// candleGap tracks the last candle of a subscription to fill the candles missed while the websocket
+// was disconnected, see WithBinanceGapFill
+type candleGap struct {
+	fetch    candleFetcher
+	duration time.Duration
+	last     time.Time
+}
+
+func newCandleGap(fetch candleFetcher, period string) (*candleGap, error) {
+	duration, err := str2duration.ParseDuration(period)
+	if err != nil {
+		return nil, err
+	}
+
+	return &candleGap{
+		fetch:    fetch,
+		duration: duration,
+	}, nil
+}
+
+// accept returns false for candles of periods already completed, e.g. a backfilled candle repeated
+// by the stream after the reconnection
+func (g *candleGap) accept(candle model.Candle) bool {
+	if !g.last.IsZero() && !candle.Time.After(g.last) {
+		return false
+	}
+
+	if candle.Complete {
+		g.last = candle.Time
+	}
+	return true
+}
+
+// missing returns the candles completed between the last complete candle and now, in order.
+// The candle in progress is left to the stream.
+func (g *candleGap) missing(ctx context.Context, pair, period string, now time.Time) ([]model.Candle, error) {
+	if g.last.IsZero() {
+		return nil, nil
+	}
+
+	start := g.last.Add(g.duration)
+	if start.Add(g.duration).After(now) {
+		return nil, nil
+	}
+
+	candles, err := g.fetch(ctx, pair, period, start, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if !candle.Time.After(g.last) || candle.Time.Add(g.duration).After(now) {
+			continue
+		}
+		candle.Complete = true
+		result = append(result, candle)
+	}
+
+	return result, nil
+}
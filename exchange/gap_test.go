@@ -0,0 +1,72 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestCandleGap(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(i int, complete bool) model.Candle {
+		return model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Close:    float64(100 + i),
+			Complete: complete,
+		}
+	}
+
+	var requested []time.Time
+	fetch := func(_ context.Context, pair, period string, from, to time.Time) ([]model.Candle, error) {
+		requested = append(requested, from, to)
+		// the exchange returns the candle in progress as complete
+		candles := make([]model.Candle, 0)
+		for i := 0; i <= 6; i++ {
+			if c := candle(i, true); !c.Time.Before(from) && !c.Time.After(to) {
+				candles = append(candles, c)
+			}
+		}
+		return candles, nil
+	}
+
+	gap, err := newCandleGap(fetch, "1m")
+	require.NoError(t, err)
+
+	// no backfill before the first candle
+	candles, err := gap.missing(context.Background(), "BTCUSDT", "1m", start)
+	require.NoError(t, err)
+	require.Empty(t, candles)
+
+	// stream before the disconnection
+	require.True(t, gap.accept(candle(0, true)))
+	require.True(t, gap.accept(candle(1, false)))
+	require.True(t, gap.accept(candle(1, true)))
+	require.False(t, gap.accept(candle(1, true)))
+
+	// reconnection during the candle 6
+	now := start.Add(6*time.Minute + 30*time.Second)
+	candles, err = gap.missing(context.Background(), "BTCUSDT", "1m", now)
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{start.Add(2 * time.Minute), now}, requested)
+	require.Len(t, candles, 4)
+	for i, c := range candles {
+		require.Equal(t, candle(i+2, true).Time, c.Time)
+		require.True(t, gap.accept(c))
+	}
+
+	// the stream repeats the last candle of the gap and resumes with the candle in progress
+	require.False(t, gap.accept(candle(5, true)))
+	require.True(t, gap.accept(candle(6, false)))
+	require.True(t, gap.accept(candle(6, true)))
+
+	// no gap in a reconnection during the next candle
+	candles, err = gap.missing(context.Background(), "BTCUSDT", "1m", start.Add(7*time.Minute+10*time.Second))
+	require.NoError(t, err)
+	require.Empty(t, candles)
+	require.Len(t, requested, 2)
+}
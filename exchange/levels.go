@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// PriceLevels returns count prices evenly spaced from one price to another, both included, e.g. the levels of
+// a grid or of a DCA ladder. Each level is snapped to the nearest multiple of the tick size of the pair, from
+// the asset info of the feeder, so the orders are not rejected by the price filter of the exchange. The paper
+// wallet accepts any price with 8 decimal places. The levels are in the order of the range, descending if from
+// is above to, and levels merged by the snapping are returned once, so a range narrower than the ticks returns
+// fewer levels. A single level is the price from, it returns nil without levels.
+func PriceLevels(feeder service.Feeder, pair string, from, to float64, count int) []float64 {
+	if count <= 0 {
+		return nil
+	}
+
+	info := feeder.AssetsInfo(pair)
+	levels := make([]float64, 0, count)
+	for i := 0; i < count; i++ {
+		price := from
+		if count > 1 {
+			price = from + (to-from)*float64(i)/float64(count-1)
+		}
+
+		price = roundToStep(price, info.TickSize, info.QuotePrecision, RoundingNearest)
+		if len(levels) > 0 && levels[len(levels)-1] == price {
+			continue
+		}
+		levels = append(levels, price)
+	}
+
+	return levels
+}
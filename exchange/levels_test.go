@@ -0,0 +1,60 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+type levelsFeed struct {
+	*CSVFeed
+	info model.AssetInfo
+}
+
+func (f levelsFeed) AssetsInfo(string) model.AssetInfo {
+	return f.info
+}
+
+func TestPriceLevels(t *testing.T) {
+	feed := levelsFeed{info: model.AssetInfo{TickSize: 0.01, QuotePrecision: 8}}
+
+	t.Run("snapped to the tick size", func(t *testing.T) {
+		levels := PriceLevels(feed, "BTCUSDT", 100, 101, 4)
+		require.Len(t, levels, 4)
+		for i, expected := range []float64{100, 100.33, 100.67, 101} {
+			require.InDelta(t, expected, levels[i], 1e-9)
+		}
+	})
+
+	t.Run("descending range", func(t *testing.T) {
+		levels := PriceLevels(feed, "BTCUSDT", 30000, 29000, 3)
+		require.Equal(t, []float64{30000, 29500, 29000}, levels)
+	})
+
+	t.Run("half tick boundary", func(t *testing.T) {
+		// 10.005 and 10.015 are not multiples of the tick, they are snapped to the nearest ticks
+		levels := PriceLevels(feed, "BTCUSDT", 10.005, 10.015, 2)
+		require.Equal(t, []float64{10.01, 10.02}, levels)
+	})
+
+	t.Run("range narrower than the ticks", func(t *testing.T) {
+		levels := PriceLevels(feed, "BTCUSDT", 10, 10.02, 5)
+		require.Equal(t, []float64{10, 10.01, 10.02}, levels)
+	})
+
+	t.Run("single and no level", func(t *testing.T) {
+		require.Equal(t, []float64{100.12}, PriceLevels(feed, "BTCUSDT", 100.123, 200, 1))
+		require.Nil(t, PriceLevels(feed, "BTCUSDT", 100, 200, 0))
+	})
+
+	t.Run("paper wallet", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		levels := PriceLevels(wallet, "BTCUSDT", 1, 2, 4)
+		for i, expected := range []float64{1, 1.33333333, 1.66666667, 2} {
+			require.InDelta(t, expected, levels[i], 1e-12)
+		}
+	})
+}
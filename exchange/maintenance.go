@@ -0,0 +1,29 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/adshao/go-binance/v2/common"
+)
+
+// ErrMaintenance is returned by the order endpoints of an exchange under maintenance, the orders can be retried
+// when the endpoints recover, see order.Controller.SetMaintenancePause
+var ErrMaintenance = errors.New("exchange under maintenance")
+
+// binanceMaintenanceCodes are the error codes of the Binance API while its services are unavailable:
+// DISCONNECTED (-1001), TOO_MANY_REQUESTS when the server is overloaded (-1008) and SERVICE_SHUTTING_DOWN (-1016)
+var binanceMaintenanceCodes = map[int64]bool{
+	-1001: true,
+	-1008: true,
+	-1016: true,
+}
+
+// maintenanceError wraps the errors of the Binance API with a maintenance code with ErrMaintenance
+func maintenanceError(err error) error {
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) && binanceMaintenanceCodes[apiErr.Code] {
+		return fmt.Errorf("%w: %v", ErrMaintenance, err)
+	}
+	return err
+}
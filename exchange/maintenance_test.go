@@ -0,0 +1,22 @@
+package exchange
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceError(t *testing.T) {
+	for _, code := range []int64{-1001, -1008, -1016} {
+		err := maintenanceError(fmt.Errorf("create order: %w", &common.APIError{Code: code}))
+		require.ErrorIs(t, err, ErrMaintenance)
+		require.Contains(t, err.Error(), fmt.Sprintf("code=%d", code))
+	}
+
+	insufficient := &common.APIError{Code: -2010, Message: "Account has insufficient balance"}
+	require.Equal(t, error(insufficient), maintenanceError(insufficient))
+
+	require.NoError(t, maintenanceError(nil))
+}
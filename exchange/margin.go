@@ -0,0 +1,269 @@
+package exchange
+
+import (
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// DefaultMaintenanceMargin is the maintenance margin rate of leveraged positions, 0.5% of the position value
+const DefaultMaintenanceMargin = 0.005
+
+// LiquidationTag is the tag of the orders created by the liquidation of leveraged positions
+const LiquidationTag = "liquidation"
+
+// WithPaperLeverage trades the pair with leverage, e.g. 3 for 3x, in an isolated margin. The orders that open or
+// increase a position require only the position value divided by the leverage as margin, the orders that reduce
+// it release their margin with the realized profit. When the equity of the position, its margin plus the
+// unrealized profit, falls below the maintenance margin in a candle, the position is liquidated, see
+// WithPaperMaintenanceMargin. Without leverage, the positions of the pair are fully collateralized.
+func WithPaperLeverage(pair string, leverage float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		if wallet.leverage == nil {
+			wallet.leverage = make(map[string]float64)
+		}
+		wallet.leverage[pair] = leverage
+	}
+}
+
+// WithPaperMaintenanceMargin sets the maintenance margin rate of the leveraged positions, the fraction of the
+// position value below which its equity is liquidated, default is DefaultMaintenanceMargin
+func WithPaperMaintenanceMargin(rate float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.maintenance = rate
+	}
+}
+
+// Margin returns the margin used by the position of a leveraged pair, at the entry price, and the margin
+// available to open positions, the free quote balance of the pair. The margin locked by open orders is not
+// available. See WithPaperLeverage.
+func (p *PaperWallet) Margin(pair string) (used, available float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.usedMargin(pair), p.quoteBalance(pair).Free
+}
+
+// leveraged returns true if the pair is traded with margin, see WithPaperLeverage
+func (p *PaperWallet) leveraged(pair string) bool {
+	return p.leverage[pair] > 0
+}
+
+// marginPosition returns the quantity of the position of a pair, negative for shorts, and its entry price
+func (p *PaperWallet) marginPosition(pair string) (quantity, entry float64) {
+	asset, _ := SplitAssetQuote(pair)
+	if info, ok := p.assets[asset]; ok {
+		quantity = info.Free
+	}
+
+	if quantity < 0 {
+		return quantity, p.avgShortPrice[pair]
+	}
+	return quantity, p.avgLongPrice[pair]
+}
+
+// usedMargin returns the margin of the position of a leveraged pair, its value at the entry price divided by
+// the leverage
+func (p *PaperWallet) usedMargin(pair string) float64 {
+	if !p.leveraged(pair) {
+		return 0
+	}
+
+	quantity, entry := p.marginPosition(pair)
+	return p.div(p.mul(math.Abs(quantity), entry), p.leverage[pair])
+}
+
+// marginEquity returns the value of the position of a leveraged pair at a price, the margin plus the unrealized
+// profit
+func (p *PaperWallet) marginEquity(pair string, price float64) float64 {
+	quantity, entry := p.marginPosition(pair)
+	return p.add(p.usedMargin(pair), p.mul(price-entry, quantity))
+}
+
+// closingQuantity returns the part of an order that reduces the position of the pair
+func (p *PaperWallet) closingQuantity(side model.SideType, pair string, amount float64) float64 {
+	quantity, _ := p.marginPosition(pair)
+	if (side == model.SideTypeBuy && quantity < 0) || (side == model.SideTypeSell && quantity > 0) {
+		return math.Min(amount, math.Abs(quantity))
+	}
+	return 0
+}
+
+// validateMargin is the validateFunds of leveraged pairs, the margin of the part of the order that opens a
+// position must be available in the quote balance. The margin is filled or locked until the order is filled.
+func (p *PaperWallet) validateMargin(side model.SideType, pair string, amount, value float64, fill bool) error {
+	asset, _ := SplitAssetQuote(pair)
+	if _, ok := p.assets[asset]; !ok {
+		p.assets[asset] = &assetInfo{}
+	}
+
+	if p.spotOnly && side == model.SideTypeSell && amount > p.assets[asset].Free {
+		return &OrderError{
+			Err:      ErrShortingNotAllowed,
+			Pair:     pair,
+			Quantity: amount,
+		}
+	}
+
+	quoteInfo := p.quoteBalance(pair)
+	opening := p.sub(amount, p.closingQuantity(side, pair, amount))
+	required := p.div(p.mul(opening, value), p.leverage[pair])
+	if quoteInfo.Free < required {
+		return &OrderError{
+			Err:      ErrInsufficientFunds,
+			Pair:     pair,
+			Quantity: amount,
+		}
+	}
+
+	if fill {
+		p.fillMargin(side, pair, amount, value)
+		return nil
+	}
+
+	quoteInfo.Free = p.sub(quoteInfo.Free, required)
+	quoteInfo.Lock = p.add(quoteInfo.Lock, required)
+	log.Debugf("%s -> MARGIN LOCK = %f / FREE %f", pair, quoteInfo.Lock, quoteInfo.Free)
+	return nil
+}
+
+// fillMargin applies the fill of an order to the position of a leveraged pair. The closed part releases its
+// margin and the realized profit, the opened part consumes its margin at the fill price.
+func (p *PaperWallet) fillMargin(side model.SideType, pair string, amount, price float64) {
+	asset, _ := SplitAssetQuote(pair)
+	if _, ok := p.assets[asset]; !ok {
+		p.assets[asset] = &assetInfo{}
+	}
+
+	quoteInfo := p.quoteBalance(pair)
+	quantity, entry := p.marginPosition(pair)
+	closing := p.closingQuantity(side, pair, amount)
+	if closing > 0 {
+		profit := p.mul(price-entry, closing)
+		if quantity < 0 {
+			profit = -profit
+		}
+		released := p.div(p.mul(closing, entry), p.leverage[pair])
+		quoteInfo.Free = p.add(quoteInfo.Free, p.add(released, profit))
+	}
+
+	p.updateAveragePrice(side, pair, amount, price)
+	opening := p.sub(amount, closing)
+	quoteInfo.Free = p.sub(quoteInfo.Free, p.div(p.mul(opening, price), p.leverage[pair]))
+	if side == model.SideTypeBuy {
+		p.assets[asset].Free = p.add(p.assets[asset].Free, amount)
+	} else {
+		p.assets[asset].Free = p.sub(p.assets[asset].Free, amount)
+	}
+	log.Debugf("%s -> POSITION = %f / MARGIN %f", pair, p.assets[asset].Free, p.usedMargin(pair))
+}
+
+// fillMarginOrder fills a quantity of a pending order of a leveraged pair at the price, the margin locked by
+// the filled part is released before the fill. It returns true if the order is completely filled.
+func (p *PaperWallet) fillMarginOrder(i int, candle model.Candle, quantity, price float64,
+	complete, maker bool) bool {
+
+	order := p.orders[i]
+	var released float64
+	if lock, ok := p.locks[lockID(order)]; ok {
+		remaining := p.sub(order.Quantity, p.executed[order.ExchangeID])
+		released = p.mul(lock.quote, math.Min(quantity/remaining, 1))
+		quoteInfo := p.quoteBalance(order.Pair)
+		quoteInfo.Lock = p.sub(quoteInfo.Lock, released)
+		quoteInfo.Free = p.add(quoteInfo.Free, released)
+	}
+
+	p.volume[order.Pair] += price * quantity
+	p.orders[i].UpdatedAt = candle.Time
+	p.fillMargin(order.Side, order.Pair, quantity, price)
+	p.chargeFee(order.Pair, price*quantity, maker)
+	if !complete {
+		p.partialFill(i, fundsLock{quote: released}, quantity)
+		return false
+	}
+
+	p.orders[i].Status = model.OrderStatusTypeFilled
+	p.settleFunds(order, fundsLock{quote: released}, candle.Time)
+	delete(p.executed, order.ExchangeID)
+	return true
+}
+
+// liquidationPrice returns the price at which the equity of the position of a leveraged pair equals its
+// maintenance margin
+func (p *PaperWallet) liquidationPrice(pair string) float64 {
+	quantity, entry := p.marginPosition(pair)
+	leverage := p.leverage[pair]
+	if quantity < 0 {
+		return entry * (1 + 1/leverage) / (1 + p.maintenanceRate())
+	}
+	return entry * (1 - 1/leverage) / (1 - p.maintenanceRate())
+}
+
+func (p *PaperWallet) maintenanceRate() float64 {
+	if p.maintenance > 0 {
+		return p.maintenance
+	}
+	return DefaultMaintenanceMargin
+}
+
+// checkLiquidation closes the position of a leveraged pair when the worst price of the candle reaches its
+// liquidation price. The position is closed at the liquidation price, or at the open of the candle if it opened
+// beyond it, and the loss is realized as a trade. It returns the liquidation order, tagged with LiquidationTag.
+func (p *PaperWallet) checkLiquidation(candle model.Candle) (model.Order, bool) {
+	if !p.leveraged(candle.Pair) {
+		return model.Order{}, false
+	}
+
+	quantity, _ := p.marginPosition(candle.Pair)
+	if quantity == 0 {
+		return model.Order{}, false
+	}
+
+	liquidation := p.liquidationPrice(candle.Pair)
+	low, high := candle.Low, candle.High
+	if low <= 0 || high <= 0 { // candles without range
+		low, high = candle.Close, candle.Close
+	}
+
+	side, price := model.SideTypeSell, liquidation
+	if quantity > 0 {
+		if low > liquidation {
+			return model.Order{}, false
+		}
+		if candle.Open > 0 && candle.Open < liquidation {
+			price = candle.Open
+		}
+	} else {
+		if high < liquidation {
+			return model.Order{}, false
+		}
+		side = model.SideTypeBuy
+		if candle.Open > liquidation {
+			price = candle.Open
+		}
+	}
+
+	amount := math.Abs(quantity)
+	log.Warnf("[LIQUIDATION] %s %s: %f at %f, margin %f", side, candle.Pair, amount, price,
+		p.usedMargin(candle.Pair))
+
+	p.volume[candle.Pair] += price * amount
+	p.fillMargin(side, candle.Pair, amount, price)
+	p.chargeFee(candle.Pair, price*amount, false)
+
+	order := model.Order{
+		ExchangeID: p.ID(),
+		CreatedAt:  candle.Time,
+		UpdatedAt:  candle.Time,
+		Pair:       candle.Pair,
+		Side:       side,
+		Type:       model.OrderTypeMarket,
+		Status:     model.OrderStatusTypeFilled,
+		Price:      price,
+		Quantity:   amount,
+		Tag:        LiquidationTag,
+	}
+	p.orders = append(p.orders, order)
+	return order, true
+}
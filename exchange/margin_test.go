@@ -0,0 +1,106 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestPaperWallet_Leverage(t *testing.T) {
+	newWallet := func() *PaperWallet {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperLeverage("BTCUSDT", 10))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Open: 100, Low: 100, High: 100})
+		return wallet
+	}
+
+	t.Run("long", func(t *testing.T) {
+		wallet := newWallet()
+
+		// 5000 USDT of position with 500 USDT of margin
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 50)
+		require.NoError(t, err)
+		used, available := wallet.Margin("BTCUSDT")
+		require.Equal(t, 500.0, used)
+		require.Equal(t, 500.0, available)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 60)
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110, Open: 100, Low: 100, High: 110, Complete: true})
+		require.Equal(t, 1500.0, wallet.Result().FinalValue)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 50)
+		require.NoError(t, err)
+		used, available = wallet.Margin("BTCUSDT")
+		require.Zero(t, used)
+		require.Equal(t, 1500.0, available)
+		require.Equal(t, 500.0, wallet.Trades("BTCUSDT")[0].Profit)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		wallet := newWallet()
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 50)
+		require.NoError(t, err)
+		used, available := wallet.Margin("BTCUSDT")
+		require.Equal(t, 500.0, used)
+		require.Equal(t, 500.0, available)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 102, Open: 100, Low: 100, High: 105, Complete: true})
+		require.Equal(t, 900.0, wallet.Result().FinalValue)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 50)
+		require.NoError(t, err)
+		_, available = wallet.Margin("BTCUSDT")
+		require.Equal(t, 900.0, available)
+		require.Equal(t, -100.0, wallet.Trades("BTCUSDT")[0].Profit)
+	})
+
+	t.Run("limit order", func(t *testing.T) {
+		wallet := newWallet()
+
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 10, 95)
+		require.NoError(t, err)
+		require.Equal(t, 95.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 905.0, wallet.assets["USDT"].Free)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 94, Open: 100, Low: 94, High: 100})
+		used, available := wallet.Margin("BTCUSDT")
+		require.Equal(t, 95.0, used)
+		require.Equal(t, 905.0, available)
+		require.Zero(t, wallet.assets["USDT"].Lock)
+		require.Equal(t, 10.0, wallet.assets["BTC"].Free)
+	})
+
+	t.Run("liquidation", func(t *testing.T) {
+		var liquidated []model.Order
+		wallet := newWallet()
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 50)
+		require.NoError(t, err)
+
+		// registered after the entry, which is notified as a fill too
+		WithPaperOnFill(func(order model.Order) {
+			liquidated = append(liquidated, order)
+		})(wallet)
+
+		// liquidation price: 100 * (1 - 1/10) / (1 - 0.005)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 95, Open: 95, Low: 91, High: 96})
+		require.Empty(t, liquidated)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 95, Open: 95, Low: 85, High: 96})
+		require.Len(t, liquidated, 1)
+		require.Equal(t, LiquidationTag, liquidated[0].Tag)
+		require.Equal(t, model.SideTypeSell, liquidated[0].Side)
+		require.InDelta(t, 90.4522613, liquidated[0].Price, 1e-6)
+
+		used, available := wallet.Margin("BTCUSDT")
+		require.Zero(t, used)
+		require.InDelta(t, 1000+50*(90.4522613-100), available, 1e-4)
+		require.Zero(t, wallet.assets["BTC"].Free)
+		require.InDelta(t, 50*(90.4522613-100), wallet.Trades("BTCUSDT")[0].Profit, 1e-4)
+	})
+}
@@ -0,0 +1,580 @@
+// Package mock provides a scriptable exchange for unit tests of controllers and strategies, without network or
+// paper wallet. The candles, quotes, balances and errors of the exchange are defined by the test, the orders are
+// recorded and filled on demand and every call is recorded to be inspected.
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// Call is a call to a method of the exchange and its arguments, in the order of the method signature
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type Option func(*MockExchange)
+
+// WithBalance sets the initial free balance of an asset
+func WithBalance(asset string, amount float64) Option {
+	return func(m *MockExchange) {
+		m.balances[asset] = amount
+	}
+}
+
+// WithCandles scripts the candles of a pair and timeframe, returned by CandlesByPeriod, CandlesByLimit and
+// CandlesSubscription
+func WithCandles(pair, timeframe string, candles ...model.Candle) Option {
+	return func(m *MockExchange) {
+		m.candles[candleKey(pair, timeframe)] = candles
+	}
+}
+
+// WithQuote sets the last price of a pair, see SetQuote
+func WithQuote(pair string, price float64) Option {
+	return func(m *MockExchange) {
+		m.quotes[pair] = price
+	}
+}
+
+// WithAssetInfo sets the asset info of a pair, the default has no limits and precision of 8 digits
+func WithAssetInfo(pair string, info model.AssetInfo) Option {
+	return func(m *MockExchange) {
+		m.assets[pair] = info
+	}
+}
+
+// WithPendingMarket keeps the market orders as new until they are filled with Fill, e.g. to test the handling
+// of orders filled later
+func WithPendingMarket() Option {
+	return func(m *MockExchange) {
+		m.pendingMarket = true
+	}
+}
+
+// MockExchange implements service.Exchange and service.OrderHistory with scripted responses. The market orders
+// are filled at the last quote of the pair, the limit, stop and OCO orders stay new until Fill or Cancel. The
+// balances change with the fills, without checking the funds.
+type MockExchange struct {
+	mtx           sync.Mutex
+	counter       int64
+	calls         []Call
+	orders        []model.Order
+	balances      map[string]float64
+	candles       map[string][]model.Candle
+	quotes        map[string]float64
+	assets        map[string]model.AssetInfo
+	errors        map[string]error
+	pendingMarket bool
+}
+
+// NewExchange creates a mock exchange, see the options to script its responses
+func NewExchange(options ...Option) *MockExchange {
+	m := &MockExchange{
+		balances: make(map[string]float64),
+		candles:  make(map[string][]model.Candle),
+		quotes:   make(map[string]float64),
+		assets:   make(map[string]model.AssetInfo),
+		errors:   make(map[string]error),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+func candleKey(pair, timeframe string) string {
+	return fmt.Sprintf("%s--%s", pair, timeframe)
+}
+
+// SetError makes the calls of a method fail with the error, e.g. SetError("CreateOrderMarket", err), until it is
+// set to nil. The error of CandlesSubscription is sent in its error channel. The variants of a method share
+// its name, e.g. CreateOrderMarketTagged is recorded as CreateOrderMarket and CreateOrderLimitTIF as
+// CreateOrderLimit.
+func (m *MockExchange) SetError(method string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err == nil {
+		delete(m.errors, method)
+		return
+	}
+	m.errors[method] = err
+}
+
+// SetQuote sets the last price of a pair, the price of the market orders
+func (m *MockExchange) SetQuote(pair string, price float64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.quotes[pair] = price
+}
+
+// SetCandles replaces the scripted candles of a pair and timeframe
+func (m *MockExchange) SetCandles(pair, timeframe string, candles ...model.Candle) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.candles[candleKey(pair, timeframe)] = candles
+}
+
+// Balance returns the free balance of an asset
+func (m *MockExchange) Balance(asset string) float64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.balances[asset]
+}
+
+// Calls returns the calls of a method, in the order they were made, or all calls if the method is empty
+func (m *MockExchange) Calls(method string) []Call {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	calls := make([]Call, 0, len(m.calls))
+	for _, call := range m.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Fill fills a new order at the price, the other order of an OCO group is canceled. The price of a limit order is
+// kept if the given price is zero.
+func (m *MockExchange) Fill(id int64, price float64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	i, ok := m.find(id)
+	if !ok {
+		return model.Order{}, fmt.Errorf("%w: %d", ErrOrderNotFound, id)
+	}
+
+	order := &m.orders[i]
+	if order.Status != model.OrderStatusTypeNew && order.Status != model.OrderStatusTypePartiallyFilled {
+		return model.Order{}, fmt.Errorf("%w: %d is %s", ErrOrderNotFound, id, order.Status)
+	}
+
+	if price > 0 {
+		order.Price = price
+	}
+	m.settle(order.Side, order.Pair, order.Quantity, order.Price)
+	order.Status = model.OrderStatusTypeFilled
+	order.UpdatedAt = time.Now()
+
+	if order.GroupID != nil {
+		for j := range m.orders {
+			if j != i && m.orders[j].GroupID != nil && *m.orders[j].GroupID == *order.GroupID {
+				m.orders[j].Status = model.OrderStatusTypeCanceled
+				m.orders[j].UpdatedAt = order.UpdatedAt
+			}
+		}
+	}
+
+	return *order, nil
+}
+
+// record records a call and returns the error scripted for the method
+func (m *MockExchange) record(method string, args ...interface{}) error {
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+	return m.errors[method]
+}
+
+func (m *MockExchange) find(id int64) (int, bool) {
+	for i := range m.orders {
+		if m.orders[i].ExchangeID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (m *MockExchange) settle(side model.SideType, pair string, quantity, price float64) {
+	asset, quote := exchange.SplitAssetQuote(pair)
+	if side == model.SideTypeBuy {
+		m.balances[asset] += quantity
+		m.balances[quote] -= quantity * price
+		return
+	}
+	m.balances[asset] -= quantity
+	m.balances[quote] += quantity * price
+}
+
+func (m *MockExchange) lastQuote(pair string) (float64, error) {
+	if price, ok := m.quotes[pair]; ok {
+		return price, nil
+	}
+
+	var last model.Candle
+	for _, candles := range m.candles {
+		for _, candle := range candles {
+			if candle.Pair == pair && candle.Time.After(last.Time) {
+				last = candle
+			}
+		}
+	}
+	if last.Close == 0 {
+		return 0, fmt.Errorf("%w: %s", exchange.ErrNoMarketData, pair)
+	}
+	return last.Close, nil
+}
+
+func (m *MockExchange) newOrder(side model.SideType, orderType model.OrderType, pair string,
+	quantity, price float64) model.Order {
+	m.counter++
+	now := time.Now()
+	return model.Order{
+		ExchangeID: m.counter,
+		Pair:       pair,
+		Side:       side,
+		Type:       orderType,
+		Status:     model.OrderStatusTypeNew,
+		Price:      price,
+		Quantity:   quantity,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+func (m *MockExchange) createOrderMarket(side model.SideType, pair string, quantity float64,
+	tag string) (model.Order, error) {
+	if quantity <= 0 {
+		return model.Order{}, exchange.ErrInvalidQuantity
+	}
+
+	price, err := m.lastQuote(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order := m.newOrder(side, model.OrderTypeMarket, pair, quantity, price)
+	order.Tag = tag
+	if !m.pendingMarket {
+		m.settle(side, pair, quantity, price)
+		order.Status = model.OrderStatusTypeFilled
+	}
+	m.orders = append(m.orders, order)
+	return order, nil
+}
+
+func (m *MockExchange) AssetsInfo(pair string) model.AssetInfo {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	_ = m.record("AssetsInfo", pair)
+
+	if info, ok := m.assets[pair]; ok {
+		return info
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	return model.AssetInfo{
+		BaseAsset:          asset,
+		QuoteAsset:         quote,
+		MaxPrice:           math.MaxFloat64,
+		MaxQuantity:        math.MaxFloat64,
+		StepSize:           0.00000001,
+		TickSize:           0.00000001,
+		QuotePrecision:     8,
+		BaseAssetPrecision: 8,
+	}
+}
+
+func (m *MockExchange) LastQuote(_ context.Context, pair string) (float64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("LastQuote", pair); err != nil {
+		return 0, err
+	}
+	return m.lastQuote(pair)
+}
+
+// CandlesByPeriod returns the scripted candles of the pair and period with time in [start, end)
+func (m *MockExchange) CandlesByPeriod(_ context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CandlesByPeriod", pair, period, start, end); err != nil {
+		return nil, err
+	}
+
+	candles := make([]model.Candle, 0)
+	for _, candle := range m.candles[candleKey(pair, period)] {
+		if !candle.Time.Before(start) && candle.Time.Before(end) {
+			candles = append(candles, candle)
+		}
+	}
+	return candles, nil
+}
+
+// CandlesByLimit returns the last scripted candles of the pair and period
+func (m *MockExchange) CandlesByLimit(_ context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CandlesByLimit", pair, period, limit); err != nil {
+		return nil, err
+	}
+
+	candles := m.candles[candleKey(pair, period)]
+	if limit < len(candles) {
+		candles = candles[len(candles)-limit:]
+	}
+	return append([]model.Candle(nil), candles...), nil
+}
+
+// CandlesSubscription sends the scripted candles of the pair and timeframe and closes the channels, as the CSV
+// feed. A scripted error is buffered after the candles, so it can be read once the candles channel is closed.
+func (m *MockExchange) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	m.mtx.Lock()
+	err := m.record("CandlesSubscription", pair, timeframe)
+	candles := append([]model.Candle(nil), m.candles[candleKey(pair, timeframe)]...)
+	m.mtx.Unlock()
+
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error, 1)
+	go func() {
+		defer close(ccandle)
+		defer close(cerr)
+
+		for _, candle := range candles {
+			select {
+			case ccandle <- candle:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err != nil {
+			cerr <- err
+		}
+	}()
+	return ccandle, cerr
+}
+
+func (m *MockExchange) Account() (model.Account, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("Account"); err != nil {
+		return model.Account{}, err
+	}
+
+	balances := make([]model.Balance, 0, len(m.balances))
+	for asset, amount := range m.balances {
+		balances = append(balances, model.Balance{Asset: asset, Free: amount})
+	}
+	return model.Account{Balances: balances}, nil
+}
+
+func (m *MockExchange) Position(pair string) (asset, quote float64, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("Position", pair); err != nil {
+		return 0, 0, err
+	}
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	return m.balances[assetTick], m.balances[quoteTick], nil
+}
+
+func (m *MockExchange) Order(pair string, id int64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("Order", pair, id); err != nil {
+		return model.Order{}, err
+	}
+
+	i, ok := m.find(id)
+	if !ok {
+		return model.Order{}, fmt.Errorf("%w: %d", ErrOrderNotFound, id)
+	}
+	return m.orders[i], nil
+}
+
+// Orders returns the last orders of a pair, from the oldest to the newest
+func (m *MockExchange) Orders(pair string, limit int) ([]model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("Orders", pair, limit); err != nil {
+		return nil, err
+	}
+
+	orders := make([]model.Order, 0)
+	for _, order := range m.orders {
+		if order.Pair == pair {
+			orders = append(orders, order)
+		}
+	}
+	if limit > 0 && limit < len(orders) {
+		orders = orders[len(orders)-limit:]
+	}
+	return orders, nil
+}
+
+func (m *MockExchange) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CreateOrderOCO", side, pair, size, price, stop, stopLimit); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, exchange.ErrInvalidQuantity
+	}
+
+	m.counter++
+	groupID := m.counter
+	limitMaker := m.newOrder(side, model.OrderTypeLimitMaker, pair, size, price)
+	limitMaker.GroupID = &groupID
+	stopOrder := m.newOrder(side, model.OrderTypeStopLoss, pair, size, stopLimit)
+	stopOrder.Stop = &stop
+	stopOrder.GroupID = &groupID
+	m.orders = append(m.orders, limitMaker, stopOrder)
+	return []model.Order{limitMaker, stopOrder}, nil
+}
+
+func (m *MockExchange) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	return m.CreateOrderLimitTIF(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF records a limit order, the time in force is ignored
+func (m *MockExchange) CreateOrderLimitTIF(side model.SideType, pair string, size float64, limit float64,
+	tif model.TimeInForceType) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CreateOrderLimit", side, pair, size, limit, tif); err != nil {
+		return model.Order{}, err
+	}
+	if size <= 0 {
+		return model.Order{}, exchange.ErrInvalidQuantity
+	}
+
+	order := m.newOrder(side, model.OrderTypeLimit, pair, size, limit)
+	m.orders = append(m.orders, order)
+	return order, nil
+}
+
+func (m *MockExchange) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return m.CreateOrderMarketTagged(side, pair, size, "")
+}
+
+func (m *MockExchange) CreateOrderMarketTagged(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CreateOrderMarket", side, pair, size, tag); err != nil {
+		return model.Order{}, err
+	}
+	return m.createOrderMarket(side, pair, size, tag)
+}
+
+func (m *MockExchange) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CreateOrderMarketQuote", side, pair, quote); err != nil {
+		return model.Order{}, err
+	}
+
+	price, err := m.lastQuote(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return m.createOrderMarket(side, pair, quote/price, "")
+}
+
+func (m *MockExchange) BuyPercent(pair string, percent float64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("BuyPercent", pair, percent); err != nil {
+		return model.Order{}, err
+	}
+
+	price, err := m.lastQuote(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+	_, quote := exchange.SplitAssetQuote(pair)
+	return m.createOrderMarket(model.SideTypeBuy, pair, m.balances[quote]*percent/price, "")
+}
+
+func (m *MockExchange) SellPercent(pair string, percent float64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("SellPercent", pair, percent); err != nil {
+		return model.Order{}, err
+	}
+
+	asset, _ := exchange.SplitAssetQuote(pair)
+	return m.createOrderMarket(model.SideTypeSell, pair, m.balances[asset]*percent, "")
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order, see exchange.ClosePosition
+func (m *MockExchange) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	m.mtx.Lock()
+	err := m.record("ClosePosition", pair, fraction)
+	m.mtx.Unlock()
+	if err != nil {
+		return model.Order{}, err
+	}
+	return exchange.ClosePosition(m, pair, fraction)
+}
+
+func (m *MockExchange) CreateOrderStop(side model.SideType, pair string, quantity float64,
+	limit float64) (model.Order, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("CreateOrderStop", side, pair, quantity, limit); err != nil {
+		return model.Order{}, err
+	}
+	if quantity <= 0 {
+		return model.Order{}, exchange.ErrInvalidQuantity
+	}
+
+	order := m.newOrder(side, model.OrderTypeStopLoss, pair, quantity, limit)
+	order.Stop = &limit
+	m.orders = append(m.orders, order)
+	return order, nil
+}
+
+func (m *MockExchange) Cancel(order model.Order) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.record("Cancel", order); err != nil {
+		return err
+	}
+
+	i, ok := m.find(order.ExchangeID)
+	if !ok || m.orders[i].Status != model.OrderStatusTypeNew {
+		return fmt.Errorf("%w: %d", ErrOrderNotFound, order.ExchangeID)
+	}
+
+	m.orders[i].Status = model.OrderStatusTypeCanceled
+	m.orders[i].UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,188 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+var start = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func candles(closes ...float64) []model.Candle {
+	result := make([]model.Candle, 0, len(closes))
+	for i, price := range closes {
+		result = append(result, model.Candle{
+			Pair:      "BTCUSDT",
+			Time:      start.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: start.Add(time.Duration(i+1) * time.Hour),
+			Open:      price,
+			Close:     price,
+			Low:       price,
+			High:      price,
+			Complete:  true,
+		})
+	}
+	return result
+}
+
+func TestMockExchange_Feeder(t *testing.T) {
+	var _ service.Exchange = &MockExchange{}
+	var _ service.OrderHistory = &MockExchange{}
+
+	ctx := context.Background()
+	m := NewExchange(WithCandles("BTCUSDT", "1h", candles(10, 11, 12)...))
+
+	price, err := m.LastQuote(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 12.0, price)
+	_, err = m.LastQuote(ctx, "ETHUSDT")
+	require.ErrorIs(t, err, exchange.ErrNoMarketData)
+
+	result, err := m.CandlesByLimit(ctx, "BTCUSDT", "1h", 2)
+	require.NoError(t, err)
+	require.Equal(t, candles(10, 11, 12)[1:], result)
+
+	result, err = m.CandlesByPeriod(ctx, "BTCUSDT", "1h", start, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, candles(10, 11), result)
+
+	require.Equal(t, "BTC", m.AssetsInfo("BTCUSDT").BaseAsset)
+
+	timeout := errors.New("timeout")
+	m.SetError("CandlesByLimit", timeout)
+	_, err = m.CandlesByLimit(ctx, "BTCUSDT", "1h", 2)
+	require.ErrorIs(t, err, timeout)
+
+	calls := m.Calls("CandlesByLimit")
+	require.Len(t, calls, 2)
+	require.Equal(t, []interface{}{"BTCUSDT", "1h", 2}, calls[1].Args)
+	require.Len(t, m.Calls(""), 6)
+
+	m.SetError("CandlesSubscription", timeout)
+	ccandle, cerr := m.CandlesSubscription(ctx, "BTCUSDT", "1h")
+	var received []model.Candle
+	for candle := range ccandle {
+		received = append(received, candle)
+	}
+	require.Equal(t, candles(10, 11, 12), received)
+	require.ErrorIs(t, <-cerr, timeout)
+}
+
+func TestMockExchange_Orders(t *testing.T) {
+	m := NewExchange(WithBalance("USDT", 1000), WithQuote("BTCUSDT", 100))
+
+	t.Run("market", func(t *testing.T) {
+		order, err := m.CreateOrderMarketTagged(model.SideTypeBuy, "BTCUSDT", 2, "entry")
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 100.0, order.Price)
+
+		asset, quote, err := m.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+		require.Equal(t, 800.0, quote)
+
+		rejected := errors.New("rejected")
+		m.SetError("CreateOrderMarket", rejected)
+		_, err = m.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.ErrorIs(t, err, rejected)
+		m.SetError("CreateOrderMarket", nil)
+		require.Equal(t, 2.0, m.Balance("BTC"))
+	})
+
+	t.Run("oco", func(t *testing.T) {
+		orders, err := m.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 2, 120, 90, 89)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+
+		filled, err := m.Fill(orders[0].ExchangeID, 0)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, filled.Status)
+		require.Equal(t, 1040.0, m.Balance("USDT"))
+		require.Zero(t, m.Balance("BTC"))
+
+		stop, err := m.Order("BTCUSDT", orders[1].ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, stop.Status)
+		_, err = m.Fill(stop.ExchangeID, 90)
+		require.ErrorIs(t, err, ErrOrderNotFound)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		order, err := m.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 95)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.NoError(t, m.Cancel(order))
+		require.ErrorIs(t, m.Cancel(order), ErrOrderNotFound)
+	})
+
+	orders, err := m.Orders("BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, model.OrderTypeStopLoss, orders[0].Type)
+	require.Equal(t, model.OrderStatusTypeCanceled, orders[1].Status)
+}
+
+func TestMockExchange_PendingMarket(t *testing.T) {
+	m := NewExchange(WithBalance("USDT", 1000), WithQuote("BTCUSDT", 100), WithPendingMarket())
+
+	order, err := m.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeNew, order.Status)
+	require.Equal(t, 1000.0, m.Balance("USDT"))
+
+	order, err = m.Fill(order.ExchangeID, 101)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	require.Equal(t, 899.0, m.Balance("USDT"))
+}
+
+// buyStrategy buys one unit in the first candle after the warmup
+type buyStrategy struct{}
+
+func (b buyStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (b buyStrategy) WarmupPeriod() int {
+	return 2
+}
+
+func (b buyStrategy) Indicators(_ *model.Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (b buyStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	asset, _, err := broker.Position(df.Pair)
+	if err == nil && asset == 0 {
+		_, _ = broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1)
+	}
+}
+
+// TestMockExchange_Strategy feeds the scripted candles of the subscription to a strategy controller
+func TestMockExchange_Strategy(t *testing.T) {
+	m := NewExchange(WithBalance("USDT", 1000), WithCandles("BTCUSDT", "1h", candles(10, 11, 12, 13)...))
+
+	controller := strategy.NewStrategyController("BTCUSDT", buyStrategy{}, m)
+	controller.Start()
+
+	ccandle, _ := m.CandlesSubscription(context.Background(), "BTCUSDT", "1h")
+	for candle := range ccandle {
+		m.SetQuote(candle.Pair, candle.Close)
+		controller.OnCandle(candle)
+	}
+
+	calls := m.Calls("CreateOrderMarket")
+	require.Len(t, calls, 1)
+	require.Equal(t, []interface{}{model.SideTypeBuy, "BTCUSDT", 1.0, ""}, calls[0].Args)
+	require.Equal(t, 1.0, m.Balance("BTC"))
+	require.Equal(t, 989.0, m.Balance("USDT"))
+}
@@ -9,8 +9,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/adshao/go-binance/v2/common"
-
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
@@ -21,11 +19,38 @@ type assetInfo struct {
 	Lock float64
 }
 
+// fundsLock is the amount of asset and quote locked by a pending order (or OCO group)
+type fundsLock struct {
+	asset float64
+	quote float64
+}
+
 type AssetValue struct {
 	Time  time.Time
 	Value float64
 }
 
+// QuoteDeviation compares a simulated fill with the last price of the reference feeder at fill time.
+// A positive deviation means the simulation filled at a better price than the market.
+type QuoteDeviation struct {
+	Order     model.Order
+	Quote     float64
+	Deviation float64
+}
+
+// Trade is the realized result of closing a position, or a part of it, in the paper wallet. Side is the side of
+// the position, buy for longs and sell for shorts, and Quantity is the closed quantity.
+type Trade struct {
+	Pair          string
+	Side          model.SideType
+	EntryPrice    float64
+	ExitPrice     float64
+	Quantity      float64
+	Profit        float64
+	ProfitPercent float64
+	Time          time.Time
+}
+
 type PaperWallet struct {
 	sync.Mutex
 	ctx           context.Context
@@ -33,10 +58,13 @@ type PaperWallet struct {
 	counter       int64
 	takerFee      float64
 	makerFee      float64
+	feeAsset      string
+	feeDiscount   float64
 	initialValue  float64
 	feeder        service.Feeder
 	orders        []model.Order
 	assets        map[string]*assetInfo
+	allocations   map[string]*assetInfo
 	avgShortPrice map[string]float64
 	avgLongPrice  map[string]float64
 	volume        map[string]float64
@@ -44,6 +72,43 @@ type PaperWallet struct {
 	fistCandle    map[string]model.Candle
 	assetValues   map[string][]AssetValue
 	equityValues  []AssetValue
+	onFill        []func(order model.Order)
+	locks         map[int64]fundsLock
+	marketType    model.MarketType
+	contractSize  float64
+	rounding      RoundingMode
+	dustThreshold float64
+	sweptDust     map[string]float64
+	spotOnly      bool
+	precision     PrecisionMode
+	spreadBps     float64
+	rangeSpread   float64
+	impact        float64
+	slippage      float64
+	slippageFunc  SlippageFunc
+	spreadCost    map[string]float64
+	fillModel     string
+	trades        []Trade
+	fillRatio     float64
+	executed      map[int64]float64
+	leverage      map[string]float64
+	maintenance   float64
+	fundingRates  map[string]*paperFunding
+	funding       map[string]float64
+	latency       int
+	elapsed       map[int64]int
+
+	// markSubscribers are the channels of MarkPriceSubscription by pair
+	markSubscribers map[string][]chan float64
+
+	// equity sampling, the last values not sampled are kept to be included at the end of the series
+	equitySampling int
+	equityCandles  int
+	pendingEquity  *AssetValue
+	pendingAssets  map[string]AssetValue
+
+	quoteTolerance  float64
+	quoteDeviations []QuoteDeviation
 }
 
 func (p *PaperWallet) AssetsInfo(pair string) model.AssetInfo {
@@ -60,8 +125,57 @@ func (p *PaperWallet) AssetsInfo(pair string) model.AssetInfo {
 	}
 }
 
+// WalletState is the balance and the average entry prices of a paper wallet, used to continue
+// a simulation in another run, e.g. a live paper trading started from the end of a backtest.
+// Pending orders are not included, the funds locked by them are counted as free balance.
+type WalletState struct {
+	Assets        map[string]float64 `json:"assets"`
+	AvgLongPrice  map[string]float64 `json:"avg_long_price"`
+	AvgShortPrice map[string]float64 `json:"avg_short_price"`
+
+	// Allocations are the quote balances of the pairs with dedicated capital, see WithPaperAllocation
+	Allocations map[string]float64 `json:"allocations,omitempty"`
+
+	// LastOrderID is the ID of the last order of the wallet, the restored wallet continues the sequence
+	LastOrderID int64 `json:"last_order_id,omitempty"`
+}
+
 type PaperWalletOption func(*PaperWallet)
 
+// WithPaperState restores the balances and average prices of a previous run, see PaperWallet.State.
+// Assets set by WithPaperAsset before this option are replaced.
+func WithPaperState(state WalletState) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		for asset, amount := range state.Assets {
+			wallet.assets[asset] = &assetInfo{Free: amount}
+		}
+		for pair, price := range state.AvgLongPrice {
+			wallet.avgLongPrice[pair] = price
+		}
+		for pair, price := range state.AvgShortPrice {
+			wallet.avgShortPrice[pair] = price
+		}
+		for pair, amount := range state.Allocations {
+			wallet.allocations[pair] = &assetInfo{Free: amount}
+		}
+		if state.LastOrderID > wallet.counter {
+			wallet.counter = state.LastOrderID
+		}
+	}
+}
+
+// WithPaperOrderIDStart sets the ID of the first order of the wallet, default is 1. The IDs are sequential,
+// so a run with the same orders has the same IDs. It can be used to give distinct ranges of IDs to wallets
+// that share a storage, e.g. the accounts of `order.Controller.AddAccount`. A restored state with a later
+// ID prevails, see WithPaperState.
+func WithPaperOrderIDStart(id int64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		if id-1 > wallet.counter {
+			wallet.counter = id - 1
+		}
+	}
+}
+
 func WithPaperAsset(pair string, amount float64) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.assets[pair] = &assetInfo{
@@ -71,6 +185,20 @@ func WithPaperAsset(pair string, amount float64) PaperWalletOption {
 	}
 }
 
+// WithPaperAllocation isolates the capital of a pair in a dedicated balance of its quote, e.g. 1000 USDT for
+// BTCUSDT, so the losses of a pair do not reduce the funds of the others. The orders of the pair are validated
+// and settled only with its allocation, the pairs without allocation share the balance of WithPaperAsset.
+// The account reports the quote balance combined with the allocations, and the position of an allocated pair
+// reports its allocation as quote.
+func WithPaperAllocation(pair string, amount float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.allocations[strings.ToUpper(pair)] = &assetInfo{Free: amount}
+	}
+}
+
+// WithPaperFee sets the fee rate charged on each fill (e.g. 0.001 for 0.1%), the maker fee is used by
+// limit orders waiting in the book and the taker fee by market, stop and immediate limit orders.
+// By default, the fee is deducted from the quote balance, see WithPaperFeeAsset.
 func WithPaperFee(maker, taker float64) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.makerFee = maker
@@ -78,18 +206,195 @@ func WithPaperFee(maker, taker float64) PaperWalletOption {
 	}
 }
 
+// WithPaperFeeAsset pays the fees with another asset, e.g. BNB on Binance, at its last price with a discount
+// (e.g. 0.25 for 25%). The price is the close of the asset pair with the quote (e.g. BNBUSDT), so the pair must
+// be fed to the wallet. If the asset balance is not enough or the price is unknown, the fee is paid in quote.
+func WithPaperFeeAsset(asset string, discount float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.feeAsset = strings.ToUpper(asset)
+		wallet.feeDiscount = discount
+	}
+}
+
+// WithPaperMarketType sets the accounting model used to report the profit of the fills, default is spot.
+// The contract size is only used by futures markets.
+func WithPaperMarketType(marketType model.MarketType, contractSize float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.marketType = marketType
+		wallet.contractSize = contractSize
+	}
+}
+
+// WithPaperRounding sets how the quantity of quote orders is adjusted to the step size, default is RoundingFloor
+func WithPaperRounding(mode RoundingMode) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.rounding = mode
+	}
+}
+
+// WithPaperDustThreshold sets the value in quote below which the remaining position of a pair is considered dust.
+// After a fill that reduces a position, the dust left is closed at the last price and recorded as a trade, so it
+// does not remain as an untradable position.
+func WithPaperDustThreshold(threshold float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.dustThreshold = threshold
+	}
+}
+
+// WithPaperEquitySampling records the equity and asset values every N complete candles, instead of every
+// candle, to reduce the memory usage of long backtests. The last values are always included in the series.
+// The drawdown and the equity chart are calculated from the sampled series, so a drawdown that starts and
+// recovers between two samples is not detected and the reported max drawdown may be lower than the real one.
+func WithPaperEquitySampling(every int) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.equitySampling = every
+	}
+}
+
+// WithPaperSpotOnly rejects sell orders above the free asset balance with ErrShortingNotAllowed,
+// instead of opening a short position. Selling the whole position is still allowed.
+func WithPaperSpotOnly() PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.spotOnly = true
+	}
+}
+
+// WithPaperSpread fills market orders at the bid or ask price instead of the close, with a fixed spread in
+// basis points of the close, e.g. 10 for 0.1%. Buys fill at close+spread/2 and sells at close-spread/2.
+// The spread cost is reported separately in the summary, see SpreadCost.
+func WithPaperSpread(bps float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.spreadBps = bps
+	}
+}
+
+// WithPaperRangeSpread estimates the spread of market orders as a fraction of the high-low range of the last
+// candle, e.g. 0.1 for 10% of the range, so the spread widens in volatile candles. When used with
+// WithPaperSpread, the greater spread is applied.
+func WithPaperRangeSpread(fraction float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.rangeSpread = fraction
+	}
+}
+
+// WithPaperImpactModel adds the market impact of the size of market orders to the fill price, with the
+// square-root model: the price moves by coefficient * sqrt(size / volume) of the reference price, where
+// volume is the volume of the last candle, e.g. a coefficient of 0.1 costs 1% for an order of 1% of the volume.
+// The impact is added to the spread, see WithPaperSpread, and reported with the spread cost. Candles without
+// volume have no impact. The quantities of BuyPercent and CreateOrderMarketQuote are estimated without it.
+func WithPaperImpactModel(coefficient float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.impact = coefficient
+	}
+}
+
+// WithPaperFillRatio limits the quantity of limit orders filled in each candle to a fraction of the candle volume,
+// e.g. 0.1 for 10%, the remainder of the order stays open, partially filled, for the next candles. Only the funds
+// of the filled quantity are consumed and the remainder can be canceled, IOC orders are filled with the available
+// quantity only. Stop and OCO orders and candles without volume are filled completely. By default, limit orders are
+// filled completely when the price is reached.
+func WithPaperFillRatio(maxCandleFraction float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.fillRatio = maxCandleFraction
+	}
+}
+
+// SlippageFunc returns the slippage of a market order in basis points of the reference price, from the last
+// candle of the pair and the size of the order, see WithPaperSlippageFunc
+type SlippageFunc func(candle model.Candle, size float64) float64
+
+// WithPaperSlippage penalizes the fill price of market orders by a fixed slippage in basis points of the
+// reference price, e.g. 5 for 0.05%: buys fill at close*(1+bps/10000) and sells at close*(1-bps/10000). Unlike
+// WithPaperSpread, the whole slippage is applied to each side. It is added to the spread and the market impact,
+// and reported with the spread cost. The quantities of BuyPercent and CreateOrderMarketQuote are estimated
+// without it.
+func WithPaperSlippage(bps float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.slippage = bps
+	}
+}
+
+// WithPaperSlippageFunc adds a slippage that depends on the order, e.g. on the size of the order relative to the
+// volume of the candle. The slippage of the function is added to WithPaperSlippage.
+func WithPaperSlippageFunc(fn SlippageFunc) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.slippageFunc = fn
+	}
+}
+
+// Fill models of market orders, see WithPaperFillModel
+const (
+	// FillClose fills at the close of the last candle, the default
+	FillClose = "close"
+	// FillNextOpen fills at the open of the next candle, the order stays pending until it is received
+	FillNextOpen = "next_open"
+	// FillMid fills at the middle of the high-low range of the last candle
+	FillMid = "mid"
+	// FillHighLow fills buys at the high and sells at the low of the last candle, the worst price of the candle
+	FillHighLow = "high_low"
+	// FillVWAP fills at the typical price of the last candle, (high+low+close)/3, an estimate of its VWAP
+	FillVWAP = "vwap"
+)
+
+// WithPaperLatency delays the execution of market orders by a number of candles of the pair, to measure the
+// sensitivity of a strategy to the execution delay. The orders are accepted pending, with the funds locked, and
+// filled at the open of the candle after the latency, e.g. 1 is the next candle, as FillNextOpen. The orders
+// still pending at the end are reported as unfilled in the summary.
+func WithPaperLatency(candles int) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.latency = candles
+	}
+}
+
+// WithPaperFillModel sets the reference price of market orders, default is FillClose. Filling at the close of
+// the candle that generated the signal is a lookahead bias, since the strategy only sees the close when the
+// candle is complete. FillNextOpen avoids it: market orders are accepted with the funds locked and are filled
+// at the open of the next candle of the pair. The spread of WithPaperSpread is applied over the reference.
+func WithPaperFillModel(fillModel string) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		switch fillModel {
+		case FillClose, FillNextOpen, FillMid, FillHighLow, FillVWAP:
+			wallet.fillModel = fillModel
+		default:
+			log.Errorf("paperwallet: invalid fill model %q, using %q", fillModel, FillClose)
+			wallet.fillModel = FillClose
+		}
+	}
+}
+
 func WithDataFeed(feeder service.Feeder) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.feeder = feeder
 	}
 }
 
-func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWalletOption) *PaperWallet {
-	wallet := PaperWallet{
+// WithPaperOnFill registers a callback executed for each simulated fill, e.g. to write in a custom ledger.
+// Callbacks run synchronously after the wallet lock is released, in the same goroutine of the fill,
+// so they may query the wallet (e.g. Position), but a slow callback delays the backtest.
+func WithPaperOnFill(callback func(order model.Order)) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.onFill = append(wallet.onFill, callback)
+	}
+}
+
+// WithPaperQuoteValidator compares each simulated fill with the last quote of the attached data feed
+// (see WithDataFeed), to measure how optimistic the simulation is with live data. Fills with a deviation
+// above the tolerance (e.g. 0.001 for 0.1%) are logged as warnings. It is not suitable for backtesting,
+// since CSV feeds do not provide quotes.
+func WithPaperQuoteValidator(tolerance float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.quoteTolerance = tolerance
+		wallet.onFill = append(wallet.onFill, wallet.validateQuote)
+	}
+}
+
+func newPaperWallet(ctx context.Context, baseCoin string) *PaperWallet {
+	return &PaperWallet{
 		ctx:           ctx,
 		baseCoin:      baseCoin,
 		orders:        make([]model.Order, 0),
 		assets:        make(map[string]*assetInfo),
+		allocations:   make(map[string]*assetInfo),
 		fistCandle:    make(map[string]model.Candle),
 		lastCandle:    make(map[string]model.Candle),
 		avgShortPrice: make(map[string]float64),
@@ -97,19 +402,67 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 		volume:        make(map[string]float64),
 		assetValues:   make(map[string][]AssetValue),
 		equityValues:  make([]AssetValue, 0),
+		locks:         make(map[int64]fundsLock),
+		sweptDust:     make(map[string]float64),
+		spreadCost:    make(map[string]float64),
+		marketType:    model.MarketTypeSpot,
+		contractSize:  1,
 	}
+}
 
+func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWalletOption) *PaperWallet {
+	wallet := newPaperWallet(ctx, baseCoin)
 	for _, option := range options {
-		option(&wallet)
+		option(wallet)
+	}
+
+	if _, ok := wallet.assets[wallet.baseCoin]; !ok {
+		wallet.assets[wallet.baseCoin] = &assetInfo{}
 	}
 
-	wallet.initialValue = wallet.assets[wallet.baseCoin].Free
+	wallet.initialValue = wallet.baseCoinBalance()
 	log.Info("[SETUP] Using paper wallet")
 	log.Infof("[SETUP] Initial Portfolio = %f %s", wallet.initialValue, wallet.baseCoin)
 
-	return &wallet
+	return wallet
+}
+
+// SimulateOrderMarket fills a market order at the given price in a temporary paper wallet seeded with the
+// account balances, returning the filled order and the wallet after the fill. The account is not modified.
+func SimulateOrderMarket(ctx context.Context, account model.Account, side model.SideType, pair string,
+	size, price float64) (model.Order, *PaperWallet, error) {
+
+	_, quote := SplitAssetQuote(pair)
+	wallet := newPaperWallet(ctx, quote)
+
+	for _, balance := range account.Balances {
+		wallet.assets[balance.Asset] = &assetInfo{
+			Free: balance.Free,
+			Lock: balance.Lock,
+		}
+	}
+
+	now := time.Now()
+	wallet.lastCandle[pair] = model.Candle{
+		Pair:      pair,
+		Time:      now,
+		UpdatedAt: now,
+		Open:      price,
+		Close:     price,
+		Low:       price,
+		High:      price,
+	}
+
+	order, err := wallet.createOrderMarket(side, pair, size, "")
+	if err != nil {
+		return model.Order{}, nil, err
+	}
+
+	return order, wallet, nil
 }
 
+// ID returns the next order ID of the sequence of the wallet. IDs are only unique within a wallet instance
+// and the wallets restored from its state, see WalletState.LastOrderID and WithPaperOrderIDStart.
 func (p *PaperWallet) ID() int64 {
 	p.counter++
 	return p.counter
@@ -127,115 +480,640 @@ func (p *PaperWallet) LastQuote(ctx context.Context, pair string) (float64, erro
 	return p.feeder.LastQuote(ctx, pair)
 }
 
+// BestBidAsk synthesizes the best prices from the last candle, the paper wallet has no order book. The bid and
+// ask are the prices of sell and buy market orders without size, the reference of the fill model with the spread,
+// see WithPaperSpread and WithPaperFillModel. Without spread, both are the reference price.
+func (p *PaperWallet) BestBidAsk(_ context.Context, pair string) (bid, ask float64, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.validateMarketData(pair); err != nil {
+		return 0, 0, err
+	}
+
+	return p.marketPrice(model.SideTypeSell, pair), p.marketPrice(model.SideTypeBuy, pair), nil
+}
+
+// MarkPrice returns the mark price of a pair, the paper wallet has no index, so it is the close of the last
+// candle. It is the reference for the liquidation of futures positions, see MarkPriceSubscription.
+func (p *PaperWallet) MarkPrice(pair string) (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.validateMarketData(pair); err != nil {
+		return 0, err
+	}
+	return p.lastCandle[pair].Close, nil
+}
+
+// MarkPriceSubscription streams the mark price of a pair, the close of each candle received by the wallet, see
+// MarkPrice. A slow reader receives the latest price only, the candles are not blocked by the subscription.
+func (p *PaperWallet) MarkPriceSubscription(ctx context.Context, pair string) (chan float64, chan error) {
+	cprice := make(chan float64, 1)
+	cerr := make(chan error)
+
+	p.Lock()
+	if p.markSubscribers == nil {
+		p.markSubscribers = make(map[string][]chan float64)
+	}
+	p.markSubscribers[pair] = append(p.markSubscribers[pair], cprice)
+	p.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		p.Lock()
+		defer p.Unlock()
+		subscribers := p.markSubscribers[pair]
+		for i, subscriber := range subscribers {
+			if subscriber == cprice {
+				p.markSubscribers[pair] = append(subscribers[:i:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		close(cprice)
+		close(cerr)
+	}()
+
+	return cprice, cerr
+}
+
+// publishMarkPrice sends the mark price of a candle to the subscriptions, replacing a price not read yet.
+// The wallet lock must be held.
+func (p *PaperWallet) publishMarkPrice(candle model.Candle) {
+	for _, subscriber := range p.markSubscribers[candle.Pair] {
+		select {
+		case <-subscriber:
+		default:
+		}
+		subscriber <- candle.Close
+	}
+}
+
 func (p *PaperWallet) AssetValues(pair string) []AssetValue {
+	if pending, ok := p.pendingAssets[pair]; ok {
+		return append(p.assetValues[pair][:len(p.assetValues[pair]):len(p.assetValues[pair])], pending)
+	}
 	return p.assetValues[pair]
 }
 
 func (p *PaperWallet) EquityValues() []AssetValue {
+	if p.pendingEquity != nil {
+		return append(p.equityValues[:len(p.equityValues):len(p.equityValues)], *p.pendingEquity)
+	}
 	return p.equityValues
 }
 
+// SweptDust returns the total asset quantity swept as dust by pair, see WithPaperDustThreshold
+func (p *PaperWallet) SweptDust() map[string]float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	swept := make(map[string]float64, len(p.sweptDust))
+	for pair, quantity := range p.sweptDust {
+		swept[pair] = quantity
+	}
+	return swept
+}
+
+// SpreadCost returns the cost of the spread paid by market orders of each pair, in quote, see WithPaperSpread.
+// It includes the market impact of WithPaperImpactModel.
+func (p *PaperWallet) SpreadCost() map[string]float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	cost := make(map[string]float64, len(p.spreadCost))
+	for pair, value := range p.spreadCost {
+		cost[pair] = value
+	}
+	return cost
+}
+
+// QuoteDeviations returns the fills checked by the quote validator, see WithPaperQuoteValidator
+func (p *PaperWallet) QuoteDeviations() []QuoteDeviation {
+	p.Lock()
+	defer p.Unlock()
+	return append([]QuoteDeviation(nil), p.quoteDeviations...)
+}
+
+// Trades returns the closed trades of a pair in the order of execution, or of all pairs if the pair is empty.
+// Partial closes are recorded with the closed quantity, and an order that flips the position closes the
+// previous position only.
+func (p *PaperWallet) Trades(pair string) []Trade {
+	p.Lock()
+	defer p.Unlock()
+
+	trades := make([]Trade, 0)
+	for _, trade := range p.trades {
+		if pair == "" || trade.Pair == pair {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+func (p *PaperWallet) validateQuote(order model.Order) {
+	if p.feeder == nil {
+		log.Warn("paperwallet/validateQuote: data feed not defined")
+		return
+	}
+
+	quote, err := p.feeder.LastQuote(p.ctx, order.Pair)
+	if err != nil {
+		log.Error("paperwallet/validateQuote: ", err)
+		return
+	}
+
+	if quote == 0 {
+		return
+	}
+
+	deviation := (order.Price - quote) / quote
+	if order.Side == model.SideTypeBuy {
+		deviation = -deviation
+	}
+
+	if math.Abs(deviation) > p.quoteTolerance {
+		log.Warnf("[VALIDATOR] %s %s filled at %f, market quote %f (%.3f%%)", order.Side, order.Pair,
+			order.Price, quote, deviation*100)
+	}
+
+	p.Lock()
+	p.quoteDeviations = append(p.quoteDeviations, QuoteDeviation{
+		Order:     order,
+		Quote:     quote,
+		Deviation: deviation,
+	})
+	p.Unlock()
+}
+
+// DrawdownSeries returns the drawdown at each equity point, relative to the running peak of the equity,
+// e.g. -0.1 for an equity 10% below the peak. It is 0 while the equity is at a new peak.
+func (p *PaperWallet) DrawdownSeries() []AssetValue {
+	equityValues := p.EquityValues()
+	series := make([]AssetValue, 0, len(equityValues))
+
+	peak := 0.0
+	for _, equity := range equityValues {
+		if equity.Value > peak {
+			peak = equity.Value
+		}
+
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = (equity.Value - peak) / peak
+		}
+
+		series = append(series, AssetValue{
+			Time:  equity.Time,
+			Value: drawdown,
+		})
+	}
+
+	return series
+}
+
+// MaxDrawdown returns the largest decline of the equity from a running peak to a later trough, as a negative
+// fraction of the peak, e.g. -0.1 for 10%, and the times of the peak and the trough. It is zero if the equity
+// never declines.
 func (p *PaperWallet) MaxDrawdown() (float64, time.Time, time.Time) {
-	if len(p.equityValues) < 1 {
+	equityValues := p.EquityValues()
+	if len(equityValues) < 1 {
 		return 0, time.Time{}, time.Time{}
 	}
 
-	localMin := math.MaxFloat64
-	localMinBase := p.equityValues[0].Value
-	localMinStart := p.equityValues[0].Time
-	localMinEnd := p.equityValues[0].Time
+	peak := equityValues[0]
+	maxDrawdown, peakTime, troughTime := 0.0, peak.Time, peak.Time
+	for _, equity := range equityValues[1:] {
+		if equity.Value > peak.Value {
+			peak = equity
+			continue
+		}
+
+		if peak.Value <= 0 {
+			continue
+		}
+
+		drawdown := (equity.Value - peak.Value) / peak.Value
+		if drawdown < maxDrawdown {
+			maxDrawdown, peakTime, troughTime = drawdown, peak.Time, equity.Time
+		}
+	}
+
+	return maxDrawdown, peakTime, troughTime
+}
 
-	globalMin := localMin
-	globalMinBase := localMinBase
-	globalMinStart := localMinStart
-	globalMinEnd := localMinEnd
+// State returns the balances, average prices and last order ID of the wallet, to be restored with WithPaperState
+func (p *PaperWallet) State() WalletState {
+	p.Lock()
+	defer p.Unlock()
 
-	for i := 1; i < len(p.equityValues); i++ {
-		diff := p.equityValues[i].Value - p.equityValues[i-1].Value
+	state := WalletState{
+		Assets:        make(map[string]float64, len(p.assets)),
+		AvgLongPrice:  make(map[string]float64, len(p.avgLongPrice)),
+		AvgShortPrice: make(map[string]float64, len(p.avgShortPrice)),
+	}
 
-		if localMin > 0 {
-			localMin = diff
-			localMinBase = p.equityValues[i-1].Value
-			localMinStart = p.equityValues[i-1].Time
-			localMinEnd = p.equityValues[i].Time
-		} else {
-			localMin += diff
-			localMinEnd = p.equityValues[i].Time
+	for asset, info := range p.assets {
+		state.Assets[asset] = info.Free + info.Lock
+	}
+	for pair, price := range p.avgLongPrice {
+		state.AvgLongPrice[pair] = price
+	}
+	for pair, price := range p.avgShortPrice {
+		state.AvgShortPrice[pair] = price
+	}
+	if len(p.allocations) > 0 {
+		state.Allocations = make(map[string]float64, len(p.allocations))
+		for pair, info := range p.allocations {
+			state.Allocations[pair] = info.Free + info.Lock
+		}
+	}
+	state.LastOrderID = p.counter
+
+	return state
+}
+
+// WalletResult is the performance of the paper wallet in a backtest, the values are in the base coin
+type WalletResult struct {
+	InitialValue  float64
+	FinalValue    float64
+	Profit        float64
+	ProfitPercent float64
+	// MarketChange is the average change of the pairs from the first to the last candle, buy and hold
+	MarketChange float64
+	// MaxDrawdown is the largest decline of the equity, e.g. -0.1 for 10%, see MaxDrawdown
+	MaxDrawdown float64
+	// AnnualSharpe and AnnualSortino are the annualized ratios without risk-free rate, see Sharpe and Sortino
+	AnnualSharpe  float64
+	AnnualSortino float64
+	Volume        map[string]float64
+	// RealizedProfit is the profit of the closed trades of each pair in its quote, see Trades
+	RealizedProfit map[string]float64
+	// Unfilled are the market orders still pending, e.g. by the latency of WithPaperLatency
+	Unfilled []model.Order
+
+	// Positions are the positions of each pair at the last candle and BaseCoinBalance is the balance of the
+	// base coin, the final wallet of Summary
+	Positions       map[string]WalletPosition
+	BaseCoinBalance float64
+	// Allocations are the quote balances of the pairs with dedicated capital, see WithPaperAllocation
+	Allocations map[string]float64
+	// Trades is the number of closed trades of each pair, see Trades
+	Trades map[string]int
+	// SpreadCost is the cost of the spread and slippage of each pair, see SpreadCost
+	SpreadCost map[string]float64
+	// Funding is the net funding of each pair, see Funding
+	Funding map[string]float64
+	// QuoteDeviations are the fills checked by the quote validator, see WithPaperQuoteValidator
+	QuoteDeviations []QuoteDeviation
+}
+
+// WalletPosition is the quantity of the asset of a pair and its value at the last candle
+type WalletPosition struct {
+	Quantity float64
+	Value    float64
+}
+
+// positionValue returns the quantity of the asset of a pair and its value at the last candle
+func (p *PaperWallet) positionValue(pair string) (quantity, value float64) {
+	asset, _ := SplitAssetQuote(pair)
+	quantity = p.assets[asset].Free + p.assets[asset].Lock
+	value = quantity * p.lastCandle[pair].Close
+	if p.leveraged(pair) {
+		return quantity, p.marginEquity(pair, p.lastCandle[pair].Close)
+	}
+	if quantity < 0 {
+		totalShort := 2.0*p.avgShortPrice[pair]*quantity - p.lastCandle[pair].Close*quantity
+		value = math.Abs(totalShort)
+	}
+	return quantity, value
+}
+
+// Result returns the performance of the wallet without printing, the programmatic counterpart of Summary
+func (p *PaperWallet) Result() WalletResult {
+	p.Lock()
+	defer p.Unlock()
+
+	result := WalletResult{
+		InitialValue:    p.initialValue,
+		BaseCoinBalance: p.baseCoinBalance(),
+		Volume:          make(map[string]float64, len(p.volume)),
+		RealizedProfit:  make(map[string]float64),
+		Positions:       make(map[string]WalletPosition, len(p.lastCandle)),
+		Allocations:     make(map[string]float64, len(p.allocations)),
+		Trades:          make(map[string]int),
+		SpreadCost:      make(map[string]float64, len(p.spreadCost)),
+		Funding:         make(map[string]float64, len(p.funding)),
+		QuoteDeviations: append([]QuoteDeviation(nil), p.quoteDeviations...),
+	}
+
+	var total, marketChange float64
+	for pair := range p.lastCandle {
+		quantity, value := p.positionValue(pair)
+		result.Positions[pair] = WalletPosition{Quantity: quantity, Value: value}
+		total += value
+		marketChange += (p.lastCandle[pair].Close - p.fistCandle[pair].Close) / p.fistCandle[pair].Close
+	}
+
+	result.FinalValue = total + result.BaseCoinBalance
+	result.Profit = result.FinalValue - p.initialValue
+	if p.initialValue > 0 {
+		result.ProfitPercent = result.Profit / p.initialValue
+	}
+	if len(p.lastCandle) > 0 {
+		result.MarketChange = marketChange / float64(len(p.lastCandle))
+	}
+	result.MaxDrawdown, _, _ = p.MaxDrawdown()
+	result.AnnualSharpe = p.Sharpe(0)
+	result.AnnualSortino = p.Sortino(0)
+	for pair, volume := range p.volume {
+		result.Volume[pair] = volume
+	}
+	for _, trade := range p.trades {
+		result.RealizedProfit[trade.Pair] += trade.Profit
+		result.Trades[trade.Pair]++
+	}
+	for pair, info := range p.allocations {
+		result.Allocations[pair] = info.Free + info.Lock
+	}
+	for pair, cost := range p.spreadCost {
+		result.SpreadCost[pair] = cost
+	}
+	for pair, funding := range p.funding {
+		result.Funding[pair] = funding
+	}
+	for _, order := range p.orders {
+		if order.Type == model.OrderTypeMarket && order.Status == model.OrderStatusTypeNew {
+			result.Unfilled = append(result.Unfilled, order)
 		}
+	}
+
+	return result
+}
+
+// equityReturns returns the returns between consecutive equity values
+func equityReturns(equityValues []AssetValue) []float64 {
+	returns := make([]float64, 0, len(equityValues))
+	for i := 1; i < len(equityValues); i++ {
+		if equityValues[i-1].Value == 0 {
+			continue
+		}
+		returns = append(returns, equityValues[i].Value/equityValues[i-1].Value-1)
+	}
+	return returns
+}
+
+// meanStdDev returns the mean and the sample standard deviation of the values
+func meanStdDev(values []float64) (mean, stdDev float64) {
+	var variance float64
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
+
+// equityPeriodsPerYear returns the number of equity values in a year, by the mean interval between them: the
+// timeframe of the candles, multiplied by the equity sampling
+func equityPeriodsPerYear(equityValues []AssetValue) float64 {
+	if len(equityValues) < 2 {
+		return 0
+	}
+
+	first, last := equityValues[0].Time, equityValues[len(equityValues)-1].Time
+	interval := last.Sub(first) / time.Duration(len(equityValues)-1)
+	if interval <= 0 {
+		return 0
+	}
+	return float64(365*24*time.Hour) / float64(interval)
+}
+
+// Sharpe returns the annualized Sharpe ratio of the equity: the mean return per period in excess of the
+// risk-free rate, divided by the standard deviation of the returns and multiplied by the square root of the
+// periods in a year. The risk-free rate is annual, e.g. 0.04 for 4%, and the period is the interval between the
+// equity values. It returns zero with fewer than two equity values.
+func (p *PaperWallet) Sharpe(riskFreeRate float64) float64 {
+	equityValues := p.EquityValues()
+	periods := equityPeriodsPerYear(equityValues)
+	returns := equityReturns(equityValues)
+	if periods == 0 || len(returns) < 2 {
+		return 0
+	}
+
+	mean, stdDev := meanStdDev(returns)
+	if stdDev == 0 {
+		return 0
+	}
+
+	return (mean - riskFreeRate/periods) / stdDev * math.Sqrt(periods)
+}
 
-		if localMin < globalMin {
-			globalMin = localMin
-			globalMinBase = localMinBase
-			globalMinStart = localMinStart
-			globalMinEnd = localMinEnd
+// Sortino returns the annualized Sortino ratio of the equity, as Sharpe but divided by the downside deviation:
+// only the returns below the risk-free rate are penalized. It returns zero with fewer than two equity values or
+// without returns below the risk-free rate.
+func (p *PaperWallet) Sortino(riskFreeRate float64) float64 {
+	equityValues := p.EquityValues()
+	periods := equityPeriodsPerYear(equityValues)
+	returns := equityReturns(equityValues)
+	if periods == 0 || len(returns) < 2 {
+		return 0
+	}
+
+	riskFree := riskFreeRate / periods
+	var mean, downside float64
+	for _, value := range returns {
+		mean += value
+		if value < riskFree {
+			downside += (value - riskFree) * (value - riskFree)
 		}
 	}
+	mean /= float64(len(returns))
+	downside = math.Sqrt(downside / float64(len(returns)))
+	if downside == 0 {
+		return 0
+	}
 
-	return globalMin / globalMinBase, globalMinStart, globalMinEnd
+	return (mean - riskFree) / downside * math.Sqrt(periods)
 }
 
+// Summary prints the performance of the wallet, from a single snapshot of Result
 func (p *PaperWallet) Summary() {
-	var (
-		total        float64
-		marketChange float64
-		volume       float64
-	)
+	var volume float64
 
+	result := p.Result()
 	fmt.Println("-- FINAL WALLET --")
-	for pair := range p.lastCandle {
+	for pair, position := range result.Positions {
 		asset, quote := SplitAssetQuote(pair)
-		quantity := p.assets[asset].Free + p.assets[asset].Lock
-		value := quantity * p.lastCandle[pair].Close
-		if quantity < 0 {
-			totalShort := 2.0*p.avgShortPrice[pair]*quantity - p.lastCandle[pair].Close*quantity
-			value = math.Abs(totalShort)
-		}
-		total += value
-		marketChange += (p.lastCandle[pair].Close - p.fistCandle[pair].Close) / p.fistCandle[pair].Close
-		fmt.Printf("%.4f %s = %.4f %s\n", quantity, asset, total, quote)
+		fmt.Printf("%.4f %s = %.4f %s\n", position.Quantity, asset, position.Value, quote)
 	}
 
-	avgMarketChange := marketChange / float64(len(p.lastCandle))
-	baseCoinValue := p.assets[p.baseCoin].Free + p.assets[p.baseCoin].Lock
-	profit := total + baseCoinValue - p.initialValue
-	fmt.Printf("%.4f %s\n", baseCoinValue, p.baseCoin)
+	fmt.Printf("%.4f %s\n", result.BaseCoinBalance, p.baseCoin)
+	for pair, allocation := range result.Allocations {
+		_, quote := SplitAssetQuote(pair)
+		fmt.Printf("%s allocation = %.4f %s\n", pair, allocation, quote)
+	}
 	fmt.Println()
-	maxDrawDown, _, _ := p.MaxDrawdown()
 	fmt.Println("----- RETURNS -----")
-	fmt.Printf("START PORTFOLIO     = %.2f %s\n", p.initialValue, p.baseCoin)
-	fmt.Printf("FINAL PORTFOLIO     = %.2f %s\n", total+baseCoinValue, p.baseCoin)
-	fmt.Printf("GROSS PROFIT        =  %f %s (%.2f%%)\n", profit, p.baseCoin, profit/p.initialValue*100)
-	fmt.Printf("MARKET CHANGE (B&H) =  %.2f%%\n", avgMarketChange*100)
+	fmt.Printf("START PORTFOLIO     = %s\n", model.FormatValue(result.InitialValue, 2, p.baseCoin))
+	fmt.Printf("FINAL PORTFOLIO     = %s\n", model.FormatValue(result.FinalValue, 2, p.baseCoin))
+	fmt.Printf("GROSS PROFIT        =  %s (%s)\n", model.FormatValue(result.Profit, 6, p.baseCoin),
+		model.FormatPercent(result.ProfitPercent*100, 2))
+	fmt.Printf("MARKET CHANGE (B&H) =  %s\n", model.FormatPercent(result.MarketChange*100, 2))
 	fmt.Println()
 	fmt.Println("------ RISK -------")
-	fmt.Printf("MAX DRAWDOWN = %.2f %%\n", maxDrawDown*100)
+	fmt.Printf("MAX DRAWDOWN = %s\n", model.FormatPercent(result.MaxDrawdown*100, 2))
+	fmt.Printf("SHARPE/YEAR  = %.3f\n", result.AnnualSharpe)
+	fmt.Printf("SORTINO/YEAR = %.3f\n", result.AnnualSortino)
 	fmt.Println()
 	fmt.Println("------ VOLUME -----")
-	for pair, vol := range p.volume {
+	for pair, vol := range result.Volume {
 		volume += vol
 		fmt.Printf("%s         = %.2f %s\n", pair, vol, p.baseCoin)
 	}
 	fmt.Printf("TOTAL           = %.2f %s\n", volume, p.baseCoin)
 	fmt.Println("-------------------")
+
+	if len(result.Trades) > 0 {
+		fmt.Println()
+		fmt.Println("----- REALIZED ----")
+		for pair, profit := range result.RealizedProfit {
+			_, quote := SplitAssetQuote(pair)
+			fmt.Printf("%s         = %s (%d trades)\n", pair, model.FormatValue(profit, 4, quote), result.Trades[pair])
+		}
+		fmt.Println("-------------------")
+	}
+
+	if len(result.SpreadCost) > 0 {
+		var spreadCost float64
+		fmt.Println()
+		fmt.Println("------ SPREAD -----")
+		for pair, cost := range result.SpreadCost {
+			spreadCost += cost
+			fmt.Printf("%s         = %.2f %s\n", pair, cost, p.baseCoin)
+		}
+		fmt.Printf("TOTAL           = %.2f %s\n", spreadCost, p.baseCoin)
+		fmt.Println("-------------------")
+	}
+
+	if len(result.Unfilled) > 0 {
+		fmt.Println()
+		fmt.Println("----- UNFILLED ----")
+		for _, order := range result.Unfilled {
+			fmt.Printf("%s %s %.4f created at %s\n", order.Side, order.Pair, order.Quantity,
+				order.CreatedAt.Format(time.RFC3339))
+		}
+		fmt.Printf("TOTAL           = %d\n", len(result.Unfilled))
+		fmt.Println("-------------------")
+	}
+
+	if len(result.Funding) > 0 {
+		fmt.Println()
+		fmt.Println("----- FUNDING -----")
+		for pair, funding := range result.Funding {
+			_, quote := SplitAssetQuote(pair)
+			fmt.Printf("%s         = %s\n", pair, model.FormatValue(funding, 4, quote))
+		}
+		fmt.Println("-------------------")
+	}
+
+	if len(result.QuoteDeviations) > 0 {
+		var (
+			totalDeviation float64
+			maxDeviation   = result.QuoteDeviations[0].Deviation
+			outOfTolerance int
+		)
+		for _, quoteDeviation := range result.QuoteDeviations {
+			totalDeviation += quoteDeviation.Deviation
+			maxDeviation = math.Max(maxDeviation, quoteDeviation.Deviation)
+			if math.Abs(quoteDeviation.Deviation) > p.quoteTolerance {
+				outOfTolerance++
+			}
+		}
+
+		fmt.Println()
+		fmt.Println("---- LIVE QUOTE ---")
+		fmt.Printf("AVG DEVIATION    = %.3f %%\n", totalDeviation/float64(len(result.QuoteDeviations))*100)
+		fmt.Printf("MAX DEVIATION    = %.3f %%\n", maxDeviation*100)
+		fmt.Printf("OUT OF TOLERANCE = %d / %d\n", outOfTolerance, len(result.QuoteDeviations))
+		fmt.Println("-------------------")
+	}
 }
 
-func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value float64, fill bool) error {
-	asset, quote := SplitAssetQuote(pair)
-	if _, ok := p.assets[asset]; !ok {
-		p.assets[asset] = &assetInfo{}
+// WithPaperPrecision sets the arithmetic of balances and average prices, default is PrecisionFloat.
+// PrecisionDecimal avoids the accumulation of rounding errors in long backtests, at the cost of speed.
+func WithPaperPrecision(mode PrecisionMode) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.precision = mode
+	}
+}
+
+// validateMarketData ensures a candle was received for the pair, it is the reference for price and time of orders
+func (p *PaperWallet) validateMarketData(pair string) error {
+	if _, ok := p.lastCandle[pair]; !ok {
+		return fmt.Errorf("%w: %s", ErrNoMarketData, pair)
+	}
+	return nil
+}
+
+// quoteBalance returns the balance of quote used by the orders of the pair, its allocation or the shared
+// balance of the quote, see WithPaperAllocation
+func (p *PaperWallet) quoteBalance(pair string) *assetInfo {
+	if info, ok := p.allocations[pair]; ok {
+		return info
 	}
 
+	_, quote := SplitAssetQuote(pair)
 	if _, ok := p.assets[quote]; !ok {
 		p.assets[quote] = &assetInfo{}
 	}
+	return p.assets[quote]
+}
+
+// baseCoinBalance returns the total balance of the base coin, including the allocations of pairs quoted in it
+func (p *PaperWallet) baseCoinBalance() float64 {
+	var total float64
+	if info, ok := p.assets[p.baseCoin]; ok {
+		total = info.Free + info.Lock
+	}
+
+	for pair, info := range p.allocations {
+		if _, quote := SplitAssetQuote(pair); quote == p.baseCoin {
+			total += info.Free + info.Lock
+		}
+	}
+	return total
+}
+
+func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value float64, fill bool) error {
+	if p.leveraged(pair) {
+		return p.validateMargin(side, pair, amount, value, fill)
+	}
+
+	asset, _ := SplitAssetQuote(pair)
+	if _, ok := p.assets[asset]; !ok {
+		p.assets[asset] = &assetInfo{}
+	}
 
-	funds := p.assets[quote].Free
+	quoteInfo := p.quoteBalance(pair)
+	funds := quoteInfo.Free
 	if side == model.SideTypeSell {
+		if p.spotOnly && amount > p.assets[asset].Free {
+			return &OrderError{
+				Err:      ErrShortingNotAllowed,
+				Pair:     pair,
+				Quantity: amount,
+			}
+		}
+
 		if p.assets[asset].Free > 0 {
-			funds += p.assets[asset].Free * value
+			funds = p.add(funds, p.mul(p.assets[asset].Free, value))
 		}
 
-		if funds < amount*value {
+		if funds < p.mul(amount, value) {
 			return &OrderError{
 				Err:      ErrInsufficientFunds,
 				Pair:     pair,
@@ -243,22 +1121,25 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 			}
 		}
 
+		if fill { // before the lock, so a closed position is recorded as a trade
+			p.updateAveragePrice(side, pair, amount, value)
+		}
+
 		lockedAsset := math.Min(math.Max(p.assets[asset].Free, 0), amount) // ignore negative asset amount to lock
-		lockedQuote := (amount - lockedAsset) * value
+		lockedQuote := p.mul(p.sub(amount, lockedAsset), value)
 
-		p.assets[asset].Free -= lockedAsset
-		p.assets[quote].Free -= lockedQuote
+		p.assets[asset].Free = p.sub(p.assets[asset].Free, lockedAsset)
+		quoteInfo.Free = p.sub(quoteInfo.Free, lockedQuote)
 		if fill {
-			p.updateAveragePrice(side, pair, amount, value)
 			if lockedQuote > 0 { // entering in short position
-				p.assets[asset].Free -= amount
+				p.assets[asset].Free = p.sub(p.assets[asset].Free, amount)
 			} else { // liquidating long position
-				p.assets[quote].Free += amount * value
+				quoteInfo.Free = p.add(quoteInfo.Free, p.mul(amount, value))
 
 			}
 		} else {
-			p.assets[asset].Lock += lockedAsset
-			p.assets[quote].Lock += lockedQuote
+			p.assets[asset].Lock = p.add(p.assets[asset].Lock, lockedAsset)
+			quoteInfo.Lock = p.add(quoteInfo.Lock, lockedQuote)
 		}
 
 		log.Debugf("%s -> LOCK = %f / FREE %f", asset, p.assets[asset].Lock, p.assets[asset].Free)
@@ -266,16 +1147,17 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 		var liquidShortValue float64
 		if p.assets[asset].Free < 0 {
 			v := math.Abs(p.assets[asset].Free)
-			liquidShortValue = 2*v*p.avgShortPrice[pair] - v*value // liquid price of short position
-			funds += liquidShortValue
+			// liquid price of short position
+			liquidShortValue = p.sub(p.mul(2*v, p.avgShortPrice[pair]), p.mul(v, value))
+			funds = p.add(funds, liquidShortValue)
 		}
 
 		amountToBuy := amount
 		if p.assets[asset].Free < 0 {
-			amountToBuy = amount + p.assets[asset].Free
+			amountToBuy = p.add(amount, p.assets[asset].Free)
 		}
 
-		if funds < amountToBuy*value {
+		if funds < p.mul(amountToBuy, value) {
 			return &OrderError{
 				Err:      ErrInsufficientFunds,
 				Pair:     pair,
@@ -283,18 +1165,21 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 			}
 		}
 
+		if fill { // before the lock, so a closed position is recorded as a trade
+			p.updateAveragePrice(side, pair, amount, value)
+		}
+
 		lockedAsset := math.Min(-math.Min(p.assets[asset].Free, 0), amount) // ignore positive amount to lock
-		lockedQuote := (amount-lockedAsset)*value - liquidShortValue
+		lockedQuote := p.sub(p.mul(p.sub(amount, lockedAsset), value), liquidShortValue)
 
-		p.assets[asset].Free += lockedAsset
-		p.assets[quote].Free -= lockedQuote
+		p.assets[asset].Free = p.add(p.assets[asset].Free, lockedAsset)
+		quoteInfo.Free = p.sub(quoteInfo.Free, lockedQuote)
 
 		if fill {
-			p.updateAveragePrice(side, pair, amount, value)
-			p.assets[asset].Free += amount - lockedAsset
+			p.assets[asset].Free = p.add(p.assets[asset].Free, p.sub(amount, lockedAsset))
 		} else {
-			p.assets[asset].Lock += lockedAsset
-			p.assets[quote].Lock += lockedQuote
+			p.assets[asset].Lock = p.add(p.assets[asset].Lock, lockedAsset)
+			quoteInfo.Lock = p.add(quoteInfo.Lock, lockedQuote)
 		}
 		log.Debugf("%s -> LOCK = %f / FREE %f", asset, p.assets[asset].Lock, p.assets[asset].Free)
 	}
@@ -302,9 +1187,131 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 	return nil
 }
 
+// lockFunds validates and locks the funds of a pending order, returning the amounts moved to lock
+func (p *PaperWallet) lockFunds(side model.SideType, pair string, amount, value float64) (fundsLock, error) {
+	asset, _ := SplitAssetQuote(pair)
+	var assetLock float64
+	if info, ok := p.assets[asset]; ok {
+		assetLock = info.Lock
+	}
+
+	quoteLock := p.quoteBalance(pair).Lock
+	err := p.validateFunds(side, pair, amount, value, false)
+	if err != nil {
+		return fundsLock{}, err
+	}
+
+	return fundsLock{
+		asset: p.sub(p.assets[asset].Lock, assetLock),
+		quote: p.sub(p.quoteBalance(pair).Lock, quoteLock),
+	}, nil
+}
+
+// lockID identifies the funds locked by an order, OCO orders share the lock of their group
+func lockID(order model.Order) int64 {
+	if order.GroupID != nil {
+		return *order.GroupID
+	}
+	return order.ExchangeID
+}
+
+// releaseFunds returns the funds locked by the order to free balance
+func (p *PaperWallet) releaseFunds(order model.Order) {
+	delete(p.executed, order.ExchangeID)
+	delete(p.elapsed, order.ExchangeID)
+	id := lockID(order)
+	lock, ok := p.locks[id]
+	if !ok {
+		return
+	}
+
+	asset, _ := SplitAssetQuote(order.Pair)
+	quoteInfo := p.quoteBalance(order.Pair)
+	p.assets[asset].Lock = p.sub(p.assets[asset].Lock, lock.asset)
+	p.assets[asset].Free = p.add(p.assets[asset].Free, lock.asset)
+	quoteInfo.Lock = p.sub(quoteInfo.Lock, lock.quote)
+	quoteInfo.Free = p.add(quoteInfo.Free, lock.quote)
+	delete(p.locks, id)
+}
+
+// settleFunds clears the lock of a filled order. For OCO orders, the other orders of the group are canceled
+// and the funds locked beyond the amount consumed by the fill are released.
+func (p *PaperWallet) settleFunds(order model.Order, consumed fundsLock, updatedAt time.Time) {
+	id := lockID(order)
+	lock, ok := p.locks[id]
+	delete(p.locks, id)
+
+	if order.GroupID == nil {
+		return
+	}
+
+	for j, groupOrder := range p.orders {
+		if groupOrder.GroupID != nil && *groupOrder.GroupID == *order.GroupID &&
+			groupOrder.ExchangeID != order.ExchangeID && groupOrder.Status == model.OrderStatusTypeNew {
+			p.orders[j].Status = model.OrderStatusTypeCanceled
+			p.orders[j].UpdatedAt = updatedAt
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	asset, _ := SplitAssetQuote(order.Pair)
+	quoteInfo := p.quoteBalance(order.Pair)
+	remainingAsset := p.sub(lock.asset, consumed.asset)
+	remainingQuote := p.sub(lock.quote, consumed.quote)
+	p.assets[asset].Lock = p.sub(p.assets[asset].Lock, remainingAsset)
+	p.assets[asset].Free = p.add(p.assets[asset].Free, remainingAsset)
+	quoteInfo.Lock = p.sub(quoteInfo.Lock, remainingQuote)
+	quoteInfo.Free = p.add(quoteInfo.Free, remainingQuote)
+}
+
+// chargeFee deducts the fee of a fill with the given value in quote, from the fee asset when configured
+func (p *PaperWallet) chargeFee(pair string, value float64, maker bool) {
+	rate := p.takerFee
+	if maker {
+		rate = p.makerFee
+	}
+
+	if rate == 0 {
+		return
+	}
+
+	_, quote := SplitAssetQuote(pair)
+	fee := p.mul(value, rate)
+
+	if p.feeAsset != "" {
+		price := 1.0
+		if p.feeAsset != quote {
+			price = p.lastCandle[strings.ToUpper(p.feeAsset+quote)].Close
+		}
+
+		if info, ok := p.assets[p.feeAsset]; ok && price > 0 {
+			amount := p.div(p.mul(fee, 1-p.feeDiscount), price)
+			if info.Free >= amount {
+				info.Free = p.sub(info.Free, amount)
+				log.Debugf("[FEE] %s: %f %s", pair, amount, p.feeAsset)
+				return
+			}
+		}
+	}
+
+	quoteInfo := p.quoteBalance(pair)
+	quoteInfo.Free = p.sub(quoteInfo.Free, fee)
+	log.Debugf("[FEE] %s: %f %s", pair, fee, quote)
+}
+
+// futuresProfit returns the linear profit of closing a quantity of a futures position,
+// the quantity is negative for short positions
+func (p *PaperWallet) futuresProfit(entry, exit, quantity float64) (value, percent float64) {
+	value = (exit - entry) * quantity * p.contractSize
+	return value, value / (entry * math.Abs(quantity) * p.contractSize)
+}
+
 func (p *PaperWallet) updateAveragePrice(side model.SideType, pair string, amount, value float64) {
 	actualQty := 0.0
-	asset, quote := SplitAssetQuote(pair)
+	asset, _ := SplitAssetQuote(pair)
 
 	if p.assets[asset] != nil {
 		actualQty = p.assets[asset].Free
@@ -322,16 +1329,14 @@ func (p *PaperWallet) updateAveragePrice(side model.SideType, pair string, amoun
 
 	// actual long + order buy
 	if actualQty > 0 && side == model.SideTypeBuy {
-		positionValue := p.avgLongPrice[pair] * actualQty
-		p.avgLongPrice[pair] = (positionValue + amount*value) / (actualQty + amount)
+		positionValue := p.mul(p.avgLongPrice[pair], actualQty)
+		p.avgLongPrice[pair] = p.div(p.add(positionValue, p.mul(amount, value)), p.add(actualQty, amount))
 		return
 	}
 
 	// actual long + order sell
 	if actualQty > 0 && side == model.SideTypeSell {
-		profitValue := amount*value - math.Min(amount, actualQty)*p.avgLongPrice[pair]
-		percentage := profitValue / (amount * p.avgLongPrice[pair])
-		log.Infof("PROFIT = %.4f %s (%.2f %%)", profitValue, quote, percentage*100.0) // TODO: store profits
+		p.recordTrade(pair, model.SideTypeBuy, p.avgLongPrice[pair], value, math.Min(amount, actualQty))
 
 		if amount <= actualQty { // not enough quantity to close the position
 			return
@@ -342,39 +1347,172 @@ func (p *PaperWallet) updateAveragePrice(side model.SideType, pair string, amoun
 		return
 	}
 
-	// actual short + order sell
-	if actualQty < 0 && side == model.SideTypeSell {
-		positionValue := p.avgShortPrice[pair] * -actualQty
-		p.avgShortPrice[pair] = (positionValue + amount*value) / (-actualQty + amount)
+	// actual short + order sell
+	if actualQty < 0 && side == model.SideTypeSell {
+		positionValue := p.mul(p.avgShortPrice[pair], -actualQty)
+		p.avgShortPrice[pair] = p.div(p.add(positionValue, p.mul(amount, value)), p.add(-actualQty, amount))
+
+		return
+	}
+
+	// actual short + order buy
+	if actualQty < 0 && side == model.SideTypeBuy {
+		p.recordTrade(pair, model.SideTypeSell, p.avgShortPrice[pair], value, math.Min(amount, -actualQty))
+
+		if amount <= -actualQty { // not enough quantity to close the position
+			return
+		}
+
+		p.avgLongPrice[pair] = value
+	}
+}
+
+// recordTrade registers the realized profit of closing a quantity of a position, side is the side of the
+// position: buy for longs and sell for shorts
+func (p *PaperWallet) recordTrade(pair string, side model.SideType, entry, exit, quantity float64) {
+	signed := quantity
+	if side == model.SideTypeSell {
+		signed = -quantity
+	}
+
+	profitValue := (exit - entry) * signed
+	percentage := profitValue / (entry * quantity)
+	if p.marketType == model.MarketTypeFutures {
+		profitValue, percentage = p.futuresProfit(entry, exit, signed)
+	}
+
+	_, quote := SplitAssetQuote(pair)
+	log.Infof("PROFIT = %s (%s)", model.FormatValue(profitValue, 4, quote),
+		model.FormatPercent(percentage*100.0, 2))
+
+	p.trades = append(p.trades, Trade{
+		Pair:          pair,
+		Side:          side,
+		EntryPrice:    entry,
+		ExitPrice:     exit,
+		Quantity:      quantity,
+		Profit:        profitValue,
+		ProfitPercent: percentage,
+		Time:          p.lastCandle[pair].Time,
+	})
+}
+
+// fillQuantity returns the quantity of an order filled in a candle, the remaining quantity of the order limited
+// by the fill ratio of the candle volume, see WithPaperFillRatio, and true if the order is completely filled.
+// Only limit orders can be partially filled, stop and OCO orders are always completely filled.
+func (p *PaperWallet) fillQuantity(order model.Order, candle model.Candle, limit bool) (float64, bool) {
+	remaining := p.sub(order.Quantity, p.executed[order.ExchangeID])
+	if !limit || order.GroupID != nil || p.fillRatio <= 0 || candle.Volume <= 0 {
+		return remaining, true
+	}
+
+	available := p.mul(candle.Volume, p.fillRatio)
+	if remaining <= available {
+		return remaining, true
+	}
+	return available, false
+}
+
+// partialFill registers the fill of a part of an order, the funds consumed by the fill are removed from the
+// lock of the order, so a cancel releases only the funds of the remaining quantity
+func (p *PaperWallet) partialFill(i int, consumed fundsLock, quantity float64) {
+	order := p.orders[i]
+	if p.executed == nil {
+		p.executed = make(map[int64]float64)
+	}
+	p.executed[order.ExchangeID] = p.add(p.executed[order.ExchangeID], quantity)
+	p.orders[i].Status = model.OrderStatusTypePartiallyFilled
+
+	if lock, ok := p.locks[order.ExchangeID]; ok {
+		lock.asset = math.Max(p.sub(lock.asset, consumed.asset), 0)
+		lock.quote = math.Max(p.sub(lock.quote, consumed.quote), 0)
+		p.locks[order.ExchangeID] = lock
+	}
+
+	log.Debugf("[PARTIAL FILL] %s %s: %f of %f", order.Side, order.Pair, p.executed[order.ExchangeID],
+		order.Quantity)
+}
+
+// Executed returns the quantity filled of a partially filled order, see WithPaperFillRatio
+func (p *PaperWallet) Executed(id int64) float64 {
+	p.Lock()
+	defer p.Unlock()
+	return p.executed[id]
+}
+
+// sweepDust closes the free position left by a fill of the side if its value is below the dust threshold, at the
+// last price. Only the remainder of a reduced position is swept, a position opened or increased by the fill is
+// kept. The wallet lock must be held.
+func (p *PaperWallet) sweepDust(side model.SideType, pair string) {
+	asset, quote := SplitAssetQuote(pair)
+	if p.dustThreshold <= 0 || p.assets[asset] == nil || p.assets[asset].Lock != 0 {
+		return
+	}
+
+	quantity := p.assets[asset].Free
+	if (side == model.SideTypeSell && quantity <= 0) || (side == model.SideTypeBuy && quantity >= 0) {
+		return
+	}
 
+	price := p.lastCandle[pair].Close
+	if math.Abs(quantity*price) >= p.dustThreshold {
 		return
 	}
 
-	// actual short + order buy
-	if actualQty < 0 && side == model.SideTypeBuy {
-		profitValue := math.Min(amount, -actualQty)*p.avgShortPrice[pair] - amount*value
-		percentage := profitValue / (amount * p.avgShortPrice[pair])
-		log.Infof("PROFIT = %.4f %s (%.2f %%)", profitValue, quote, percentage*100.0) // TODO: store profits
+	value := p.mul(quantity, price)
+	if quantity < 0 { // liquid value of short position
+		value = p.sub(p.mul(2*p.avgShortPrice[pair], math.Abs(quantity)), p.mul(math.Abs(quantity), price))
+		p.recordTrade(pair, model.SideTypeSell, p.avgShortPrice[pair], price, math.Abs(quantity))
+	} else {
+		p.recordTrade(pair, model.SideTypeBuy, p.avgLongPrice[pair], price, quantity)
+	}
 
-		if amount <= -actualQty { // not enough quantity to close the position
-			return
+	quoteInfo := p.quoteBalance(pair)
+	p.assets[asset].Free = 0
+	quoteInfo.Free = p.add(quoteInfo.Free, value)
+	p.sweptDust[pair] += quantity
+	log.Infof("[DUST] %s swept: %f %s = %s", pair, quantity, asset, model.FormatValue(value, 4, quote))
+}
+
+// notifyFill calls the fill callbacks, it must be called without holding the wallet lock
+func (p *PaperWallet) notifyFill(orders ...model.Order) {
+	filled := orders[:0:0]
+	for _, order := range orders {
+		// market orders of FillNextOpen are created pending
+		if order.Status == model.OrderStatusTypeFilled {
+			filled = append(filled, order)
 		}
+	}
+	orders = filled
 
-		p.avgLongPrice[pair] = value
+	for _, order := range orders {
+		for _, callback := range p.onFill {
+			callback(order)
+		}
 	}
 }
 
 func (p *PaperWallet) OnCandle(candle model.Candle) {
 	p.Lock()
-	defer p.Unlock()
+	filled := p.onCandle(candle)
+	p.publishMarkPrice(candle)
+	p.Unlock()
+
+	p.notifyFill(filled...)
+}
+
+func (p *PaperWallet) onCandle(candle model.Candle) []model.Order {
+	var filled []model.Order
 
 	p.lastCandle[candle.Pair] = candle
 	if _, ok := p.fistCandle[candle.Pair]; !ok {
 		p.fistCandle[candle.Pair] = candle
 	}
 
+	p.settleFunding(candle)
 	for i, order := range p.orders {
-		if order.Pair != candle.Pair || order.Status != model.OrderStatusTypeNew {
+		if order.Pair != candle.Pair || (order.Status != model.OrderStatusTypeNew &&
+			order.Status != model.OrderStatusTypePartiallyFilled) {
 			continue
 		}
 
@@ -382,20 +1520,67 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 			p.volume[candle.Pair] = 0
 		}
 
-		asset, quote := SplitAssetQuote(order.Pair)
-		if order.Side == model.SideTypeBuy && order.Price >= candle.Close {
+		// pending market orders are filled at the open of the candle after their creation, or after the latency,
+		// see FillNextOpen and WithPaperLatency
+		if order.Type == model.OrderTypeMarket {
+			if !candle.Time.After(order.UpdatedAt) {
+				continue
+			}
+
+			p.orders[i].UpdatedAt = candle.Time
+			if p.elapsed == nil {
+				p.elapsed = make(map[int64]int)
+			}
+			p.elapsed[order.ExchangeID]++
+			if p.elapsed[order.ExchangeID] < p.latency {
+				continue
+			}
+
+			p.orders[i] = p.fillNextOpen(p.orders[i], candle)
+			if p.orders[i].Status == model.OrderStatusTypeFilled {
+				filled = append(filled, p.orders[i])
+			}
+			continue
+		}
+
+		asset, _ := SplitAssetQuote(order.Pair)
+		quoteInfo := p.quoteBalance(order.Pair)
+		if order.Side == model.SideTypeBuy {
+			// buy stops are triggered when the price rises to the stop, e.g. breakout entries
+			stop := order.Type == model.OrderTypeStopLossLimit || order.Type == model.OrderTypeStopLoss
+			if (stop && candle.High < *order.Stop) || (!stop && order.Price < candle.Close) {
+				continue
+			}
+
 			if _, ok := p.assets[asset]; !ok {
 				p.assets[asset] = &assetInfo{}
 			}
 
-			p.volume[candle.Pair] += order.Price * order.Quantity
+			quantity, complete := p.fillQuantity(order, candle, !stop)
+			if p.leveraged(order.Pair) {
+				if p.fillMarginOrder(i, candle, quantity, order.Price, complete, !stop) {
+					filled = append(filled, p.orders[i])
+				}
+				continue
+			}
+
+			p.volume[candle.Pair] += order.Price * quantity
 			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
 
 			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, order.Price)
-			p.assets[asset].Free = p.assets[asset].Free + order.Quantity
-			p.assets[quote].Lock = p.assets[quote].Lock - order.Price*order.Quantity
+			p.updateAveragePrice(order.Side, order.Pair, quantity, order.Price)
+			p.assets[asset].Free = p.add(p.assets[asset].Free, quantity)
+			quoteInfo.Lock = p.sub(quoteInfo.Lock, p.mul(order.Price, quantity))
+			p.chargeFee(order.Pair, order.Price*quantity, !stop)
+			if !complete {
+				p.partialFill(i, fundsLock{quote: p.mul(order.Price, quantity)}, quantity)
+				continue
+			}
+
+			p.orders[i].Status = model.OrderStatusTypeFilled
+			p.settleFunds(order, fundsLock{quote: p.mul(order.Price, quantity)}, candle.Time)
+			delete(p.executed, order.ExchangeID)
+			filled = append(filled, p.orders[i])
 		}
 
 		if order.Side == model.SideTypeSell {
@@ -414,41 +1599,59 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 				continue
 			}
 
-			// Cancel other orders from same group
-			if order.GroupID != nil {
-				for j, groupOrder := range p.orders {
-					if groupOrder.GroupID != nil && *groupOrder.GroupID == *order.GroupID &&
-						groupOrder.ExchangeID != order.ExchangeID {
-						p.orders[j].Status = model.OrderStatusTypeCanceled
-						p.orders[j].UpdatedAt = candle.Time
-						break
-					}
+			stop := order.Type == model.OrderTypeStopLossLimit || order.Type == model.OrderTypeStopLoss
+			quantity, complete := p.fillQuantity(order, candle, !stop)
+			if p.leveraged(order.Pair) {
+				if p.fillMarginOrder(i, candle, quantity, orderPrice, complete, !stop) {
+					filled = append(filled, p.orders[i])
 				}
+				continue
 			}
 
-			if _, ok := p.assets[quote]; !ok {
-				p.assets[quote] = &assetInfo{}
-			}
-
-			orderVolume := order.Quantity * orderPrice
+			orderVolume := p.mul(quantity, orderPrice)
 
 			p.volume[candle.Pair] += orderVolume
 			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
 
 			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, orderPrice)
-			p.assets[asset].Lock = p.assets[asset].Lock - order.Quantity
-			p.assets[quote].Free = p.assets[quote].Free + order.Quantity*orderPrice
+			p.updateAveragePrice(order.Side, order.Pair, quantity, orderPrice)
+			p.assets[asset].Lock = p.sub(p.assets[asset].Lock, quantity)
+			quoteInfo.Free = p.add(quoteInfo.Free, orderVolume)
+			p.chargeFee(order.Pair, orderVolume, !stop)
+			if !complete {
+				p.partialFill(i, fundsLock{asset: quantity}, quantity)
+				continue
+			}
+
+			p.orders[i].Status = model.OrderStatusTypeFilled
+			p.settleFunds(order, fundsLock{asset: quantity}, candle.Time)
+			delete(p.executed, order.ExchangeID)
+			filled = append(filled, p.orders[i])
 		}
 	}
 
+	if order, ok := p.checkLiquidation(candle); ok {
+		filled = append(filled, order)
+	}
+
+	for _, order := range filled {
+		p.sweepDust(order.Side, order.Pair)
+	}
+
 	if candle.Complete {
+		// record the first candle and every N candles, the others are kept as pending values
+		sampled := p.equitySampling <= 1 || p.equityCandles%p.equitySampling == 0
+		p.equityCandles++
+		p.pendingEquity = nil
+		p.pendingAssets = nil
+
 		var total float64
 		for asset, info := range p.assets {
 			amount := info.Free + info.Lock
 			pair := strings.ToUpper(asset + p.baseCoin)
-			if amount < 0 {
+			if p.leveraged(pair) {
+				total += p.marginEquity(pair, p.lastCandle[pair].Close)
+			} else if amount < 0 {
 				v := math.Abs(amount)
 				liquid := 2*v*p.avgShortPrice[pair] - v*p.lastCandle[pair].Close
 				total += liquid
@@ -456,25 +1659,52 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 				total += amount * p.lastCandle[pair].Close
 			}
 
-			p.assetValues[asset] = append(p.assetValues[asset], AssetValue{
+			assetValue := AssetValue{
 				Time:  candle.Time,
 				Value: amount * p.lastCandle[pair].Close,
-			})
+			}
+			if sampled {
+				p.assetValues[asset] = append(p.assetValues[asset], assetValue)
+			} else {
+				if p.pendingAssets == nil {
+					p.pendingAssets = make(map[string]AssetValue)
+				}
+				p.pendingAssets[asset] = assetValue
+			}
 		}
 
-		baseCoinInfo := p.assets[p.baseCoin]
-		p.equityValues = append(p.equityValues, AssetValue{
+		equity := AssetValue{
 			Time:  candle.Time,
-			Value: total + baseCoinInfo.Lock + baseCoinInfo.Free,
-		})
+			Value: total + p.baseCoinBalance(),
+		}
+		if sampled {
+			p.equityValues = append(p.equityValues, equity)
+		} else {
+			p.pendingEquity = &equity
+		}
 	}
+
+	return filled
 }
 
 func (p *PaperWallet) Account() (model.Account, error) {
-	balances := make([]model.Balance, 0)
-	for pair, info := range p.assets {
+	// the allocations are reported in the balance of their quote
+	assets := make(map[string]assetInfo, len(p.assets))
+	for asset, info := range p.assets {
+		assets[asset] = *info
+	}
+	for pair, info := range p.allocations {
+		_, quote := SplitAssetQuote(pair)
+		balance := assets[quote]
+		balance.Free = p.add(balance.Free, info.Free)
+		balance.Lock = p.add(balance.Lock, info.Lock)
+		assets[quote] = balance
+	}
+
+	balances := make([]model.Balance, 0, len(assets))
+	for asset, info := range assets {
 		balances = append(balances, model.Balance{
-			Asset: pair,
+			Asset: asset,
 			Free:  info.Free,
 			Lock:  info.Lock,
 		})
@@ -496,10 +1726,59 @@ func (p *PaperWallet) Position(pair string) (asset, quote float64, err error) {
 	}
 
 	assetBalance, quoteBalance := acc.Balance(assetTick, quoteTick)
+	if info, ok := p.allocations[pair]; ok {
+		return assetBalance.Free + assetBalance.Lock, info.Free + info.Lock, nil
+	}
 
 	return assetBalance.Free + assetBalance.Lock, quoteBalance.Free + quoteBalance.Lock, nil
 }
 
+// OpenPositions returns the quantity of the open positions of the pairs with market data, negative for shorts.
+// Pairs with the same asset share its balance.
+func (p *PaperWallet) OpenPositions() map[string]float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	positions := make(map[string]float64)
+	for pair := range p.lastCandle {
+		asset, _ := SplitAssetQuote(pair)
+		info, ok := p.assets[asset]
+		if !ok {
+			continue
+		}
+
+		if quantity := info.Free + info.Lock; quantity != 0 {
+			positions[pair] = quantity
+		}
+	}
+	return positions
+}
+
+// OpenPositionCount returns the number of open positions, see OpenPositions
+func (p *PaperWallet) OpenPositionCount() int {
+	return len(p.OpenPositions())
+}
+
+// BreakEvenPrice returns the price at which the position of the pair breaks even after the fees, from the
+// average entry price of the position and the taker fee of the wallet, see WithPaperFee. It returns
+// ErrNoPosition without position.
+func (p *PaperWallet) BreakEvenPrice(pair string) (float64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	asset, _ := SplitAssetQuote(pair)
+	info, ok := p.assets[asset]
+	if !ok {
+		return 0, ErrNoPosition
+	}
+
+	quantity := p.add(info.Free, info.Lock)
+	if quantity < 0 {
+		return BreakEvenPrice(p.avgShortPrice[pair], quantity, p.takerFee)
+	}
+	return BreakEvenPrice(p.avgLongPrice[pair], quantity, p.takerFee)
+}
+
 func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 	size, price, stop, stopLimit float64) ([]model.Order, error) {
 	p.Lock()
@@ -509,12 +1788,17 @@ func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 		return nil, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, price, false)
+	if err := p.validateMarketData(pair); err != nil {
+		return nil, err
+	}
+
+	lock, err := p.lockFunds(side, pair, size, price)
 	if err != nil {
 		return nil, err
 	}
 
 	groupID := p.ID()
+	p.locks[groupID] = lock
 	limitMaker := model.Order{
 		ExchangeID: p.ID(),
 		CreatedAt:  p.lastCandle[pair].Time,
@@ -550,6 +1834,27 @@ func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 
 func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 	size float64, limit float64) (model.Order, error) {
+	return p.CreateOrderLimitTIF(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF creates a limit order with the given time in force. GTC orders wait for a candle
+// that reaches the limit price. IOC and FOK orders are matched immediately with the close of the last candle,
+// at or better than the limit, limited by the fill ratio of the candle volume, see WithPaperFillRatio: IOC
+// fills the available quantity and cancels the remainder, FOK is rejected if it can not be fully filled. A
+// partially filled IOC order is returned filled with the executed quantity, see `model.Order.Executed`.
+func (p *PaperWallet) CreateOrderLimitTIF(side model.SideType, pair string,
+	size float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
+
+	if tif == model.TimeInForceIOC || tif == model.TimeInForceFOK {
+		p.Lock()
+		order, err := p.createOrderLimitImmediate(side, pair, size, limit, tif)
+		p.Unlock()
+
+		if err == nil && order.Status == model.OrderStatusTypeFilled {
+			p.notifyFill(order)
+		}
+		return order, err
+	}
 
 	p.Lock()
 	defer p.Unlock()
@@ -558,7 +1863,11 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, limit, false)
+	if err := p.validateMarketData(pair); err != nil {
+		return model.Order{}, err
+	}
+
+	lock, err := p.lockFunds(side, pair, size, limit)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -573,18 +1882,91 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 		Price:      limit,
 		Quantity:   size,
 	}
+	p.locks[order.ExchangeID] = lock
+	p.orders = append(p.orders, order)
+	return order, nil
+}
+
+// createOrderLimitImmediate matches an IOC or FOK limit order with the last candle. A crossable order fills at
+// the close, not the limit, limited by the fill ratio of the candle volume, see WithPaperFillRatio. The remainder
+// of an IOC order is canceled, and a FOK order that cannot be filled completely is rejected.
+func (p *PaperWallet) createOrderLimitImmediate(side model.SideType, pair string,
+	size float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
+
+	if size == 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+
+	if err := p.validateMarketData(pair); err != nil {
+		return model.Order{}, err
+	}
+
+	candle := p.lastCandle[pair]
+	order := model.Order{
+		ExchangeID: p.ID(),
+		CreatedAt:  candle.Time,
+		UpdatedAt:  candle.Time,
+		Pair:       pair,
+		Side:       side,
+		Type:       model.OrderTypeLimit,
+		Status:     model.OrderStatusTypeFilled,
+		Price:      limit,
+		Quantity:   size,
+	}
+
+	crossable := (side == model.SideTypeBuy && limit >= candle.Close) ||
+		(side == model.SideTypeSell && limit <= candle.Close)
+
+	quantity := 0.0
+	if crossable {
+		quantity, _ = p.fillQuantity(order, candle, true)
+	}
+
+	switch {
+	case tif == model.TimeInForceFOK && quantity < size:
+		order.Status = model.OrderStatusTypeRejected
+	case quantity == 0:
+		order.Status = model.OrderStatusTypeCanceled
+	default:
+		if err := p.validateFunds(side, pair, quantity, candle.Close, true); err != nil {
+			return model.Order{}, err
+		}
+		p.volume[pair] += candle.Close * quantity
+		p.chargeFee(pair, candle.Close*quantity, false)
+		order.Fill(quantity, candle.Close)
+		p.sweepDust(side, pair)
+
+		// the remainder of the order is canceled, the executed part is a filled order
+		if quantity < size {
+			order.Status = model.OrderStatusTypeCanceled
+			order = order.Executed()
+		}
+	}
+
 	p.orders = append(p.orders, order)
 	return order, nil
 }
 
 func (p *PaperWallet) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return p.CreateOrderMarketTagged(side, pair, size, "")
+}
+
+func (p *PaperWallet) CreateOrderMarketTagged(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
 	p.Lock()
-	defer p.Unlock()
+	order, err := p.createOrderMarket(side, pair, size, tag)
+	p.Unlock()
 
-	return p.createOrderMarket(side, pair, size)
+	if err == nil {
+		p.notifyFill(order)
+	}
+	return order, err
 }
 
-func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
+// CreateOrderStop creates a stop-limit order filled at the limit price. Sell stops are triggered when the
+// low of a candle reaches the limit, and buy stops when the high reaches it, e.g. a breakout entry.
+func (p *PaperWallet) CreateOrderStop(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
 	p.Lock()
 	defer p.Unlock()
 
@@ -592,7 +1974,11 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(model.SideTypeSell, pair, size, limit, false)
+	if err := p.validateMarketData(pair); err != nil {
+		return model.Order{}, err
+	}
+
+	lock, err := p.lockFunds(side, pair, size, limit)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -602,43 +1988,155 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		CreatedAt:  p.lastCandle[pair].Time,
 		UpdatedAt:  p.lastCandle[pair].Time,
 		Pair:       pair,
-		Side:       model.SideTypeSell,
+		Side:       side,
 		Type:       model.OrderTypeStopLossLimit,
 		Status:     model.OrderStatusTypeNew,
 		Price:      limit,
 		Stop:       &limit,
 		Quantity:   size,
 	}
+	p.locks[order.ExchangeID] = lock
 	p.orders = append(p.orders, order)
 	return order, nil
 }
 
-func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+// marketPrice returns the fill price of a market order, the reference price of the fill model in the last candle
+// adjusted by half of the spread. With FillNextOpen, it is an estimate with the last close.
+func (p *PaperWallet) marketPrice(side model.SideType, pair string) float64 {
+	candle := p.lastCandle[pair]
+	return p.withSpread(side, candle, p.referencePrice(side, candle))
+}
+
+// referencePrice returns the price of the candle used to fill market orders, see WithPaperFillModel
+func (p *PaperWallet) referencePrice(side model.SideType, candle model.Candle) float64 {
+	switch p.fillModel {
+	case FillMid:
+		return (candle.High + candle.Low) / 2
+	case FillHighLow:
+		if side == model.SideTypeBuy {
+			return candle.High
+		}
+		return candle.Low
+	case FillVWAP:
+		return (candle.High + candle.Low + candle.Close) / 3
+	default:
+		return candle.Close
+	}
+}
+
+// withSpread adjusts the reference price by half of the spread, buys pay the ask and sells receive the bid
+func (p *PaperWallet) withSpread(side model.SideType, candle model.Candle, reference float64) float64 {
+	spread := p.mul(reference, p.spreadBps/10_000)
+	if p.rangeSpread > 0 {
+		spread = math.Max(spread, p.mul(candle.High-candle.Low, p.rangeSpread))
+	}
+
+	if side == model.SideTypeBuy {
+		return p.add(reference, spread/2)
+	}
+	return p.sub(reference, spread/2)
+}
+
+// fillPrice returns the fill price of a market order from the reference price, with the spread, the market
+// impact of its size and the slippage
+func (p *PaperWallet) fillPrice(side model.SideType, candle model.Candle, reference, size float64) float64 {
+	price := p.withSpread(side, candle, reference)
+
+	slippage := p.slippage
+	if p.slippageFunc != nil {
+		slippage += p.slippageFunc(candle, size)
+	}
+
+	penalty := p.mul(reference, slippage/10_000)
+	if p.impact > 0 && candle.Volume > 0 {
+		penalty = p.add(penalty, p.mul(reference, p.impact*math.Sqrt(math.Abs(size)/candle.Volume)))
+	}
+
+	if penalty == 0 {
+		return price
+	}
+
+	if side == model.SideTypeBuy {
+		return p.add(price, penalty)
+	}
+	return p.sub(price, penalty)
+}
+
+// recordMarketFill updates the volume, the spread cost and the fee of a market fill
+func (p *PaperWallet) recordMarketFill(pair string, size, price, reference float64) {
+	if _, ok := p.volume[pair]; !ok {
+		p.volume[pair] = 0
+	}
+
+	if spread := math.Abs(price - reference); spread > 0 {
+		p.spreadCost[pair] += spread * size
+	}
+
+	p.volume[pair] += price * size
+	p.chargeFee(pair, price*size, false)
+}
+
+// fillNextOpen fills a pending market order at the open of the candle, the funds locked with the estimated
+// price are released and the order is rejected if the balance is not enough at the open
+func (p *PaperWallet) fillNextOpen(order model.Order, candle model.Candle) model.Order {
+	p.releaseFunds(order)
+	order.UpdatedAt = candle.Time
+
+	reference := candle.Open
+	if reference <= 0 { // candles without open
+		reference = candle.Close
+	}
+
+	price := p.fillPrice(order.Side, candle, reference, order.Quantity)
+	if err := p.validateFunds(order.Side, order.Pair, order.Quantity, price, true); err != nil {
+		log.Warnf("[PAPER] %s %s market order %d rejected at the open: %v", order.Side, order.Pair,
+			order.ExchangeID, err)
+		order.Status = model.OrderStatusTypeRejected
+		return order
+	}
+
+	p.recordMarketFill(order.Pair, order.Quantity, price, reference)
+	order.Price = price
+	order.Status = model.OrderStatusTypeFilled
+	return order
+}
+
+func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
 	if size == 0 {
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, p.lastCandle[pair].Close, true)
-	if err != nil {
+	if err := p.validateMarketData(pair); err != nil {
 		return model.Order{}, err
 	}
 
-	if _, ok := p.volume[pair]; !ok {
-		p.volume[pair] = 0
+	if p.fillModel == FillNextOpen || p.latency > 0 {
+		return p.queueOrderMarket(side, pair, size, tag)
 	}
 
-	p.volume[pair] += p.lastCandle[pair].Close * size
+	candle := p.lastCandle[pair]
+	reference := p.referencePrice(side, candle)
+	price := p.fillPrice(side, candle, reference, size)
+	err := p.validateFunds(side, pair, size, price, true)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	p.recordMarketFill(pair, size, price, reference)
+	p.sweepDust(side, pair)
 
 	order := model.Order{
 		ExchangeID: p.ID(),
-		CreatedAt:  p.lastCandle[pair].Time,
-		UpdatedAt:  p.lastCandle[pair].Time,
+		CreatedAt:  candle.Time,
+		UpdatedAt:  candle.Time,
 		Pair:       pair,
 		Side:       side,
 		Type:       model.OrderTypeMarket,
 		Status:     model.OrderStatusTypeFilled,
-		Price:      p.lastCandle[pair].Close,
+		Price:      price,
 		Quantity:   size,
+		Tag:        tag,
 	}
 
 	p.orders = append(p.orders, order)
@@ -646,14 +2144,97 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 	return order, nil
 }
 
+// queueOrderMarket creates a pending market order filled at the open of the next candle, see FillNextOpen.
+// The funds are locked with the estimated price until the fill.
+func (p *PaperWallet) queueOrderMarket(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
+	candle := p.lastCandle[pair]
+	price := p.fillPrice(side, candle, p.referencePrice(side, candle), size)
+	lock, err := p.lockFunds(side, pair, size, price)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order := model.Order{
+		ExchangeID: p.ID(),
+		CreatedAt:  p.lastCandle[pair].Time,
+		UpdatedAt:  p.lastCandle[pair].Time,
+		Pair:       pair,
+		Side:       side,
+		Type:       model.OrderTypeMarket,
+		Status:     model.OrderStatusTypeNew,
+		Price:      price,
+		Quantity:   size,
+		Tag:        tag,
+	}
+	p.locks[order.ExchangeID] = lock
+	p.orders = append(p.orders, order)
+	return order, nil
+}
+
 func (p *PaperWallet) CreateOrderMarketQuote(side model.SideType, pair string,
 	quoteQuantity float64) (model.Order, error) {
 	p.Lock()
-	defer p.Unlock()
+	if err := p.validateMarketData(pair); err != nil {
+		p.Unlock()
+		return model.Order{}, err
+	}
 
 	info := p.AssetsInfo(pair)
-	quantity := common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, quoteQuantity/p.lastCandle[pair].Close)
-	return p.createOrderMarket(side, pair, quantity)
+	quantity := roundToStep(quoteQuantity/p.marketPrice(side, pair), info.StepSize, info.BaseAssetPrecision, p.rounding)
+	order, err := p.createOrderMarket(side, pair, quantity, "")
+	p.Unlock()
+
+	if err == nil {
+		p.notifyFill(order)
+	}
+	return order, err
+}
+
+// BuyPercent creates a market order to buy with a fraction of the free quote balance, see PercentQuantity
+func (p *PaperWallet) BuyPercent(pair string, percent float64) (model.Order, error) {
+	return p.createOrderPercent(model.SideTypeBuy, pair, percent)
+}
+
+// SellPercent creates a market order to sell a fraction of the free asset balance, see PercentQuantity
+func (p *PaperWallet) SellPercent(pair string, percent float64) (model.Order, error) {
+	return p.createOrderPercent(model.SideTypeSell, pair, percent)
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order, see ClosePosition
+func (p *PaperWallet) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	return ClosePosition(p, pair, fraction)
+}
+
+func (p *PaperWallet) createOrderPercent(side model.SideType, pair string, percent float64) (model.Order, error) {
+	p.Lock()
+	if err := p.validateMarketData(pair); err != nil {
+		p.Unlock()
+		return model.Order{}, err
+	}
+
+	free := func(coin string) float64 {
+		if info, ok := p.assets[coin]; ok {
+			return info.Free
+		}
+		return 0
+	}
+
+	asset, _ := SplitAssetQuote(pair)
+	quantity, err := PercentQuantity(p.AssetsInfo(pair), side, free(asset), p.quoteBalance(pair).Free,
+		p.marketPrice(side, pair), percent)
+	if err != nil {
+		p.Unlock()
+		return model.Order{}, err
+	}
+
+	order, err := p.createOrderMarket(side, pair, quantity, "")
+	p.Unlock()
+
+	if err == nil {
+		p.notifyFill(order)
+	}
+	return order, err
 }
 
 func (p *PaperWallet) Cancel(order model.Order) error {
@@ -661,11 +2242,63 @@ func (p *PaperWallet) Cancel(order model.Order) error {
 	defer p.Unlock()
 
 	for i, o := range p.orders {
-		if o.ExchangeID == order.ExchangeID {
-			p.orders[i].Status = model.OrderStatusTypeCanceled
+		if o.ExchangeID != order.ExchangeID {
+			continue
+		}
+
+		if o.Status != model.OrderStatusTypeNew && o.Status != model.OrderStatusTypePartiallyFilled {
+			return nil
+		}
+
+		// as in the exchange, canceling an OCO order cancels all orders of the group
+		for j, groupOrder := range p.orders {
+			if j == i || o.GroupID == nil || groupOrder.GroupID == nil || *groupOrder.GroupID != *o.GroupID ||
+				groupOrder.Status != model.OrderStatusTypeNew {
+				continue
+			}
+			p.orders[j].Status = model.OrderStatusTypeCanceled
+			p.orders[j].UpdatedAt = p.lastCandle[o.Pair].Time
 		}
+
+		p.orders[i].Status = model.OrderStatusTypeCanceled
+		p.orders[i].UpdatedAt = p.lastCandle[o.Pair].Time
+		p.releaseFunds(o)
+		return nil
+	}
+
+	return errors.New("order not found")
+}
+
+// OpenOrders returns the pending orders of the pair (new or partially filled)
+func (p *PaperWallet) OpenOrders(pair string) []model.Order {
+	p.Lock()
+	defer p.Unlock()
+
+	orders := make([]model.Order, 0)
+	for _, order := range p.orders {
+		if order.Pair == pair && (order.Status == model.OrderStatusTypeNew ||
+			order.Status == model.OrderStatusTypePartiallyFilled) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// CancelAll cancels the pending orders of the pair and releases the locked funds
+func (p *PaperWallet) CancelAll(pair string) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, order := range p.orders {
+		if order.Pair != pair || (order.Status != model.OrderStatusTypeNew &&
+			order.Status != model.OrderStatusTypePartiallyFilled) {
+			continue
+		}
+
+		p.orders[i].Status = model.OrderStatusTypeCanceled
+		p.orders[i].UpdatedAt = p.lastCandle[pair].Time
+		p.releaseFunds(order)
 	}
-	return nil
 }
 
 func (p *PaperWallet) Order(_ string, id int64) (model.Order, error) {
@@ -2,12 +2,17 @@ package exchange
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
 )
 
 func TestPaperWallet_ValidateFunds(t *testing.T) {
@@ -81,6 +86,7 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 func TestPaperWallet_OrderLimit(t *testing.T) {
 	t.Run("normal order", func(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110})
 		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
 		require.NoError(t, err)
 
@@ -191,6 +197,828 @@ func TestPaperWallet_OrderLimit(t *testing.T) {
 	})
 }
 
+func TestPaperWallet_OrderLimitTIF(t *testing.T) {
+	t.Run("gtc", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 2})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceGTC)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.Equal(t, 5.0, order.Quantity)
+		require.Equal(t, 550.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("ioc full fill", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 2})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceIOC)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 5.0, order.Quantity)
+		require.Equal(t, 5.0, order.ExecutedQuantity)
+		require.Equal(t, 5.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("ioc marketable fills at the close", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 10})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceIOC)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 100.0, order.Price)
+		require.Equal(t, 500.0, wallet.assets["USDT"].Free)
+	})
+
+	t.Run("ioc partial fill", func(t *testing.T) {
+		var filled []model.Order
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillRatio(0.5), WithPaperOnFill(func(order model.Order) {
+				filled = append(filled, order)
+			}))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 4})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceIOC)
+		require.NoError(t, err)
+
+		// the remainder is canceled, the executed part is filled
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 2.0, order.Quantity)
+		require.Equal(t, 2.0, order.ExecutedQuantity)
+		require.Equal(t, 100.0, order.Price)
+		require.Equal(t, []model.Order{order}, filled)
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 800.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("ioc not crossable", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 10})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 90, model.TimeInForceIOC)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, order.Status)
+		require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+		// canceled order is not filled by the next candles
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 80, Volume: 10})
+		require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[0].Status)
+	})
+
+	t.Run("fok full fill", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 0),
+			WithPaperAsset("BTC", 5))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 10})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeSell, "BTCUSDT", 5, 90, model.TimeInForceFOK)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 5.0, order.Quantity)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 500.0, wallet.assets["USDT"].Free)
+	})
+
+	t.Run("fok rejected", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillRatio(1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Volume: 2})
+		order, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceFOK)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeRejected, order.Status)
+		require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+		require.NotContains(t, wallet.assets, "BTC")
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderLimitTIF(model.SideTypeBuy, "BTCUSDT", 5, 110, model.TimeInForceIOC)
+		require.Error(t, err)
+		require.Empty(t, wallet.orders)
+	})
+}
+
+func TestPaperWallet_Rounding(t *testing.T) {
+	t.Run("floor", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 3})
+		order, err := wallet.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.Equal(t, 0.66666666, order.Quantity)
+	})
+
+	t.Run("nearest", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperRounding(RoundingNearest))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 3})
+		order, err := wallet.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.Equal(t, 0.66666667, order.Quantity)
+	})
+}
+
+func TestPaperWallet_DustThreshold(t *testing.T) {
+	t.Run("sweep dust", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperDustThreshold(0.01))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.99999)
+		require.NoError(t, err)
+
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.InDelta(t, 100.0, wallet.assets["USDT"].Free, 1e-9)
+		require.InDelta(t, 0.00001, wallet.SweptDust()["BTCUSDT"], 1e-12)
+
+		trades := wallet.Trades("BTCUSDT")
+		require.Len(t, trades, 2)
+		require.InDelta(t, 0.00001, trades[1].Quantity, 1e-12)
+		require.Equal(t, 100.0, trades[1].ExitPrice)
+	})
+
+	t.Run("fresh position below threshold", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperDustThreshold(0.01))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.00005)
+		require.NoError(t, err)
+
+		require.Equal(t, 0.00005, wallet.assets["BTC"].Free)
+		require.Empty(t, wallet.SweptDust())
+		require.Empty(t, wallet.Trades(""))
+	})
+
+	t.Run("position above threshold", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperDustThreshold(0.01))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.5)
+		require.NoError(t, err)
+
+		require.Equal(t, 0.5, wallet.assets["BTC"].Free)
+		require.Empty(t, wallet.SweptDust())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.00001)
+		require.NoError(t, err)
+
+		require.Equal(t, 0.00001, wallet.assets["BTC"].Free)
+	})
+}
+
+func TestPaperWallet_EquitySampling(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		name     string
+		every    int
+		expected int
+	}{
+		{name: "every candle", every: 0, expected: 25},
+		{name: "every 10 candles", every: 10, expected: 4},      // 0, 10, 20 and the last one
+		{name: "every 12 candles", every: 12, expected: 3},      // 0, 12 and 24, the last one is sampled
+		{name: "sampling above series", every: 50, expected: 2}, // 0 and the last one
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+				WithPaperEquitySampling(tc.every))
+			for i := 0; i < 25; i++ {
+				wallet.OnCandle(model.Candle{
+					Pair:     "BTCUSDT",
+					Time:     start.Add(time.Duration(i) * time.Minute),
+					Close:    float64(100 + i),
+					Complete: true,
+				})
+			}
+
+			equity := wallet.EquityValues()
+			require.Len(t, equity, tc.expected)
+			require.Equal(t, start, equity[0].Time)
+			require.Equal(t, start.Add(24*time.Minute), equity[len(equity)-1].Time)
+			require.Len(t, wallet.AssetValues("USDT"), tc.expected)
+		})
+	}
+}
+
+func TestPaperWallet_FeeAsset(t *testing.T) {
+	t.Run("fee asset", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperAsset("BNB", 1), WithPaperFee(0.001, 0.001), WithPaperFeeAsset("BNB", 0.25))
+		wallet.OnCandle(model.Candle{Pair: "BNBUSDT", Close: 300})
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		// fee of 0.1 USDT with 25% of discount, paid in BNB
+		require.Equal(t, 900.0, wallet.assets["USDT"].Free)
+		require.InDelta(t, 1-0.075/300, wallet.assets["BNB"].Free, 1e-9)
+	})
+
+	t.Run("fallback to quote", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperAsset("BNB", 0.0001), WithPaperFee(0.001, 0.001), WithPaperFeeAsset("BNB", 0.25))
+		wallet.OnCandle(model.Candle{Pair: "BNBUSDT", Close: 300})
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		// insufficient BNB, the fee is paid in USDT without discount
+		require.InDelta(t, 899.9, wallet.assets["USDT"].Free, 1e-9)
+		require.Equal(t, 0.0001, wallet.assets["BNB"].Free)
+	})
+
+	t.Run("maker fee", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFee(0.001, 0.002))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110})
+
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		require.InDelta(t, 899.9, wallet.assets["USDT"].Free, 1e-9)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+	})
+}
+
+func TestPaperWallet_OrderPercent(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 50})
+
+	order, err := wallet.BuyPercent("BTCUSDT", 0.5)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	require.Equal(t, 1.0, order.Quantity)
+	require.Equal(t, 50.0, wallet.assets["USDT"].Free)
+
+	order, err = wallet.SellPercent("BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, model.SideTypeSell, order.Side)
+	require.Equal(t, 1.0, order.Quantity)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+
+	_, err = wallet.SellPercent("BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestPaperWallet_SpotOnly(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100), WithPaperSpotOnly())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 50})
+
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// sell more than the position
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1.5)
+	require.ErrorIs(t, err, ErrShortingNotAllowed)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+
+	_, err = wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 60)
+	require.ErrorIs(t, err, ErrShortingNotAllowed)
+
+	// sell the full position
+	order, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+}
+
+func TestPaperWallet_Spread(t *testing.T) {
+	t.Run("fixed spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000), WithPaperSpread(20))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 100.1, order.Price, 1e-9)
+		require.InDelta(t, 899.9, wallet.assets["USDT"].Free, 1e-9)
+
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 99.9, order.Price, 1e-9)
+		require.InDelta(t, 999.8, wallet.assets["USDT"].Free, 1e-9)
+
+		require.InDelta(t, 0.2, wallet.SpreadCost()["BTCUSDT"], 1e-9)
+	})
+
+	t.Run("range spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperSpread(20), WithPaperRangeSpread(0.25))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 98, High: 102})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.InDelta(t, 100.5, order.Price, 1e-9)
+
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.InDelta(t, 99.5, order.Price, 1e-9)
+		require.InDelta(t, 2.0, wallet.SpreadCost()["BTCUSDT"], 1e-9)
+	})
+
+	t.Run("without spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 98, High: 102})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 100.0, order.Price)
+		require.Empty(t, wallet.SpreadCost())
+	})
+}
+
+func TestPaperWallet_BestBidAsk(t *testing.T) {
+	t.Run("without spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		_, _, err := wallet.BestBidAsk(context.Background(), "BTCUSDT")
+		require.ErrorIs(t, err, ErrNoMarketData)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 98, High: 102})
+		bid, ask, err := wallet.BestBidAsk(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 100.0, bid)
+		require.Equal(t, 100.0, ask)
+	})
+
+	t.Run("with spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000), WithPaperSpread(20))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 98, High: 102})
+
+		bid, ask, err := wallet.BestBidAsk(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 99.9, bid, 1e-9)
+		require.InDelta(t, 100.1, ask, 1e-9)
+
+		// market orders are filled at the synthesized prices
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, ask, order.Price)
+	})
+}
+
+func TestPaperWallet_ImpactModel(t *testing.T) {
+	t.Run("grows with the order to volume ratio", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100000),
+			WithPaperImpactModel(0.1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 10000})
+
+		var last float64
+		for _, test := range []struct {
+			size  float64
+			price float64
+		}{
+			{size: 1, price: 100.1}, // 0.01% of the volume, 0.1% of impact
+			{size: 100, price: 101}, // 1% of the volume, 1% of impact
+			{size: 400, price: 102}, // 4% of the volume, 2% of impact
+		} {
+			order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", test.size)
+			require.NoError(t, err)
+			require.InDelta(t, test.price, order.Price, 1e-9)
+			require.Greater(t, order.Price, last)
+			last = order.Price
+		}
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 100)
+		require.NoError(t, err)
+		require.InDelta(t, 99.0, order.Price, 1e-9)
+		require.InDelta(t, 0.1+100+800+100, wallet.SpreadCost()["BTCUSDT"], 1e-6)
+	})
+
+	t.Run("with spread", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100000),
+			WithPaperImpactModel(0.1), WithPaperSpread(20))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 10000})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 100)
+		require.NoError(t, err)
+		require.InDelta(t, 101.1, order.Price, 1e-9)
+	})
+
+	t.Run("without volume", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100000),
+			WithPaperImpactModel(0.1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 100)
+		require.NoError(t, err)
+		require.Equal(t, 100.0, order.Price)
+	})
+}
+
+func TestPaperWallet_Slippage(t *testing.T) {
+	t.Run("fixed", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperSlippage(50))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 100.5, order.Price, 1e-9)
+		require.InDelta(t, 100.5, wallet.avgLongPrice["BTCUSDT"], 1e-9)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110, Low: 110, High: 110})
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 109.45, order.Price, 1e-9)
+
+		require.InDelta(t, 100.5+109.45, wallet.Result().Volume["BTCUSDT"], 1e-9)
+		require.InDelta(t, 0.5+0.55, wallet.SpreadCost()["BTCUSDT"], 1e-9)
+		require.InDelta(t, 8.95, wallet.Trades("BTCUSDT")[0].Profit, 1e-9)
+
+		asset, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Zero(t, asset)
+		require.InDelta(t, 1008.95, quote, 1e-9)
+
+		result := wallet.Result()
+		require.Equal(t, WalletPosition{}, result.Positions["BTCUSDT"])
+		require.InDelta(t, 1008.95, result.BaseCoinBalance, 1e-9)
+		require.InDelta(t, 0.5+0.55, result.SpreadCost["BTCUSDT"], 1e-9)
+	})
+
+	t.Run("volume dependent", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000),
+			WithPaperSlippage(50), WithPaperSlippageFunc(func(candle model.Candle, size float64) float64 {
+				return size / candle.Volume * 10_000 // 1 bps for each 0.01% of the volume
+			}))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 1000})
+
+		// 1% of the volume: 100 bps of the function and 50 bps fixed
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+		require.NoError(t, err)
+		require.InDelta(t, 101.5, order.Price, 1e-9)
+
+		order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 99.4, order.Price, 1e-9)
+	})
+}
+
+func TestPaperWallet_FillRatio(t *testing.T) {
+	t.Run("partial fill and cancel", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillRatio(0.1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 10})
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 4, 90)
+		require.NoError(t, err)
+
+		// 10% of the volume of the candle: 2 of 4
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 90, Low: 85, High: 95, Volume: 20})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+		require.Equal(t, 2.0, wallet.Executed(order.ExchangeID))
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 180.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 640.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 180.0, wallet.Result().Volume["BTCUSDT"])
+
+		// only the funds of the remaining quantity are released
+		require.NoError(t, wallet.Cancel(order))
+		require.Zero(t, wallet.assets["USDT"].Lock)
+		require.Equal(t, 820.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Zero(t, wallet.Executed(order.ExchangeID))
+	})
+
+	t.Run("remainder filled in the next candle", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 4),
+			WithPaperAsset("USDT", 0), WithPaperFillRatio(0.1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100, Volume: 10})
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 4, 110)
+		require.NoError(t, err)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110, Low: 100, High: 115, Volume: 30})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 330.0, wallet.assets["USDT"].Free)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 112, Low: 105, High: 115, Volume: 30})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 4.0, order.Quantity)
+		require.Zero(t, wallet.assets["BTC"].Lock)
+		require.Zero(t, wallet.assets["BTC"].Free)
+		require.Equal(t, 440.0, wallet.assets["USDT"].Free)
+	})
+
+	t.Run("without volume", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillRatio(0.1))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 4, 90)
+		require.NoError(t, err)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 90, Low: 85, High: 95})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 4.0, wallet.assets["BTC"].Free)
+	})
+}
+
+func TestPaperWallet_MarkPrice(t *testing.T) {
+	var _ service.MarkPrice = &PaperWallet{}
+
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+	_, err := wallet.MarkPrice("BTCUSDT")
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	prices, _ := wallet.MarkPriceSubscription(ctx, "BTCUSDT")
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 90, High: 110})
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 10, Low: 10, High: 10})
+	require.Equal(t, 100.0, <-prices)
+
+	price, err := wallet.MarkPrice("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 100.0, price)
+
+	// a slow reader receives the latest price
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 101, Low: 101, High: 101})
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 102, Low: 102, High: 102})
+	require.Equal(t, 102.0, <-prices)
+
+	cancel()
+	_, ok := <-prices
+	require.False(t, ok)
+
+	// candles after the cancel are not published
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 103, Low: 103, High: 103})
+}
+
+func TestPaperWallet_BreakEvenPrice(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000), WithPaperFee(0.001, 0.002))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+
+	_, err := wallet.BreakEvenPrice("BTCUSDT")
+	require.ErrorIs(t, err, ErrNoPosition)
+
+	t.Run("long", func(t *testing.T) {
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		price, err := wallet.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 100*1.002/0.998, price, 1e-9)
+
+		// selling at the break-even price recovers the initial balance, after the fees of both orders
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: price, Low: price, High: price})
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.InDelta(t, 10000.0, wallet.assets["USDT"].Free, 1e-9)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		price, err := wallet.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 100*0.998/1.002, price, 1e-9)
+	})
+}
+
+func TestPaperWallet_Allocation(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+		WithPaperAllocation("BTCUSDT", 1000), WithPaperAllocation("ETHUSDT", 500))
+	require.Equal(t, 1600.0, wallet.initialValue)
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start, Close: 100, Low: 100, High: 100, Complete: true})
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Time: start, Close: 10, Low: 10, High: 10, Complete: true})
+	wallet.OnCandle(model.Candle{Pair: "BNBUSDT", Time: start, Close: 1, Low: 1, High: 1, Complete: true})
+
+	// each pair trades only with its allocation
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.NoError(t, err)
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = wallet.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 40, 10)
+	require.NoError(t, err)
+	require.Equal(t, 400.0, wallet.allocations["ETHUSDT"].Lock)
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 20)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// pairs without allocation use the shared balance
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BNBUSDT", 100)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, wallet.assets["USDT"].Free)
+
+	// the loss of BTC does not reduce the funds of ETH
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Hour), Close: 50, Low: 50, High: 50,
+		Complete: true})
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 10)
+	require.NoError(t, err)
+
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 500.0, quote)
+
+	_, quote, err = wallet.Position("ETHUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 500.0, quote)
+
+	// combined balance of the quote
+	account, err := wallet.Account()
+	require.NoError(t, err)
+	_, usdt := account.Balance("BTC", "USDT")
+	require.Equal(t, 600.0, usdt.Free)
+	require.Equal(t, 400.0, usdt.Lock)
+
+	state := wallet.State()
+	require.Equal(t, map[string]float64{"BTCUSDT": 500, "ETHUSDT": 500}, state.Allocations)
+
+	restored := NewPaperWallet(context.Background(), "USDT", WithPaperState(state))
+	require.Equal(t, 1000.0, restored.initialValue)
+}
+
+func TestPaperWallet_FillModel(t *testing.T) {
+	t.Run("next open without same-bar lookahead", func(t *testing.T) {
+		var fills []model.Order
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillModel(FillNextOpen), WithPaperOnFill(func(order model.Order) {
+				fills = append(fills, order)
+			}))
+
+		// signal candle, the strategy sees its close when it is complete
+		signal := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: signal, Open: 90, Close: 100, Low: 90, High: 100,
+			Complete: true})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.Empty(t, fills)
+		require.Equal(t, 800.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 200.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+
+		// a partial update of the signal candle does not fill the order
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: signal, Open: 90, Close: 101, Low: 90, High: 101})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+
+		// filled at the open of the next candle, not at the close of the signal candle or the next one
+		next := signal.Add(time.Hour)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: next, Open: 110, Close: 120, Low: 105, High: 125})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 110.0, order.Price)
+		require.Equal(t, next, order.UpdatedAt)
+		require.Equal(t, 780.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Len(t, fills, 1)
+		require.Equal(t, 110.0, fills[0].Price)
+	})
+
+	t.Run("next open rejected without funds", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperFillModel(FillNextOpen))
+		signal := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: signal, Open: 90, Close: 100, Low: 90, High: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		// gap up above the balance
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: signal.Add(time.Hour), Open: 150, Close: 150})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeRejected, order.Status)
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("next open canceled", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperFillModel(FillNextOpen))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 90, Close: 100})
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.NoError(t, wallet.Cancel(order))
+		require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	tcs := []struct {
+		model string
+		buy   float64
+		sell  float64
+	}{
+		{FillClose, 100, 100},
+		{FillMid, 99, 99},
+		{FillHighLow, 104, 94},
+		{FillVWAP, 99.333333, 99.333333},
+		{"invalid", 100, 100},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.model, func(t *testing.T) {
+			wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+				WithPaperFillModel(tc.model))
+			wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Open: 96, Close: 100, Low: 94, High: 104})
+
+			order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+			require.NoError(t, err)
+			require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+			require.InDelta(t, tc.buy, order.Price, 1e-6)
+
+			order, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+			require.NoError(t, err)
+			require.InDelta(t, tc.sell, order.Price, 1e-6)
+		})
+	}
+}
+func TestPaperWallet_Latency(t *testing.T) {
+	var fills []model.Order
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000), WithPaperLatency(2),
+		WithPaperOnFill(func(order model.Order) {
+			fills = append(fills, order)
+		}))
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start, Open: 90, Close: 100, Low: 90, High: 100})
+
+	order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeNew, order.Status)
+
+	// the next candle is within the latency
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Hour), Open: 110, Close: 115})
+	order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeNew, order.Status)
+	require.Empty(t, fills)
+
+	// filled at the open of the second candle
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(2 * time.Hour), Open: 120, Close: 125})
+	order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	require.Equal(t, 120.0, order.Price)
+	require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+	require.Len(t, fills, 1)
+
+	// pending at the end
+	pending, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(3 * time.Hour), Open: 130, Close: 130})
+
+	result := wallet.Result()
+	require.Len(t, result.Unfilled, 1)
+	require.Equal(t, pending.ExchangeID, result.Unfilled[0].ExchangeID)
+}
+
+func TestPaperWallet_Precision(t *testing.T) {
+	trade := func(mode PrecisionMode) (asset, quote float64) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperPrecision(mode))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1.1})
+
+		for i := 0; i < 1000; i++ {
+			_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+			require.NoError(t, err)
+		}
+
+		return wallet.assets["BTC"].Free, wallet.assets["USDT"].Free
+	}
+
+	// rounding errors accumulated by float64
+	asset, quote := trade(PrecisionFloat)
+	require.NotEqual(t, 100.0, asset)
+	require.NotEqual(t, 890.0, quote)
+	require.InDelta(t, 100.0, asset, 1e-9)
+	require.InDelta(t, 890.0, quote, 1e-9)
+
+	asset, quote = trade(PrecisionDecimal)
+	require.Equal(t, 100.0, asset)
+	require.Equal(t, 890.0, quote)
+}
+
 func TestPaperWallet_OrderMarket(t *testing.T) {
 	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 50})
@@ -268,8 +1096,33 @@ func TestPaperWallet_OrderOCO(t *testing.T) {
 	require.Equal(t, wallet.orders[2].Status, model.OrderStatusTypeFilled)
 }
 
+func TestPaperWallet_OrderOCOPartialPosition(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 200))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.5)
+	require.NoError(t, err)
+
+	// position is not enough for the OCO, the limit leg locks quote for the remaining quantity
+	_, err = wallet.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 150, 80, 79)
+	require.NoError(t, err)
+	require.Equal(t, 75.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 75.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 0.5, wallet.assets["BTC"].Lock)
+
+	// execute stop and cancel limit, the funds locked for the limit leg are released
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 70, Low: 70})
+	require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[1].Status)
+	require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[2].Status)
+	require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+	require.Equal(t, 230.0, wallet.assets["USDT"].Free)
+	require.Equal(t, -0.5, wallet.assets["BTC"].Free)
+}
+
 func TestPaperWallet_Order(t *testing.T) {
 	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
 	expectOrder, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
 	require.NoError(t, err)
 	require.Equal(t, int64(1), expectOrder.ExchangeID)
@@ -342,6 +1195,42 @@ func TestPaperWallet_MaxDrawndown(t *testing.T) {
 			start:  time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC),
 			end:    time.Date(2019, time.January, 8, 0, 0, 0, 0, time.UTC),
 		},
+		{
+			name: "peak to trough",
+			values: []AssetValue{
+				{Time: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), Value: 100},
+				{Time: time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC), Value: 120},
+				{Time: time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC), Value: 80},
+				{Time: time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC), Value: 130},
+				{Time: time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC), Value: 60},
+			},
+			result: (60.0 - 130) / 130,
+			start:  time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC),
+			end:    time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "interrupted by a bounce",
+			values: []AssetValue{
+				{Time: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), Value: 100},
+				{Time: time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC), Value: 80},
+				{Time: time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC), Value: 95},
+				{Time: time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC), Value: 70},
+				{Time: time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC), Value: 99},
+			},
+			result: -0.3,
+			start:  time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC),
+			end:    time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "up only",
+			values: []AssetValue{
+				{Time: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), Value: 100},
+				{Time: time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC), Value: 110},
+			},
+			result: 0,
+			start:  time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC),
+			end:    time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
 	}
 
 	for _, tc := range tt {
@@ -351,13 +1240,268 @@ func TestPaperWallet_MaxDrawndown(t *testing.T) {
 			}
 
 			max, start, end := wallet.MaxDrawdown()
-			require.Equal(t, tc.result, max)
+			require.InDelta(t, tc.result, max, 1e-9)
 			require.Equal(t, tc.start, start)
 			require.Equal(t, tc.end, end)
 		})
 	}
 }
 
+func TestPaperWallet_DrawdownSeries(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		wallet := PaperWallet{}
+		require.Empty(t, wallet.DrawdownSeries())
+	})
+
+	t.Run("running peak", func(t *testing.T) {
+		values := []AssetValue{
+			{Time: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), Value: 4},
+			{Time: time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC), Value: 5},
+			{Time: time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC), Value: 4},
+			{Time: time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC), Value: 8},
+			{Time: time.Date(2019, time.January, 5, 0, 0, 0, 0, time.UTC), Value: 2},
+			{Time: time.Date(2019, time.January, 6, 0, 0, 0, 0, time.UTC), Value: 6},
+		}
+		wallet := PaperWallet{equityValues: values}
+
+		series := wallet.DrawdownSeries()
+		require.Len(t, series, len(values))
+		expected := []float64{0, 0, -0.2, 0, -0.75, -0.25}
+		for i, value := range series {
+			require.Equal(t, values[i].Time, value.Time)
+			require.InDelta(t, expected[i], value.Value, 1e-9)
+		}
+
+		// the max drawdown is the lowest point of the series
+		max, _, _ := wallet.MaxDrawdown()
+		require.InDelta(t, max, series[4].Value, 1e-9)
+	})
+}
+
+func TestPaperWallet_Result(t *testing.T) {
+	wallet := PaperWallet{
+		baseCoin:     "USDT",
+		initialValue: 100,
+		assets:       map[string]*assetInfo{"USDT": {Free: 108.9}},
+		volume:       map[string]float64{"BTCUSDT": 50},
+		equityValues: []AssetValue{
+			{Time: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), Value: 100},
+			{Time: time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC), Value: 110},
+			{Time: time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC), Value: 99},
+			{Time: time.Date(2019, time.January, 4, 0, 0, 0, 0, time.UTC), Value: 108.9},
+		},
+	}
+
+	result := wallet.Result()
+	require.Equal(t, 100.0, result.InitialValue)
+	require.InDelta(t, 108.9, result.FinalValue, 1e-9)
+	require.InDelta(t, 8.9, result.Profit, 1e-9)
+	require.InDelta(t, 0.089, result.ProfitPercent, 1e-9)
+	require.InDelta(t, -0.1, result.MaxDrawdown, 1e-9)
+	require.Equal(t, 50.0, result.Volume["BTCUSDT"])
+
+	// returns of 10%, -10% and 10%
+	require.InDelta(t, 5.515131, result.AnnualSharpe, 1e-6)
+	require.InDelta(t, 11.030261, result.AnnualSortino, 1e-6)
+}
+
+func TestPaperWallet_SharpeSortino(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	wallet := PaperWallet{
+		equityValues: []AssetValue{
+			{Time: start, Value: 100},
+			{Time: start.AddDate(0, 0, 1), Value: 110},
+			{Time: start.AddDate(0, 0, 2), Value: 99},
+			{Time: start.AddDate(0, 0, 3), Value: 108.9},
+		},
+	}
+
+	// daily returns of 10%, -10% and 10%, with a risk-free rate of 1% per day
+	require.InDelta(t, 3.860591, wallet.Sharpe(3.65), 1e-6)
+	require.InDelta(t, 7.019257, wallet.Sortino(3.65), 1e-6)
+
+	// hourly returns are annualized with more periods
+	for i := range wallet.equityValues {
+		wallet.equityValues[i].Time = start.Add(time.Duration(i) * time.Hour)
+	}
+	require.InDelta(t, 0.288675*math.Sqrt(365*24), wallet.Sharpe(0), 1e-4)
+
+	// without downside returns
+	wallet.equityValues[2].Value = 120
+	wallet.equityValues[3].Value = 130
+	require.Zero(t, wallet.Sortino(0))
+
+	wallet.equityValues = wallet.equityValues[:1]
+	require.Zero(t, wallet.Sharpe(0))
+	require.Zero(t, wallet.Sortino(0))
+}
+
+func TestPaperWallet_Trades(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+	start := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	update := func(hours int, price float64) {
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(hours) * time.Hour),
+			Close: price, Low: price, High: price})
+	}
+
+	update(0, 100)
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Empty(t, wallet.Trades("BTCUSDT"))
+
+	// partial close
+	update(1, 110)
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.4)
+	require.NoError(t, err)
+
+	// closes the remaining 0.6 and opens a short of 1
+	update(2, 120)
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1.6)
+	require.NoError(t, err)
+
+	update(3, 100)
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	trades := wallet.Trades("BTCUSDT")
+	require.Len(t, trades, 3)
+	expected := []Trade{
+		{Side: model.SideTypeBuy, EntryPrice: 100, ExitPrice: 110, Quantity: 0.4, Profit: 4, ProfitPercent: 0.1},
+		{Side: model.SideTypeBuy, EntryPrice: 100, ExitPrice: 120, Quantity: 0.6, Profit: 12, ProfitPercent: 0.2},
+		{Side: model.SideTypeSell, EntryPrice: 120, ExitPrice: 100, Quantity: 1, Profit: 20, ProfitPercent: 1.0 / 6},
+	}
+	for i, trade := range expected {
+		require.Equal(t, "BTCUSDT", trades[i].Pair)
+		require.Equal(t, trade.Side, trades[i].Side)
+		require.InDelta(t, trade.EntryPrice, trades[i].EntryPrice, 1e-9)
+		require.InDelta(t, trade.ExitPrice, trades[i].ExitPrice, 1e-9)
+		require.InDelta(t, trade.Quantity, trades[i].Quantity, 1e-9)
+		require.InDelta(t, trade.Profit, trades[i].Profit, 1e-9)
+		require.InDelta(t, trade.ProfitPercent, trades[i].ProfitPercent, 1e-9)
+		require.Equal(t, start.Add(time.Duration(i+1)*time.Hour), trades[i].Time)
+	}
+
+	require.Len(t, wallet.Trades(""), 3)
+	require.Empty(t, wallet.Trades("ETHUSDT"))
+	result := wallet.Result()
+	require.InDelta(t, 36, result.RealizedProfit["BTCUSDT"], 1e-9)
+	require.Equal(t, map[string]int{"BTCUSDT": 3}, result.Trades)
+}
+
+func TestPaperWallet_NoMarketData(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+
+	_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	_, err = wallet.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 100, 40, 39)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	_, err = wallet.CreateOrderStop(model.SideTypeSell, "BTCUSDT", 1, 50)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	_, err = wallet.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 10)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	// funds are not locked and no order is created
+	require.Empty(t, wallet.orders)
+	require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+	// candle of another pair is not a reference
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 10})
+	_, err = wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	_, err = wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+	require.NoError(t, err)
+}
+
+func TestPaperWallet_Cancel(t *testing.T) {
+	t.Run("limit order", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 80)
+		require.NoError(t, err)
+		require.Equal(t, 20.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 80.0, wallet.assets["USDT"].Lock)
+
+		require.NoError(t, wallet.Cancel(order))
+		require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[0].Status)
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+		// cancel again should not release funds twice
+		require.NoError(t, wallet.Cancel(order))
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("oco order", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		orders, err := wallet.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 150, 80, 79)
+		require.NoError(t, err)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Lock)
+
+		require.NoError(t, wallet.Cancel(orders[1]))
+		require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[1].Status)
+		require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[2].Status)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+	})
+
+	t.Run("order not found", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
+		require.Error(t, wallet.Cancel(model.Order{ExchangeID: 42}))
+	})
+}
+
+func TestPaperWallet_CancelAll(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 200))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100})
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	_, err = wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 50)
+	require.NoError(t, err)
+	_, err = wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 200)
+	require.NoError(t, err)
+
+	require.Len(t, wallet.OpenOrders("BTCUSDT"), 2)
+	require.Empty(t, wallet.OpenOrders("ETHUSDT"))
+	require.Equal(t, 50.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 50.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Lock)
+
+	wallet.CancelAll("BTCUSDT")
+	require.Empty(t, wallet.OpenOrders("BTCUSDT"))
+	require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+	require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[1].Status)
+	require.Equal(t, model.OrderStatusTypeCanceled, wallet.orders[2].Status)
+
+	// locked funds are restored
+	require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+
+	// canceled orders are not executed
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 40, High: 300})
+	require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+}
+
 func TestPaperWallet_AssetsInfo(t *testing.T) {
 	wallet := PaperWallet{}
 	info := wallet.AssetsInfo("BTCUSDT")
@@ -373,7 +1517,7 @@ func TestPaperWallet_CreateOrderStop(t *testing.T) {
 		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
 		require.NoError(t, err)
 
-		order, err := wallet.CreateOrderStop("BTCUSDT", 1, 50)
+		order, err := wallet.CreateOrderStop(model.SideTypeSell, "BTCUSDT", 1, 50)
 		require.NoError(t, err)
 
 		// create order and lock values
@@ -393,6 +1537,126 @@ func TestPaperWallet_CreateOrderStop(t *testing.T) {
 		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
 		require.Equal(t, 100.0, wallet.avgLongPrice["BTCUSDT"])
 	})
+
+	t.Run("buy stop", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 200))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Low: 95, High: 105})
+
+		order, err := wallet.CreateOrderStop(model.SideTypeBuy, "BTCUSDT", 1, 120)
+		require.NoError(t, err)
+		require.Equal(t, model.SideTypeBuy, order.Side)
+		require.Equal(t, model.OrderTypeStopLossLimit, order.Type)
+		require.Equal(t, 80.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 120.0, wallet.assets["USDT"].Lock)
+
+		// below the stop
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 105, Low: 95, High: 110})
+		require.Equal(t, model.OrderStatusTypeNew, wallet.orders[0].Status)
+
+		// breakout above the stop
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 122, Low: 105, High: 125})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+		require.Equal(t, 80.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 120.0, wallet.avgLongPrice["BTCUSDT"])
+	})
+}
+
+func TestPaperWallet_OnFill(t *testing.T) {
+	var fills []model.Order
+	var wallet *PaperWallet
+	wallet = NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 300),
+		WithPaperOnFill(func(order model.Order) {
+			// wallet lock must be released during the callback
+			_, _, err := wallet.Position(order.Pair)
+			require.NoError(t, err)
+			fills = append(fills, order)
+		}))
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	market, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Len(t, fills, 1)
+	require.Equal(t, market, fills[0])
+
+	limit, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+	require.Len(t, fills, 1)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 90})
+	require.Len(t, fills, 2)
+	require.Equal(t, limit.ExchangeID, fills[1].ExchangeID)
+	require.Equal(t, model.OrderStatusTypeFilled, fills[1].Status)
+
+	// failed orders are not reported
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.Error(t, err)
+	require.Len(t, fills, 2)
+}
+
+func TestPaperWallet_OrderIDs(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	ids := make(map[int64]bool)
+	for i := 0; i < 3; i++ {
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		ids[order.ExchangeID] = true
+	}
+	require.Equal(t, map[int64]bool{1: true, 2: true, 3: true}, ids)
+
+	// the sequence continues after a snapshot and restore
+	content, err := json.Marshal(wallet.State())
+	require.NoError(t, err)
+	var state WalletState
+	require.NoError(t, json.Unmarshal(content, &state))
+	require.Equal(t, int64(3), state.LastOrderID)
+
+	restored := NewPaperWallet(context.Background(), "USDT", WithPaperState(state), WithPaperOrderIDStart(2))
+	restored.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	for i := 0; i < 3; i++ {
+		order, err := restored.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.False(t, ids[order.ExchangeID], "reused ID %d", order.ExchangeID)
+		ids[order.ExchangeID] = true
+	}
+	require.Len(t, ids, 6)
+
+	// distinct range of IDs
+	other := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000), WithPaperOrderIDStart(1000))
+	other.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	order, err := other.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), order.ExchangeID)
+}
+
+func TestPaperWallet_QuoteValidator(t *testing.T) {
+	feeder := mocks.NewFeeder(t)
+	feeder.EXPECT().LastQuote(mock.Anything, "BTCUSDT").Return(102, nil).Once()
+	feeder.EXPECT().LastQuote(mock.Anything, "BTCUSDT").Return(95, nil).Once()
+
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 300),
+		WithDataFeed(feeder), WithPaperQuoteValidator(0.01))
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	deviations := wallet.QuoteDeviations()
+	require.Len(t, deviations, 2)
+
+	// bought at 100 with market at 102: optimistic simulation
+	require.Equal(t, 102.0, deviations[0].Quote)
+	require.InDelta(t, 0.0196, deviations[0].Deviation, 0.0001)
+
+	// sold at 100 with market at 95: optimistic simulation
+	require.Equal(t, 95.0, deviations[1].Quote)
+	require.InDelta(t, 0.0526, deviations[1].Deviation, 0.0001)
 }
 
 func TestUpdateAveragePrice(t *testing.T) {
@@ -552,3 +1816,30 @@ func TestUpdateAveragePrice(t *testing.T) {
 	})
 
 }
+
+func TestPaperWallet_OpenPositions(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000))
+	var _ service.Positions = wallet
+	for pair, price := range map[string]float64{"BTCUSDT": 1000, "ETHUSDT": 100, "SOLUSDT": 10} {
+		wallet.OnCandle(model.Candle{Pair: pair, Close: price})
+	}
+	require.Zero(t, wallet.OpenPositionCount())
+
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "ETHUSDT", 2)
+	require.NoError(t, err)
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "SOLUSDT", 10)
+	require.NoError(t, err)
+
+	// the asset locked by an order is still in the position
+	_, err = wallet.CreateOrderLimit(model.SideTypeSell, "SOLUSDT", 10, 20)
+	require.NoError(t, err)
+
+	require.Equal(t, 3, wallet.OpenPositionCount())
+	require.Equal(t, map[string]float64{"BTCUSDT": 1, "ETHUSDT": -2, "SOLUSDT": 10}, wallet.OpenPositions())
+
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, wallet.OpenPositionCount())
+}
@@ -0,0 +1,100 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// PercentQuantity returns the quantity of an order that uses a fraction (e.g. 0.5 for 50%) of the free balance,
+// the quote balance for buys and the asset balance for sells. The quantity is rounded down to the step size,
+// so the order never exceeds the balance, and validated with the minimum quantity and notional of the pair.
+func PercentQuantity(info model.AssetInfo, side model.SideType, asset, quote, price, percent float64) (float64, error) {
+	if percent <= 0 || percent > 1 {
+		return 0, fmt.Errorf("%w: percent %f out of range (0, 1]", ErrInvalidQuantity, percent)
+	}
+
+	if price <= 0 {
+		return 0, ErrNoMarketData
+	}
+
+	quantity := asset * percent
+	if side == model.SideTypeBuy {
+		quantity = quote * percent / price
+	}
+
+	quantity = roundToStep(quantity, info.StepSize, info.BaseAssetPrecision, RoundingFloor)
+	if quantity <= 0 || quantity < info.MinQuantity {
+		return 0, fmt.Errorf("%w: %f below the minimum quantity", ErrInvalidQuantity, quantity)
+	}
+
+	if quantity*price < info.MinNotional {
+		return 0, fmt.Errorf("%w: %f below %f", ErrMinNotional, quantity*price, info.MinNotional)
+	}
+
+	return quantity, nil
+}
+
+// createOrderPercent creates a market order with a fraction of the free balance of the exchange account
+func createOrderPercent(exchange service.Exchange, side model.SideType, pair string,
+	percent float64) (model.Order, error) {
+
+	account, err := exchange.Account()
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	price, err := exchange.LastQuote(context.Background(), pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	assetTick, quoteTick := SplitAssetQuote(pair)
+	asset, quote := account.Balance(assetTick, quoteTick)
+	quantity, err := PercentQuantity(exchange.AssetsInfo(pair), side, asset.Free, quote.Free, price, percent)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	return exchange.CreateOrderMarket(side, pair, quantity)
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order of the exchange, e.g. 0.5 to
+// close half of the position or 1 to close it entirely. Long positions are closed with a sell order and short
+// positions with a buy order, see PercentQuantity. It returns ErrNoPosition without position.
+func ClosePosition(exchange service.Exchange, pair string, fraction float64) (model.Order, error) {
+	if fraction <= 0 || fraction > 1 {
+		return model.Order{}, fmt.Errorf("%w: fraction %f out of range (0, 1]", ErrInvalidQuantity, fraction)
+	}
+
+	position, _, err := exchange.Position(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if position == 0 {
+		return model.Order{}, ErrNoPosition
+	}
+
+	side := model.SideTypeSell
+	if position < 0 {
+		side = model.SideTypeBuy
+	}
+
+	price, err := exchange.LastQuote(context.Background(), pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// the side of PercentQuantity is always a sell to use a fraction of the asset
+	quantity, err := PercentQuantity(exchange.AssetsInfo(pair), model.SideTypeSell, math.Abs(position), 0, price,
+		fraction)
+	if err != nil {
+		return model.Order{}, fmt.Errorf("close position %s: %w", pair, err)
+	}
+
+	return exchange.CreateOrderMarket(side, pair, quantity)
+}
@@ -0,0 +1,59 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestPercentQuantity(t *testing.T) {
+	info := model.AssetInfo{
+		StepSize:           0.001,
+		MinQuantity:        0.001,
+		MinNotional:        10,
+		BaseAssetPrecision: 8,
+	}
+
+	tt := []struct {
+		name     string
+		side     model.SideType
+		asset    float64
+		quote    float64
+		price    float64
+		percent  float64
+		expected float64
+		err      error
+	}{
+		{name: "buy half", side: model.SideTypeBuy, quote: 1000, price: 300, percent: 0.5, expected: 1.666},
+		{name: "buy all", side: model.SideTypeBuy, asset: 10, quote: 600, price: 300, percent: 1, expected: 2},
+		{name: "sell all", side: model.SideTypeSell, asset: 0.12345, price: 300, percent: 1, expected: 0.123},
+		{name: "sell quarter", side: model.SideTypeSell, asset: 2, quote: 1000, price: 300, percent: 0.25,
+			expected: 0.5},
+		{name: "below min notional", side: model.SideTypeBuy, quote: 20, price: 300, percent: 0.4,
+			err: ErrMinNotional},
+		{name: "below step size", side: model.SideTypeSell, asset: 0.0009, price: 300, percent: 1,
+			err: ErrInvalidQuantity},
+		{name: "short position", side: model.SideTypeSell, asset: -1, price: 300, percent: 1,
+			err: ErrInvalidQuantity},
+		{name: "percent above one", side: model.SideTypeBuy, quote: 1000, price: 300, percent: 1.5,
+			err: ErrInvalidQuantity},
+		{name: "zero percent", side: model.SideTypeBuy, quote: 1000, price: 300, percent: 0,
+			err: ErrInvalidQuantity},
+		{name: "no price", side: model.SideTypeBuy, quote: 1000, percent: 0.5, err: ErrNoMarketData},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			quantity, err := PercentQuantity(info, tc.side, tc.asset, tc.quote, tc.price, tc.percent)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.InDelta(t, tc.expected, quantity, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"math/big"
+	"strconv"
+)
+
+// PrecisionMode defines the arithmetic used by the paper wallet for balances and average prices
+type PrecisionMode int
+
+const (
+	// PrecisionFloat uses float64 arithmetic, the fastest mode, but rounding errors accumulate over
+	// millions of operations, e.g. adding 0.1 ten times results in 0.9999999999999999.
+	PrecisionFloat PrecisionMode = iota
+	// PrecisionDecimal computes each operation with the exact decimal value of the operands, as written
+	// in the shortest representation of the float, and rounds only the result. The errors do not accumulate,
+	// but each operation is about two orders of magnitude slower than PrecisionFloat.
+	PrecisionDecimal
+)
+
+// decimal converts a float to the exact value of its shortest decimal representation, e.g. 0.1 is 1/10
+// instead of 0.1000000000000000055511151231257827
+func decimal(value float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	if !ok { // NaN or Inf, not representable as a rational number
+		return nil
+	}
+	return r
+}
+
+// decimalOperation applies an operation to the decimal values of a and b, falling back to the float
+// result for values without decimal representation
+func decimalOperation(a, b float64, op func(z, x, y *big.Rat) *big.Rat, fallback float64) float64 {
+	x, y := decimal(a), decimal(b)
+	if x == nil || y == nil {
+		return fallback
+	}
+
+	result, _ := op(new(big.Rat), x, y).Float64()
+	return result
+}
+
+func (p *PaperWallet) add(a, b float64) float64 {
+	if p.precision != PrecisionDecimal {
+		return a + b
+	}
+	return decimalOperation(a, b, (*big.Rat).Add, a+b)
+}
+
+func (p *PaperWallet) sub(a, b float64) float64 {
+	if p.precision != PrecisionDecimal {
+		return a - b
+	}
+	return decimalOperation(a, b, (*big.Rat).Sub, a-b)
+}
+
+func (p *PaperWallet) mul(a, b float64) float64 {
+	if p.precision != PrecisionDecimal {
+		return a * b
+	}
+	return decimalOperation(a, b, (*big.Rat).Mul, a*b)
+}
+
+func (p *PaperWallet) div(a, b float64) float64 {
+	if p.precision != PrecisionDecimal || b == 0 {
+		return a / b
+	}
+	return decimalOperation(a, b, (*big.Rat).Quo, a/b)
+}
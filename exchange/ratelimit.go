@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// request weights of the Binance API, see https://binance-docs.github.io/apidocs/spot/en/#limits
+const (
+	weightKlines     = 2
+	weightQueryOrder = 4
+	weightOrder      = 1
+)
+
+// weightLimiter is a token bucket of request weight, it holds up to the weight of a minute and is refilled
+// continuously. A nil limiter does not limit.
+type weightLimiter struct {
+	mtx      sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // weight per second
+	last     time.Time
+}
+
+func newWeightLimiter(weightPerMinute int) *weightLimiter {
+	return &weightLimiter{
+		capacity: float64(weightPerMinute),
+		tokens:   float64(weightPerMinute),
+		rate:     float64(weightPerMinute) / time.Minute.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// wait takes the weight of a request from the bucket, blocking until it is available or the context is done.
+// A weight above the capacity takes the whole bucket.
+func (l *weightLimiter) wait(ctx context.Context, weight int) error {
+	if l == nil {
+		return nil
+	}
+
+	required := float64(weight)
+	if required > l.capacity {
+		required = l.capacity
+	}
+
+	for {
+		l.mtx.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.capacity {
+			l.tokens = l.capacity
+		}
+		l.last = now
+
+		if l.tokens >= required {
+			l.tokens -= required
+			l.mtx.Unlock()
+			return nil
+		}
+
+		delay := time.Duration((required - l.tokens) / l.rate * float64(time.Second))
+		l.mtx.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightLimiter(t *testing.T) {
+	t.Run("without limit", func(t *testing.T) {
+		var limiter *weightLimiter
+		require.NoError(t, limiter.wait(context.Background(), 1000))
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		// 100 of weight per second
+		limiter := newWeightLimiter(6000)
+		require.NoError(t, limiter.wait(context.Background(), 6000))
+
+		start := time.Now()
+		require.NoError(t, limiter.wait(context.Background(), 2))
+		require.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		limiter := newWeightLimiter(60)
+		require.NoError(t, limiter.wait(context.Background(), 60))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, limiter.wait(ctx, 1), context.DeadlineExceeded)
+	})
+
+	t.Run("weight above the capacity", func(t *testing.T) {
+		limiter := newWeightLimiter(10)
+		require.NoError(t, limiter.wait(context.Background(), 20))
+	})
+}
+
+func TestBinance_RateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := binance.NewClient("", "")
+	client.BaseURL = server.URL
+	exchange := Binance{client: client}
+	WithBinanceRateLimit(weightKlines)(&exchange)
+
+	_, err := exchange.CandlesByLimit(context.Background(), "BTCUSDT", "1h", 10)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// the bucket is empty, the request is not sent
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = exchange.CandlesByPeriod(ctx, "BTCUSDT", "1h", time.Now().Add(-time.Hour), time.Now())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
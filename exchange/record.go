@@ -0,0 +1,293 @@
+package exchange
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// Sources of the recorded candles
+const (
+	// RecordHistory is a candle returned by CandlesByLimit or CandlesByPeriod, e.g. the warmup of a strategy
+	RecordHistory = "history"
+	// RecordStream is a candle received by a subscription, partial candles included
+	RecordStream = "stream"
+)
+
+// RecordedCandle is a line of a recording of RecordFeed, the file is in the JSON lines format: a JSON object
+// by line, in the order the candles were received, e.g.
+//
+//	{"source":"stream","pair":"BTCUSDT","timeframe":"1h","time":"2021-05-13T10:00:00Z",...,"complete":false}
+type RecordedCandle struct {
+	Source    string             `json:"source"`
+	Pair      string             `json:"pair"`
+	Timeframe string             `json:"timeframe"`
+	Time      time.Time          `json:"time"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Open      float64            `json:"open"`
+	Close     float64            `json:"close"`
+	Low       float64            `json:"low"`
+	High      float64            `json:"high"`
+	Volume    float64            `json:"volume"`
+	Complete  bool               `json:"complete"`
+	Metadata  map[string]float64 `json:"metadata"`
+}
+
+func (r RecordedCandle) Candle() model.Candle {
+	return model.Candle{
+		Pair:      r.Pair,
+		Time:      r.Time,
+		UpdatedAt: r.UpdatedAt,
+		Open:      r.Open,
+		Close:     r.Close,
+		Low:       r.Low,
+		High:      r.High,
+		Volume:    r.Volume,
+		Complete:  r.Complete,
+		Metadata:  r.Metadata,
+	}
+}
+
+// Recorder is a feeder that records the candles of another feeder to a file, see RecordFeed
+type Recorder struct {
+	service.Feeder
+	mtx     sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// RecordFeed wraps a feeder to record its candles to a file, e.g. the live candles of an exchange, to replay
+// them offline with ReplayRecordedFeed and reproduce the decisions of a live session. The history requests and
+// the candles of the subscriptions are recorded as received, see RecordedCandle. The candles are appended to
+// the file, which must be closed with Close.
+func RecordFeed(feed service.Feeder, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("record feed: %w", err)
+	}
+
+	return &Recorder{
+		Feeder:  feed,
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (r *Recorder) record(source, timeframe string, candles ...model.Candle) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, candle := range candles {
+		err := r.encoder.Encode(RecordedCandle{
+			Source:    source,
+			Pair:      candle.Pair,
+			Timeframe: timeframe,
+			Time:      candle.Time,
+			UpdatedAt: candle.UpdatedAt,
+			Open:      candle.Open,
+			Close:     candle.Close,
+			Low:       candle.Low,
+			High:      candle.High,
+			Volume:    candle.Volume,
+			Complete:  candle.Complete,
+			Metadata:  candle.Metadata,
+		})
+		if err != nil {
+			log.Errorf("recorder: %s-%s: %v", candle.Pair, timeframe, err)
+		}
+	}
+}
+
+func (r *Recorder) CandlesByPeriod(ctx context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	candles, err := r.Feeder.CandlesByPeriod(ctx, pair, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+	r.record(RecordHistory, period, candles...)
+	return candles, nil
+}
+
+func (r *Recorder) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	candles, err := r.Feeder.CandlesByLimit(ctx, pair, period, limit)
+	if err != nil {
+		return nil, err
+	}
+	r.record(RecordHistory, period, candles...)
+	return candles, nil
+}
+
+// CandlesSubscription records the candles of the subscription before forwarding them
+func (r *Recorder) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	candles, errs := r.Feeder.CandlesSubscription(ctx, pair, timeframe)
+
+	go func() {
+		defer close(cerr)
+		defer close(ccandle)
+
+		for {
+			select {
+			case candle, ok := <-candles:
+				if !ok {
+					return
+				}
+				r.record(RecordStream, timeframe, candle)
+				select {
+				case ccandle <- candle:
+				case <-ctx.Done():
+					return
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				select {
+				case cerr <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
+
+// Close closes the file of the recording
+func (r *Recorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.file.Close()
+}
+
+// RecordedFeed is a feeder that replays a recording of RecordFeed, see ReplayRecordedFeed
+type RecordedFeed struct {
+	history map[string][]model.Candle
+	stream  map[string][]model.Candle
+}
+
+// ReplayRecordedFeed reads a recording of RecordFeed. The subscriptions replay the recorded stream of the
+// pair and timeframe in the same order, partial candles included, and the history requests return the
+// recorded history, so a strategy is warmed with the same candles. It can be used as the feeder of a paper
+// wallet in a backtest, to reproduce a live session deterministically.
+func ReplayRecordedFeed(path string) (*RecordedFeed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay feed: %w", err)
+	}
+	defer file.Close()
+
+	feed := &RecordedFeed{
+		history: make(map[string][]model.Candle),
+		stream:  make(map[string][]model.Candle),
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record RecordedCandle
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("replay feed: line %d: %w", line, err)
+		}
+
+		key := feed.key(record.Pair, record.Timeframe)
+		if record.Source == RecordHistory {
+			feed.history[key] = append(feed.history[key], record.Candle())
+		} else {
+			feed.stream[key] = append(feed.stream[key], record.Candle())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay feed: %w", err)
+	}
+
+	return feed, nil
+}
+
+func (f RecordedFeed) key(pair, timeframe string) string {
+	return fmt.Sprintf("%s--%s", pair, timeframe)
+}
+
+func (f RecordedFeed) AssetsInfo(pair string) model.AssetInfo {
+	return CSVFeed{}.AssetsInfo(pair)
+}
+
+// LastQuote returns the close of the last recorded candle of the pair
+func (f RecordedFeed) LastQuote(_ context.Context, pair string) (float64, error) {
+	var last model.Candle
+	for _, recorded := range []map[string][]model.Candle{f.history, f.stream} {
+		for _, candles := range recorded {
+			if len(candles) == 0 || candles[0].Pair != pair {
+				continue
+			}
+			if candle := candles[len(candles)-1]; !candle.Time.Before(last.Time) {
+				last = candle
+			}
+		}
+	}
+
+	if last.Empty() {
+		return 0, fmt.Errorf("%w: %s", ErrNoMarketData, pair)
+	}
+	return last.Close, nil
+}
+
+func (f RecordedFeed) CandlesByPeriod(_ context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	candles := make([]model.Candle, 0)
+	for _, candle := range f.history[f.key(pair, period)] {
+		if candle.Time.Before(start) || candle.Time.After(end) {
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// CandlesByLimit returns the last recorded history candles of the pair and timeframe
+func (f RecordedFeed) CandlesByLimit(_ context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	candles := f.history[f.key(pair, period)]
+	if len(candles) < limit {
+		return nil, fmt.Errorf("%w: %s", ErrInsufficientData, pair)
+	}
+	return candles[len(candles)-limit:], nil
+}
+
+func (f RecordedFeed) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	candles := f.stream[f.key(pair, timeframe)]
+
+	go func() {
+		defer close(cerr)
+		defer close(ccandle)
+
+		for _, candle := range candles {
+			select {
+			case ccandle <- candle:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func requireSameCandles(t *testing.T, expected, actual []model.Candle) {
+	t.Helper()
+
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.True(t, expected[i].Time.Equal(actual[i].Time))
+		require.True(t, expected[i].UpdatedAt.Equal(actual[i].UpdatedAt))
+		actual[i].Time, actual[i].UpdatedAt = expected[i].Time, expected[i].UpdatedAt
+		require.Equal(t, expected[i], actual[i])
+	}
+}
+
+func TestRecordFeed(t *testing.T) {
+	ctx := context.Background()
+	source, err := NewCSVFeed("1d", PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "../testdata/btc-1h-2021-05-13.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "feed.jsonl")
+	recorder, err := RecordFeed(source, path)
+	require.NoError(t, err)
+
+	history, err := recorder.CandlesByLimit(ctx, "BTCUSDT", "1d", 5)
+	require.NoError(t, err)
+
+	var streamed []model.Candle
+	candles, _ := recorder.CandlesSubscription(ctx, "BTCUSDT", "1d")
+	for candle := range candles {
+		streamed = append(streamed, candle)
+	}
+	require.Len(t, streamed, 19)
+	require.False(t, streamed[0].Complete)
+	require.True(t, streamed[len(streamed)-1].Complete)
+	require.NoError(t, recorder.Close())
+
+	replay, err := ReplayRecordedFeed(path)
+	require.NoError(t, err)
+
+	replayedHistory, err := replay.CandlesByLimit(ctx, "BTCUSDT", "1d", 5)
+	require.NoError(t, err)
+	requireSameCandles(t, history, replayedHistory)
+
+	_, err = replay.CandlesByLimit(ctx, "BTCUSDT", "1d", 6)
+	require.ErrorIs(t, err, ErrInsufficientData)
+
+	// the stream is replayed in the same order, partial candles included
+	var replayed []model.Candle
+	candles, _ = replay.CandlesSubscription(ctx, "BTCUSDT", "1d")
+	for candle := range candles {
+		replayed = append(replayed, candle)
+	}
+	requireSameCandles(t, streamed, replayed)
+
+	quote, err := replay.LastQuote(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, streamed[len(streamed)-1].Close, quote)
+
+	_, err = replay.LastQuote(ctx, "ETHUSDT")
+	require.ErrorIs(t, err, ErrNoMarketData)
+
+	t.Run("invalid line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalid.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("{\"pair\":\"BTCUSDT\"}\ninvalid\n"), 0600))
+
+		_, err := ReplayRecordedFeed(path)
+		require.ErrorContains(t, err, "line 2")
+	})
+}
@@ -0,0 +1,39 @@
+package exchange
+
+import "math"
+
+// RoundingMode defines how quantities and prices are adjusted to the step size of the exchange
+type RoundingMode int
+
+const (
+	// RoundingFloor rounds down to the step size, so an order never requires more than the available balance
+	RoundingFloor RoundingMode = iota
+	// RoundingNearest rounds to the nearest step size
+	RoundingNearest
+)
+
+// stepTolerance absorbs floating point errors in the division by the step size, e.g. 0.3 / 0.1 = 2.9999999999999996.
+// It is relative to the number of steps and close to the float64 precision, so it does not change values that are
+// really below the step boundary, e.g. 1.9999999999999 with a step of 0.00001.
+const stepTolerance = 1e-15
+
+// floorSteps floors the number of steps, tolerating floating point errors of the division
+func floorSteps(steps float64) float64 {
+	return math.Floor(steps + math.Abs(steps)*stepTolerance)
+}
+
+// roundToStep adjusts the value to a multiple of the step size, limited to the given decimal precision
+func roundToStep(value, step float64, precision int, mode RoundingMode) float64 {
+	if step <= 0 {
+		return value
+	}
+
+	pow := math.Pow10(precision)
+	if mode == RoundingNearest {
+		value = math.Round(value/step) * step
+		return math.Round(value*pow) / pow
+	}
+
+	value = floorSteps(value/step) * step
+	return floorSteps(value*pow) / pow
+}
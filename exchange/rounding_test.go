@@ -0,0 +1,41 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundToStep(t *testing.T) {
+	tt := []struct {
+		name      string
+		value     float64
+		step      float64
+		precision int
+		mode      RoundingMode
+		expected  float64
+	}{
+		{name: "floor exact step", value: 0.3, step: 0.1, precision: 8, mode: RoundingFloor, expected: 0.3},
+		{name: "floor below step", value: 0.29999, step: 0.1, precision: 8, mode: RoundingFloor, expected: 0.2},
+		{name: "floor above step", value: 0.30001, step: 0.1, precision: 8, mode: RoundingFloor, expected: 0.3},
+		{name: "floor small step", value: 1.23456789, step: 0.001, precision: 8, mode: RoundingFloor,
+			expected: 1.234},
+		{name: "floor close to step", value: 1.9999999999999, step: 0.00001, precision: 5, mode: RoundingFloor,
+			expected: 1.99999},
+		{name: "floor integer step", value: 19.99, step: 1, precision: 0, mode: RoundingFloor, expected: 19},
+		{name: "nearest down", value: 0.34, step: 0.1, precision: 8, mode: RoundingNearest, expected: 0.3},
+		{name: "nearest up", value: 0.36, step: 0.1, precision: 8, mode: RoundingNearest, expected: 0.4},
+		{name: "nearest exact step", value: 0.3, step: 0.1, precision: 8, mode: RoundingNearest, expected: 0.3},
+		{name: "precision limit", value: 0.123456789, step: 0.00000001, precision: 4, mode: RoundingFloor,
+			expected: 0.1234},
+		{name: "nearest precision limit", value: 0.123456789, step: 0.00000001, precision: 4, mode: RoundingNearest,
+			expected: 0.1235},
+		{name: "no step", value: 0.123, step: 0, precision: 8, mode: RoundingFloor, expected: 0.123},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, roundToStep(tc.value, tc.step, tc.precision, tc.mode))
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// SignalOnly is a broker decorator that intercepts order creation and sends it as a notification
+// instead of executing it. Market data and account queries are delegated to the wrapped exchange.
+// There is no simulated fill or balance: signals remain with status NEW until canceled.
+type SignalOnly struct {
+	service.Exchange
+	mtx      sync.Mutex
+	notifier service.Notifier
+	counter  int64
+	orders   map[int64]model.Order
+}
+
+func NewSignalOnly(exchange service.Exchange, notifier service.Notifier) *SignalOnly {
+	return &SignalOnly{
+		Exchange: exchange,
+		notifier: notifier,
+		orders:   make(map[int64]model.Order),
+	}
+}
+
+func (s *SignalOnly) signal(order model.Order) model.Order {
+	s.counter++
+	order.ExchangeID = s.counter
+	order.Status = model.OrderStatusTypeNew
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+	s.orders[order.ExchangeID] = order
+
+	message := fmt.Sprintf("[SIGNAL] %s %s %s | %f x $%f", order.Type, order.Side, order.Pair,
+		order.Quantity, order.Price)
+	if order.Tag != "" {
+		message += fmt.Sprintf(" | %s", order.Tag)
+	}
+	log.Info(message)
+	if s.notifier != nil {
+		s.notifier.Notify(message)
+	}
+
+	return order
+}
+
+func (s *SignalOnly) Order(_ string, id int64) (model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return model.Order{}, errors.New("order not found")
+	}
+	return order, nil
+}
+
+func (s *SignalOnly) CreateOrderOCO(side model.SideType, pair string,
+	size, price, stop, stopLimit float64) ([]model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if size == 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	s.counter++
+	groupID := s.counter
+	limitMaker := s.signal(model.Order{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeLimitMaker,
+		Price:    price,
+		Quantity: size,
+		GroupID:  &groupID,
+	})
+
+	stopOrder := s.signal(model.Order{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeStopLoss,
+		Price:    stopLimit,
+		Stop:     &stop,
+		Quantity: size,
+		GroupID:  &groupID,
+	})
+
+	return []model.Order{limitMaker, stopOrder}, nil
+}
+
+func (s *SignalOnly) CreateOrderLimit(side model.SideType, pair string,
+	size float64, limit float64) (model.Order, error) {
+	return s.CreateOrderLimitTIF(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF sends a limit order signal, the time in force is only informative
+func (s *SignalOnly) CreateOrderLimitTIF(side model.SideType, pair string,
+	size float64, limit float64, _ model.TimeInForceType) (model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if size == 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+
+	return s.signal(model.Order{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeLimit,
+		Price:    limit,
+		Quantity: size,
+	}), nil
+}
+
+func (s *SignalOnly) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return s.CreateOrderMarketTagged(side, pair, size, "")
+}
+
+func (s *SignalOnly) CreateOrderMarketTagged(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if size == 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+
+	return s.signal(model.Order{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeMarket,
+		Quantity: size,
+		Tag:      tag,
+	}), nil
+}
+
+func (s *SignalOnly) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if quote == 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+
+	// quantity is unknown without a fill, the quote amount is reported as price
+	return s.signal(model.Order{
+		Pair:  pair,
+		Side:  side,
+		Type:  model.OrderTypeMarket,
+		Price: quote,
+	}), nil
+}
+
+// BuyPercent signals a market order to buy with a fraction of the free quote balance, see PercentQuantity
+func (s *SignalOnly) BuyPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(s, model.SideTypeBuy, pair, percent)
+}
+
+// SellPercent signals a market order to sell a fraction of the free asset balance, see PercentQuantity
+func (s *SignalOnly) SellPercent(pair string, percent float64) (model.Order, error) {
+	return createOrderPercent(s, model.SideTypeSell, pair, percent)
+}
+
+// ClosePosition signals a market order that closes a fraction of the position of the pair, see ClosePosition
+func (s *SignalOnly) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	return ClosePosition(s, pair, fraction)
+}
+
+func (s *SignalOnly) CreateOrderStop(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if size == 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+
+	return s.signal(model.Order{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeStopLossLimit,
+		Price:    limit,
+		Stop:     &limit,
+		Quantity: size,
+	}), nil
+}
+
+func (s *SignalOnly) Cancel(order model.Order) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	signal, ok := s.orders[order.ExchangeID]
+	if !ok {
+		return errors.New("order not found")
+	}
+
+	signal.Status = model.OrderStatusTypeCanceled
+	signal.UpdatedAt = time.Now()
+	s.orders[order.ExchangeID] = signal
+
+	message := fmt.Sprintf("[SIGNAL] CANCEL %s %s | ID: %d", signal.Side, signal.Pair, signal.ExchangeID)
+	log.Info(message)
+	if s.notifier != nil {
+		s.notifier.Notify(message)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+func TestSignalOnly(t *testing.T) {
+	t.Run("orders are not sent to exchange", func(t *testing.T) {
+		// no expectations: any call to the exchange fails the test
+		exc := mocks.NewExchange(t)
+		notifier := mocks.NewNotifier(t)
+		notifier.EXPECT().Notify(mock.Anything).Times(6)
+
+		signal := NewSignalOnly(exc, notifier)
+
+		order, err := signal.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.Equal(t, 1.0, order.Quantity)
+
+		_, err = signal.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+		require.NoError(t, err)
+
+		limit, err := signal.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 200)
+		require.NoError(t, err)
+		require.Equal(t, 200.0, limit.Price)
+
+		orders, err := signal.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 200, 50, 50)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, *orders[0].GroupID, *orders[1].GroupID)
+
+		found, err := signal.Order("BTCUSDT", limit.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, limit, found)
+
+		require.NoError(t, signal.Cancel(limit))
+		found, err = signal.Order("BTCUSDT", limit.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, found.Status)
+	})
+
+	t.Run("invalid quantity", func(t *testing.T) {
+		signal := NewSignalOnly(mocks.NewExchange(t), nil)
+		_, err := signal.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0)
+		require.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+
+	t.Run("account queries are delegated", func(t *testing.T) {
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().Position("BTCUSDT").Return(1, 100, nil)
+
+		signal := NewSignalOnly(exc, nil)
+		asset, quote, err := signal.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+		require.Equal(t, 100.0, quote)
+	})
+}
@@ -0,0 +1,82 @@
+package ninjabot
+
+import (
+	"context"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+// feed is the subscription of a strategy controller to the candles of a pair in a timeframe. Each feed is
+// warmed up and streamed independently, and its candles are routed only to its controller.
+//
+// The primary feeds are the pairs of the settings in the timeframe of the bot strategy, they also drive the
+// paper wallet, the order controller and the observers. Other feeds are the groundwork of strategies in
+// other timeframes.
+type feed struct {
+	pair       string
+	timeframe  string
+	primary    bool
+	controller *strategy.Controller
+}
+
+// feedCandle is a candle in the queue of the bot, with the feed that received it
+type feedCandle struct {
+	model.Candle
+	feed *feed
+}
+
+func (c feedCandle) Less(j model.Item) bool {
+	return c.Candle.Less(j.(feedCandle).Candle)
+}
+
+// addFeed registers a strategy in the candles of a pair and timeframe, the feed is started by startFeeds
+func (n *NinjaBot) addFeed(pair, timeframe string, str strategy.Strategy, primary bool) *feed {
+	controller := strategy.NewStrategyController(pair, str, n.orderController)
+	controller.SetCandlePolicy(n.candlePolicy)
+	controller.SetTimezone(n.timezone)
+
+	f := &feed{
+		pair:       pair,
+		timeframe:  timeframe,
+		primary:    primary,
+		controller: controller,
+	}
+
+	if primary && len(n.observers) > 0 {
+		controller.AddObserver(n.observers...)
+		n.orderFeed.Subscribe(pair, controller.OnOrder, false)
+	}
+
+	n.feeds = append(n.feeds, f)
+	return f
+}
+
+// primaryFeed returns the feed of a pair in the timeframe of the bot strategy
+func (n *NinjaBot) primaryFeed(pair string) *feed {
+	for _, f := range n.feeds {
+		if f.primary && f.pair == pair {
+			return f
+		}
+	}
+	return nil
+}
+
+// startFeeds warms up each feed with its own history, then subscribes it to the data feed and starts
+// its strategy controller
+func (n *NinjaBot) startFeeds(ctx context.Context) error {
+	for _, f := range n.feeds {
+		if err := n.preload(ctx, f); err != nil {
+			return err
+		}
+
+		// link to ninja bot controller
+		f := f
+		n.dataFeed.Subscribe(f.pair, f.timeframe, func(candle model.Candle) {
+			n.priorityQueueCandle.Push(feedCandle{Candle: candle, feed: f})
+		}, false)
+
+		f.controller.Start()
+	}
+	return nil
+}
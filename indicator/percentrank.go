@@ -0,0 +1,28 @@
+package indicator
+
+import "github.com/rodrigo-brito/ninjabot/model"
+
+// PercentRank returns the percentile, from 0 to 100, of each value within its trailing window of period values,
+// e.g. 100 for the highest value of the window and 0 for the lowest. Values equal to the current one count as
+// half, so a flat window ranks at 50. The values before the first complete window are zero.
+func PercentRank(series model.Series[float64], period int) model.Series[float64] {
+	result := make(model.Series[float64], len(series))
+	if period < 2 {
+		return result
+	}
+
+	for i := period - 1; i < len(series); i++ {
+		var below, equal float64
+		for _, value := range series[i-period+1 : i] {
+			switch {
+			case value < series[i]:
+				below++
+			case value == series[i]:
+				equal++
+			}
+		}
+		result[i] = (below + equal/2) / float64(period-1) * 100
+	}
+
+	return result
+}
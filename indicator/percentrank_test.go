@@ -0,0 +1,34 @@
+package indicator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestPercentRank(t *testing.T) {
+	series := model.Series[float64]{10, 12, 11, 15, 9, 11, 11, 11}
+	rank := PercentRank(series, 3)
+
+	// warmup region
+	require.Equal(t, 0.0, rank[0])
+	require.Equal(t, 0.0, rank[1])
+
+	// 11 is between 10 and 12
+	require.InDelta(t, 50, rank[2], 1e-9)
+	// highest of the window
+	require.InDelta(t, 100, rank[3], 1e-9)
+	// lowest of the window
+	require.InDelta(t, 0, rank[4], 1e-9)
+	// above 9, below 15
+	require.InDelta(t, 50, rank[5], 1e-9)
+	// ties count as half: above 9, equal to 11
+	require.InDelta(t, 75, rank[6], 1e-9)
+	// flat window
+	require.InDelta(t, 50, rank[7], 1e-9)
+
+	require.Len(t, PercentRank(series, 10), len(series))
+	require.Equal(t, make(model.Series[float64], len(series)), PercentRank(series, 1))
+}
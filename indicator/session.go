@@ -0,0 +1,85 @@
+package indicator
+
+import (
+	"math"
+	"time"
+)
+
+// SessionStart returns the start of the daily session of t, the midnight in the location.
+// A nil location is UTC, e.g. the timezone of the dataframe `df.Location`.
+func SessionStart(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// Pivots returns the classic pivot points of each candle, computed with the high, low and close of the
+// previous daily session in the location. Candles of the first session have zero values.
+func Pivots(times []time.Time, high, low, close []float64,
+	loc *time.Location) (pivot, r1, s1, r2, s2 []float64) {
+
+	pivot = make([]float64, len(times))
+	r1 = make([]float64, len(times))
+	s1 = make([]float64, len(times))
+	r2 = make([]float64, len(times))
+	s2 = make([]float64, len(times))
+
+	var (
+		session                 time.Time
+		sessionHigh, sessionLow float64
+		prev                    struct{ high, low, close float64 }
+		hasPrev                 bool
+	)
+	for i := range times {
+		if start := SessionStart(times[i], loc); i == 0 || !start.Equal(session) {
+			if i > 0 {
+				prev.high, prev.low, prev.close = sessionHigh, sessionLow, close[i-1]
+				hasPrev = true
+			}
+			session = start
+			sessionHigh, sessionLow = high[i], low[i]
+		}
+
+		sessionHigh = math.Max(sessionHigh, high[i])
+		sessionLow = math.Min(sessionLow, low[i])
+
+		if !hasPrev {
+			continue
+		}
+
+		pivot[i] = (prev.high + prev.low + prev.close) / 3
+		r1[i] = 2*pivot[i] - prev.low
+		s1[i] = 2*pivot[i] - prev.high
+		r2[i] = pivot[i] + (prev.high - prev.low)
+		s2[i] = pivot[i] - (prev.high - prev.low)
+	}
+
+	return pivot, r1, s1, r2, s2
+}
+
+// VWAP returns the volume weighted average of the typical price, reset at the start of each daily
+// session in the location
+func VWAP(times []time.Time, high, low, close, volume []float64, loc *time.Location) []float64 {
+	vwap := make([]float64, len(times))
+
+	var (
+		session            time.Time
+		priceVolume, total float64
+	)
+	for i := range times {
+		if start := SessionStart(times[i], loc); i == 0 || !start.Equal(session) {
+			session = start
+			priceVolume, total = 0, 0
+		}
+
+		priceVolume += (high[i] + low[i] + close[i]) / 3 * volume[i]
+		total += volume[i]
+		if total > 0 {
+			vwap[i] = priceVolume / total
+		}
+	}
+
+	return vwap
+}
@@ -0,0 +1,73 @@
+package indicator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hourly candles from 2021-01-01 00:00 UTC, with high = 10+i, low = i and close = 5+i
+func sessionCandles(size int) (times []time.Time, high, low, close, volume []float64) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < size; i++ {
+		times = append(times, start.Add(time.Duration(i)*time.Hour))
+		high = append(high, float64(10+i))
+		low = append(low, float64(i))
+		close = append(close, float64(5+i))
+		volume = append(volume, 1)
+	}
+	return times, high, low, close, volume
+}
+
+func TestSessionStart(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	date := time.Date(2021, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	require.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), SessionStart(date, nil))
+	require.True(t, time.Date(2021, 1, 1, 5, 0, 0, 0, time.UTC).Equal(SessionStart(date, est)))
+}
+
+func TestPivots(t *testing.T) {
+	times, high, low, close, _ := sessionCandles(48)
+
+	t.Run("utc", func(t *testing.T) {
+		pivot, _, _, _, _ := Pivots(times, high, low, close, nil)
+		require.Equal(t, 0.0, pivot[23])
+		require.InDelta(t, 61.0/3, pivot[24], 1e-9)
+		require.InDelta(t, 61.0/3, pivot[47], 1e-9)
+	})
+
+	t.Run("session at midnight of UTC-5", func(t *testing.T) {
+		pivot, r1, s1, r2, s2 := Pivots(times, high, low, close, time.FixedZone("EST", -5*60*60))
+
+		// first session, from 00:00 to 04:00 UTC
+		require.Equal(t, 0.0, pivot[4])
+
+		// previous session from 00:00 to 04:00 UTC: high 14, low 0 and close 9
+		require.InDelta(t, 23.0/3, pivot[5], 1e-9)
+		require.InDelta(t, 23.0/3, pivot[28], 1e-9)
+
+		// previous session from 05:00 to 04:00 UTC: high 38, low 5 and close 33
+		expected := 76.0 / 3
+		require.InDelta(t, expected, pivot[29], 1e-9)
+		require.InDelta(t, 2*expected-5, r1[29], 1e-9)
+		require.InDelta(t, 2*expected-38, s1[29], 1e-9)
+		require.InDelta(t, expected+33, r2[29], 1e-9)
+		require.InDelta(t, expected-33, s2[29], 1e-9)
+	})
+}
+
+func TestVWAP(t *testing.T) {
+	times, high, low, close, volume := sessionCandles(48)
+
+	// typical price of each candle is 5+i
+	vwap := VWAP(times, high, low, close, volume, nil)
+	require.InDelta(t, 7.5, vwap[5], 1e-9)
+	require.InDelta(t, 29, vwap[24], 1e-9)
+
+	vwap = VWAP(times, high, low, close, volume, time.FixedZone("EST", -5*60*60))
+	require.InDelta(t, 7, vwap[4], 1e-9)
+	require.InDelta(t, 10, vwap[5], 1e-9)
+	require.InDelta(t, 10.5, vwap[6], 1e-9)
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrUnknownLocale is returned by LocaleDisplayFormat for locales without a known number format
+var ErrUnknownLocale = errors.New("unknown locale")
+
+// CurrencyFormatter formats a value already rounded to the given precision with its currency, eg: "$1234.56"
+type CurrencyFormatter func(value float64, precision int, currency string) string
+
+// DisplayFormat controls how profit, price and volume values are displayed in summaries and notifications
+type DisplayFormat struct {
+	// Precision is the number of decimal places, a negative value keeps the default precision of each output
+	Precision int
+	// AssetPrecision is the number of decimal places of the values of each currency,
+	// eg: {"BTC": 8, "USDT": 2}. It has priority over Precision.
+	AssetPrecision map[string]int
+	// ThousandsSeparator is inserted between groups of three digits, eg: "," for 1,234.56. Empty by default.
+	ThousandsSeparator string
+	// DecimalSeparator replaces the decimal point, eg: "," for 1.234,56
+	DecimalSeparator string
+	// Currency is an optional formatter, by default the currency is appended after the value
+	Currency CurrencyFormatter
+	// PercentPrecision is the number of decimal places of percentages, eg: 2 for "12.35 %". Zero keeps the
+	// default precision of each output.
+	PercentPrecision int
+}
+
+// displayFormat holds the package-level DisplayFormat, see SetDisplayFormat
+var displayFormat atomic.Value
+
+// locales are the thousands and decimal separators of the supported locales
+var locales = map[string][2]string{
+	"en-US": {",", "."},
+	"en-GB": {",", "."},
+	"de-DE": {".", ","},
+	"es-ES": {".", ","},
+	"it-IT": {".", ","},
+	"pt-BR": {".", ","},
+	"fr-FR": {" ", ","},
+	"ru-RU": {" ", ","},
+	"de-CH": {"'", "."},
+}
+
+// LocaleDisplayFormat returns the display format with the separators of a locale, eg: "en-US" or "pt-BR"
+func LocaleDisplayFormat(locale string) (DisplayFormat, error) {
+	separators, ok := locales[locale]
+	if !ok {
+		return DisplayFormat{}, fmt.Errorf("%w: %s", ErrUnknownLocale, locale)
+	}
+
+	return DisplayFormat{
+		Precision:          -1,
+		ThousandsSeparator: separators[0],
+		DecimalSeparator:   separators[1],
+	}, nil
+}
+
+// SetDisplayFormat sets the package-level display format, the default of the formatting functions and of the
+// components without their own format, e.g. 8 decimals for BTC quoted pairs or 10 decimals for low priced coins.
+// The format must not be modified after set, it is shared by the goroutines that read it.
+func SetDisplayFormat(format DisplayFormat) {
+	displayFormat.Store(format)
+}
+
+// CurrentDisplayFormat returns the package-level display format, see SetDisplayFormat
+func CurrentDisplayFormat() DisplayFormat {
+	if format, ok := displayFormat.Load().(DisplayFormat); ok {
+		return format
+	}
+	return DisplayFormat{Precision: -1}
+}
+
+// FormatNumber formats a value with the package-level display format, see DisplayFormat.FormatNumber
+func FormatNumber(value float64, precision int) string {
+	return CurrentDisplayFormat().FormatNumber(value, precision)
+}
+
+// FormatPercent formats a percentage with the package-level display format, see DisplayFormat.FormatPercent
+func FormatPercent(value float64, precision int) string {
+	return CurrentDisplayFormat().FormatPercent(value, precision)
+}
+
+// FormatValue formats a value with the package-level display format, see DisplayFormat.FormatValue
+func FormatValue(value float64, defaultPrecision int, currency string) string {
+	return CurrentDisplayFormat().FormatValue(value, defaultPrecision, currency)
+}
+
+// FormatNumber formats a value with the given precision and the separators of the display format
+func (f DisplayFormat) FormatNumber(value float64, precision int) string {
+	text := strconv.FormatFloat(value, 'f', precision, 64)
+	if f.ThousandsSeparator == "" && f.DecimalSeparator == "" {
+		return text
+	}
+
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+
+	integer, fraction, hasFraction := strings.Cut(text, ".")
+	if f.ThousandsSeparator != "" {
+		groups := make([]string, 0, len(integer)/3+1)
+		for len(integer) > 3 {
+			groups = append([]string{integer[len(integer)-3:]}, groups...)
+			integer = integer[:len(integer)-3]
+		}
+		integer = strings.Join(append([]string{integer}, groups...), f.ThousandsSeparator)
+	}
+
+	if !hasFraction {
+		return sign + integer
+	}
+
+	decimalSeparator := f.DecimalSeparator
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	return sign + integer + decimalSeparator + fraction
+}
+
+// FormatPercent formats a percentage with the configured precision, or the given precision when not configured,
+// eg: "12.5 %"
+func (f DisplayFormat) FormatPercent(value float64, precision int) string {
+	if f.PercentPrecision > 0 {
+		precision = f.PercentPrecision
+	}
+	return f.FormatNumber(value, precision) + " %"
+}
+
+// FormatValue formats a value with the configured precision, or defaultPrecision when not configured.
+// The currency is omitted when empty.
+func (f DisplayFormat) FormatValue(value float64, defaultPrecision int, currency string) string {
+	precision := defaultPrecision
+	if f.Precision >= 0 {
+		precision = f.Precision
+	}
+
+	if assetPrecision, ok := f.AssetPrecision[currency]; ok && currency != "" {
+		precision = assetPrecision
+	}
+
+	if f.Currency != nil && currency != "" {
+		return f.Currency(value, precision, currency)
+	}
+
+	text := f.FormatNumber(value, precision)
+	if currency == "" {
+		return text
+	}
+	return fmt.Sprintf("%s %s", text, currency)
+}
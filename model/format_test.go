@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatValue(t *testing.T) {
+	t.Cleanup(func() {
+		SetDisplayFormat(DisplayFormat{Precision: -1})
+	})
+
+	require.Equal(t, "1.2346 USDT", FormatValue(1.23456789, 4, "USDT"))
+	require.Equal(t, "1.23", FormatValue(1.23456789, 2, ""))
+
+	SetDisplayFormat(DisplayFormat{Precision: 8})
+	require.Equal(t, "0.00001234 BTC", FormatValue(0.0000123400, 4, "BTC"))
+
+	SetDisplayFormat(DisplayFormat{
+		Precision: 2,
+		Currency: func(value float64, precision int, currency string) string {
+			return fmt.Sprintf("%s$%.*f", currency, precision, value)
+		},
+	})
+	require.Equal(t, "USD$1.50", FormatValue(1.499, 4, "USD"))
+	require.Equal(t, "1.50", FormatValue(1.499, 4, ""))
+}
+
+func TestFormatNumber(t *testing.T) {
+	t.Cleanup(func() {
+		SetDisplayFormat(DisplayFormat{Precision: -1})
+	})
+
+	require.Equal(t, "1234567.891", FormatNumber(1234567.891, 3))
+	require.Equal(t, "12.5 %", FormatPercent(12.49, 1))
+
+	SetDisplayFormat(DisplayFormat{Precision: -1, ThousandsSeparator: ","})
+	require.Equal(t, "1,234,567.89", FormatNumber(1234567.891, 2))
+	require.Equal(t, "-123,456", FormatNumber(-123456, 0))
+	require.Equal(t, "999.00", FormatNumber(999, 2))
+
+	format, err := LocaleDisplayFormat("pt-BR")
+	require.NoError(t, err)
+	SetDisplayFormat(format)
+	require.Equal(t, "-1.234,50", FormatNumber(-1234.5, 2))
+	require.Equal(t, "1.234,5 %", FormatPercent(1234.5, 1))
+	require.Equal(t, "1.234,5000 USDT", FormatValue(1234.5, 4, "USDT"))
+
+	SetDisplayFormat(DisplayFormat{Precision: -1, PercentPrecision: 2})
+	require.Equal(t, "12.35 %", FormatPercent(12.3456, 6))
+
+	_, err = LocaleDisplayFormat("xx-XX")
+	require.ErrorIs(t, err, ErrUnknownLocale)
+}
+
+func TestFormatValue_AssetPrecision(t *testing.T) {
+	t.Cleanup(func() {
+		SetDisplayFormat(DisplayFormat{Precision: -1})
+	})
+
+	SetDisplayFormat(DisplayFormat{
+		Precision:          4,
+		AssetPrecision:     map[string]int{"BTC": 8, "USDT": 2},
+		ThousandsSeparator: ",",
+	})
+	require.Equal(t, "0.00001234 BTC", FormatValue(0.0000123400, 2, "BTC"))
+	require.Equal(t, "12,345.68 USDT", FormatValue(12345.6789, 6, "USDT"))
+	require.Equal(t, "12,345.6789 ETH", FormatValue(12345.6789, 6, "ETH"))
+	require.Equal(t, "12,345.6789", FormatValue(12345.6789, 6, ""))
+}
+
+func TestDisplayFormat(t *testing.T) {
+	t.Cleanup(func() {
+		SetDisplayFormat(DisplayFormat{Precision: -1})
+	})
+
+	// the format of a component does not depend on the package-level format
+	format := DisplayFormat{Precision: 2, ThousandsSeparator: ","}
+	SetDisplayFormat(DisplayFormat{Precision: 6})
+	require.Equal(t, "1,234.50 USDT", format.FormatValue(1234.5, 4, "USDT"))
+	require.Equal(t, "1234.500000 USDT", FormatValue(1234.5, 4, "USDT"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(precision int) {
+			defer wg.Done()
+			SetDisplayFormat(DisplayFormat{Precision: precision})
+			_ = FormatValue(1.5, 4, "USDT")
+		}(i)
+	}
+	wg.Wait()
+}
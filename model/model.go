@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
@@ -18,6 +19,17 @@ type Settings struct {
 	Telegram TelegramSettings
 }
 
+// MarketType defines the accounting model used to calculate the profit of a position
+type MarketType string
+
+const (
+	// MarketTypeSpot profit is the quote received by selling the asset minus the quote paid for it
+	MarketTypeSpot MarketType = "spot"
+	// MarketTypeFutures profit is linear on the contract notional, (exit - entry) * quantity * contract size,
+	// and only the quantity that reduces the position is realized
+	MarketTypeFutures MarketType = "futures"
+)
+
 type Balance struct {
 	Asset    string
 	Free     float64
@@ -33,6 +45,7 @@ type AssetInfo struct {
 	MaxPrice    float64
 	MinQuantity float64
 	MaxQuantity float64
+	MinNotional float64
 	StepSize    float64
 	TickSize    float64
 
@@ -52,6 +65,10 @@ type Dataframe struct {
 	Time       []time.Time
 	LastUpdate time.Time
 
+	// Location is the timezone of the daily sessions, e.g. to compute `indicator.Pivots`.
+	// Candle times remain in UTC, nil is UTC.
+	Location *time.Location
+
 	// Custom user metadata
 	Metadata map[string]Series[float64]
 }
@@ -93,6 +110,66 @@ func (c Candle) Empty() bool {
 	return c.Pair == "" && c.Close == 0 && c.Open == 0 && c.Volume == 0
 }
 
+// ErrInvalidCandle is returned for candles with zero, negative or NaN prices, see CandlePolicy
+var ErrInvalidCandle = errors.New("invalid candle")
+
+// CandlePolicy defines how candles with zero, negative or NaN prices are handled, e.g. corrupt CSV rows
+type CandlePolicy int
+
+const (
+	// CandlePolicySkip discards invalid candles, it is the default policy
+	CandlePolicySkip CandlePolicy = iota
+	// CandlePolicyError returns ErrInvalidCandle for invalid candles
+	CandlePolicyError
+	// CandlePolicyFill replaces the invalid values with the close of the previous candle (forward fill),
+	// candles without a previous valid candle are discarded
+	CandlePolicyFill
+)
+
+func validPrice(value float64) bool {
+	return value > 0 && !math.IsInf(value, 0)
+}
+
+// Valid returns false if any price of the candle is zero, negative or NaN, or the volume is negative or NaN
+func (c Candle) Valid() bool {
+	return validPrice(c.Open) && validPrice(c.Close) && validPrice(c.Low) && validPrice(c.High) &&
+		c.Volume >= 0 && !math.IsInf(c.Volume, 0)
+}
+
+// Fill replaces the invalid prices of the candle with the close of the previous candle and an invalid volume with zero
+func (c Candle) Fill(previous Candle) Candle {
+	for _, value := range []*float64{&c.Open, &c.Close, &c.Low, &c.High} {
+		if !validPrice(*value) {
+			*value = previous.Close
+		}
+	}
+
+	if !(c.Volume >= 0) || math.IsInf(c.Volume, 0) {
+		c.Volume = 0
+	}
+
+	return c
+}
+
+// Apply returns the candle to be used according to the policy and false if the candle must be discarded.
+// The previous candle is the last valid candle of the pair, or nil if there is none.
+func (p CandlePolicy) Apply(candle Candle, previous *Candle) (Candle, bool, error) {
+	if candle.Valid() {
+		return candle, true, nil
+	}
+
+	switch p {
+	case CandlePolicyError:
+		return candle, false, fmt.Errorf("%w: %s at %s", ErrInvalidCandle, candle.Pair, candle.Time)
+	case CandlePolicyFill:
+		if previous != nil {
+			return candle.Fill(*previous), true, nil
+		}
+	}
+
+	return candle, false, nil
+}
+
 type HeikinAshi struct {
 	PreviousHACandle Candle
 }
@@ -185,6 +262,13 @@ func (a Account) Equity() float64 {
 	return total
 }
 
+// EquitySnapshot is the equity of the accounts of a live bot at a time, a point of its equity curve
+type EquitySnapshot struct {
+	ID    int64     `db:"id" json:"id" gorm:"primaryKey,autoIncrement"`
+	Time  time.Time `db:"time" json:"time" gorm:"index"`
+	Value float64   `db:"value" json:"value"`
+}
+
 func (ha *HeikinAshi) CalculateHeikinAshi(c Candle) Candle {
 	var hkCandle Candle
 
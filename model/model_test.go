@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -39,6 +40,34 @@ func TestCandle_Less(t *testing.T) {
 	})
 }
 
+func TestCandlePolicy_Apply(t *testing.T) {
+	previous := Candle{Pair: "BTCUSDT", Open: 9, Close: 10, Low: 8, High: 11, Volume: 100}
+	invalid := Candle{Pair: "BTCUSDT", Open: 12, Close: math.NaN(), Low: 0, High: 13, Volume: -1}
+
+	candle, ok, err := CandlePolicySkip.Apply(previous, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, previous, candle)
+
+	_, ok, err = CandlePolicySkip.Apply(invalid, &previous)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, ok, err = CandlePolicyError.Apply(invalid, &previous)
+	require.ErrorIs(t, err, ErrInvalidCandle)
+	require.False(t, ok)
+
+	candle, ok, err = CandlePolicyFill.Apply(invalid, &previous)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, Candle{Pair: "BTCUSDT", Open: 12, Close: 10, Low: 10, High: 13}, candle)
+
+	// without previous candle
+	_, ok, err = CandlePolicyFill.Apply(invalid, nil)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
 func TestAccount_Balance(t *testing.T) {
 	account := Account{}
 	account.Balances = []Balance{{Asset: "A", Free: 1.2, Lock: 1.0}, {Asset: "B", Free: 1.1, Lock: 1.3}}
@@ -8,6 +8,7 @@ import (
 type SideType string
 type OrderType string
 type OrderStatusType string
+type TimeInForceType string
 
 var (
 	SideTypeBuy  SideType = "BUY"
@@ -28,6 +29,13 @@ var (
 	OrderStatusTypePendingCancel   OrderStatusType = "PENDING_CANCEL"
 	OrderStatusTypeRejected        OrderStatusType = "REJECTED"
 	OrderStatusTypeExpired         OrderStatusType = "EXPIRED"
+
+	// TimeInForceGTC keeps the order open until it is filled or canceled
+	TimeInForceGTC TimeInForceType = "GTC"
+	// TimeInForceIOC fills the order immediately as much as possible and cancels the remainder
+	TimeInForceIOC TimeInForceType = "IOC"
+	// TimeInForceFOK fills the whole order immediately or rejects it
+	TimeInForceFOK TimeInForceType = "FOK"
 )
 
 type Order struct {
@@ -47,13 +55,49 @@ type Order struct {
 	Stop    *float64 `db:"stop" json:"stop"`
 	GroupID *int64   `db:"group_id" json:"group_id"`
 
+	// Tag is an optional annotation of the reason of the order, e.g. the strategy signal
+	Tag string `db:"tag" json:"tag"`
+
+	// Account is the name of the account of the order, empty for the default account
+	Account string `db:"account" json:"account"`
+
+	// ExecutedQuantity and ExecutedCost are the cumulative quantity and quote cost of the fills of the order,
+	// the price of an order with fills is their weighted average, see Fill
+	ExecutedQuantity float64 `db:"executed_quantity" json:"executed_quantity"`
+	ExecutedCost     float64 `db:"executed_cost" json:"executed_cost"`
+
 	// Internal use (Plot)
 	RefPrice float64 `json:"ref_price" gorm:"-"`
 	Profit   float64 `json:"profit" gorm:"-"`
 	Candle   Candle  `json:"-" gorm:"-"`
 }
 
+// Fill adds a fill of the order and updates its price to the quantity-weighted average price of the fills
+func (o *Order) Fill(quantity, price float64) {
+	if quantity <= 0 {
+		return
+	}
+
+	o.ExecutedQuantity += quantity
+	o.ExecutedCost += quantity * price
+	o.Price = o.ExecutedCost / o.ExecutedQuantity
+}
+
+// Executed returns the order as filled with its executed quantity if the remainder was canceled or expired after
+// some fills, e.g. a partially filled IOC order, so the executed part is accounted as a filled order
+func (o Order) Executed() Order {
+	if (o.Status == OrderStatusTypeCanceled || o.Status == OrderStatusTypeExpired) && o.ExecutedQuantity > 0 {
+		o.Status = OrderStatusTypeFilled
+		o.Quantity = o.ExecutedQuantity
+	}
+	return o
+}
+
 func (o Order) String() string {
-	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %f x $%f (~$%.f)",
+	text := fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %f x $%f (~$%.f)",
 		o.Status, o.Side, o.Pair, o.ID, o.Type, o.Quantity, o.Price, o.Quantity*o.Price)
+	if o.Tag != "" {
+		text += fmt.Sprintf(", Tag: %s", o.Tag)
+	}
+	return text
 }
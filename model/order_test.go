@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -21,4 +22,44 @@ func TestOrder_String(t *testing.T) {
 		UpdatedAt:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
 	require.Equal(t, "[FILLED] SELL BNBUSDT | ID: 1, Type: LIMIT, 1.000000 x $10.000000 (~$10)", order.String())
+
+	order.Tag = "EMA8>SMA21"
+	require.Equal(t, "[FILLED] SELL BNBUSDT | ID: 1, Type: LIMIT, 1.000000 x $10.000000 (~$10), Tag: EMA8>SMA21",
+		order.String())
+}
+
+func TestOrder_JSON(t *testing.T) {
+	order := Order{ID: 1, Pair: "BNBUSDT", Tag: "EMA8>SMA21"}
+	content, err := json.Marshal(order)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"tag":"EMA8\u003eSMA21"`)
+
+	var decoded Order
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	require.Equal(t, order.Tag, decoded.Tag)
+}
+
+func TestOrder_Fill(t *testing.T) {
+	order := Order{Pair: "BNBUSDT", Price: 10, Quantity: 4}
+	order.Fill(0, 12)
+	require.Equal(t, 10.0, order.Price)
+
+	order.Fill(1, 10)
+	order.Fill(3, 12)
+	require.Equal(t, 4.0, order.ExecutedQuantity)
+	require.Equal(t, 46.0, order.ExecutedCost)
+	require.Equal(t, 11.5, order.Price)
+}
+
+func TestOrder_Executed(t *testing.T) {
+	order := Order{Status: OrderStatusTypeExpired, Quantity: 5, ExecutedQuantity: 2}
+	executed := order.Executed()
+	require.Equal(t, OrderStatusTypeFilled, executed.Status)
+	require.Equal(t, 2.0, executed.Quantity)
+
+	order = Order{Status: OrderStatusTypeCanceled, Quantity: 5}
+	require.Equal(t, order, order.Executed())
+
+	order = Order{Status: OrderStatusTypePartiallyFilled, Quantity: 5, ExecutedQuantity: 2}
+	require.Equal(t, order, order.Executed())
 }
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -37,21 +39,47 @@ type CandleSubscriber interface {
 }
 
 type NinjaBot struct {
-	storage  storage.Storage
-	settings model.Settings
-	exchange service.Exchange
-	strategy strategy.Strategy
-	notifier service.Notifier
-	telegram service.Telegram
-
-	orderController       *order.Controller
-	priorityQueueCandle   *model.PriorityQueue
-	strategiesControllers map[string]*strategy.Controller
-	orderFeed             *order.Feed
-	dataFeed              *exchange.DataFeedSubscription
-	paperWallet           *exchange.PaperWallet
-
-	backtest bool
+	storage   storage.Storage
+	settings  model.Settings
+	exchange  service.Exchange
+	strategy  strategy.Strategy
+	notifiers []service.Notifier
+	throttle  []notification.ThrottleOption
+	telegram  service.Telegram
+
+	orderController     *order.Controller
+	priorityQueueCandle *model.PriorityQueue
+	feeds               []*feed
+	orderFeed           *order.Feed
+	dataFeed            *exchange.DataFeedSubscription
+	paperWallet         *exchange.PaperWallet
+	observers           []strategy.Observer
+	snapshot            *Snapshot
+
+	backtest         bool
+	minProfitToClose float64
+	warmupCandles    int
+	statsWarmup      int
+	noShort          bool
+	sizeClamp        bool
+	partialUpdates   bool
+	feeRate          float64
+	maxErrors        int
+	precision        int
+	displayFormat    *model.DisplayFormat
+	haltAll          bool
+	staleDistance    map[string]float64
+	reportCurrency   string
+	skipVerbosity    order.SkipVerbosity
+	noLookahead      bool
+	resume           bool
+	resumeSince      time.Time
+	candlePolicy     model.CandlePolicy
+	timezone         *time.Location
+	profitTarget     float64
+	targetScope      string
+	marketType       model.MarketType
+	contractSize     float64
 }
 
 type Option func(*NinjaBot)
@@ -60,13 +88,14 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 	options ...Option) (*NinjaBot, error) {
 
 	bot := &NinjaBot{
-		settings:              settings,
-		exchange:              exch,
-		strategy:              str,
-		orderFeed:             order.NewOrderFeed(),
-		dataFeed:              exchange.NewDataFeed(exch),
-		strategiesControllers: make(map[string]*strategy.Controller),
-		priorityQueueCandle:   model.NewPriorityQueue(nil),
+		settings:            settings,
+		exchange:            exch,
+		strategy:            str,
+		orderFeed:           order.NewOrderFeed(),
+		dataFeed:            exchange.NewDataFeed(exch),
+		priorityQueueCandle: model.NewPriorityQueue(nil),
+		marketType:          model.MarketTypeSpot,
+		contractSize:        1,
 	}
 
 	for _, pair := range settings.Pairs {
@@ -80,6 +109,14 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		option(bot)
 	}
 
+	if bot.noLookahead {
+		if bot.paperWallet == nil {
+			log.Warn("[SETUP] strict no lookahead requires a paper wallet, ignored")
+		} else {
+			exchange.WithPaperFillModel(exchange.FillNextOpen)(bot.paperWallet)
+		}
+	}
+
 	var err error
 	if bot.storage == nil {
 		bot.storage, err = storage.FromFile(defaultDatabase)
@@ -88,7 +125,31 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		}
 	}
 
-	bot.orderController = order.NewController(ctx, exch, bot.storage, bot.orderFeed)
+	bot.orderController = order.NewController(ctx, bot.exchange, bot.storage, bot.orderFeed)
+	bot.orderController.SetMinProfitToClose(bot.minProfitToClose)
+	bot.orderController.SetBacktest(bot.backtest)
+	bot.orderController.SetMarketType(bot.marketType, bot.contractSize)
+	bot.orderController.SetStatsWarmup(bot.statsWarmup)
+	bot.orderController.SetNoShort(bot.noShort)
+	bot.orderController.SetOrderSizeClamp(bot.sizeClamp)
+	bot.orderController.SetPartialFillUpdates(bot.partialUpdates)
+	bot.orderController.SetFeeRate(bot.feeRate)
+	bot.orderController.SetMaxConsecutiveErrors(bot.maxErrors, bot.haltAll)
+	bot.orderController.SetSummaryPrecision(bot.precision)
+	if bot.displayFormat != nil {
+		bot.orderController.SetDisplayFormat(*bot.displayFormat)
+	}
+	bot.orderController.SetReportingCurrency(bot.reportCurrency)
+	bot.orderController.SetSkipVerbosity(bot.skipVerbosity)
+	if bot.profitTarget > 0 {
+		err = bot.orderController.SetProfitTarget(bot.profitTarget, bot.targetScope, bot.timezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for pair, distance := range bot.staleDistance {
+		bot.orderController.SetOrderStaleCancel(pair, distance)
+	}
 
 	if settings.Telegram.Enabled {
 		bot.telegram, err = notification.NewTelegram(bot.orderController, settings)
@@ -99,6 +160,14 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		WithNotifier(bot.telegram)(bot)
 	}
 
+	if len(bot.notifiers) > 0 {
+		var notifier service.Notifier = notification.NewMultiNotifier(bot.notifiers...)
+		if bot.throttle != nil {
+			notifier = notification.NewThrottle(notifier, bot.throttle...)
+		}
+		bot.orderController.SetNotifier(notifier)
+	}
+
 	return bot, nil
 }
 
@@ -126,12 +195,228 @@ func WithLogLevel(level log.Level) Option {
 	}
 }
 
-// WithNotifier registers a notifier to the bot, currently only email and telegram are supported
-func WithNotifier(notifier service.Notifier) Option {
+// WithNotifier registers notifiers to the bot, e.g. email, Telegram or a webhook. The option can be used
+// more than once, all the notifiers receive the orders, messages and errors of the bot.
+func WithNotifier(notifiers ...service.Notifier) Option {
 	return func(bot *NinjaBot) {
-		bot.notifier = notifier
-		bot.orderController.SetNotifier(notifier)
-		bot.SubscribeOrder(notifier)
+		bot.notifiers = append(bot.notifiers, notifiers...)
+		for _, notifier := range notifiers {
+			bot.SubscribeOrder(notifier)
+		}
+	}
+}
+
+// WithNotificationThrottle suppresses duplicated messages and errors sent by the bot to the notifiers and
+// limits the messages per minute, e.g. repeated profit notifications. Orders are always delivered.
+// e.g. ninjabot.WithNotificationThrottle(notification.WithThrottleWindow(5 * time.Minute))
+func WithNotificationThrottle(options ...notification.ThrottleOption) Option {
+	return func(bot *NinjaBot) {
+		bot.throttle = append([]notification.ThrottleOption{}, options...)
+	}
+}
+
+// WithSignalOnly sets the bot to run as a signal generator, orders created by the strategy are
+// logged and sent to the given notifier without reaching the exchange. Candles and indicators still run.
+func WithSignalOnly(notifier service.Notifier) Option {
+	return func(bot *NinjaBot) {
+		bot.exchange = exchange.NewSignalOnly(bot.exchange, notifier)
+	}
+}
+
+// WithOrderFeed sets the buffer size and backpressure policy of the order feed delivered to subscribers
+// e.g. ninjabot.WithOrderFeed(order.WithFeedBufferSize(1000), order.WithFeedBackpressure(order.BackpressureDrop))
+func WithOrderFeed(options ...order.FeedOption) Option {
+	return func(bot *NinjaBot) {
+		bot.orderFeed.Configure(options...)
+	}
+}
+
+// WithMinProfitToClose blocks limit and market orders that close a position with a profit below
+// the given value (e.g. 0.005 for 0.5%), to avoid trades eaten by fees. Blocked orders return an
+// *order.MinProfitError, so the strategy may try again later. Stop and OCO orders are not affected.
+func WithMinProfitToClose(profit float64) Option {
+	return func(bot *NinjaBot) {
+		bot.minProfitToClose = profit
+	}
+}
+
+// WithMarketType sets the accounting model used to calculate the profit of the trades, default is spot.
+// For futures, the profit is (exit - entry) * quantity * contract size. When using a paper wallet,
+// set the same market type with `exchange.WithPaperMarketType`.
+func WithMarketType(marketType model.MarketType, contractSize float64) Option {
+	return func(bot *NinjaBot) {
+		bot.marketType = marketType
+		bot.contractSize = contractSize
+	}
+}
+
+// WithNoShort rejects sell orders above the asset position, to avoid accidental short positions in
+// spot accounts. When using a paper wallet, use `exchange.WithPaperSpotOnly` for the same behavior.
+func WithNoShort() Option {
+	return func(bot *NinjaBot) {
+		bot.noShort = true
+	}
+}
+
+// WithOrderSizeClamp reduces orders that exceed the free balance to the maximum affordable quantity,
+// respecting the step size and minimum notional of the pair, instead of failing with insufficient funds.
+// The adjustments are logged. It is opt-in, strategies that rely on the failure are not affected by default.
+func WithOrderSizeClamp() Option {
+	return func(bot *NinjaBot) {
+		bot.sizeClamp = true
+	}
+}
+
+// WithPartialFillUpdates publishes the partial fills of orders to strategies and notifiers. By default,
+// only the final status of orders is published, reducing the notifications of large orders filled in pieces.
+func WithPartialFillUpdates() Option {
+	return func(bot *NinjaBot) {
+		bot.partialUpdates = true
+	}
+}
+
+// WithFeeRate sets the fee rate of each order (e.g. 0.001 for 0.1%), used by strategies to calculate the
+// break-even price of positions, see `order.Controller.BreakEvenPrice`. In backtests, the fee of the paper wallet
+// is used by default, see `exchange.WithPaperFee`.
+func WithFeeRate(rate float64) Option {
+	return func(bot *NinjaBot) {
+		bot.feeRate = rate
+	}
+}
+
+// WithSummaryPrecision rounds the profits of the summary to a number of decimal places, to hide the noise of
+// float sums. Break-even trades are not counted as wins. Default is the quote precision of each pair.
+func WithSummaryPrecision(digits int) Option {
+	return func(bot *NinjaBot) {
+		bot.precision = digits
+	}
+}
+
+// WithDisplayFormat sets the format of the values in the summaries and notifications of the bot, e.g. the
+// precision of a low priced coin or the separators of a locale. Default is the package-level format, see
+// `model.SetDisplayFormat`.
+func WithDisplayFormat(format model.DisplayFormat) Option {
+	return func(bot *NinjaBot) {
+		bot.displayFormat = &format
+	}
+}
+
+// WithMaxConsecutiveErrors halts the orders of a pair after n consecutive failures, e.g. rejected orders of a
+// misconfigured strategy, and notifies the halt. With haltAll, the orders of all pairs are halted. A successful
+// order resets the count, see `order.Controller.SetMaxConsecutiveErrors`.
+func WithMaxConsecutiveErrors(n int, haltAll bool) Option {
+	return func(bot *NinjaBot) {
+		bot.maxErrors = n
+		bot.haltAll = haltAll
+	}
+}
+
+// WithProfitTarget stops the bot once the realized profit reaches the amount, to lock in the gains. The scope
+// is "daily", reset at the midnight of the timezone, see WithTimezone, or "total". The stop is notified, see
+// `order.Controller.SetProfitTarget`.
+func WithProfitTarget(amount float64, scope string) Option {
+	return func(bot *NinjaBot) {
+		bot.profitTarget = amount
+		bot.targetScope = scope
+	}
+}
+
+// WithSkippedSignals reports the orders of the strategy that were not created with the reason, e.g. insufficient
+// funds or minimum notional, in the log or also in the notifier, see `order.Controller.SetSkipVerbosity`
+func WithSkippedSignals(verbosity order.SkipVerbosity) Option {
+	return func(bot *NinjaBot) {
+		bot.skipVerbosity = verbosity
+	}
+}
+
+// WithReportingCurrency converts the profit and volume of the trades to a currency (e.g. USDT) with the exchange
+// rates at the time of the trades, so the totals of the summary are coherent across pairs with different quotes,
+// e.g. ETHBTC and BTCUSDT, see `order.Controller.SetReportingCurrency`
+func WithReportingCurrency(currency string) Option {
+	return func(bot *NinjaBot) {
+		bot.reportCurrency = currency
+	}
+}
+
+// WithOrderStaleCancel cancels the open limit orders of a pair with a price more than maxDistance from the
+// market (e.g. 0.05 for 5%), to avoid fills of stale orders after a big move, see
+// `order.Controller.SetOrderStaleCancel`
+func WithOrderStaleCancel(pair string, maxDistance float64) Option {
+	return func(bot *NinjaBot) {
+		if bot.staleDistance == nil {
+			bot.staleDistance = make(map[string]float64)
+		}
+		bot.staleDistance[pair] = maxDistance
+	}
+}
+
+// WithStrictNoLookahead defers the execution of market orders to the open of the next candle, as in a real
+// session: the strategy decides on the close of a candle and the order is executed in the next one. By default,
+// the paper wallet fills market orders at the close of the candle that generated the signal, a price that is
+// no longer available when the candle is complete, and indicators of the current candle make it worse.
+// In strict mode, market orders are accepted pending with the funds locked, and they are filled (or rejected
+// without funds) with the open of the next candle of the pair, before the strategy receives it. So the position
+// is only visible to the strategy in the next candle. Resting limit and stop orders are already checked against the
+// next candles. It requires a paper wallet, see `exchange.WithPaperFillModel`.
+func WithStrictNoLookahead() Option {
+	return func(bot *NinjaBot) {
+		bot.noLookahead = true
+	}
+}
+
+// WithResume seeds the order storage with the positions already open in the exchange when a live session
+// starts, so the profit of the orders that close them is calculated from their entry price. The entry price is
+// rebuilt with the orders of the exchange filled until the initial time of the session, zero is the start time.
+// See `order.Controller.ResumePositions`. It is ignored in backtests.
+func WithResume(since time.Time) Option {
+	return func(bot *NinjaBot) {
+		bot.resume = true
+		bot.resumeSince = since
+	}
+}
+
+// WithCandlePolicy sets how the strategy handles candles with zero, negative or NaN prices, e.g. skip,
+// fail or forward fill, default is model.CandlePolicySkip. Invalid candles are logged and never reach the
+// indicators. To validate the candles of the feeds, see `exchange.PairFeed` and `exchange.WithBinanceCandlePolicy`.
+func WithCandlePolicy(policy model.CandlePolicy) Option {
+	return func(bot *NinjaBot) {
+		bot.candlePolicy = policy
+	}
+}
+
+// WithTimezone aligns the daily sessions of the strategy with the timezone of a market, e.g. the exchange
+// timezone. It is available to the session indicators in `df.Location`, e.g. `indicator.Pivots` and
+// `indicator.VWAP`. Candle times remain in UTC. To align the candles resampled by the feeds, see
+// `exchange.PairFeed` and `exchange.Aggregated`.
+func WithTimezone(loc *time.Location) Option {
+	return func(bot *NinjaBot) {
+		bot.timezone = loc
+	}
+}
+
+// WithStatsWarmup excludes the first closed trades from the summary statistics, e.g. win rate and payoff,
+// since the first trades after startup may be noise while indicators settle. The orders are still stored.
+func WithStatsWarmup(trades int) Option {
+	return func(bot *NinjaBot) {
+		bot.statsWarmup = trades
+	}
+}
+
+// WithWarmupCandles sets the number of candles fetched from the exchange before the bot starts.
+// By default, it fetches the strategy `WarmupPeriod` (or `MaxHistory`), a greater value can be used
+// to load extra history for long indicators.
+func WithWarmupCandles(size int) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupCandles = size
+	}
+}
+
+// WithObservers registers tools driven by the bot alongside the strategy, eg: a `tools.Scheduler`.
+// Observers receive the dataframe of each closed candle after the strategy and, if they implement
+// `strategy.OrderObserver`, the orders of each pair. They are executed in the order of registration.
+func WithObservers(observers ...strategy.Observer) Option {
+	return func(bot *NinjaBot) {
+		bot.observers = append(bot.observers, observers...)
 	}
 }
 
@@ -175,82 +460,197 @@ func (n *NinjaBot) Controller() *order.Controller {
 	return n.orderController
 }
 
-// Summary function displays all trades, accuracy and some bot metrics in stdout
-// To access the raw data, you may access `bot.Controller().Results`
-func (n *NinjaBot) Summary() {
+// tagSummary prints the results grouped by the tag of the orders, if any tagged order was closed
+func (n *NinjaBot) tagSummary() {
+	buffer := bytes.NewBuffer(nil)
+	table := tablewriter.NewWriter(buffer)
+	table.SetHeader([]string{"Pair", "Tag", "Trades", "Win", "Loss", "% Win", "Profit", "Volume"})
+
+	format := n.orderController.DisplayFormat()
+	rows := 0
+	for _, summary := range n.orderController.Results {
+		for tag, tagSummary := range summary.ByTag {
+			table.Append([]string{
+				summary.Pair,
+				tag,
+				strconv.Itoa(len(tagSummary.Win()) + len(tagSummary.Lose())),
+				strconv.Itoa(len(tagSummary.Win())),
+				strconv.Itoa(len(tagSummary.Lose())),
+				format.FormatPercent(tagSummary.WinPercentage(), 1),
+				format.FormatValue(tagSummary.Profit(), 2, ""),
+				format.FormatValue(tagSummary.Volume, 2, ""),
+			})
+			rows++
+		}
+	}
+
+	if rows == 0 {
+		return
+	}
+
+	table.Render()
+	fmt.Println(buffer.String())
+}
+
+// PairResult is the result of the trades of a pair, see Result
+type PairResult struct {
+	Pair          string
+	Trades        int
+	Wins          int
+	Losses        int
+	WinPercentage float64
+	Payoff        float64
+	SQN           float64
+	Profit        float64
+	Volume        float64
+}
+
+// BacktestResult is the result of a bot, the values printed by Summary
+type BacktestResult struct {
+	// Pairs are the results of each pair, sorted by pair
+	Pairs         []PairResult
+	Trades        int
+	Wins          int
+	Losses        int
+	WinPercentage float64
+	// Payoff is the average payoff of the pairs weighted by their trades, SQN is the average of the pairs
+	Payoff float64
+	SQN    float64
+	// Profit is the sum of the profit of the trades, in the reporting currency if set, see WithReportingCurrency.
+	// Otherwise, the profits of pairs with different quotes are summed as is.
+	Profit   float64
+	Volume   float64
+	Currency string
+	// Wallet is the performance of the paper wallet, with the drawdown and the Sharpe ratio, nil without it
+	Wallet *exchange.WalletResult
+}
+
+// Result returns the trades, accuracy and metrics of the bot without printing, e.g. to compare the parameters
+// of a strategy in many backtests. It is the programmatic counterpart of Summary.
+func (n *NinjaBot) Result() BacktestResult {
 	var (
-		total  float64
-		wins   int
-		loses  int
-		volume float64
-		sqn    float64
+		result    BacktestResult
+		avgPayoff float64
 	)
 
+	result.Currency = n.orderController.ReportingCurrency()
+	for _, summary := range n.orderController.Results {
+		wins, losses := len(summary.Win()), len(summary.Lose())
+		pair := PairResult{
+			Pair:   summary.Pair,
+			Trades: wins + losses,
+			Wins:   wins,
+			Losses: losses,
+			Payoff: summary.Payoff(),
+			SQN:    summary.SQN(),
+			Profit: summary.Profit(),
+			Volume: summary.Volume,
+		}
+		if pair.Trades > 0 {
+			pair.WinPercentage = float64(wins) / float64(pair.Trades) * 100
+		}
+		result.Pairs = append(result.Pairs, pair)
+
+		avgPayoff += pair.Payoff * float64(pair.Trades)
+		result.Trades += pair.Trades
+		result.Wins += wins
+		result.Losses += losses
+		result.SQN += pair.SQN
+		if result.Currency != "" {
+			result.Profit += summary.ReportProfit
+			result.Volume += summary.ReportVolume
+		} else {
+			result.Profit += pair.Profit
+			result.Volume += pair.Volume
+		}
+	}
+
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		return result.Pairs[i].Pair < result.Pairs[j].Pair
+	})
+
+	if result.Trades > 0 {
+		result.WinPercentage = float64(result.Wins) / float64(result.Trades) * 100
+		result.Payoff = avgPayoff / float64(result.Trades)
+	}
+	if len(result.Pairs) > 0 {
+		result.SQN /= float64(len(result.Pairs))
+	}
+
+	if n.paperWallet != nil {
+		wallet := n.paperWallet.Result()
+		result.Wallet = &wallet
+	}
+
+	return result
+}
+
+// Summary function displays all trades, accuracy and some bot metrics in stdout
+// To access the raw data, you may access `bot.Result()` or `bot.Controller().Results`
+func (n *NinjaBot) Summary() {
+	result := n.Result()
+	format := n.orderController.DisplayFormat()
+
 	buffer := bytes.NewBuffer(nil)
 	table := tablewriter.NewWriter(buffer)
 	table.SetHeader([]string{"Pair", "Trades", "Win", "Loss", "% Win", "Payoff", "SQN", "Profit", "Volume"})
 	table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
-	avgPayoff := 0.0
 
-	for _, summary := range n.orderController.Results {
-		avgPayoff += summary.Payoff() * float64(len(summary.Win())+len(summary.Lose()))
+	for _, pair := range result.Pairs {
 		table.Append([]string{
-			summary.Pair,
-			strconv.Itoa(len(summary.Win()) + len(summary.Lose())),
-			strconv.Itoa(len(summary.Win())),
-			strconv.Itoa(len(summary.Lose())),
-			fmt.Sprintf("%.1f %%", float64(len(summary.Win()))/float64(len(summary.Win())+len(summary.Lose()))*100),
-			fmt.Sprintf("%.3f", summary.Payoff()),
-			fmt.Sprintf("%.1f", summary.SQN()),
-			fmt.Sprintf("%.2f", summary.Profit()),
-			fmt.Sprintf("%.2f", summary.Volume),
+			pair.Pair,
+			strconv.Itoa(pair.Trades),
+			strconv.Itoa(pair.Wins),
+			strconv.Itoa(pair.Losses),
+			format.FormatPercent(pair.WinPercentage, 1),
+			format.FormatNumber(pair.Payoff, 3),
+			fmt.Sprintf("%.1f", pair.SQN),
+			format.FormatValue(pair.Profit, 2, ""),
+			format.FormatValue(pair.Volume, 2, ""),
 		})
-		total += summary.Profit()
-		sqn += summary.SQN()
-		wins += len(summary.Win())
-		loses += len(summary.Lose())
-		volume += summary.Volume
 	}
 
 	table.SetFooter([]string{
 		"TOTAL",
-		strconv.Itoa(wins + loses),
-		strconv.Itoa(wins),
-		strconv.Itoa(loses),
-		fmt.Sprintf("%.1f %%", float64(wins)/float64(wins+loses)*100),
-		fmt.Sprintf("%.3f", avgPayoff/float64(wins+loses)),
-		fmt.Sprintf("%.1f", sqn/float64(len(n.orderController.Results))),
-		fmt.Sprintf("%.2f", total),
-		fmt.Sprintf("%.2f", volume),
+		strconv.Itoa(result.Trades),
+		strconv.Itoa(result.Wins),
+		strconv.Itoa(result.Losses),
+		format.FormatPercent(result.WinPercentage, 1),
+		format.FormatNumber(result.Payoff, 3),
+		fmt.Sprintf("%.1f", result.SQN),
+		format.FormatValue(result.Profit, 2, result.Currency),
+		format.FormatValue(result.Volume, 2, result.Currency),
 	})
 	table.Render()
 
 	fmt.Println(buffer.String())
+	n.tagSummary()
 	if n.paperWallet != nil {
 		n.paperWallet.Summary()
 	}
 }
 
-func (n *NinjaBot) onCandle(candle model.Candle) {
-	n.priorityQueueCandle.Push(candle)
-}
-
-func (n *NinjaBot) processCandle(candle model.Candle) {
-	if n.paperWallet != nil {
+// processCandle routes a candle to the strategy controller of its feed, the candles of the primary feeds
+// also update the paper wallet and the order controller
+func (n *NinjaBot) processCandle(f *feed, candle model.Candle) {
+	if f.primary && n.paperWallet != nil {
 		n.paperWallet.OnCandle(candle)
 	}
 
-	n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
+	f.controller.OnPartialCandle(candle)
 	if candle.Complete {
-		n.strategiesControllers[candle.Pair].OnCandle(candle)
-		n.orderController.OnCandle(candle)
+		f.controller.OnCandle(candle)
+		if f.primary {
+			n.orderController.OnCandle(candle)
+		}
 	}
 }
 
 // Process pending candles in buffer
 func (n *NinjaBot) processCandles() {
 	for item := range n.priorityQueueCandle.PopLock() {
-		n.processCandle(item.(model.Candle))
+		candle := item.(feedCandle)
+		n.processCandle(candle.feed, candle.Candle)
 	}
 }
 
@@ -263,14 +663,22 @@ func (n *NinjaBot) backtestCandles() {
 	for n.priorityQueueCandle.Len() > 0 {
 		item := n.priorityQueueCandle.Pop()
 
-		candle := item.(model.Candle)
-		if n.paperWallet != nil {
-			n.paperWallet.OnCandle(candle)
+		candle, f := item.(feedCandle).Candle, item.(feedCandle).feed
+		if f.primary {
+			if n.paperWallet != nil {
+				n.paperWallet.OnCandle(candle)
+			}
+
+			// process fills of the candle before the strategy, without the live ticker
+			n.orderController.Reconcile()
 		}
 
-		n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
+		f.controller.OnPartialCandle(candle)
 		if candle.Complete {
-			n.strategiesControllers[candle.Pair].OnCandle(candle)
+			f.controller.OnCandle(candle)
+			if f.primary {
+				n.orderController.OnCandle(candle)
+			}
 		}
 
 		if err := progressBar.Add(1); err != nil {
@@ -280,43 +688,91 @@ func (n *NinjaBot) backtestCandles() {
 }
 
 // Before Ninjabot start, we need to load the necessary data to fill strategy indicators
-// Then, we need to get the time frame and warmup period to fetch the necessary candles
-func (n *NinjaBot) preload(ctx context.Context, pair string) error {
+// Then, we need to get the time frame and history size (warmup period or max history) to fetch the necessary candles
+func (n *NinjaBot) preload(ctx context.Context, f *feed) error {
 	if n.backtest {
 		return nil
 	}
 
-	candles, err := n.exchange.CandlesByLimit(ctx, pair, n.strategy.Timeframe(), n.strategy.WarmupPeriod())
+	if f.primary && n.snapshot != nil && len(n.snapshot.Candles[f.pair]) > 0 {
+		return n.preloadSnapshot(ctx, f)
+	}
+
+	size := f.controller.HistorySize()
+	if n.warmupCandles > size {
+		size = n.warmupCandles
+	}
+
+	candles, err := n.exchange.CandlesByLimit(ctx, f.pair, f.timeframe, size)
+	if err != nil {
+		return err
+	}
+
+	if len(candles) < size {
+		log.Warnf("[SETUP] %s-%s: exchange returned %d of %d candles requested for warmup", f.pair, f.timeframe,
+			len(candles), size)
+	}
+
+	for _, candle := range candles {
+		n.processCandle(f, candle)
+	}
+
+	n.dataFeed.Preload(f.pair, f.timeframe, candles)
+
+	return nil
+}
+
+// preloadSnapshot fills the strategy with the candles of the snapshot, followed by the candles
+// closed in the exchange after the snapshot
+func (n *NinjaBot) preloadSnapshot(ctx context.Context, f *feed) error {
+	pair := f.pair
+	if n.snapshot.Timeframe != f.timeframe {
+		return fmt.Errorf("snapshot timeframe %s does not match the strategy timeframe %s",
+			n.snapshot.Timeframe, f.timeframe)
+	}
+
+	candles := append([]model.Candle(nil), n.snapshot.Candles[pair]...)
+	loaded := len(candles)
+	last := candles[loaded-1].Time
+
+	recent, err := n.exchange.CandlesByPeriod(ctx, pair, f.timeframe, last, time.Now())
 	if err != nil {
 		return err
 	}
 
+	for _, candle := range recent {
+		if candle.Time.After(last) {
+			candles = append(candles, candle)
+		}
+	}
+
+	log.Infof("[SETUP] %s: loaded %d candles from snapshot of %s, %d candles from the exchange", pair,
+		loaded, n.snapshot.CreatedAt.Format(time.RFC3339), len(candles)-loaded)
+
 	for _, candle := range candles {
-		n.processCandle(candle)
+		n.processCandle(f, candle)
 	}
 
-	n.dataFeed.Preload(pair, n.strategy.Timeframe(), candles)
+	n.dataFeed.Preload(pair, f.timeframe, candles)
 
 	return nil
 }
 
 // Run will initialize the strategy controller, order controller, preload data and start the bot
 func (n *NinjaBot) Run(ctx context.Context) error {
+	// setup the strategy in the candles of each pair, preload the warmup period and subscribe to the data feed
 	for _, pair := range n.settings.Pairs {
-		// setup and subscribe strategy to data feed (candles)
-		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, n.orderController)
+		n.addFeed(pair, n.strategy.Timeframe(), n.strategy, true)
+	}
 
-		// preload candles for warmup period
-		err := n.preload(ctx, pair)
-		if err != nil {
+	if err := n.startFeeds(ctx); err != nil {
+		return err
+	}
+
+	if n.resume && !n.backtest {
+		if err := n.orderController.ResumePositions(n.settings.Pairs, n.resumeSince); err != nil {
 			return err
 		}
-
-		// link to ninja bot controller
-		n.dataFeed.Subscribe(pair, n.strategy.Timeframe(), n.onCandle, false)
-
-		// start strategy controller
-		n.strategiesControllers[pair].Start()
 	}
 
 	// start order feed and controller
@@ -2,17 +2,27 @@ package ninjabot
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/strategy"
 
 	"github.com/markcheno/go-talib"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
 )
 
 type fakeStrategy struct{}
@@ -112,5 +122,379 @@ func TestMarketOrder(t *testing.T) {
 	require.Len(t, results.Win(), 9)
 	require.Len(t, results.Lose(), 8)
 
-	bot.Summary()
+	result := bot.Result()
+	require.Len(t, result.Pairs, 2)
+	require.Equal(t, "BTCUSDT", result.Pairs[0].Pair)
+	require.Equal(t, 17, result.Pairs[0].Trades)
+	require.Equal(t, 6, result.Pairs[0].Wins)
+	require.InDelta(t, 7424.3705, result.Pairs[0].Profit, 0.001)
+	require.Equal(t, "ETHUSDT", result.Pairs[1].Pair)
+	require.Equal(t, 34, result.Trades)
+	require.Equal(t, 15, result.Wins)
+	require.Equal(t, 19, result.Losses)
+	require.InDelta(t, 7424.3705+9270.3036, result.Profit, 0.001)
+	require.NotNil(t, result.Wallet)
+	require.Equal(t, 10000.0, result.Wallet.InitialValue)
+	require.InDelta(t, result.Wallet.FinalValue-10000, result.Wallet.Profit, 1e-6)
+	require.Less(t, result.Wallet.MaxDrawdown, 0.0)
+
+	// the summary prints the values of the result
+	output := captureStdout(t, bot.Summary)
+	require.Contains(t, output, model.FormatValue(result.Profit, 2, ""))
+	require.Contains(t, output, model.FormatValue(result.Pairs[1].Profit, 2, ""))
+	require.Contains(t, output, fmt.Sprintf("FINAL PORTFOLIO     = %s",
+		model.FormatValue(result.Wallet.FinalValue, 2, "USDT")))
+	require.Contains(t, output, fmt.Sprintf("MAX DRAWDOWN = %s",
+		model.FormatPercent(result.Wallet.MaxDrawdown*100, 2)))
+	require.Contains(t, output, fmt.Sprintf("SHARPE/YEAR  = %.3f", result.Wallet.AnnualSharpe))
+	require.Contains(t, output, fmt.Sprintf("BTCUSDT         = %s (%d trades)",
+		model.FormatValue(result.Wallet.RealizedProfit["BTCUSDT"], 4, "USDT"), len(paperWallet.Trades("BTCUSDT"))))
+}
+
+// captureStdout returns the output of a function in stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buffer strings.Builder
+		_, _ = io.Copy(&buffer, reader)
+		output <- buffer.String()
+	}()
+
+	fn()
+	require.NoError(t, writer.Close())
+	return <-output
+}
+
+// breakoutStrategy buys one unit when a candle closes 5% above its open and sells it in the next candle
+type breakoutStrategy struct{}
+
+func (b breakoutStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (b breakoutStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (b breakoutStrategy) Indicators(_ *Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (b breakoutStrategy) OnCandle(df *Dataframe, broker service.Broker) {
+	assetPosition, _, err := broker.Position(df.Pair)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if assetPosition > 0 {
+		if _, err := broker.CreateOrderMarket(SideTypeSell, df.Pair, assetPosition); err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	if df.Close.Last(0) > df.Open.Last(0)*1.05 {
+		if _, err := broker.CreateOrderMarket(SideTypeBuy, df.Pair, 1); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func TestStrictNoLookahead(t *testing.T) {
+	// time, open, close, low, high, volume
+	file := filepath.Join(t.TempDir(), "btc-1d.csv")
+	content := strings.Join([]string{
+		"1640995200,100,100,100,100,1",
+		"1641081600,100,100,100,100,1",
+		"1641168000,100,110,100,110,1", // breakout
+		"1641254400,120,125,118,126,1", // gap up after the signal
+		"1641340800,124,124,124,124,1",
+	}, "\n")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+
+	backtest := func(options ...Option) float64 {
+		ctx := context.Background()
+
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+
+		csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      file,
+			Timeframe: "1d",
+		})
+		require.NoError(t, err)
+
+		paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithDataFeed(csvFeed))
+
+		options = append(options, WithStorage(storage), WithBacktest(paperWallet), WithLogLevel(log.ErrorLevel))
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, breakoutStrategy{}, options...)
+		require.NoError(t, err)
+		require.NoError(t, bot.Run(ctx))
+
+		orders, err := storage.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+
+		return bot.orderController.Results["BTCUSDT"].Profit()
+	}
+
+	// biased: bought at the close of the breakout candle (110) and sold at the close of the next one (125)
+	require.InDelta(t, 15.0, backtest(), 1e-9)
+
+	// unbiased: bought at the open after the breakout (120) and sold at the open of the last candle (124)
+	require.InDelta(t, 4.0, backtest(WithStrictNoLookahead()), 1e-9)
+}
+
+type observerEvents struct {
+	sync.Mutex
+	candles []string
+	orders  []string
+}
+
+type fakeObserver struct {
+	name   string
+	events *observerEvents
+}
+
+func (o fakeObserver) OnCandle(df *Dataframe, _ service.Broker) {
+	o.events.Lock()
+	defer o.events.Unlock()
+	o.events.candles = append(o.events.candles, fmt.Sprintf("%s %s %s", df.Pair, df.LastUpdate, o.name))
+}
+
+func (o fakeObserver) OnOrder(order model.Order) {
+	o.events.Lock()
+	defer o.events.Unlock()
+	o.events.orders = append(o.events.orders, fmt.Sprintf("%s %d %s", order.Pair, order.ID, o.name))
+}
+
+func TestObservers(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	events := &observerEvents{}
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, new(fakeStrategy),
+		WithStorage(storage),
+		WithBacktest(paperWallet),
+		WithLogLevel(log.ErrorLevel),
+		WithObservers(fakeObserver{name: "first", events: events}, fakeObserver{name: "second", events: events}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.NotEmpty(t, orders)
+
+	// orders are delivered asynchronously by the order feed
+	require.Eventually(t, func() bool {
+		events.Lock()
+		defer events.Unlock()
+		return len(events.orders) >= 2*len(orders) && len(events.orders)%2 == 0
+	}, time.Second, 10*time.Millisecond)
+
+	events.Lock()
+	defer events.Unlock()
+
+	// each event is received by the first observer, then by the second one
+	for _, received := range [][]string{events.candles, events.orders} {
+		require.NotEmpty(t, received)
+		for i := 0; i < len(received); i += 2 {
+			require.True(t, strings.HasSuffix(received[i], " first"))
+			require.Equal(t, strings.TrimSuffix(received[i], "first")+"second", received[i+1])
+		}
+	}
+}
+
+func TestWarmupCandles(t *testing.T) {
+	ctx := context.Background()
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	candles := make([]model.Candle, 0)
+	for i := 0; i < 20; i++ {
+		price := float64(i + 1)
+		candles = append(candles, model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     time.Date(2021, 1, i+1, 0, 0, 0, 0, time.UTC),
+			Open:     price,
+			Close:    price,
+			Low:      price,
+			High:     price,
+			Complete: true,
+		})
+	}
+
+	t.Run("default warmup period", func(t *testing.T) {
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1d", 9).Return(candles[:9], nil)
+
+		str := new(fakeStrategy)
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, exc, str, WithStorage(storage))
+		require.NoError(t, err)
+
+		f := bot.addFeed("BTCUSDT", "1d", str, true)
+		require.NoError(t, bot.preload(ctx, f))
+		require.Len(t, f.controller.Dataframe().Close, 9)
+	})
+
+	t.Run("custom size", func(t *testing.T) {
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1d", 20).Return(candles, nil)
+
+		str := new(fakeStrategy)
+		bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, exc, str, WithStorage(storage),
+			WithWarmupCandles(20))
+		require.NoError(t, err)
+
+		f := bot.addFeed("BTCUSDT", "1d", str, true)
+		require.NoError(t, bot.preload(ctx, f))
+		require.Len(t, f.controller.Dataframe().Close, 20)
+	})
+}
+
+func TestFeedsWarmup(t *testing.T) {
+	ctx := context.Background()
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	candlesOf := func(pair string, size int, interval time.Duration) []model.Candle {
+		candles := make([]model.Candle, 0, size)
+		start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < size; i++ {
+			price := float64(i + 1)
+			candles = append(candles, model.Candle{
+				Pair:     pair,
+				Time:     start.Add(time.Duration(i) * interval),
+				Open:     price,
+				Close:    price,
+				Low:      price,
+				High:     price,
+				Complete: true,
+			})
+		}
+		return candles
+	}
+
+	exc := mocks.NewExchange(t)
+	exc.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1d", 9).
+		Return(candlesOf("BTCUSDT", 9, 24*time.Hour), nil)
+	exc.EXPECT().CandlesByLimit(mock.Anything, "ETHUSDT", "1d", 9).
+		Return(candlesOf("ETHUSDT", 5, 24*time.Hour), nil)
+	exc.EXPECT().CandlesByLimit(mock.Anything, "BTCUSDT", "1h", 1).
+		Return(candlesOf("BTCUSDT", 1, time.Hour), nil)
+
+	str := new(fakeStrategy)
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT", "ETHUSDT"}}, exc, str, WithStorage(storage))
+	require.NoError(t, err)
+
+	btc := bot.addFeed("BTCUSDT", "1d", str, true)
+	eth := bot.addFeed("ETHUSDT", "1d", str, true)
+	hourly := bot.addFeed("BTCUSDT", "1h", new(breakoutStrategy), false)
+	for _, f := range []*feed{btc, eth, hourly} {
+		require.NoError(t, bot.preload(ctx, f))
+	}
+
+	// each feed is warmed with its own history size, and the candles are routed only to its controller
+	require.Len(t, btc.controller.Dataframe().Close, 9)
+	require.Len(t, eth.controller.Dataframe().Close, 5)
+	require.Len(t, hourly.controller.Dataframe().Close, 1)
+	require.Equal(t, "ETHUSDT", eth.controller.Dataframe().Pair)
+	require.Equal(t, btc, bot.primaryFeed("BTCUSDT"))
+	require.Nil(t, bot.primaryFeed("BNBUSDT"))
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	csvFeed, err := exchange.NewCSVFeed("1d", exchange.PairFeed{
+		Pair:      "BTCUSDT",
+		File:      "testdata/btc-1h.csv",
+		Timeframe: "1h",
+	})
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed))
+
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, new(fakeStrategy),
+		WithStorage(storage),
+		WithBacktest(paperWallet),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	path := t.TempDir() + "/snapshot.json"
+	require.NoError(t, bot.SaveSnapshot(path))
+
+	snapshot, err := LoadSnapshot(path)
+	require.NoError(t, err)
+	require.Equal(t, "1d", snapshot.Timeframe)
+	require.Equal(t, paperWallet.State(), *snapshot.Wallet)
+
+	// candles limited to the warmup period
+	df := bot.primaryFeed("BTCUSDT").controller.Dataframe()
+	candles := snapshot.Candles["BTCUSDT"]
+	require.Len(t, candles, 9)
+	last := candles[len(candles)-1]
+	require.True(t, df.Time[len(df.Time)-1].Equal(last.Time))
+	require.Equal(t, df.Close.Last(0), last.Close)
+
+	t.Run("live startup", func(t *testing.T) {
+		next := model.Candle{Pair: "BTCUSDT", Time: last.Time.AddDate(0, 0, 1), Open: 1, Close: 1, Low: 1, High: 1,
+			Complete: true}
+
+		exc := mocks.NewExchange(t)
+		exc.EXPECT().CandlesByPeriod(mock.Anything, "BTCUSDT", "1d", last.Time, mock.Anything).
+			Return([]model.Candle{last, next}, nil)
+
+		str := new(fakeStrategy)
+		liveBot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, exc, str, WithStorage(storage),
+			WithSnapshot(snapshot))
+		require.NoError(t, err)
+
+		f := liveBot.addFeed("BTCUSDT", "1d", str, true)
+		require.NoError(t, liveBot.preload(ctx, f))
+
+		df := f.controller.Dataframe()
+		require.Len(t, df.Close, 10)
+		require.Equal(t, next.Close, df.Close.Last(0))
+		require.Len(t, df.Metadata["ema9"], 10)
+	})
+
+	t.Run("version mismatch", func(t *testing.T) {
+		path := t.TempDir() + "/snapshot.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{"version": 99}`), 0600))
+
+		_, err := LoadSnapshot(path)
+		require.ErrorIs(t, err, ErrSnapshotVersion)
+	})
 }
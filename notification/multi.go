@@ -0,0 +1,54 @@
+package notification
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// MultiNotifier sends the notifications to a list of notifiers, e.g. Telegram and a webhook.
+// The notifiers are called in sequence, a notifier that panics does not prevent the next ones to be called.
+type MultiNotifier struct {
+	notifiers []service.Notifier
+}
+
+func NewMultiNotifier(notifiers ...service.Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+// Add registers more notifiers, it must be called before the notifier is in use
+func (m *MultiNotifier) Add(notifiers ...service.Notifier) {
+	m.notifiers = append(m.notifiers, notifiers...)
+}
+
+func (m *MultiNotifier) each(call func(notifier service.Notifier)) {
+	for _, notifier := range m.notifiers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("notification: %T failed: %v", notifier, r)
+				}
+			}()
+			call(notifier)
+		}()
+	}
+}
+
+func (m *MultiNotifier) Notify(text string) {
+	m.each(func(notifier service.Notifier) {
+		notifier.Notify(text)
+	})
+}
+
+func (m *MultiNotifier) OnOrder(order model.Order) {
+	m.each(func(notifier service.Notifier) {
+		notifier.OnOrder(order)
+	})
+}
+
+func (m *MultiNotifier) OnError(err error) {
+	m.each(func(notifier service.Notifier) {
+		notifier.OnError(err)
+	})
+}
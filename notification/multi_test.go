@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+type panicNotifier struct{}
+
+func (panicNotifier) Notify(string) {
+	panic("connection refused")
+}
+
+func (panicNotifier) OnOrder(model.Order) {
+	panic("connection refused")
+}
+
+func (panicNotifier) OnError(error) {
+	panic("connection refused")
+}
+
+func TestMultiNotifier(t *testing.T) {
+	order := model.Order{Pair: "BTCUSDT"}
+	err := errors.New("insufficient funds")
+
+	notifiers := []service.Notifier{panicNotifier{}}
+	for i := 0; i < 2; i++ {
+		notifier := mocks.NewNotifier(t)
+		notifier.EXPECT().Notify("message").Once()
+		notifier.EXPECT().OnOrder(order).Once()
+		notifier.EXPECT().OnError(err).Once()
+		notifiers = append(notifiers, notifier)
+	}
+
+	multi := NewMultiNotifier(notifiers[:2]...)
+	multi.Add(notifiers[2])
+
+	require.NotPanics(t, func() {
+		multi.Notify("message")
+		multi.OnOrder(order)
+		multi.OnError(err)
+	})
+}
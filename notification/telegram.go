@@ -18,8 +18,9 @@ import (
 )
 
 var (
-	buyRegexp  = regexp.MustCompile(`/buy\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
-	sellRegexp = regexp.MustCompile(`/sell\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
+	buyRegexp      = regexp.MustCompile(`/buy\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
+	sellRegexp     = regexp.MustCompile(`/sell\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
+	simulateRegexp = regexp.MustCompile(`(?i)/simulate\s+(?P<side>buy|sell)\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)`)
 )
 
 type telegram struct {
@@ -79,6 +80,7 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 		{Text: "/profit", Description: "Summary of last trade results"},
 		{Text: "/buy", Description: "open a buy order"},
 		{Text: "/sell", Description: "open a sell order"},
+		{Text: "/simulate", Description: "estimate the effect of a market order"},
 	})
 	if err != nil {
 		return nil, err
@@ -108,6 +110,7 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 	client.Handle("/profit", bot.ProfitHandle)
 	client.Handle("/buy", bot.BuyHandle)
 	client.Handle("/sell", bot.SellHandle)
+	client.Handle("/simulate", bot.SimulateHandle)
 
 	return bot, nil
 }
@@ -314,6 +317,51 @@ func (t telegram) SellHandle(m *tb.Message) {
 	log.Info("[TELEGRAM]: SELL ORDER CREATED: ", order)
 }
 
+func (t telegram) SimulateHandle(m *tb.Message) {
+	match := simulateRegexp.FindStringSubmatch(m.Text)
+	if len(match) == 0 {
+		_, err := t.client.Send(m.Sender, "Invalid command.\nExample of usage:\n`/simulate buy BTCUSDT 0.01`")
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	command := make(map[string]string)
+	for i, name := range simulateRegexp.SubexpNames() {
+		if i != 0 && name != "" {
+			command[name] = match[i]
+		}
+	}
+
+	side := model.SideType(strings.ToUpper(command["side"]))
+	pair := strings.ToUpper(command["pair"])
+	amount, err := strconv.ParseFloat(command["amount"], 64)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	} else if amount <= 0 {
+		_, err := t.client.Send(m.Sender, "Invalid amount")
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	result, err := t.orderController.SimulateOrder(side, pair, amount)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	_, err = t.client.Send(m.Sender, fmt.Sprintf("*SIMULATION*\n`%s`", result))
+	if err != nil {
+		log.Error(err)
+	}
+}
+
 func (t telegram) StatusHandle(m *tb.Message) {
 	status := t.orderController.Status()
 	_, err := t.client.Send(m.Sender, fmt.Sprintf("Status: `%s`", status))
@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// Throttle wraps a notifier to avoid flooding it, e.g. repeated profit or error messages of a flapping
+// condition. Messages and errors equal to one sent within the dedup window are suppressed, and the
+// excess over the rate limit per minute is dropped. Orders are always delivered.
+type Throttle struct {
+	sync.Mutex
+	notifier service.Notifier
+	window   time.Duration
+	limit    int
+	now      func() time.Time
+
+	sent    map[string]time.Time
+	history []time.Time
+}
+
+type ThrottleOption func(*Throttle)
+
+// WithThrottleWindow sets the window to suppress duplicated messages, default is 1 minute
+func WithThrottleWindow(window time.Duration) ThrottleOption {
+	return func(throttle *Throttle) {
+		throttle.window = window
+	}
+}
+
+// WithThrottleRateLimit sets the maximum number of messages per minute, default is 20.
+// Zero disables the rate limit.
+func WithThrottleRateLimit(limit int) ThrottleOption {
+	return func(throttle *Throttle) {
+		throttle.limit = limit
+	}
+}
+
+func NewThrottle(notifier service.Notifier, options ...ThrottleOption) *Throttle {
+	throttle := &Throttle{
+		notifier: notifier,
+		window:   time.Minute,
+		limit:    20,
+		now:      time.Now,
+		sent:     make(map[string]time.Time),
+	}
+
+	for _, option := range options {
+		option(throttle)
+	}
+
+	return throttle
+}
+
+// allow returns true if the message must be sent, registering it as sent
+func (t *Throttle) allow(message string) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.now()
+	for key, sentAt := range t.sent {
+		if now.Sub(sentAt) >= t.window {
+			delete(t.sent, key)
+		}
+	}
+
+	if _, ok := t.sent[message]; ok {
+		log.Debugf("notification: duplicated message suppressed: %s", message)
+		return false
+	}
+
+	if t.limit > 0 {
+		start := 0
+		for start < len(t.history) && now.Sub(t.history[start]) >= time.Minute {
+			start++
+		}
+		t.history = t.history[start:]
+
+		if len(t.history) >= t.limit {
+			log.Warnf("notification: rate limit of %d messages per minute reached, message dropped: %s",
+				t.limit, message)
+			return false
+		}
+		t.history = append(t.history, now)
+	}
+
+	t.sent[message] = now
+	return true
+}
+
+func (t *Throttle) Notify(text string) {
+	if t.allow(text) {
+		t.notifier.Notify(text)
+	}
+}
+
+func (t *Throttle) OnOrder(order model.Order) {
+	t.notifier.OnOrder(order)
+}
+
+func (t *Throttle) OnError(err error) {
+	if t.allow("error: " + err.Error()) {
+		t.notifier.OnError(err)
+	}
+}
@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestThrottle(t *testing.T) {
+	t.Run("dedup", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+		notifier := mocks.NewNotifier(t)
+		throttle := NewThrottle(notifier, WithThrottleWindow(time.Minute), WithThrottleRateLimit(0))
+		throttle.now = clock.Now
+
+		notifier.EXPECT().Notify("profit").Twice()
+		notifier.EXPECT().Notify("loss").Once()
+		notifier.EXPECT().OnError(errors.New("timeout")).Once()
+		notifier.EXPECT().OnOrder(model.Order{ID: 1}).Twice()
+
+		throttle.Notify("profit")
+		throttle.Notify("loss")
+		clock.Add(30 * time.Second)
+		throttle.Notify("profit")
+		throttle.OnError(errors.New("timeout"))
+		throttle.OnError(errors.New("timeout"))
+
+		// orders are not suppressed
+		throttle.OnOrder(model.Order{ID: 1})
+		throttle.OnOrder(model.Order{ID: 1})
+
+		// window expired
+		clock.Add(30 * time.Second)
+		throttle.Notify("profit")
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+		notifier := mocks.NewNotifier(t)
+		throttle := NewThrottle(notifier, WithThrottleWindow(0), WithThrottleRateLimit(2))
+		throttle.now = clock.Now
+
+		notifier.EXPECT().Notify("first").Once()
+		notifier.EXPECT().Notify("second").Once()
+		notifier.EXPECT().Notify("fourth").Once()
+
+		throttle.Notify("first")
+		clock.Add(20 * time.Second)
+		throttle.Notify("second")
+		clock.Add(20 * time.Second)
+		throttle.Notify("third") // dropped
+
+		// first message out of the last minute
+		clock.Add(20 * time.Second)
+		throttle.Notify("fourth")
+	})
+}
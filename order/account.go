@@ -0,0 +1,230 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var (
+	ErrUnknownAccount   = errors.New("unknown account")
+	ErrDuplicateAccount = errors.New("account already registered")
+	ErrNoEquity         = errors.New("equity is not positive")
+)
+
+// CashWeight is the key of the weight of the quote balances in Weights
+const CashWeight = "cash"
+
+// AddAccount registers an additional account with its own exchange, e.g. a short account of a hedged strategy.
+// The orders of the account are created with ForAccount and stored with the account name, sharing the storage
+// and the order feed of the controller. The account copies the current settings of the controller, so it
+// must be added after them. The results of each account are calculated separately.
+func (c *Controller) AddAccount(name string, exchange service.Exchange) error {
+	if _, err := c.ForAccount(name); err == nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateAccount, name)
+	}
+
+	account := NewController(c.ctx, exchange, c.storage, c.orderFeed)
+	account.account = name
+	account.notifier = c.notifier
+	account.backtest = c.backtest
+	account.tickerInterval = c.tickerInterval
+	account.minProfitToClose = c.minProfitToClose
+	account.marketType = c.marketType
+	account.contractSize = c.contractSize
+	account.statsWarmup = c.statsWarmup
+	account.noShort = c.noShort
+	account.sizeClamp = c.sizeClamp
+	account.partialUpdates = c.partialUpdates
+	account.feeRate = c.feeRate
+	account.precision = c.precision
+	account.maxErrors = c.maxErrors
+	account.haltAll = c.haltAll
+	account.reportCurrency = c.reportCurrency
+	account.skipVerbosity = c.skipVerbosity
+	for pair, price := range c.lastPrice {
+		account.lastPrice[pair] = price
+	}
+	for pair, distance := range c.staleDistance {
+		account.SetOrderStaleCancel(pair, distance)
+	}
+
+	if c.accounts == nil {
+		c.accounts = make(map[string]*Controller)
+	}
+	c.accounts[name] = account
+	return nil
+}
+
+// ForAccount returns the controller of an account, to route orders and query the balance of the account.
+// The name of the default account is empty.
+func (c *Controller) ForAccount(name string) (*Controller, error) {
+	if name == c.account {
+		return c, nil
+	}
+
+	if account, ok := c.accounts[name]; ok {
+		return account, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, name)
+}
+
+// Accounts returns the names of the accounts of the controller, sorted, starting with the default account
+func (c *Controller) Accounts() []string {
+	names := make([]string, 0, len(c.accounts))
+	for name := range c.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{c.account}, names...)
+}
+
+// all returns the controllers of all accounts, starting with the default account
+func (c *Controller) all() []*Controller {
+	controllers := []*Controller{c}
+	for _, name := range c.Accounts()[1:] {
+		controllers = append(controllers, c.accounts[name])
+	}
+	return controllers
+}
+
+// Equity returns the value of the account in quote: the quote balances and the positions of the pairs
+// with a known price, valued at the last price. The pairs are expected to share the same quote.
+func (c *Controller) Equity() (float64, error) {
+	account, err := c.exchange.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	var equity float64
+	quotes := make(map[string]bool)
+	for pair, price := range c.lastPrice {
+		assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+		asset, _ := account.Balance(assetTick, quoteTick)
+		equity += (asset.Free + asset.Lock) * price
+		quotes[quoteTick] = true
+	}
+
+	for quoteTick := range quotes {
+		_, quote := account.Balance("", quoteTick)
+		equity += quote.Free + quote.Lock
+	}
+
+	return equity, nil
+}
+
+// OpenPositions returns the quantity of the open positions of the pairs with a known price, negative for shorts.
+// The dust is not a position, see Position. The errors of the exchange are notified and the pair is skipped.
+func (c *Controller) OpenPositions() map[string]float64 {
+	positions := make(map[string]float64)
+	for pair := range c.lastPrice {
+		asset, _, err := c.Position(pair)
+		if err != nil {
+			c.notifyError(fmt.Errorf("open positions %s: %w", pair, err))
+			continue
+		}
+
+		if asset != 0 {
+			positions[pair] = asset
+		}
+	}
+	return positions
+}
+
+// OpenPositionCount returns the number of open positions, see OpenPositions
+func (c *Controller) OpenPositionCount() int {
+	return len(c.OpenPositions())
+}
+
+// Weights returns the fraction of the equity of each pair with a known price, the position value divided by the
+// equity, see PositionValue and Equity. Short positions have negative weights. The remaining equity, the quote
+// balances and the dust excluded from the positions, is the weight of CashWeight, so the weights sum to 1.
+// It returns ErrNoEquity if the equity is zero or negative.
+func (c *Controller) Weights() (map[string]float64, error) {
+	equity, err := c.Equity()
+	if err != nil {
+		return nil, err
+	}
+
+	if equity <= 0 {
+		return nil, fmt.Errorf("%w: %f", ErrNoEquity, equity)
+	}
+
+	weights := make(map[string]float64, len(c.lastPrice)+1)
+	cash := equity
+	for pair := range c.lastPrice {
+		value, err := c.PositionValue(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		weights[pair] = value / equity
+		cash -= value
+	}
+	weights[CashWeight] = cash / equity
+
+	return weights, nil
+}
+
+// AggregateAccount returns the balances of all accounts, summed by asset
+func (c *Controller) AggregateAccount() (model.Account, error) {
+	var (
+		assets   []string
+		balances = make(map[string]*model.Balance)
+	)
+	for _, controller := range c.all() {
+		account, err := controller.exchange.Account()
+		if err != nil {
+			return model.Account{}, fmt.Errorf("account %s: %w", controller.account, err)
+		}
+
+		for _, balance := range account.Balances {
+			total, ok := balances[balance.Asset]
+			if !ok {
+				total = &model.Balance{Asset: balance.Asset}
+				balances[balance.Asset] = total
+				assets = append(assets, balance.Asset)
+			}
+			total.Free += balance.Free
+			total.Lock += balance.Lock
+		}
+	}
+
+	aggregated := model.Account{}
+	for _, asset := range assets {
+		aggregated.Balances = append(aggregated.Balances, *balances[asset])
+	}
+	return aggregated, nil
+}
+
+// AggregatePosition returns the asset and quote of a pair summed across all accounts, e.g. the net
+// position of a long and a short account
+func (c *Controller) AggregatePosition(pair string) (asset, quote float64, err error) {
+	for _, controller := range c.all() {
+		accountAsset, accountQuote, err := controller.Position(pair)
+		if err != nil {
+			return 0, 0, fmt.Errorf("account %s: %w", controller.account, err)
+		}
+		asset += accountAsset
+		quote += accountQuote
+	}
+	return asset, quote, nil
+}
+
+// AggregateEquity returns the equity of all accounts, see Equity
+func (c *Controller) AggregateEquity() (float64, error) {
+	var total float64
+	for _, controller := range c.all() {
+		equity, err := controller.Equity()
+		if err != nil {
+			return 0, fmt.Errorf("account %s: %w", controller.account, err)
+		}
+		total += equity
+	}
+	return total, nil
+}
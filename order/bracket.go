@@ -0,0 +1,153 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+var (
+	ErrInvalidBracket  = errors.New("invalid bracket: stop loss and take profit must be on opposite sides")
+	ErrBracketRejected = errors.New("bracket entry not filled")
+)
+
+// bracket is an entry order with a stop loss and a take profit, placed as an OCO order when the entry is filled
+type bracket struct {
+	entry      model.Order
+	stopLoss   float64
+	takeProfit float64
+	exits      []model.Order
+}
+
+func (b *bracket) exitSide() model.SideType {
+	if b.entry.Side == model.SideTypeBuy {
+		return model.SideTypeSell
+	}
+	return model.SideTypeBuy
+}
+
+func (b *bracket) isExit(order model.Order) bool {
+	for _, exit := range b.exits {
+		if exit.ExchangeID == order.ExchangeID {
+			return true
+		}
+	}
+	return false
+}
+
+// covered returns true if the position of the pair still covers the exits of the bracket
+func (b *bracket) covered(asset float64) bool {
+	if b.entry.Side == model.SideTypeBuy {
+		return asset >= b.entry.Quantity
+	}
+	return asset <= -b.entry.Quantity
+}
+
+// CreateBracketOrder enters a position with a market order and, when the entry is filled, attaches an OCO
+// order to exit the position with the stop loss and take profit prices. If the entry is rejected, no exit
+// is placed and ErrBracketRejected is returned. The exits are canceled if the position is closed by other
+// orders, e.g. a manual close.
+func (c *Controller) CreateBracketOrder(side model.SideType, pair string, size, stopLoss,
+	takeProfit float64) (model.Order, error) {
+
+	if stopLoss <= 0 || takeProfit <= 0 ||
+		(side == model.SideTypeBuy && stopLoss >= takeProfit) ||
+		(side == model.SideTypeSell && stopLoss <= takeProfit) {
+		return model.Order{}, ErrInvalidBracket
+	}
+
+	c.mtx.Lock()
+	defer c.unlock()
+
+	entry, err := c.placeOrderMarket(side, pair, size, "")
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	switch entry.Status {
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected, model.OrderStatusTypeExpired:
+		log.Warnf("[BRACKET] %s entry %s, exits not placed", pair, entry.Status)
+		return entry, fmt.Errorf("%w: %s", ErrBracketRejected, entry.Status)
+	}
+
+	c.brackets[entry.ExchangeID] = &bracket{
+		entry:      entry,
+		stopLoss:   stopLoss,
+		takeProfit: takeProfit,
+	}
+
+	// market orders are usually filled at creation
+	c.processBrackets(entry)
+
+	return entry, nil
+}
+
+// processBrackets updates the brackets of the pair with an order update. The exits are placed when the entry
+// is filled and canceled when the position no longer covers them. The controller lock must be held.
+func (c *Controller) processBrackets(order model.Order) {
+	for id, b := range c.brackets {
+		if b.entry.Pair != order.Pair {
+			continue
+		}
+
+		switch {
+		case order.ExchangeID == id:
+			c.processBracketEntry(b, order)
+		case b.isExit(order):
+			if order.Status != model.OrderStatusTypeNew && order.Status != model.OrderStatusTypePartiallyFilled {
+				log.Infof("[BRACKET] %s exit %s", order.Pair, order.Status)
+				delete(c.brackets, id)
+			}
+		case order.Status == model.OrderStatusTypeFilled && len(b.exits) > 0:
+			c.checkBracketPosition(b)
+		}
+	}
+}
+
+// processBracketEntry places the exits of a filled entry, or discards the bracket of a rejected entry
+func (c *Controller) processBracketEntry(b *bracket, order model.Order) {
+	id := b.entry.ExchangeID
+	switch order.Status {
+	case model.OrderStatusTypeFilled:
+		if len(b.exits) > 0 {
+			return
+		}
+
+		b.entry = order
+		exits, err := c.createOrderOCO(b.exitSide(), order.Pair, order.Quantity, b.takeProfit, b.stopLoss,
+			b.stopLoss)
+		if err != nil {
+			log.Errorf("[BRACKET] %s: exits not placed: %v", order.Pair, err)
+			delete(c.brackets, id)
+			return
+		}
+
+		b.exits = exits
+		log.Infof("[BRACKET] %s: stop loss at %f and take profit at %f", order.Pair, b.stopLoss, b.takeProfit)
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected, model.OrderStatusTypeExpired:
+		log.Warnf("[BRACKET] %s entry %s, exits not placed", order.Pair, order.Status)
+		delete(c.brackets, id)
+	}
+}
+
+// checkBracketPosition cancels the exits of a bracket if the position was closed by other orders
+func (c *Controller) checkBracketPosition(b *bracket) {
+	asset, _, err := c.exchange.Position(b.entry.Pair)
+	if err != nil {
+		c.notifyError(err)
+		return
+	}
+
+	if b.covered(asset) {
+		return
+	}
+
+	// canceling an order of the OCO group cancels the other one
+	log.Infof("[BRACKET] %s: position closed, canceling exits", b.entry.Pair)
+	if err := c.cancel(b.exits[0]); err != nil {
+		c.notifyError(err)
+	}
+	delete(c.brackets, b.entry.ExchangeID)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +26,64 @@ type summary struct {
 	LoseLong  []float64
 	LoseShort []float64
 	Volume    float64
+
+	// precision is the number of decimal places of the reported profits, differences below it are float
+	// noise, see SetSummaryPrecision. Zero disables the rounding.
+	precision int
+	// format is the display format of the controller, see SetDisplayFormat
+	format *model.DisplayFormat
+
+	// Warmup holds the profit of the trades closed during the statistics warmup, see SetStatsWarmup.
+	// They are not included in the metrics of the summary.
+	Warmup []float64
+
+	// ByTag groups the results of the tagged orders, see CreateOrderMarketTagged
+	ByTag map[string]*summary
+
+	// Currency is the reporting currency of the controller, ReportProfit and ReportVolume are the profit and
+	// volume converted to it at the time of the trades, see SetReportingCurrency
+	Currency     string
+	ReportProfit float64
+	ReportVolume float64
+}
+
+// add registers the profit of an order that closed a position
+func (s *summary) add(side model.SideType, profitValue float64) {
+	if profitValue > 0 {
+		if side == model.SideTypeBuy {
+			s.WinLong = append(s.WinLong, profitValue)
+		} else {
+			s.WinShort = append(s.WinShort, profitValue)
+		}
+	} else {
+		if side == model.SideTypeBuy {
+			s.LoseLong = append(s.LoseLong, profitValue)
+		} else {
+			s.LoseShort = append(s.LoseShort, profitValue)
+		}
+	}
+}
+
+func (s *summary) tag(tag string) *summary {
+	if s.ByTag == nil {
+		s.ByTag = make(map[string]*summary)
+	}
+
+	if _, ok := s.ByTag[tag]; !ok {
+		s.ByTag[tag] = &summary{Pair: s.Pair, precision: s.precision, format: s.format}
+	}
+
+	return s.ByTag[tag]
+}
+
+// round rounds a value to the precision of the summary
+func (s summary) round(value float64) float64 {
+	if s.precision <= 0 {
+		return value
+	}
+
+	scale := math.Pow10(s.precision)
+	return math.Round(value*scale) / scale
 }
 
 func (s summary) Win() []float64 {
@@ -36,6 +95,11 @@ func (s summary) Lose() []float64 {
 }
 
 func (s summary) Profit() float64 {
+	return s.round(s.profit())
+}
+
+// profit returns the sum of the profits without rounding, for the metrics derived from it
+func (s summary) profit() float64 {
 	profit := 0.0
 	for _, value := range append(s.Win(), s.Lose()...) {
 		profit += value
@@ -45,13 +109,20 @@ func (s summary) Profit() float64 {
 
 func (s summary) SQN() float64 {
 	total := float64(len(s.Win()) + len(s.Lose()))
-	avgProfit := s.Profit() / total
+	if total == 0 {
+		return 0
+	}
+
+	avgProfit := s.profit() / total
 	stdDev := 0.0
 	for _, profit := range append(s.Win(), s.Lose()...) {
 		stdDev += math.Pow(profit-avgProfit, 2)
 	}
 	stdDev = math.Sqrt(stdDev / total)
-	return math.Sqrt(total) * (s.Profit() / total) / stdDev
+	if stdDev == 0 {
+		return 0
+	}
+	return s.round(math.Sqrt(total) * avgProfit / stdDev)
 }
 
 func (s summary) Payoff() float64 {
@@ -70,7 +141,7 @@ func (s summary) Payoff() float64 {
 		return 0
 	}
 
-	return (avgWin / float64(len(s.Win()))) / math.Abs(avgLose/float64(len(s.Lose())))
+	return s.round((avgWin / float64(len(s.Win()))) / math.Abs(avgLose/float64(len(s.Lose()))))
 }
 
 func (s summary) WinPercentage() float64 {
@@ -85,15 +156,19 @@ func (s summary) String() string {
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
 	_, quote := exchange.SplitAssetQuote(s.Pair)
+	format := displayFormat(s.format)
 	data := [][]string{
 		{"Coin", s.Pair},
 		{"Trades", strconv.Itoa(len(s.Lose()) + len(s.Win()))},
 		{"Win", strconv.Itoa(len(s.Win()))},
 		{"Loss", strconv.Itoa(len(s.Lose()))},
-		{"% Win", fmt.Sprintf("%.1f", s.WinPercentage())},
-		{"Payoff", fmt.Sprintf("%.1f", s.Payoff()*100)},
-		{"Profit", fmt.Sprintf("%.4f %s", s.Profit(), quote)},
-		{"Volume", fmt.Sprintf("%.4f %s", s.Volume, quote)},
+		{"% Win", format.FormatNumber(s.WinPercentage(), 1)},
+		{"Payoff", format.FormatNumber(s.Payoff()*100, 1)},
+		{"Profit", format.FormatValue(s.Profit(), 4, quote)},
+		{"Volume", format.FormatValue(s.Volume, 4, quote)},
+	}
+	if len(s.Warmup) > 0 {
+		data = append(data, []string{"Warmup trades", strconv.Itoa(len(s.Warmup))})
 	}
 	table.AppendBulk(data)
 	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
@@ -101,6 +176,42 @@ func (s summary) String() string {
 	return tableString.String()
 }
 
+// MinProfitError is returned when an order that closes a position is blocked by the minimum profit filter
+type MinProfitError struct {
+	Pair      string
+	Profit    float64
+	MinProfit float64
+}
+
+func (m *MinProfitError) Error() string {
+	return fmt.Sprintf("order blocked: profit of %s (%s) is below the minimum of %s", m.Pair,
+		model.FormatPercent(m.Profit*100, 2), model.FormatPercent(m.MinProfit*100, 2))
+}
+
+// SimulationResult is the expected effect of an order, estimated by SimulateOrder
+type SimulationResult struct {
+	Pair  string
+	Side  model.SideType
+	Size  float64
+	Price float64 // estimated fill price
+	Value float64 // estimated value of the order in quote
+
+	// Asset and Quote are the resulting position of the pair after the fill
+	Asset float64
+	Quote float64
+	// QuoteChange is the variation of the quote balance caused by the order (negative when spending funds)
+	QuoteChange float64
+}
+
+func (s SimulationResult) String() string {
+	asset, quote := exchange.SplitAssetQuote(s.Pair)
+	return fmt.Sprintf("%s %s %s\nPrice: %s\nValue: %s\nPosition: %s / %s\nQuote change: %s",
+		s.Side, model.FormatValue(s.Size, 4, asset), s.Pair,
+		model.FormatValue(s.Price, 4, quote), model.FormatValue(s.Value, 4, quote),
+		model.FormatValue(s.Asset, 4, asset), model.FormatValue(s.Quote, 4, quote),
+		model.FormatValue(s.QuoteChange, 4, quote))
+}
+
 type Status string
 
 const (
@@ -121,6 +232,60 @@ type Controller struct {
 	tickerInterval time.Duration
 	finish         chan bool
 	status         Status
+
+	minProfitToClose float64
+	backtest         bool
+	marketType       model.MarketType
+	contractSize     float64
+	dust             map[string]float64
+	statsWarmup      int
+	closedTrades     int
+	noShort          bool
+	sizeClamp        bool
+	partialUpdates   bool
+	feeRate          float64
+	precision        int
+	format           *model.DisplayFormat
+	brackets         map[int64]*bracket
+	staleDistance    map[string]float64
+	reportCurrency   string
+	skipVerbosity    SkipVerbosity
+	equityInterval   time.Duration
+
+	// profitTarget is the realized profit that stops the bot, see SetProfitTarget
+	profitTarget         float64
+	profitTargetScope    string
+	profitTargetLocation *time.Location
+	targetProfit         float64
+	targetDay            string
+	targetReached        bool
+	targetStop           bool
+
+	// maintenanceSince is the time the orders were paused by a maintenance, see SetMaintenancePause
+	maintenanceProbe   time.Duration
+	maintenanceSince   time.Time
+	maintenanceChecked time.Time
+
+	// maxErrors is the limit of consecutive order errors of a pair, see SetMaxConsecutiveErrors
+	maxErrors   int
+	haltAll     bool
+	haltedAll   bool
+	orderErrors map[string]int
+	halted      map[string]bool
+
+	// account is the name of the account of the exchange, accounts are the additional accounts of the
+	// default controller, see AddAccount
+	account  string
+	accounts map[string]*Controller
+
+	// published are the orders queued to the feed while the controller lock is held, see publish
+	published  []publication
+	publishMtx sync.Mutex
+}
+
+type publication struct {
+	order    model.Order
+	newOrder bool
 }
 
 func NewController(ctx context.Context, exchange service.Exchange, storage storage.Storage,
@@ -132,9 +297,13 @@ func NewController(ctx context.Context, exchange service.Exchange, storage stora
 		exchange:       exchange,
 		orderFeed:      orderFeed,
 		lastPrice:      make(map[string]float64),
+		dust:           make(map[string]float64),
+		brackets:       make(map[int64]*bracket),
+		marketType:     model.MarketTypeSpot,
+		contractSize:   1,
 		Results:        make(map[string]*summary),
 		tickerInterval: time.Second,
-		finish:         make(chan bool),
+		finish:         make(chan bool, 1),
 	}
 }
 
@@ -142,31 +311,247 @@ func (c *Controller) SetNotifier(notifier service.Notifier) {
 	c.notifier = notifier
 }
 
-func (c *Controller) OnCandle(candle model.Candle) {
-	c.lastPrice[candle.Pair] = candle.Close
+// SetBacktest disables the ticker that polls the exchange for order updates. In backtest, the orders are
+// filled by the paper wallet on each candle, so Reconcile must be called after feeding the wallet.
+func (c *Controller) SetBacktest(backtest bool) {
+	c.backtest = backtest
 }
 
-func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, err error) {
-	// get filled orders before the current order
-	orders, err := c.storage.Orders(
-		storage.WithUpdateAtBeforeOrEqual(o.UpdatedAt),
-		storage.WithStatus(model.OrderStatusTypeFilled),
-		storage.WithPair(o.Pair),
-	)
+// SetMinProfitToClose blocks limit and market orders that close a position with a profit
+// below the given value (e.g. 0.01 for 1%). Stop and OCO orders are not affected.
+func (c *Controller) SetMinProfitToClose(profit float64) {
+	c.minProfitToClose = profit
+}
+
+// SetMarketType sets the accounting model used to calculate the profit of the trades, default is spot.
+// The contract size is only used by futures markets, where the profit is multiplied by it.
+func (c *Controller) SetMarketType(marketType model.MarketType, contractSize float64) {
+	c.marketType = marketType
+	c.contractSize = contractSize
+}
+
+// SetNoShort rejects sell orders above the asset position with exchange.ErrShortingNotAllowed,
+// to avoid accidental short positions in spot accounts
+func (c *Controller) SetNoShort(noShort bool) {
+	c.noShort = noShort
+}
+
+// SetOrderSizeClamp reduces limit and market orders that exceed the free balance to the maximum affordable
+// quantity, rounded down to the step size, instead of failing with exchange.ErrInsufficientFunds. The
+// adjustments are logged. Sell orders are only clamped to the free asset if shorting is disabled, see SetNoShort.
+func (c *Controller) SetOrderSizeClamp(clamp bool) {
+	c.sizeClamp = clamp
+}
+
+// SetPartialFillUpdates publishes the partial fills of pending orders to the order feed, e.g. to notifiers
+// and strategies. By default, only the final status of pending orders is published: filled, canceled,
+// rejected or expired. The profit of a trade is calculated once, when the order is filled.
+func (c *Controller) SetPartialFillUpdates(partial bool) {
+	c.partialUpdates = partial
+}
+
+// SetFeeRate sets the fee rate of each order (e.g. 0.001 for 0.1%), used to calculate the break-even price
+// of positions, see BreakEvenPrice
+func (c *Controller) SetFeeRate(rate float64) {
+	c.feeRate = rate
+}
+
+// SetSummaryPrecision sets the number of decimal places of the profits of the summary, to avoid the noise of
+// float sums, e.g. a break-even strategy reporting a profit of 0.0000003. Trades with a profit below the
+// precision are break-even, they are counted as losses instead of wins. The default is the quote precision
+// of the pair, see `model.AssetInfo`.
+func (c *Controller) SetSummaryPrecision(digits int) {
+	c.precision = digits
+}
+
+// SetDisplayFormat sets the display format of the values in the summaries and notifications of the controller.
+// The default is the package-level format, see `model.SetDisplayFormat`.
+func (c *Controller) SetDisplayFormat(format model.DisplayFormat) {
+	c.format = &format
+}
+
+// DisplayFormat returns the display format of the controller, see SetDisplayFormat
+func (c *Controller) DisplayFormat() model.DisplayFormat {
+	return displayFormat(c.format)
+}
+
+// displayFormat returns the format if set, or the package-level display format
+func displayFormat(format *model.DisplayFormat) model.DisplayFormat {
+	if format != nil {
+		return *format
+	}
+	return model.CurrentDisplayFormat()
+}
+
+// summaryPrecision returns the precision of the profits of a pair, see SetSummaryPrecision
+func (c *Controller) summaryPrecision(pair string) int {
+	if c.precision > 0 {
+		return c.precision
+	}
+	return c.exchange.AssetsInfo(pair).QuotePrecision
+}
+
+// SetStatsWarmup excludes the first closed trades from the summary metrics, while indicators settle.
+// The orders are still stored and their profit is kept in the Warmup field of the summary.
+func (c *Controller) SetStatsWarmup(trades int) {
+	c.statsWarmup = trades
+}
+
+// positionProfit returns the profit of closing a quantity of a position, the quantity is negative for short positions
+func (c *Controller) positionProfit(entry, exit, quantity float64) (value, percent float64) {
+	value = (exit - entry) * quantity
+	notional := entry * math.Abs(quantity)
+	if c.marketType == model.MarketTypeFutures {
+		value *= c.contractSize
+		notional *= c.contractSize
+	}
+	return value, value / notional
+}
+
+// marketPrice returns the close of the last candle, or the exchange quote if no candle was received
+func (c *Controller) marketPrice(pair string) (float64, error) {
+	if price := c.lastPrice[pair]; price > 0 {
+		return price, nil
+	}
+	return c.exchange.LastQuote(c.ctx, pair)
+}
+
+// checkMinProfit returns a MinProfitError if the order closes a position below the minimum profit
+func (c *Controller) checkMinProfit(side model.SideType, pair string, quantity, price float64) error {
+	if c.minProfitToClose == 0 {
+		return nil
+	}
+
+	asset, _, err := c.exchange.Position(pair)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
 
-	quantity := 0.0
-	avgPriceLong := 0.0
-	avgPriceShort := 0.0
+	// only orders closing a long or short position are filtered
+	if !(side == model.SideTypeSell && asset > 0) && !(side == model.SideTypeBuy && asset < 0) {
+		return nil
+	}
 
-	for _, order := range orders {
-		// skip current order
-		if o.ID == order.ID {
-			continue
+	_, profit, err := c.calculateProfit(&model.Order{
+		Pair:      pair,
+		Side:      side,
+		Type:      model.OrderTypeMarket,
+		Price:     price,
+		Quantity:  quantity,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if profit < c.minProfitToClose {
+		return &MinProfitError{
+			Pair:      pair,
+			Profit:    profit,
+			MinProfit: c.minProfitToClose,
+		}
+	}
+
+	return nil
+}
+
+// checkShort returns an error if shorting is disabled and a sell order exceeds the asset position
+func (c *Controller) checkShort(side model.SideType, pair string, quantity float64) error {
+	if !c.noShort || side != model.SideTypeSell {
+		return nil
+	}
+
+	asset, _, err := c.exchange.Position(pair)
+	if err != nil {
+		return err
+	}
+
+	if quantity > asset {
+		return &exchange.OrderError{
+			Err:      exchange.ErrShortingNotAllowed,
+			Pair:     pair,
+			Quantity: quantity,
+		}
+	}
+
+	return nil
+}
+
+// clampSize returns the quantity of an order limited to the free balance, if SetOrderSizeClamp is enabled.
+// The price is the limit of the order, or zero for market orders. The controller lock must be held.
+func (c *Controller) clampSize(side model.SideType, pair string, size, price float64) (float64, error) {
+	// sells above the asset open a short position, instead of exceeding the balance
+	if !c.sizeClamp || (side == model.SideTypeSell && !c.noShort) {
+		return size, nil
+	}
+
+	if price == 0 {
+		var err error
+		if price, err = c.marketPrice(pair); err != nil {
+			return 0, err
 		}
+	}
+
+	account, err := c.exchange.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	asset, quote := account.Balance(assetTick, quoteTick)
+	available := asset.Free
+	if side == model.SideTypeBuy {
+		available = quote.Free / price
+	}
+
+	if size <= available {
+		return size, nil
+	}
+
+	quantity, err := exchange.PercentQuantity(c.exchange.AssetsInfo(pair), side, asset.Free, quote.Free, price, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Warnf("[ORDER] %s %s quantity clamped from %f to %f by the free balance", side, pair, size, quantity)
+	return quantity, nil
+}
+
+// clampQuote limits the quote amount of a buy order to the free quote balance, see SetOrderSizeClamp
+func (c *Controller) clampQuote(pair string, amount float64) (float64, error) {
+	account, err := c.exchange.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	_, quote := account.Balance(exchange.SplitAssetQuote(pair))
+	if amount <= quote.Free {
+		return amount, nil
+	}
+
+	log.Warnf("[ORDER] BUY %s amount clamped from %f to %f by the free balance", pair, amount, quote.Free)
+	return quote.Free, nil
+}
+
+func (c *Controller) OnCandle(candle model.Candle) {
+	c.mtx.Lock()
+	c.lastPrice[candle.Pair] = candle.Close
+	resume := c.rollProfitTarget(candle)
+	c.unlock()
 
+	if resume {
+		c.Start()
+	}
+
+	for _, account := range c.accounts {
+		account.OnCandle(candle)
+	}
+}
+
+// replayPosition returns the position resulting from a sequence of filled orders and the average entry price
+// of its long and short sides, the quantity is negative for short positions
+func replayPosition(orders []*model.Order) (quantity, avgPriceLong, avgPriceShort float64) {
+	for _, order := range orders {
 		// calculate avg price
 		price := order.Price
 		if order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit {
@@ -189,21 +574,50 @@ func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, er
 		} else {
 			quantity -= order.Quantity
 		}
+	}
 
+	return quantity, avgPriceLong, avgPriceShort
+}
+
+func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, err error) {
+	// get filled orders before the current order
+	orders, err := c.storage.Orders(
+		storage.WithAccount(c.account),
+		storage.WithUpdateAtBeforeOrEqual(o.UpdatedAt),
+		storage.WithStatus(model.OrderStatusTypeFilled),
+		storage.WithPair(o.Pair),
+	)
+	if err != nil {
+		return 0, 0, err
 	}
 
+	// skip current order
+	previous := make([]*model.Order, 0, len(orders))
+	for _, order := range orders {
+		if o.ID != order.ID {
+			previous = append(previous, order)
+		}
+	}
+
+	quantity, avgPriceLong, avgPriceShort := replayPosition(previous)
 	if quantity == 0 {
 		return 0, 0, nil
 	}
 
+	// in futures, only the quantity that reduces the position is realized
+	closedQuantity := o.Quantity
+	if c.marketType == model.MarketTypeFutures {
+		closedQuantity = math.Min(o.Quantity, math.Abs(quantity))
+	}
+
 	if o.Side == model.SideTypeBuy && quantity < 0 {
 		// profit short
 		price := o.Price
 		if o.Type == model.OrderTypeStopLoss || o.Type == model.OrderTypeStopLossLimit {
 			price = *o.Stop
 		}
-		profitValue := (avgPriceShort - price) * o.Quantity
-		return profitValue, profitValue / o.Quantity / avgPriceShort, nil
+		value, percent = c.positionProfit(avgPriceShort, price, -closedQuantity)
+		return value, percent, nil
 	}
 
 	if o.Side == model.SideTypeSell && quantity > 0 {
@@ -212,8 +626,8 @@ func (c *Controller) calculateProfit(o *model.Order) (value, percent float64, er
 		if o.Type == model.OrderTypeStopLoss || o.Type == model.OrderTypeStopLossLimit {
 			price = *o.Stop
 		}
-		profitValue := (price - avgPriceLong) * o.Quantity
-		return profitValue, profitValue / o.Quantity / avgPriceLong, nil
+		value, percent = c.positionProfit(avgPriceLong, price, closedQuantity)
+		return value, percent, nil
 	}
 
 	return 0, 0, nil
@@ -240,11 +654,25 @@ func (c *Controller) processTrade(order *model.Order) {
 
 	// initializer results map if needed
 	if _, ok := c.Results[order.Pair]; !ok {
-		c.Results[order.Pair] = &summary{Pair: order.Pair}
+		c.Results[order.Pair] = &summary{
+			Pair:      order.Pair,
+			Currency:  c.reportCurrency,
+			precision: c.summaryPrecision(order.Pair),
+			format:    c.format,
+		}
+	}
+
+	rate, err := c.reportRate(order.Pair)
+	if err != nil {
+		c.notifyError(err)
 	}
 
 	// register order volume
 	c.Results[order.Pair].Volume += order.Price * order.Quantity
+	c.Results[order.Pair].ReportVolume += order.Price * order.Quantity * rate
+	if order.Tag != "" {
+		c.Results[order.Pair].tag(order.Tag).Volume += order.Price * order.Quantity
+	}
 
 	profitValue, profit, err := c.calculateProfit(order)
 	if err != nil {
@@ -252,89 +680,218 @@ func (c *Controller) processTrade(order *model.Order) {
 		return
 	}
 
+	// profits below the precision are float noise of a break-even trade, neither a win nor a loss
 	order.Profit = profit
+	profitValue = c.Results[order.Pair].round(profitValue)
 	if profitValue == 0 {
 		return
-	} else if profitValue > 0 {
-		if order.Side == model.SideTypeBuy {
-			c.Results[order.Pair].WinLong = append(c.Results[order.Pair].WinLong, profitValue)
-		} else {
-			c.Results[order.Pair].WinShort = append(c.Results[order.Pair].WinShort, profitValue)
-		}
+	}
+
+	if c.reportCurrency != "" {
+		c.addTargetProfit(profitValue * rate)
 	} else {
-		if order.Side == model.SideTypeBuy {
-			c.Results[order.Pair].LoseLong = append(c.Results[order.Pair].LoseLong, profitValue)
-		} else {
-			c.Results[order.Pair].LoseShort = append(c.Results[order.Pair].LoseShort, profitValue)
-		}
+		c.addTargetProfit(profitValue)
+	}
+
+	c.closedTrades++
+	if c.closedTrades <= c.statsWarmup {
+		c.Results[order.Pair].Warmup = append(c.Results[order.Pair].Warmup, profitValue)
+		log.Infof("[WARMUP] trade %d/%d of %s ignored in statistics", c.closedTrades, c.statsWarmup, order.Pair)
+		return
+	}
+
+	c.Results[order.Pair].add(order.Side, profitValue)
+	c.Results[order.Pair].ReportProfit += profitValue * rate
+	if order.Tag != "" {
+		c.Results[order.Pair].tag(order.Tag).add(order.Side, profitValue)
 	}
 
 	_, quote := exchange.SplitAssetQuote(order.Pair)
-	c.notify(fmt.Sprintf("[PROFIT] %f %s (%f %%)\n`%s`", profitValue, quote, profit*100, c.Results[order.Pair].String()))
+	format := c.DisplayFormat()
+	c.notify(fmt.Sprintf("[PROFIT] %s (%s)\n`%s`", format.FormatValue(profitValue, 6, quote),
+		format.FormatPercent(profit*100, 6), c.Results[order.Pair].String()))
+}
+
+// accumulateFills keeps the price of an order updated across several cycles as the weighted average price of
+// its fills. The update reports the cumulative executed quantity, and the cumulative cost or the price of the
+// last fill if the exchange does not report the cost.
+func accumulateFills(stored model.Order, update *model.Order) {
+	if update.ExecutedQuantity <= 0 {
+		return
+	}
+
+	quantity := update.ExecutedQuantity - stored.ExecutedQuantity
+	price := update.Price
+	if quantity > 0 && update.ExecutedCost > stored.ExecutedCost {
+		price = (update.ExecutedCost - stored.ExecutedCost) / quantity
+	}
+
+	update.ExecutedQuantity = stored.ExecutedQuantity
+	update.ExecutedCost = stored.ExecutedCost
+	if quantity <= 0 {
+		update.Price = stored.Price
+		return
+	}
+	update.Fill(quantity, price)
+}
+
+// finalStatus returns true if the order is no longer pending in the exchange
+func finalStatus(status model.OrderStatusType) bool {
+	switch status {
+	case model.OrderStatusTypeFilled, model.OrderStatusTypeCanceled,
+		model.OrderStatusTypeRejected, model.OrderStatusTypeExpired:
+		return true
+	}
+	return false
+}
+
+// publish queues the order to the feed, it is published when the controller lock is released, so a slow
+// subscriber does not block the controller. The controller lock must be held.
+func (c *Controller) publish(order model.Order, newOrder bool) {
+	c.published = append(c.published, publication{order: order, newOrder: newOrder})
+}
+
+// unlock releases the controller lock and publishes the queued orders in the sequence of creation
+func (c *Controller) unlock() {
+	published := c.published
+	c.published = nil
+	if len(published) == 0 {
+		c.mtx.Unlock()
+		return
+	}
+
+	// acquired before releasing the controller lock, so the orders of concurrent calls are not interleaved
+	c.publishMtx.Lock()
+	defer c.publishMtx.Unlock()
+	c.mtx.Unlock()
+
+	for _, publication := range published {
+		c.orderFeed.Publish(publication.order, publication.newOrder)
+	}
 }
 
 func (c *Controller) updateOrders() {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
+
+	if c.probeMaintenance() {
+		return
+	}
 
 	// pending orders
-	orders, err := c.storage.Orders(storage.WithStatusIn(
+	orders, err := c.storage.Orders(storage.WithAccount(c.account), storage.WithStatusIn(
 		model.OrderStatusTypeNew,
 		model.OrderStatusTypePartiallyFilled,
 		model.OrderStatusTypePendingCancel,
 	))
 	if err != nil {
 		c.notifyError(err)
-		c.mtx.Unlock()
 		return
 	}
 
 	// For each pending order, check for updates
-	var updatedOrders []model.Order
+	var updatedOrders, openOrders []model.Order
 	for _, order := range orders {
 		excOrder, err := c.exchange.Order(order.Pair, order.ExchangeID)
 		if err != nil {
+			if c.enterMaintenance(err) {
+				break
+			}
 			log.WithField("id", order.ExchangeID).Error("orderControler/get: ", err)
 			continue
 		}
 
-		// no status change
-		if excOrder.Status == order.Status {
+		// no status change or new fill
+		if excOrder.Status == order.Status && excOrder.ExecutedQuantity <= order.ExecutedQuantity {
+			openOrders = append(openOrders, *order)
 			continue
 		}
 
+		accumulateFills(*order, &excOrder)
 		excOrder.ID = order.ID
+		excOrder.Tag = order.Tag
+		excOrder.Account = order.Account
 		err = c.storage.UpdateOrder(&excOrder)
 		if err != nil {
 			c.notifyError(err)
 			continue
 		}
 
+		if !finalStatus(excOrder.Status) {
+			openOrders = append(openOrders, excOrder)
+		}
+
+		// intermediate updates are stored, but not published
+		if !finalStatus(excOrder.Status) &&
+			(!c.partialUpdates || excOrder.Status != model.OrderStatusTypePartiallyFilled) {
+			continue
+		}
+
 		log.Infof("[ORDER %s] %s", excOrder.Status, excOrder)
 		updatedOrders = append(updatedOrders, excOrder)
 	}
 
+	// process in the sequence of execution, orders filled in the same candle are sorted by creation
+	sort.SliceStable(updatedOrders, func(i, j int) bool {
+		if updatedOrders[i].UpdatedAt.Equal(updatedOrders[j].UpdatedAt) {
+			return updatedOrders[i].ExchangeID < updatedOrders[j].ExchangeID
+		}
+		return updatedOrders[i].UpdatedAt.Before(updatedOrders[j].UpdatedAt)
+	})
+
 	for _, processOrder := range updatedOrders {
 		c.processTrade(&processOrder)
-		c.orderFeed.Publish(processOrder, false)
+		c.publish(processOrder, false)
+		c.processBrackets(processOrder)
 	}
+
+	c.cancelStaleOrders(openOrders)
 }
 
 func (c *Controller) Status() Status {
+	c.mtx.Lock()
+	defer c.unlock()
 	return c.status
 }
 
+// Reconcile synchronously updates the status of pending orders, it is used in backtest after each candle
+func (c *Controller) Reconcile() {
+	c.updateOrders()
+	for _, account := range c.accounts {
+		account.Reconcile()
+	}
+	c.stopOnProfitTarget()
+}
+
 func (c *Controller) Start() {
-	if c.status != StatusRunning {
-		c.status = StatusRunning
+	for _, account := range c.accounts {
+		account.Start()
+	}
+
+	c.mtx.Lock()
+	started := c.status != StatusRunning
+	c.status = StatusRunning
+	c.unlock()
+
+	if started {
+		if c.backtest {
+			log.Info("Bot started in backtest mode.")
+			return
+		}
+
 		go func() {
 			ticker := time.NewTicker(c.tickerInterval)
+			equityTicker, stopEquity := c.equityTicker()
 			for {
 				select {
 				case <-ticker.C:
 					c.updateOrders()
+					c.stopOnProfitTarget()
+				case <-equityTicker:
+					c.sampleEquity()
 				case <-c.finish:
 					ticker.Stop()
+					stopEquity()
 					return
 				}
 			}
@@ -344,10 +901,23 @@ func (c *Controller) Start() {
 }
 
 func (c *Controller) Stop() {
-	if c.status == StatusRunning {
+	for _, account := range c.accounts {
+		account.Stop()
+	}
+
+	c.mtx.Lock()
+	stopped := c.status == StatusRunning
+	if stopped {
 		c.status = StatusStopped
+	}
+	c.unlock()
+
+	if stopped {
 		c.updateOrders()
-		c.finish <- true
+		if !c.backtest {
+			// buffered, the ticker itself can stop the controller, see SetProfitTarget
+			c.finish <- true
+		}
 		log.Info("Bot stopped.")
 	}
 }
@@ -356,22 +926,168 @@ func (c *Controller) Account() (model.Account, error) {
 	return c.exchange.Account()
 }
 
+// Position returns the asset and quote of a pair, the dust registered by SweepDust is not included
 func (c *Controller) Position(pair string) (asset, quote float64, err error) {
-	return c.exchange.Position(pair)
+	asset, quote, err = c.exchange.Position(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+	return c.withoutDust(pair, asset), quote, nil
+}
+
+// withoutDust discounts the dust of a pair from the asset, if the dust is still part of the position
+func (c *Controller) withoutDust(pair string, asset float64) float64 {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	dust := c.dust[pair]
+	if dust == 0 || (asset > 0) != (dust > 0) || math.Abs(asset) < math.Abs(dust) {
+		return asset
+	}
+	return asset - dust
+}
+
+// tradable checks if a quantity reaches the minimum quantity and notional of the exchange
+func (c *Controller) tradable(pair string, quantity, price float64) bool {
+	info := c.exchange.AssetsInfo(pair)
+	return quantity >= info.StepSize && quantity >= info.MinQuantity && quantity*price >= info.MinNotional
+}
+
+// SweepDust cleans the residual position of a pair. If the position reaches the minimum quantity and notional
+// of the exchange, it is closed with a market order. Otherwise, it is registered as dust and ignored in the
+// position and equity values reported by the controller. The swept amounts are notified.
+func (c *Controller) SweepDust(pair string) error {
+	asset, _, err := c.exchange.Position(pair)
+	if err != nil {
+		return err
+	}
+
+	if asset == 0 {
+		return nil
+	}
+
+	price, err := c.marketPrice(pair)
+	if err != nil {
+		return err
+	}
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	if c.tradable(pair, math.Abs(asset), price) {
+		side := model.SideTypeSell
+		if asset < 0 {
+			side = model.SideTypeBuy
+		}
+
+		order, err := c.CreateOrderMarket(side, pair, math.Abs(asset))
+		if err != nil {
+			return err
+		}
+
+		format := c.DisplayFormat()
+		c.notify(fmt.Sprintf("[DUST] %s closed with a market order: %s (~%s)", pair,
+			format.FormatValue(order.Quantity, 8, assetTick), format.FormatValue(order.Quantity*order.Price, 4, quoteTick)))
+
+		// the order quantity is rounded to the step size, so a remainder may not be traded
+		asset, _, err = c.exchange.Position(pair)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.unlock()
+
+	if asset == 0 {
+		delete(c.dust, pair)
+		return nil
+	}
+
+	c.dust[pair] = asset
+	format := c.DisplayFormat()
+	c.notify(fmt.Sprintf("[DUST] %s ignored in position: %s (~%s)", pair,
+		format.FormatValue(asset, 8, assetTick), format.FormatValue(asset*price, 4, quoteTick)))
+	return nil
 }
 
 func (c *Controller) LastQuote(pair string) (float64, error) {
 	return c.exchange.LastQuote(c.ctx, pair)
 }
 
+// LastPrice returns the close of the last candle of the pair, or the exchange quote if no candle was received.
+// Unlike LastQuote, it is available in backtests.
+func (c *Controller) LastPrice(pair string) (float64, error) {
+	return c.marketPrice(pair)
+}
+
 func (c *Controller) PositionValue(pair string) (float64, error) {
-	asset, _, err := c.exchange.Position(pair)
+	asset, _, err := c.Position(pair)
 	if err != nil {
 		return 0, err
 	}
 	return asset * c.lastPrice[pair], nil
 }
 
+// BreakEvenPrice returns the price at which the position of the pair breaks even after the fees of the entry
+// and the exit, from the average entry price of the stored orders and the fee rate, see SetFeeRate. Without
+// fee rate, the price is calculated by the exchange if it implements service.BreakEven, e.g. the paper wallet.
+// It returns exchange.ErrNoPosition without position.
+func (c *Controller) BreakEvenPrice(pair string) (float64, error) {
+	if breakEven, ok := c.exchange.(service.BreakEven); ok && c.feeRate == 0 {
+		return breakEven.BreakEvenPrice(pair)
+	}
+
+	orders, err := c.storage.Orders(
+		storage.WithAccount(c.account),
+		storage.WithStatus(model.OrderStatusTypeFilled),
+		storage.WithPair(pair),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	quantity, avgPriceLong, avgPriceShort := replayPosition(orders)
+	if quantity < 0 {
+		return exchange.BreakEvenPrice(avgPriceShort, quantity, c.feeRate)
+	}
+	return exchange.BreakEvenPrice(avgPriceLong, quantity, c.feeRate)
+}
+
+// SimulateOrder estimates the effect of a market order without submitting it. The order is filled by the
+// paper wallet model with the current quote of the exchange, against the real account balances.
+func (c *Controller) SimulateOrder(side model.SideType, pair string, size float64) (SimulationResult, error) {
+	price, err := c.exchange.LastQuote(c.ctx, pair)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	account, err := c.exchange.Account()
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	order, wallet, err := exchange.SimulateOrderMarket(c.ctx, account, side, pair, size, price)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	asset, quote, err := wallet.Position(pair)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	_, quoteBalance := account.Balance(exchange.SplitAssetQuote(pair))
+	return SimulationResult{
+		Pair:        pair,
+		Side:        side,
+		Size:        order.Quantity,
+		Price:       order.Price,
+		Value:       order.Price * order.Quantity,
+		Asset:       asset,
+		Quote:       quote,
+		QuoteChange: quote - (quoteBalance.Free + quoteBalance.Lock),
+	}, nil
+}
+
 func (c *Controller) Order(pair string, id int64) (model.Order, error) {
 	return c.exchange.Order(pair, id)
 }
@@ -379,121 +1095,369 @@ func (c *Controller) Order(pair string, id int64) (model.Order, error) {
 func (c *Controller) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
 	stopLimit float64) ([]model.Order, error) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
+
+	return c.createOrderOCO(side, pair, size, price, stop, stopLimit)
+}
 
+// createOrderOCO creates an OCO order, the controller lock must be held
+func (c *Controller) createOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
 	log.Infof("[ORDER] Creating OCO order for %s", pair)
+	if err := c.checkHalted(pair); err != nil {
+		c.skipOrder(side, pair, err)
+		return nil, err
+	}
+
+	if err := c.checkShort(side, pair, size); err != nil {
+		c.rejectOrder(side, pair, err)
+		return nil, err
+	}
+
 	orders, err := c.exchange.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return nil, err
 	}
 
 	for i := range orders {
+		orders[i].Account = c.account
 		err := c.storage.CreateOrder(&orders[i])
 		if err != nil {
-			c.notifyError(err)
+			c.rejectOrder(side, pair, err)
 			return nil, err
 		}
-		go c.orderFeed.Publish(orders[i], true)
+		c.publish(orders[i], true)
 	}
 
+	c.acceptOrder(pair)
 	return orders, nil
 }
 
 func (c *Controller) CreateOrderLimit(side model.SideType, pair string, size, limit float64) (model.Order, error) {
+	return c.createOrderLimit(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF creates a limit order with the given time in force. IOC and FOK orders are
+// resolved by the exchange at creation, so they are returned filled, canceled or rejected.
+func (c *Controller) CreateOrderLimitTIF(side model.SideType, pair string, size, limit float64,
+	tif model.TimeInForceType) (model.Order, error) {
+	return c.createOrderLimit(side, pair, size, limit, tif)
+}
+
+// CreateOrderLimitPegged creates a limit order pegged to the best bid, for buy orders, or to the best ask,
+// for sell orders, adjusted by an offset in basis points. A positive offset improves the price towards the
+// spread, e.g. 5 bps above the best bid, and a negative offset moves it away from the spread. Offsets larger
+// than the spread cross the book. The paper wallet pegs to the last candle with its simulated spread, see
+// `exchange.PaperWallet.BestBidAsk`, and exchanges without an order book peg to the last price, see
+// service.OrderBook.
+func (c *Controller) CreateOrderLimitPegged(side model.SideType, pair string, size,
+	offsetBps float64) (model.Order, error) {
+	bid, ask, err := c.bestBidAsk(pair)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	offset := offsetBps / 10000
+	limit := bid * (1 + offset)
+	if side == model.SideTypeSell {
+		limit = ask * (1 - offset)
+	}
+
+	log.Infof("[ORDER] Pegging LIMIT %s order for %s at %f (bid=%f ask=%f offset=%.2fbps)",
+		side, pair, limit, bid, ask, offsetBps)
+	return c.createOrderLimit(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// bestBidAsk returns the best prices of the order book, or the last price for both
+func (c *Controller) bestBidAsk(pair string) (bid, ask float64, err error) {
+	if book, ok := c.exchange.(service.OrderBook); ok {
+		return book.BestBidAsk(c.ctx, pair)
+	}
+
+	price, err := c.marketPrice(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+	return price, price, nil
+}
+
+func (c *Controller) createOrderLimit(side model.SideType, pair string, size, limit float64,
+	tif model.TimeInForceType) (model.Order, error) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
 
 	log.Infof("[ORDER] Creating LIMIT %s order for %s", side, pair)
-	order, err := c.exchange.CreateOrderLimit(side, pair, size, limit)
+	if err := c.checkHalted(pair); err != nil {
+		c.skipOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	size, err := c.clampSize(side, pair, size, limit)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	if err := c.checkShort(side, pair, size); err != nil {
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
+	if err := c.checkMinProfit(side, pair, size, limit); err != nil {
+		c.skipOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	var order model.Order
+	if tif == model.TimeInForceGTC {
+		order, err = c.exchange.CreateOrderLimit(side, pair, size, limit)
+	} else {
+		order, err = c.exchange.CreateOrderLimitTIF(side, pair, size, limit, tif)
+	}
+	if err != nil {
+		c.rejectOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	order.Account = c.account
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
-	go c.orderFeed.Publish(order, true)
+
+	// immediate orders may be filled at creation
+	c.processTrade(&order)
+	c.publish(order, true)
+	c.processBrackets(order)
+	c.acceptOrder(pair)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, nil
 }
 
+// BuyPercent creates a market order to buy with a fraction (e.g. 0.5 for 50%) of the free quote balance.
+// The quantity is rounded down to the step size and validated with the minimum notional of the pair.
+func (c *Controller) BuyPercent(pair string, percent float64) (model.Order, error) {
+	return c.createOrderPercent(model.SideTypeBuy, pair, percent)
+}
+
+// SellPercent creates a market order to sell a fraction (e.g. 0.5 for 50%) of the free asset balance,
+// the dust registered by SweepDust is not included. The quantity is rounded as in BuyPercent.
+func (c *Controller) SellPercent(pair string, percent float64) (model.Order, error) {
+	return c.createOrderPercent(model.SideTypeSell, pair, percent)
+}
+
+func (c *Controller) createOrderPercent(side model.SideType, pair string, percent float64) (model.Order, error) {
+	account, err := c.exchange.Account()
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	price, err := c.marketPrice(pair)
+	if err != nil {
+		c.notifyError(err)
+		c.reportSkip(side, pair, err)
+		return model.Order{}, err
+	}
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	asset, quote := account.Balance(assetTick, quoteTick)
+	quantity, err := exchange.PercentQuantity(c.exchange.AssetsInfo(pair), side, c.withoutDust(pair, asset.Free),
+		quote.Free, price, percent)
+	if err != nil {
+		c.notifyError(err)
+		c.reportSkip(side, pair, err)
+		return model.Order{}, err
+	}
+
+	return c.CreateOrderMarket(side, pair, quantity)
+}
+
 func (c *Controller) CreateOrderMarketQuote(side model.SideType, pair string, amount float64) (model.Order, error) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
 
 	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	if err := c.checkHalted(pair); err != nil {
+		c.skipOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	if c.sizeClamp && side == model.SideTypeBuy {
+		var err error
+		if amount, err = c.clampQuote(pair, amount); err != nil {
+			c.rejectOrder(side, pair, err)
+			return model.Order{}, err
+		}
+	}
+
+	if c.minProfitToClose > 0 || c.noShort {
+		price, err := c.marketPrice(pair)
+		if err != nil {
+			c.rejectOrder(side, pair, err)
+			return model.Order{}, err
+		}
+
+		if err := c.checkShort(side, pair, amount/price); err != nil {
+			c.rejectOrder(side, pair, err)
+			return model.Order{}, err
+		}
+
+		if err := c.checkMinProfit(side, pair, amount/price, price); err != nil {
+			c.skipOrder(side, pair, err)
+			return model.Order{}, err
+		}
+	}
+
 	order, err := c.exchange.CreateOrderMarketQuote(side, pair, amount)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
+	order.Account = c.account
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
 	// calculate profit
 	c.processTrade(&order)
-	go c.orderFeed.Publish(order, true)
+	c.publish(order, true)
+	c.processBrackets(order)
+	c.acceptOrder(pair)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, err
 }
 
 func (c *Controller) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return c.createOrderMarket(side, pair, size, "")
+}
+
+// CreateOrderMarketTagged creates a market order annotated with a tag, e.g. the signal that triggered it.
+// The tag is persisted with the order and the results are also grouped by tag.
+func (c *Controller) CreateOrderMarketTagged(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
+	return c.createOrderMarket(side, pair, size, tag)
+}
+
+func (c *Controller) createOrderMarket(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
 
+	return c.placeOrderMarket(side, pair, size, tag)
+}
+
+// placeOrderMarket creates a market order, the controller lock must be held
+func (c *Controller) placeOrderMarket(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
 	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
-	order, err := c.exchange.CreateOrderMarket(side, pair, size)
+	if err := c.checkHalted(pair); err != nil {
+		c.skipOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	size, err := c.clampSize(side, pair, size, 0)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
+	if err := c.checkShort(side, pair, size); err != nil {
+		c.rejectOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	if c.minProfitToClose > 0 {
+		price, err := c.marketPrice(pair)
+		if err != nil {
+			c.rejectOrder(side, pair, err)
+			return model.Order{}, err
+		}
+
+		if err := c.checkMinProfit(side, pair, size, price); err != nil {
+			c.skipOrder(side, pair, err)
+			return model.Order{}, err
+		}
+	}
+
+	var order model.Order
+	if tag == "" {
+		order, err = c.exchange.CreateOrderMarket(side, pair, size)
+	} else {
+		order, err = c.exchange.CreateOrderMarketTagged(side, pair, size, tag)
+	}
+	if err != nil {
+		c.rejectOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	order.Account = c.account
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
 	// calculate profit
 	c.processTrade(&order)
-	go c.orderFeed.Publish(order, true)
+	c.publish(order, true)
+	c.processBrackets(order)
+	c.acceptOrder(pair)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, err
 }
 
-func (c *Controller) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
+// CreateOrderStop creates a stop-limit order, triggered when the price reaches the limit. Sell stops protect
+// a position below the market and buy stops enter on a breakout above the market.
+func (c *Controller) CreateOrderStop(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
 
-	log.Infof("[ORDER] Creating STOP order for %s", pair)
-	order, err := c.exchange.CreateOrderStop(pair, size, limit)
+	log.Infof("[ORDER] Creating STOP %s order for %s", side, pair)
+	if err := c.checkHalted(pair); err != nil {
+		c.skipOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	if err := c.checkShort(side, pair, size); err != nil {
+		c.rejectOrder(side, pair, err)
+		return model.Order{}, err
+	}
+
+	order, err := c.exchange.CreateOrderStop(side, pair, size, limit)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
 
+	order.Account = c.account
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
-		c.notifyError(err)
+		c.rejectOrder(side, pair, err)
 		return model.Order{}, err
 	}
-	go c.orderFeed.Publish(order, true)
+	c.publish(order, true)
+	c.acceptOrder(pair)
 	log.Infof("[ORDER CREATED] %s", order)
 	return order, nil
 }
 
 func (c *Controller) Cancel(order model.Order) error {
 	c.mtx.Lock()
-	defer c.mtx.Unlock()
+	defer c.unlock()
+
+	return c.cancel(order)
+}
 
+// cancel cancels an order, the controller lock must be held
+func (c *Controller) cancel(order model.Order) error {
 	log.Infof("[ORDER] Cancelling order for %s", order.Pair)
 	err := c.exchange.Cancel(order)
 	if err != nil {
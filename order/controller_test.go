@@ -1,14 +1,24 @@
 package order
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	exchangemock "github.com/rodrigo-brito/ninjabot/exchange/mock"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -135,6 +145,45 @@ func TestController_calculateProfit(t *testing.T) {
 		assert.Equal(t, -0.5, profit)
 	})
 
+	t.Run("market type", func(t *testing.T) {
+		tt := []struct {
+			marketType   model.MarketType
+			contractSize float64
+			value        float64
+			profit       float64
+		}{
+			{marketType: model.MarketTypeSpot, contractSize: 0.1, value: 1000, profit: 0.5},
+			// only 1 BTC of the position is closed, the other one opens a short position
+			{marketType: model.MarketTypeFutures, contractSize: 0.1, value: 50, profit: 0.5},
+			{marketType: model.MarketTypeFutures, contractSize: 1, value: 500, profit: 0.5},
+		}
+
+		for _, tc := range tt {
+			t.Run(fmt.Sprintf("%s %.1f", tc.marketType, tc.contractSize), func(t *testing.T) {
+				storage, err := storage.FromMemory()
+				require.NoError(t, err)
+				ctx := context.Background()
+				wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+					exchange.WithPaperMarketType(tc.marketType, tc.contractSize))
+				controller := NewController(ctx, wallet, storage, NewOrderFeed())
+				controller.SetMarketType(tc.marketType, tc.contractSize)
+
+				wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+				_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+				require.NoError(t, err)
+
+				wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1500})
+				sellOrder, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+				require.NoError(t, err)
+
+				value, profit, err := controller.calculateProfit(&sellOrder)
+				require.NoError(t, err)
+				assert.InDelta(t, tc.value, value, 1e-9)
+				assert.InDelta(t, tc.profit, profit, 1e-9)
+			})
+		}
+	})
+
 	t.Run("no buy information", func(t *testing.T) {
 		storage, err := storage.FromMemory()
 		require.NoError(t, err)
@@ -196,3 +245,1711 @@ func TestController_Position(t *testing.T) {
 	assert.Equal(t, 1.0, asset)
 	assert.Equal(t, 1500.0, quote)
 }
+
+func TestController_BreakEvenPrice(t *testing.T) {
+	setup := func(t *testing.T) *Controller {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+			exchange.WithPaperFee(0.001, 0.001))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+		lastCandle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1500, Low: 1500, High: 1500}
+		wallet.OnCandle(lastCandle)
+		controller.OnCandle(lastCandle)
+		return controller
+	}
+
+	t.Run("without position", func(t *testing.T) {
+		controller := setup(t)
+		_, err := controller.BreakEvenPrice("BTCUSDT")
+		require.ErrorIs(t, err, exchange.ErrNoPosition)
+
+		controller.SetFeeRate(0.002)
+		_, err = controller.BreakEvenPrice("BTCUSDT")
+		require.ErrorIs(t, err, exchange.ErrNoPosition)
+	})
+
+	t.Run("long", func(t *testing.T) {
+		controller := setup(t)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1.0)
+		require.NoError(t, err)
+
+		// fee of the paper wallet
+		price, err := controller.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		assert.InDelta(t, 1500*1.001/0.999, price, 1e-9)
+
+		// stored fee rate
+		controller.SetFeeRate(0.002)
+		price, err = controller.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		assert.InDelta(t, 1500*1.002/0.998, price, 1e-9)
+		assert.Greater(t, price, 1500.0)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		controller := setup(t)
+		_, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1.0)
+		require.NoError(t, err)
+
+		price, err := controller.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		assert.InDelta(t, 1500*0.999/1.001, price, 1e-9)
+
+		controller.SetFeeRate(0.002)
+		price, err = controller.BreakEvenPrice("BTCUSDT")
+		require.NoError(t, err)
+		assert.InDelta(t, 1500*0.998/1.002, price, 1e-9)
+		assert.Less(t, price, 1500.0)
+	})
+}
+
+func TestController_MinProfitToClose(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	controller.SetMinProfitToClose(0.01)
+
+	candle := model.Candle{Pair: "BTCUSDT", Close: 1000, High: 1000}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// open position is not filtered
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	t.Run("blocked", func(t *testing.T) {
+		candle := model.Candle{Pair: "BTCUSDT", Close: 1005, High: 1005}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		_, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		var minProfitErr *MinProfitError
+		require.ErrorAs(t, err, &minProfitErr)
+		require.Equal(t, "BTCUSDT", minProfitErr.Pair)
+		require.InDelta(t, 0.005, minProfitErr.Profit, 0.0001)
+
+		_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 1009)
+		require.ErrorAs(t, err, &minProfitErr)
+
+		// position is kept
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		order, err := controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 1010)
+		require.NoError(t, err)
+		require.NoError(t, wallet.Cancel(order))
+
+		candle := model.Candle{Pair: "BTCUSDT", Close: 1020, High: 1020}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+	})
+}
+
+func TestController_Reconcile(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+
+	feed := NewOrderFeed()
+	filled := make(chan model.Order, 20)
+	feed.Subscribe("BTCUSDT", func(order model.Order) {
+		if order.Status == model.OrderStatusTypeFilled {
+			filled <- order
+		}
+	}, false)
+	feed.Start()
+
+	controller := NewController(ctx, wallet, orderStorage, feed)
+	controller.SetBacktest(true)
+	controller.Start()
+	defer controller.Stop()
+
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 200})
+
+	var created []model.Order
+	for i := 0; i < 12; i++ {
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, float64(100+i))
+		require.NoError(t, err)
+		created = append(created, order)
+	}
+
+	// all orders filled in the same candle
+	wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 50})
+	controller.Reconcile()
+
+	for _, order := range created {
+		fill := <-filled
+		require.Equal(t, order.ExchangeID, fill.ExchangeID)
+	}
+
+	pending, err := orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestController_SimulateOrder(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	account := model.Account{
+		Balances: []model.Balance{
+			{Asset: "BTC", Free: 1},
+			{Asset: "USDT", Free: 1000},
+		},
+	}
+
+	exchangeMock := mocks.NewExchange(t)
+	exchangeMock.EXPECT().LastQuote(mock.Anything, "BTCUSDT").Return(500, nil)
+	exchangeMock.EXPECT().Account().Return(account, nil)
+	controller := NewController(ctx, exchangeMock, orderStorage, NewOrderFeed())
+
+	t.Run("buy", func(t *testing.T) {
+		result, err := controller.SimulateOrder(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		assert.Equal(t, 500.0, result.Price)
+		assert.Equal(t, 500.0, result.Value)
+		assert.Equal(t, 2.0, result.Asset)
+		assert.Equal(t, 500.0, result.Quote)
+		assert.Equal(t, -500.0, result.QuoteChange)
+	})
+
+	t.Run("sell", func(t *testing.T) {
+		result, err := controller.SimulateOrder(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, result.Asset)
+		assert.Equal(t, 1500.0, result.Quote)
+		assert.Equal(t, 500.0, result.QuoteChange)
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		_, err := controller.SimulateOrder(model.SideTypeBuy, "BTCUSDT", 10)
+		var orderError *exchange.OrderError
+		require.ErrorAs(t, err, &orderError)
+		require.Equal(t, exchange.ErrInsufficientFunds, orderError.Err)
+	})
+
+	// the real account is not modified
+	assert.Equal(t, 1000.0, account.Balances[1].Free)
+}
+
+func TestController_CreateOrderMarketTagged(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	order, err := controller.CreateOrderMarketTagged(model.SideTypeBuy, "BTCUSDT", 1, "EMA8>SMA21")
+	require.NoError(t, err)
+	require.Equal(t, "EMA8>SMA21", order.Tag)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1500})
+	_, err = controller.CreateOrderMarketTagged(model.SideTypeSell, "BTCUSDT", 1, "EMA8<SMA21")
+	require.NoError(t, err)
+
+	orders, err := orderStorage.Orders(storage.WithPair("BTCUSDT"))
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, "EMA8>SMA21", orders[0].Tag)
+	require.Equal(t, "EMA8<SMA21", orders[1].Tag)
+
+	results := controller.Results["BTCUSDT"]
+	require.Len(t, results.ByTag, 2)
+	require.Equal(t, 500.0, results.ByTag["EMA8<SMA21"].Profit())
+	require.Equal(t, 0.0, results.ByTag["EMA8>SMA21"].Profit())
+}
+
+func TestController_FeedSubscriber(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	feed := NewOrderFeed(WithFeedBufferSize(1))
+	controller := NewController(ctx, wallet, orderStorage, feed)
+
+	release := make(chan bool)
+	received := make(chan model.Order, 3)
+	feed.Subscribe("BTCUSDT", func(order model.Order) {
+		<-release
+		controller.Status()
+		received <- order
+	}, true)
+	feed.Start()
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	created := make(chan bool)
+	go func() {
+		defer close(created)
+		for i := 0; i < 3; i++ {
+			_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+			assert.NoError(t, err)
+		}
+	}()
+
+	// the third order waits for the subscriber, without holding the controller lock
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, controller.Status()) // not started
+	close(release)
+	<-created
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, model.SideTypeBuy, (<-received).Side)
+	}
+}
+
+func TestController_OrderPercent(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	candle := model.Candle{Pair: "BTCUSDT", Close: 1000}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	order, err := controller.BuyPercent("BTCUSDT", 0.5)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, order.Quantity)
+
+	order, err = controller.SellPercent("BTCUSDT", 0.5)
+	require.NoError(t, err)
+	require.Equal(t, 0.75, order.Quantity)
+
+	_, err = controller.BuyPercent("BTCUSDT", 2)
+	require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+
+	orders, err := orderStorage.Orders(storage.WithPair("BTCUSDT"))
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+}
+
+func TestController_NoShort(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+	controller.SetNoShort(true)
+
+	candle := model.Candle{Pair: "BTCUSDT", Close: 1000}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// sell more than the position
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+	require.ErrorIs(t, err, exchange.ErrShortingNotAllowed)
+
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeSell, "BTCUSDT", 1500)
+	require.ErrorIs(t, err, exchange.ErrShortingNotAllowed)
+
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 1100)
+	require.ErrorIs(t, err, exchange.ErrShortingNotAllowed)
+
+	// sell the full position
+	order, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+
+	asset, _, err := controller.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+}
+
+func TestController_OrderSizeClamp(t *testing.T) {
+	newController := func(t *testing.T, clamp bool) (*Controller, *exchange.PaperWallet) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+		controller.SetOrderSizeClamp(clamp)
+
+		candle := model.Candle{Pair: "BTCUSDT", Time: time.Now(), Open: 100, Close: 100, Low: 100, High: 100}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		return controller, wallet
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		controller, _ := newController(t, false)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10.01)
+		require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+	})
+
+	t.Run("balance boundary", func(t *testing.T) {
+		controller, wallet := newController(t, true)
+		order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+		require.NoError(t, err)
+		require.Equal(t, 10.0, order.Quantity)
+
+		// no balance left to clamp
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+
+		asset, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 10.0, asset)
+		require.Equal(t, 0.0, quote)
+	})
+
+	t.Run("market order above the balance", func(t *testing.T) {
+		controller, _ := newController(t, true)
+		order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10.01)
+		require.NoError(t, err)
+		require.Equal(t, 10.0, order.Quantity)
+	})
+
+	t.Run("limit order above the balance", func(t *testing.T) {
+		controller, _ := newController(t, true)
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 30, 50)
+		require.NoError(t, err)
+		require.Equal(t, 20.0, order.Quantity)
+	})
+
+	t.Run("quote order above the balance", func(t *testing.T) {
+		controller, _ := newController(t, true)
+		order, err := controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 1500)
+		require.NoError(t, err)
+		require.Equal(t, 10.0, order.Quantity)
+	})
+
+	t.Run("sell without shorting", func(t *testing.T) {
+		controller, _ := newController(t, true)
+		controller.SetNoShort(true)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+		require.NoError(t, err)
+
+		order, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 8)
+		require.NoError(t, err)
+		require.Equal(t, 5.0, order.Quantity)
+	})
+}
+
+func TestController_BracketOrder(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	candle := model.Candle{Pair: "BTCUSDT", Time: time.Now(), Open: 100, Close: 100, Low: 100, High: 100}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	t.Run("invalid prices", func(t *testing.T) {
+		_, err := controller.CreateBracketOrder(model.SideTypeBuy, "BTCUSDT", 1, 110, 90)
+		require.ErrorIs(t, err, ErrInvalidBracket)
+	})
+
+	t.Run("entry rejected", func(t *testing.T) {
+		_, err := controller.CreateBracketOrder(model.SideTypeBuy, "BTCUSDT", 100, 90, 110)
+		require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+		require.Empty(t, controller.brackets)
+	})
+
+	t.Run("take profit", func(t *testing.T) {
+		entry, err := controller.CreateBracketOrder(model.SideTypeBuy, "BTCUSDT", 1, 90, 110)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, entry.Status)
+		require.Len(t, controller.brackets[entry.ExchangeID].exits, 2)
+
+		candle := model.Candle{Pair: "BTCUSDT", Time: candle.Time.Add(time.Minute), Open: 100, Close: 105,
+			Low: 100, High: 111}
+		wallet.OnCandle(candle)
+		controller.Reconcile()
+
+		require.Empty(t, controller.brackets)
+		asset, _, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+	})
+
+	t.Run("position closed manually", func(t *testing.T) {
+		entry, err := controller.CreateBracketOrder(model.SideTypeBuy, "BTCUSDT", 1, 90, 110)
+		require.NoError(t, err)
+		exits := controller.brackets[entry.ExchangeID].exits
+
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Empty(t, controller.brackets)
+
+		for _, exit := range exits {
+			order, err := wallet.Order("BTCUSDT", exit.ExchangeID)
+			require.NoError(t, err)
+			require.Equal(t, model.OrderStatusTypeCanceled, order.Status)
+		}
+
+		asset, _, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+	})
+}
+
+func TestController_StatsWarmup(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+	controller.SetStatsWarmup(1)
+
+	trade := func(entry, exit float64) {
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: entry})
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: exit})
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+	}
+
+	trade(1000, 900)  // loss during warmup
+	trade(1000, 1100) // win
+
+	results := controller.Results["BTCUSDT"]
+	require.Equal(t, []float64{-100}, results.Warmup)
+	require.Empty(t, results.Lose())
+	require.Len(t, results.Win(), 1)
+	require.Equal(t, 100.0, results.WinPercentage())
+	require.Equal(t, 100.0, results.Profit())
+
+	// warmup orders are stored
+	orders, err := orderStorage.Orders(storage.WithPair("BTCUSDT"))
+	require.NoError(t, err)
+	require.Len(t, orders, 4)
+}
+
+func TestController_SweepDust(t *testing.T) {
+	t.Run("close tradable position", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		exchangeMock := mocks.NewExchange(t)
+		exchangeMock.EXPECT().Position("BTCUSDT").Return(0.0123456, 0, nil).Once()
+		exchangeMock.EXPECT().Position("BTCUSDT").Return(0.0000456, 100, nil)
+		exchangeMock.EXPECT().AssetsInfo("BTCUSDT").Return(model.AssetInfo{
+			StepSize:    0.001,
+			MinQuantity: 0.001,
+			MinNotional: 10,
+		})
+		exchangeMock.EXPECT().CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.0123456).Return(model.Order{
+			Pair:     "BTCUSDT",
+			Side:     model.SideTypeSell,
+			Type:     model.OrderTypeMarket,
+			Status:   model.OrderStatusTypeFilled,
+			Price:    1000,
+			Quantity: 0.012,
+		}, nil)
+
+		controller := NewController(ctx, exchangeMock, orderStorage, NewOrderFeed())
+		controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+		require.NoError(t, controller.SweepDust("BTCUSDT"))
+
+		// remainder of the step size is ignored
+		asset, quote, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+		require.Equal(t, 100.0, quote)
+	})
+
+	t.Run("ignore position below minimum", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		exchangeMock := mocks.NewExchange(t)
+		exchangeMock.EXPECT().Position("BTCUSDT").Return(0.005, 100, nil)
+		exchangeMock.EXPECT().AssetsInfo("BTCUSDT").Return(model.AssetInfo{
+			StepSize:    0.001,
+			MinQuantity: 0.001,
+			MinNotional: 10,
+		})
+
+		controller := NewController(ctx, exchangeMock, orderStorage, NewOrderFeed())
+		controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+		require.NoError(t, controller.SweepDust("BTCUSDT"))
+
+		value, err := controller.PositionValue("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, value)
+	})
+}
+
+func TestController_Accounts(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	shortWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("BTC", 2))
+
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+	controller.SetBacktest(true)
+	require.NoError(t, controller.AddAccount("short", shortWallet))
+	require.ErrorIs(t, controller.AddAccount("short", shortWallet), ErrDuplicateAccount)
+	require.Equal(t, []string{"", "short"}, controller.Accounts())
+
+	_, err = controller.ForAccount("unknown")
+	require.ErrorIs(t, err, ErrUnknownAccount)
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, Low: 1000, High: 1000}
+	wallet.OnCandle(candle)
+	shortWallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	short, err := controller.ForAccount("short")
+	require.NoError(t, err)
+
+	t.Run("route orders", func(t *testing.T) {
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		sell, err := short.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, "short", sell.Account)
+
+		orders, err := orderStorage.Orders(storage.WithAccount("short"))
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, model.SideTypeSell, orders[0].Side)
+
+		asset, quote, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+		require.Equal(t, 2000.0, quote)
+
+		asset, quote, err = short.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+		require.Equal(t, 1000.0, quote)
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		asset, quote, err := controller.AggregatePosition("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+		require.Equal(t, 3000.0, quote)
+
+		account, err := controller.AggregateAccount()
+		require.NoError(t, err)
+		btc, usdt := account.Balance("BTC", "USDT")
+		require.Equal(t, 2.0, btc.Free)
+		require.Equal(t, 3000.0, usdt.Free)
+
+		equity, err := short.Equity()
+		require.NoError(t, err)
+		require.Equal(t, 2000.0, equity)
+
+		equity, err = controller.AggregateEquity()
+		require.NoError(t, err)
+		require.Equal(t, 5000.0, equity)
+	})
+
+	t.Run("reconcile pending orders of all accounts", func(t *testing.T) {
+		order, err := short.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 1100)
+		require.NoError(t, err)
+
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1100, Low: 1000, High: 1200}
+		wallet.OnCandle(candle)
+		shortWallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		controller.Reconcile()
+
+		orders, err := orderStorage.Orders(storage.WithAccount("short"), storage.WithStatus(model.OrderStatusTypeFilled))
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, order.ExchangeID, orders[1].ExchangeID)
+		require.Equal(t, "short", orders[1].Account)
+	})
+}
+
+func TestController_PartialFillUpdates(t *testing.T) {
+	for _, partial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partial=%v", partial), func(t *testing.T) {
+			orderStorage, err := storage.FromMemory()
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			buy := model.Order{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy,
+				Type: model.OrderTypeMarket, Status: model.OrderStatusTypeFilled, Price: 1000, Quantity: 1}
+			sell := model.Order{ExchangeID: 2, Pair: "BTCUSDT", Side: model.SideTypeSell,
+				Type: model.OrderTypeLimit, Status: model.OrderStatusTypeNew, Price: 1100, Quantity: 1}
+			partiallyFilled, filled := sell, sell
+			partiallyFilled.Status = model.OrderStatusTypePartiallyFilled
+			filled.Status = model.OrderStatusTypeFilled
+
+			exchangeMock := mocks.NewExchange(t)
+			exchangeMock.EXPECT().AssetsInfo("BTCUSDT").Return(model.AssetInfo{QuotePrecision: 8})
+			exchangeMock.EXPECT().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1.0).Return(buy, nil)
+			exchangeMock.EXPECT().CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1.0, 1100.0).Return(sell, nil)
+			exchangeMock.EXPECT().Order("BTCUSDT", int64(2)).Return(partiallyFilled, nil).Once()
+			exchangeMock.EXPECT().Order("BTCUSDT", int64(2)).Return(filled, nil).Once()
+
+			var profits []string
+			notifier := mocks.NewNotifier(t)
+			notifier.EXPECT().Notify(mock.Anything).Run(func(message string) {
+				profits = append(profits, message)
+			})
+
+			feed := NewOrderFeed()
+			updates := make(chan model.Order, 10)
+			feed.Subscribe("BTCUSDT", func(order model.Order) {
+				updates <- order
+			}, false)
+			feed.Start()
+
+			controller := NewController(ctx, exchangeMock, orderStorage, feed)
+			controller.SetNotifier(notifier)
+			controller.SetPartialFillUpdates(partial)
+
+			_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+			require.NoError(t, err)
+			_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 1100)
+			require.NoError(t, err)
+
+			// intermediate status is stored even if not published
+			controller.Reconcile()
+			orders, err := orderStorage.Orders(storage.WithStatus(model.OrderStatusTypePartiallyFilled))
+			require.NoError(t, err)
+			require.Len(t, orders, 1)
+
+			controller.Reconcile()
+			orders, err = orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+			require.NoError(t, err)
+			require.Len(t, orders, 2)
+
+			expected := []model.OrderStatusType{
+				model.OrderStatusTypeFilled,
+				model.OrderStatusTypeNew,
+				model.OrderStatusTypeFilled,
+			}
+			if partial {
+				expected = []model.OrderStatusType{
+					model.OrderStatusTypeFilled,
+					model.OrderStatusTypeNew,
+					model.OrderStatusTypePartiallyFilled,
+					model.OrderStatusTypeFilled,
+				}
+			}
+
+			for _, status := range expected {
+				select {
+				case order := <-updates:
+					require.Equal(t, status, order.Status)
+				case <-time.After(time.Second):
+					t.Fatalf("order %s not published", status)
+				}
+			}
+
+			require.Len(t, profits, 1)
+			require.Contains(t, profits[0], "[PROFIT] 100.000000 USDT (10.000000 %)")
+			require.Equal(t, 100.0, controller.Results["BTCUSDT"].Profit())
+		})
+	}
+}
+
+type orderBookExchange struct {
+	*exchange.PaperWallet
+	bid, ask float64
+}
+
+func (o orderBookExchange) BestBidAsk(_ context.Context, _ string) (float64, float64, error) {
+	return o.bid, o.ask, nil
+}
+
+func TestController_CreateOrderLimitPegged(t *testing.T) {
+	newWallet := func() *exchange.PaperWallet {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT",
+			exchange.WithPaperAsset("USDT", 3000), exchange.WithPaperAsset("BTC", 1))
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000})
+		return wallet
+	}
+
+	t.Run("order book", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		book := orderBookExchange{PaperWallet: newWallet(), bid: 1000, ask: 1002}
+		controller := NewController(context.Background(), book, orderStorage, NewOrderFeed())
+
+		buy, err := controller.CreateOrderLimitPegged(model.SideTypeBuy, "BTCUSDT", 1, 5)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderTypeLimit, buy.Type)
+		require.InDelta(t, 1000.5, buy.Price, 1e-9)
+
+		sell, err := controller.CreateOrderLimitPegged(model.SideTypeSell, "BTCUSDT", 1, 5)
+		require.NoError(t, err)
+		require.InDelta(t, 1001.499, sell.Price, 1e-9)
+	})
+
+	t.Run("paper wallet pegs to the last close", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		controller := NewController(context.Background(), newWallet(), orderStorage, NewOrderFeed())
+
+		buy, err := controller.CreateOrderLimitPegged(model.SideTypeBuy, "BTCUSDT", 1, -10)
+		require.NoError(t, err)
+		require.InDelta(t, 999, buy.Price, 1e-9)
+		require.Equal(t, model.OrderStatusTypeNew, buy.Status)
+
+		sell, err := controller.CreateOrderLimitPegged(model.SideTypeSell, "BTCUSDT", 1, -10)
+		require.NoError(t, err)
+		require.InDelta(t, 1001, sell.Price, 1e-9)
+		require.Equal(t, model.OrderStatusTypeNew, sell.Status)
+	})
+}
+
+type orderHistoryExchange struct {
+	*exchange.PaperWallet
+	history []model.Order
+}
+
+func (o orderHistoryExchange) Orders(_ string, _ int) ([]model.Order, error) {
+	return o.history, nil
+}
+
+func TestController_ResumePositions(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("entry price from the order history", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("BTC", 1))
+		wallet.OnCandle(model.Candle{Time: start, Pair: "BTCUSDT", Close: 1000})
+
+		history := orderHistoryExchange{PaperWallet: wallet, history: []model.Order{
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled,
+				Price: 1100, Quantity: 0.5, UpdatedAt: start.Add(-time.Hour)},
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeFilled,
+				Price: 900, Quantity: 0.5, UpdatedAt: start.Add(-2 * time.Hour)},
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy, Status: model.OrderStatusTypeCanceled,
+				Price: 500, Quantity: 1, UpdatedAt: start.Add(-3 * time.Hour)},
+		}}
+		controller := NewController(context.Background(), history, orderStorage, NewOrderFeed())
+
+		require.NoError(t, controller.ResumePositions([]string{"BTCUSDT"}, start))
+		// the stored position is already resumed
+		require.NoError(t, controller.ResumePositions([]string{"BTCUSDT"}, start))
+
+		orders, err := orderStorage.Orders(storage.WithPair("BTCUSDT"))
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, ResumeTag, orders[0].Tag)
+		require.Equal(t, model.SideTypeBuy, orders[0].Side)
+		require.Equal(t, 1.0, orders[0].Quantity)
+		require.Equal(t, 1000.0, orders[0].Price)
+
+		// closing the position realizes the profit from the entry price
+		wallet.OnCandle(model.Candle{Time: start.Add(time.Hour), Pair: "BTCUSDT", Close: 1200})
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 200.0, controller.Results["BTCUSDT"].Profit())
+		require.Equal(t, 1200.0, controller.Results["BTCUSDT"].Volume)
+	})
+
+	t.Run("last price without history", func(t *testing.T) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("BTC", 1))
+		controller := NewController(context.Background(), wallet, orderStorage, NewOrderFeed())
+
+		candle := model.Candle{Time: start, Pair: "BTCUSDT", Close: 1000}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		require.NoError(t, controller.ResumePositions([]string{"BTCUSDT", "ETHUSDT"}, start))
+
+		orders, err := orderStorage.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, 1000.0, orders[0].Price)
+		require.True(t, start.Equal(orders[0].UpdatedAt))
+	})
+}
+
+func TestController_ScaleOut(t *testing.T) {
+	setup := func(t *testing.T) (*Controller, *exchange.PaperWallet, storage.Storage) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+		controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+		controller.SetBacktest(true)
+
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, Low: 1000, High: 1000}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		return controller, wallet, orderStorage
+	}
+
+	t.Run("levels filled independently", func(t *testing.T) {
+		controller, wallet, orderStorage := setup(t)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+
+		orders, err := controller.ScaleOut("BTCUSDT", []ScaleLevel{
+			{Price: 1100, Fraction: 0.25},
+			{Price: 1200, Fraction: 0.25},
+			{Price: 1300, Fraction: 0.5},
+		})
+		require.NoError(t, err)
+		require.Len(t, orders, 3)
+		for i, quantity := range []float64{0.5, 0.5, 1} {
+			require.Equal(t, model.SideTypeSell, orders[i].Side)
+			require.Equal(t, model.OrderTypeLimit, orders[i].Type)
+			require.InDelta(t, quantity, orders[i].Quantity, 1e-9)
+		}
+
+		// only the first target is reached
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1100, Low: 1000, High: 1150})
+		controller.Reconcile()
+
+		pending, err := orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+
+		asset, _, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 1.5, asset, 1e-9)
+
+		// the remaining levels
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1250, Low: 1150, High: 1350})
+		controller.Reconcile()
+
+		pending, err = orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+		require.NoError(t, err)
+		require.Empty(t, pending)
+
+		asset, _, err = controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 0.0, asset, 1e-9)
+		require.InDelta(t, 0.5*100+0.5*200+1*300, controller.Results["BTCUSDT"].Profit(), 1e-9)
+	})
+
+	t.Run("partial scale out", func(t *testing.T) {
+		controller, _, _ := setup(t)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		orders, err := controller.ScaleOut("BTCUSDT", []ScaleLevel{{Price: 1100, Fraction: 0.3}})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.InDelta(t, 0.3, orders[0].Quantity, 1e-9)
+
+		// fractions of the position at creation time
+		orders, err = controller.ScaleOut("BTCUSDT", []ScaleLevel{{Price: 1200, Fraction: 0.5}})
+		require.NoError(t, err)
+		require.InDelta(t, 0.5, orders[0].Quantity, 1e-9)
+	})
+
+	t.Run("short position", func(t *testing.T) {
+		controller, _, _ := setup(t)
+		_, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+		require.NoError(t, err)
+
+		orders, err := controller.ScaleOut("BTCUSDT", []ScaleLevel{
+			{Price: 900, Fraction: 0.5},
+			{Price: 800, Fraction: 0.5},
+		})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		for _, order := range orders {
+			require.Equal(t, model.SideTypeBuy, order.Side)
+			require.InDelta(t, 1.0, order.Quantity, 1e-9)
+		}
+	})
+
+	t.Run("invalid levels", func(t *testing.T) {
+		controller, _, _ := setup(t)
+		_, err := controller.ScaleOut("BTCUSDT", []ScaleLevel{{Price: 1100, Fraction: 0.5}})
+		require.ErrorIs(t, err, exchange.ErrNoPosition)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		_, err = controller.ScaleOut("BTCUSDT", nil)
+		require.ErrorIs(t, err, ErrInvalidScaleOut)
+
+		_, err = controller.ScaleOut("BTCUSDT", []ScaleLevel{{Price: 1100, Fraction: 0.6}, {Price: 1200, Fraction: 0.5}})
+		require.ErrorIs(t, err, ErrInvalidScaleOut)
+
+		_, err = controller.ScaleOut("BTCUSDT", []ScaleLevel{{Price: 0, Fraction: 0.5}})
+		require.ErrorIs(t, err, ErrInvalidScaleOut)
+	})
+}
+
+func TestController_ClosePosition(t *testing.T) {
+	setup := func(t *testing.T, side model.SideType) *Controller {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+		controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, Low: 1000, High: 1000}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		_, err = controller.CreateOrderMarket(side, "BTCUSDT", 2)
+		require.NoError(t, err)
+		return controller
+	}
+
+	for _, tc := range []struct {
+		name      string
+		side      model.SideType
+		closeSide model.SideType
+		fraction  float64
+		quantity  float64
+		remaining float64
+	}{
+		{"partial long", model.SideTypeBuy, model.SideTypeSell, 0.25, 0.5, 1.5},
+		{"full long", model.SideTypeBuy, model.SideTypeSell, 1, 2, 0},
+		{"partial short", model.SideTypeSell, model.SideTypeBuy, 0.5, 1, -1},
+		{"full short", model.SideTypeSell, model.SideTypeBuy, 1, 2, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := setup(t, tc.side)
+
+			order, err := controller.ClosePosition("BTCUSDT", tc.fraction)
+			require.NoError(t, err)
+			require.Equal(t, tc.closeSide, order.Side)
+			require.Equal(t, model.OrderTypeMarket, order.Type)
+			require.InDelta(t, tc.quantity, order.Quantity, 1e-9)
+
+			asset, _, err := controller.Position("BTCUSDT")
+			require.NoError(t, err)
+			require.InDelta(t, tc.remaining, asset, 1e-9)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		controller := setup(t, model.SideTypeBuy)
+		_, err := controller.ClosePosition("BTCUSDT", 0)
+		require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+
+		_, err = controller.ClosePosition("BTCUSDT", 1.5)
+		require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+
+		_, err = controller.ClosePosition("BTCUSDT", 1)
+		require.NoError(t, err)
+
+		_, err = controller.ClosePosition("BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrNoPosition)
+	})
+}
+
+func TestController_OrderStaleCancel(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+	controller.SetBacktest(true)
+	controller.SetOrderStaleCancel("BTCUSDT", 0.25)
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, Low: 1000, High: 1000}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	stale, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 850)
+	require.NoError(t, err)
+	near, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 950)
+	require.NoError(t, err)
+
+	// within the distance
+	controller.Reconcile()
+	pending, err := orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeNew))
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+
+	// price jump, the order at 850 is 29% from the market and the order at 950 is 21%
+	candle = model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1200, Low: 1150, High: 1250}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+	controller.Reconcile()
+	controller.Reconcile()
+
+	order, err := wallet.Order("BTCUSDT", stale.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeCanceled, order.Status)
+
+	canceled, err := orderStorage.Orders(storage.WithStatus(model.OrderStatusTypeCanceled))
+	require.NoError(t, err)
+	require.Len(t, canceled, 1)
+	require.Equal(t, stale.ExchangeID, canceled[0].ExchangeID)
+
+	order, err = wallet.Order("BTCUSDT", near.ExchangeID)
+	require.NoError(t, err)
+	require.Equal(t, model.OrderStatusTypeNew, order.Status)
+}
+
+func TestController_ReportingCurrency(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithPaperAsset("BTC", 1))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+	controller.SetReportingCurrency("USDT")
+
+	update := func(pair string, price float64) {
+		candle := model.Candle{Time: time.Now(), Pair: pair, Close: price, Low: price, High: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	update("BTCUSDT", 20000)
+	update("ETHBTC", 0.05)
+
+	// profit of 0.01 BTC, 200 USDT at 20000
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHBTC", 1)
+	require.NoError(t, err)
+	update("ETHBTC", 0.06)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "ETHBTC", 1)
+	require.NoError(t, err)
+
+	// profit of 100 USDT
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.NoError(t, err)
+	update("BTCUSDT", 21000)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.1)
+	require.NoError(t, err)
+
+	eth, btc := controller.Results["ETHBTC"], controller.Results["BTCUSDT"]
+	require.Equal(t, "USDT", eth.Currency)
+	require.InDelta(t, 0.01, eth.Profit(), 1e-9)
+	require.InDelta(t, 200, eth.ReportProfit, 1e-6)
+	require.InDelta(t, 0.11, eth.Volume, 1e-9)
+	require.InDelta(t, 2200, eth.ReportVolume, 1e-6)
+
+	require.InDelta(t, 100, btc.Profit(), 1e-9)
+	require.InDelta(t, 100, btc.ReportProfit, 1e-9)
+	require.InDelta(t, 4100, btc.ReportVolume, 1e-9)
+
+	require.InDelta(t, 300, eth.ReportProfit+btc.ReportProfit, 1e-6)
+}
+
+func TestController_SkipVerbosity(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	var messages []string
+	notifier := mocks.NewNotifier(t)
+	notifier.EXPECT().OnError(mock.Anything)
+	notifier.EXPECT().Notify(mock.Anything).Run(func(message string) {
+		messages = append(messages, message)
+	})
+	controller.SetNotifier(notifier)
+
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000, Low: 1000, High: 1000}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// not reported by default
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+	require.Empty(t, messages)
+
+	controller.SetSkipVerbosity(SkipNotify)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+	require.Len(t, messages, 1)
+	require.Contains(t, messages[0], string(SkipInsufficientFunds))
+	require.Contains(t, messages[0], "BTCUSDT")
+
+	_, err = controller.BuyPercent("BTCUSDT", 1.5)
+	require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+	require.Len(t, messages, 2)
+	require.Contains(t, messages[1], string(SkipInvalidQuantity))
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.5)
+	require.NoError(t, err)
+	controller.SetMinProfitToClose(0.1)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.5)
+	require.Error(t, err)
+	require.Len(t, messages, 3)
+	require.Contains(t, messages[2], string(SkipMinProfit))
+
+	// the halt notification and the halted order
+	controller.SetMaxConsecutiveErrors(1, false)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.ErrorIs(t, err, ErrHalted)
+	require.Contains(t, messages[len(messages)-1], string(SkipHalted))
+
+	// logged only
+	count := len(messages)
+	controller.SetSkipVerbosity(SkipLog)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.1)
+	require.ErrorIs(t, err, ErrHalted)
+	require.Len(t, messages, count)
+}
+
+func TestSkipReasonOf(t *testing.T) {
+	require.Equal(t, SkipMinNotional, SkipReasonOf(fmt.Errorf("%w: 5 below 10", exchange.ErrMinNotional)))
+	require.Equal(t, SkipInsufficientFunds, SkipReasonOf(&exchange.OrderError{Err: exchange.ErrInsufficientFunds}))
+	require.Equal(t, SkipShortingNotAllowed, SkipReasonOf(exchange.ErrShortingNotAllowed))
+	require.Equal(t, SkipMinProfit, SkipReasonOf(&MinProfitError{Pair: "BTCUSDT"}))
+	require.Equal(t, SkipNoMarketData, SkipReasonOf(exchange.ErrNoMarketData))
+	require.Equal(t, SkipRejected, SkipReasonOf(errors.New("api error")))
+}
+
+func TestController_Weights(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	account := model.Account{Balances: []model.Balance{
+		{Asset: "BTC", Free: 0.5, Lock: 0.5},
+		{Asset: "ETH", Free: -10},
+		{Asset: "USDT", Free: 4000, Lock: 1000},
+	}}
+	exchangeMock := mocks.NewExchange(t)
+	exchangeMock.EXPECT().Account().Return(account, nil)
+	exchangeMock.EXPECT().Position("BTCUSDT").Return(1, 5000, nil)
+	exchangeMock.EXPECT().Position("ETHUSDT").Return(-10, 5000, nil)
+	exchangeMock.EXPECT().Position("SOLUSDT").Return(0, 5000, nil)
+	controller := NewController(ctx, exchangeMock, orderStorage, NewOrderFeed())
+
+	controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 20000})
+	controller.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 1000})
+	controller.OnCandle(model.Candle{Pair: "SOLUSDT", Close: 20})
+
+	// equity: 20000 (long) - 10000 (short) + 5000 (cash)
+	weights, err := controller.Weights()
+	require.NoError(t, err)
+	require.Len(t, weights, 4)
+	require.InDelta(t, 20000.0/15000, weights["BTCUSDT"], 1e-9)
+	require.InDelta(t, -10000.0/15000, weights["ETHUSDT"], 1e-9)
+	require.Equal(t, 0.0, weights["SOLUSDT"])
+	require.InDelta(t, 5000.0/15000, weights[CashWeight], 1e-9)
+
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	require.InDelta(t, 1.0, total, 1e-9)
+}
+
+func TestController_WeightsWithoutEquity(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT")
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	_, err = controller.Weights()
+	require.ErrorIs(t, err, ErrNoEquity)
+}
+
+func TestController_MaxConsecutiveErrors(t *testing.T) {
+	setup := func(t *testing.T, haltAll bool) *Controller {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+		controller.SetMaxConsecutiveErrors(3, haltAll)
+
+		for _, candle := range []model.Candle{
+			{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100},
+			{Time: time.Now(), Pair: "ETHUSDT", Close: 10, Low: 10, High: 10},
+		} {
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+		return controller
+	}
+
+	t.Run("halt pair", func(t *testing.T) {
+		controller := setup(t, false)
+
+		// a successful order resets the count
+		for i := 0; i < 2; i++ {
+			_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 100)
+			require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+		}
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.False(t, controller.Halted("BTCUSDT"))
+
+		for i := 0; i < 3; i++ {
+			_, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 100, 100)
+			require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+		}
+		require.True(t, controller.Halted("BTCUSDT"))
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrHalted)
+
+		// other pairs are not affected
+		require.False(t, controller.Halted("ETHUSDT"))
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+		require.NoError(t, err)
+
+		controller.Resume("BTCUSDT")
+		require.False(t, controller.Halted("BTCUSDT"))
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+	})
+
+	t.Run("halt all", func(t *testing.T) {
+		controller := setup(t, true)
+
+		for i := 0; i < 3; i++ {
+			_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 100)
+			require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+		}
+
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+		require.ErrorIs(t, err, ErrHalted)
+
+		controller.Resume()
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+		require.NoError(t, err)
+	})
+}
+
+func TestSummary_DisplayFormat(t *testing.T) {
+	results := &summary{Pair: "BTCUSDT", WinLong: []float64{1234.5}}
+	require.Contains(t, results.String(), "1234.5000 USDT")
+
+	controller := NewController(context.Background(), nil, nil, nil)
+	require.Equal(t, model.CurrentDisplayFormat(), controller.DisplayFormat())
+
+	controller.SetDisplayFormat(model.DisplayFormat{Precision: 2, ThousandsSeparator: ","})
+	require.Equal(t, 2, controller.DisplayFormat().Precision)
+	results.format = controller.format
+	require.Contains(t, results.String(), "1,234.50 USDT")
+	require.Contains(t, results.tag("entry").String(), "0.00 USDT")
+}
+
+func TestSummary_Precision(t *testing.T) {
+	// 0.1 + 0.2 - 0.3 is not zero in float
+	noisy := summary{Pair: "BTCUSDT", WinLong: []float64{0.1, 0.2}, LoseLong: []float64{-0.3}}
+	require.NotZero(t, noisy.Profit())
+
+	rounded := noisy
+	rounded.precision = 8
+	require.Zero(t, rounded.Profit())
+	require.Zero(t, rounded.SQN())
+	require.Equal(t, 0.5, rounded.Payoff())
+
+	// only the metric is rounded, the profits below the precision are kept in the calculation
+	small := summary{Pair: "BTCUSDT", WinLong: []float64{0.004, 0.004}, LoseLong: []float64{-0.004}, precision: 2}
+	require.Zero(t, small.Profit())
+	require.Equal(t, 0.61, small.SQN())
+	require.Equal(t, 1.0, small.Payoff())
+
+	t.Run("dust trades", func(t *testing.T) {
+		trade := func(t *testing.T, precision int) *summary {
+			orderStorage, err := storage.FromMemory()
+			require.NoError(t, err)
+			ctx := context.Background()
+			wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+			controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+			controller.SetSummaryPrecision(precision)
+
+			candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100}
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+			_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+			require.NoError(t, err)
+
+			candle.Close, candle.High = 100.0000000001, 100.0000000001
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+			_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+			require.NoError(t, err)
+			return controller.Results["BTCUSDT"]
+		}
+
+		// default precision of the quote, 8 digits in the paper wallet, the trade is a break-even
+		results := trade(t, 0)
+		require.Empty(t, results.Win())
+		require.Empty(t, results.Lose())
+		require.Zero(t, results.Profit())
+
+		results = trade(t, 12)
+		require.Len(t, results.Win(), 1)
+		require.InDelta(t, 1e-10, results.Profit(), 1e-12)
+	})
+}
+
+func TestController_EquitySampling(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperAsset("BTC", 1))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	candle := model.Candle{Pair: "BTCUSDT", Close: 100}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	start := time.Now()
+	controller.SetEquitySampling(10 * time.Millisecond)
+	controller.Start()
+	require.Eventually(t, func() bool {
+		history, err := controller.EquityHistory(start, time.Now())
+		return err == nil && len(history) >= 2
+	}, time.Second, 5*time.Millisecond)
+	controller.Stop()
+
+	history, err := controller.EquityHistory(start, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1100.0, history[0].Value)
+	require.True(t, history[0].Time.Before(history[1].Time))
+
+	// snapshots out of the period are not returned
+	history, err = controller.EquityHistory(start.Add(-time.Hour), start)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	t.Run("storage without equity", func(t *testing.T) {
+		controller := NewController(ctx, wallet, struct{ storage.Storage }{orderStorage}, NewOrderFeed())
+		_, err := controller.SnapshotEquity()
+		require.ErrorIs(t, err, ErrEquityNotSupported)
+		_, err = controller.EquityHistory(start, time.Now())
+		require.ErrorIs(t, err, ErrEquityNotSupported)
+	})
+}
+
+func TestController_OpenPositions(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	var _ service.Positions = controller
+	for pair, price := range map[string]float64{"BTCUSDT": 1000, "ETHUSDT": 100, "SOLUSDT": 10} {
+		candle := model.Candle{Pair: pair, Close: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	require.Zero(t, controller.OpenPositionCount())
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "ETHUSDT", 2)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, controller.OpenPositionCount())
+	require.Equal(t, map[string]float64{"BTCUSDT": 1, "ETHUSDT": -2}, controller.OpenPositions())
+
+	// closed positions are not open
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"ETHUSDT": -2}, controller.OpenPositions())
+}
+
+func TestController_MaintenancePause(t *testing.T) {
+	maintenance := fmt.Errorf("%w: system maintenance", exchange.ErrMaintenance)
+	setup := func(t *testing.T) (*Controller, *exchangemock.MockExchange) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		m := exchangemock.NewExchange(exchangemock.WithBalance("USDT", 1000), exchangemock.WithQuote("BTCUSDT", 100))
+		controller := NewController(context.Background(), m, orderStorage, NewOrderFeed())
+		controller.SetMaxConsecutiveErrors(1, false)
+		controller.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100, Low: 100, High: 100})
+		return controller, m
+	}
+
+	t.Run("pause and resume", func(t *testing.T) {
+		controller, m := setup(t)
+		var messages []string
+		notifier := mocks.NewNotifier(t)
+		notifier.EXPECT().Notify(mock.Anything).Run(func(message string) {
+			messages = append(messages, message)
+		})
+		controller.SetNotifier(notifier)
+		controller.SetMaintenancePause(time.Millisecond)
+
+		m.SetError("CreateOrderMarket", maintenance)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrMaintenance)
+		require.True(t, controller.Maintenance())
+		require.False(t, controller.Halted("BTCUSDT"))
+		require.Len(t, messages, 1)
+		require.Contains(t, messages[0], "[MAINTENANCE] orders paused")
+
+		// paused orders do not reach the exchange
+		m.SetError("CreateOrderMarket", nil)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrMaintenance)
+		require.Len(t, m.Calls("CreateOrderMarket"), 1)
+
+		// the exchange is still in maintenance
+		m.SetError("Account", maintenance)
+		time.Sleep(2 * time.Millisecond)
+		controller.Reconcile()
+		require.True(t, controller.Maintenance())
+
+		m.SetError("Account", nil)
+		time.Sleep(2 * time.Millisecond)
+		controller.Reconcile()
+		require.False(t, controller.Maintenance())
+		require.Len(t, messages, 2)
+		require.Contains(t, messages[1], "[MAINTENANCE] orders resumed")
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, m.Balance("BTC"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		controller, m := setup(t)
+
+		m.SetError("CreateOrderMarket", maintenance)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrMaintenance)
+		require.False(t, controller.Maintenance())
+		require.True(t, controller.Halted("BTCUSDT"))
+	})
+}
+
+func TestController_ProfitTarget(t *testing.T) {
+	day := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	setup := func(t *testing.T, scope string, backtest bool) (*Controller, *exchange.PaperWallet, *[]string) {
+		orderStorage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+		controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+		controller.SetBacktest(backtest)
+		controller.tickerInterval = time.Millisecond
+		require.NoError(t, controller.SetProfitTarget(40, scope, nil))
+
+		var mtx sync.Mutex
+		var messages []string
+		notifier := mocks.NewNotifier(t)
+		notifier.EXPECT().Notify(mock.Anything).Run(func(message string) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			messages = append(messages, message)
+		})
+		controller.SetNotifier(notifier)
+		controller.Start()
+		t.Cleanup(controller.Stop)
+		return controller, wallet, &messages
+	}
+
+	// trade buys in a candle and sells in the next hour with the profit, as the backtest loop of the bot
+	trade := func(t *testing.T, controller *Controller, wallet *exchange.PaperWallet, at time.Time, profit float64) {
+		for i, price := range []float64{100, 100 + profit} {
+			candle := model.Candle{Time: at.Add(time.Duration(i) * time.Hour), Pair: "BTCUSDT", Close: price,
+				Low: price, High: price, Complete: true}
+			wallet.OnCandle(candle)
+			controller.Reconcile()
+			controller.OnCandle(candle)
+
+			side := model.SideTypeBuy
+			if i > 0 {
+				side = model.SideTypeSell
+			}
+			_, err := controller.CreateOrderMarket(side, "BTCUSDT", 1)
+			require.NoError(t, err)
+		}
+		controller.Reconcile()
+	}
+
+	t.Run("total", func(t *testing.T) {
+		controller, wallet, messages := setup(t, ProfitTargetTotal, true)
+
+		trade(t, controller, wallet, day, 30)
+		require.Equal(t, 30.0, controller.TargetProfit())
+		require.Equal(t, StatusRunning, controller.Status())
+
+		// the profits of different days are accumulated
+		trade(t, controller, wallet, day.Add(24*time.Hour), 20)
+		require.Equal(t, 50.0, controller.TargetProfit())
+		require.Contains(t, strings.Join(*messages, "\n"), "[TARGET] total profit target")
+		require.Equal(t, StatusStopped, controller.Status())
+
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrProfitTarget)
+		require.Equal(t, SkipProfitTarget, SkipReasonOf(err))
+
+		// a new day does not reset the total target
+		controller.OnCandle(model.Candle{Time: day.Add(48 * time.Hour), Pair: "BTCUSDT", Close: 100})
+		require.Equal(t, StatusStopped, controller.Status())
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrProfitTarget)
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		controller, wallet, messages := setup(t, ProfitTargetDaily, true)
+
+		trade(t, controller, wallet, day, 30)
+		trade(t, controller, wallet, day.Add(24*time.Hour), 30)
+		require.Equal(t, 30.0, controller.TargetProfit())
+
+		trade(t, controller, wallet, day.Add(26*time.Hour), 20)
+		require.Equal(t, 50.0, controller.TargetProfit())
+		require.Contains(t, strings.Join(*messages, "\n"), "[TARGET] daily profit target")
+		require.Equal(t, StatusStopped, controller.Status())
+
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrProfitTarget)
+
+		// the first candle of the next day resumes the bot
+		controller.OnCandle(model.Candle{Time: day.Add(38 * time.Hour), Pair: "BTCUSDT", Close: 100})
+		require.Equal(t, StatusRunning, controller.Status())
+		require.Zero(t, controller.TargetProfit())
+		require.Contains(t, (*messages)[len(*messages)-1], "[TARGET] new day")
+
+		trade(t, controller, wallet, day.Add(39*time.Hour), 10)
+		require.Equal(t, 10.0, controller.TargetProfit())
+		require.Equal(t, StatusRunning, controller.Status())
+	})
+
+	t.Run("live ticker", func(t *testing.T) {
+		controller, wallet, _ := setup(t, ProfitTargetTotal, false)
+
+		for i, price := range []float64{100, 150} {
+			candle := model.Candle{Time: day, Pair: "BTCUSDT", Close: price}
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+
+			side := model.SideTypeBuy
+			if i > 0 {
+				side = model.SideTypeSell
+			}
+			_, err := controller.CreateOrderMarket(side, "BTCUSDT", 1)
+			require.NoError(t, err)
+		}
+
+		// the ticker stops the controller after processing the orders
+		require.Eventually(t, func() bool {
+			return controller.Status() == StatusStopped
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("invalid scope", func(t *testing.T) {
+		controller := NewController(context.Background(), nil, nil, NewOrderFeed())
+		require.Error(t, controller.SetProfitTarget(100, "weekly", nil))
+	})
+}
+
+func TestController_ExportCSV(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, orderStorage, NewOrderFeed())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{100, 150} {
+		candle := model.Candle{Time: start.Add(time.Duration(i) * time.Hour), Pair: "BTCUSDT", Close: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		side := model.SideTypeBuy
+		if i > 0 {
+			side = model.SideTypeSell
+		}
+		_, err := controller.CreateOrderMarket(side, "BTCUSDT", 1)
+		require.NoError(t, err)
+	}
+
+	// pending orders are not exported
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	require.NoError(t, controller.ExportCSV(&buffer))
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"pair", "side", "type", "quantity", "price", "created_at", "updated_at", "profit", "profit_percent"},
+		{"BTCUSDT", "BUY", "MARKET", "1", "100", "2022-01-01T00:00:00Z", "2022-01-01T00:00:00Z", "0", "0"},
+		{"BTCUSDT", "SELL", "MARKET", "1", "150", "2022-01-01T01:00:00Z", "2022-01-01T01:00:00Z", "50", "0.5"},
+	}, records)
+}
+
+func TestController_WeightedFillPrice(t *testing.T) {
+	limit := model.Order{ExchangeID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeLimit,
+		Status: model.OrderStatusTypeNew, Price: 120, Quantity: 2}
+	update := func(status model.OrderStatusType, executed, cost, price float64) model.Order {
+		order := limit
+		order.Status = status
+		order.ExecutedQuantity = executed
+		order.ExecutedCost = cost
+		order.Price = price
+		return order
+	}
+
+	tests := []struct {
+		name    string
+		updates []model.Order
+	}{
+		{
+			// the exchange reports the price of the last fill
+			name: "last fill price",
+			updates: []model.Order{
+				update(model.OrderStatusTypePartiallyFilled, 1, 0, 100),
+				update(model.OrderStatusTypePartiallyFilled, 1.5, 0, 110),
+				update(model.OrderStatusTypeFilled, 2, 0, 120),
+			},
+		},
+		{
+			// the exchange reports the cumulative cost
+			name: "cumulative cost",
+			updates: []model.Order{
+				update(model.OrderStatusTypePartiallyFilled, 1, 100, 100),
+				update(model.OrderStatusTypePartiallyFilled, 1.5, 155, 155/1.5),
+				update(model.OrderStatusTypeFilled, 2, 215, 107.5),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			orderStorage, err := storage.FromMemory()
+			require.NoError(t, err)
+
+			exchangeMock := mocks.NewExchange(t)
+			exchangeMock.EXPECT().AssetsInfo("BTCUSDT").Return(model.AssetInfo{QuotePrecision: 8}).Maybe()
+			exchangeMock.EXPECT().CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 2.0, 120.0).Return(limit, nil)
+			for _, order := range tc.updates {
+				exchangeMock.EXPECT().Order("BTCUSDT", int64(1)).Return(order, nil).Once()
+			}
+
+			controller := NewController(context.Background(), exchangeMock, orderStorage, NewOrderFeed())
+			_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 2, 120)
+			require.NoError(t, err)
+
+			// each update is a new fill, even without a change of status
+			for _, price := range []float64{100, 310.0 / 3, 107.5} {
+				controller.Reconcile()
+				orders, err := orderStorage.Orders()
+				require.NoError(t, err)
+				require.Len(t, orders, 1)
+				require.InDelta(t, price, orders[0].Price, 1e-9)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+)
+
+var ErrNoReportRate = errors.New("no exchange rate to the reporting currency")
+
+// SetReportingCurrency converts the profit and volume of the trades to a single currency (e.g. USDT), so the
+// results of pairs with different quotes (e.g. ETHBTC and BTCUSDT) can be summed. The values are converted with
+// the exchange rate of the quote at the time of each trade: the last price of the pair between the quote and the
+// currency (e.g. BTCUSDT for ETHBTC), in any direction, or the exchange quote if the pair is not traded. The
+// converted values are stored in the ReportProfit and ReportVolume fields of the results, the values in the quote
+// of each pair are kept. Trades without exchange rate are notified and not converted.
+func (c *Controller) SetReportingCurrency(currency string) {
+	c.reportCurrency = currency
+}
+
+// ReportingCurrency returns the currency of the converted results, empty if disabled, see SetReportingCurrency
+func (c *Controller) ReportingCurrency() string {
+	return c.reportCurrency
+}
+
+// reportRate returns the price of the quote of a pair in the reporting currency, zero without reporting
+// currency. The controller lock must be held.
+func (c *Controller) reportRate(pair string) (float64, error) {
+	if c.reportCurrency == "" {
+		return 0, nil
+	}
+
+	_, quote := exchange.SplitAssetQuote(pair)
+	if quote == c.reportCurrency {
+		return 1, nil
+	}
+
+	direct, inverse := quote+c.reportCurrency, c.reportCurrency+quote
+	if price := c.lastPrice[direct]; price > 0 {
+		return price, nil
+	}
+
+	if price := c.lastPrice[inverse]; price > 0 {
+		return 1 / price, nil
+	}
+
+	if price, err := c.exchange.LastQuote(c.ctx, direct); err == nil && price > 0 {
+		return price, nil
+	}
+
+	return 0, fmt.Errorf("%w: %s to %s", ErrNoReportRate, quote, c.reportCurrency)
+}
@@ -0,0 +1,77 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+var ErrEquityNotSupported = errors.New("storage does not support the equity history")
+
+// SetEquitySampling stores a snapshot of the aggregate equity of the accounts at each interval while the
+// controller runs in a live session, the equity curve of the bot as the paper wallet has in backtests, see
+// EquityHistory. It must be set before Start and the storage must implement storage.EquityStorage. Zero
+// disables the sampling.
+func (c *Controller) SetEquitySampling(interval time.Duration) {
+	c.mtx.Lock()
+	defer c.unlock()
+	c.equityInterval = interval
+}
+
+// SnapshotEquity stores the current aggregate equity of the accounts, see AggregateEquity
+func (c *Controller) SnapshotEquity() (model.EquitySnapshot, error) {
+	equityStorage, ok := c.storage.(storage.EquityStorage)
+	if !ok {
+		return model.EquitySnapshot{}, ErrEquityNotSupported
+	}
+
+	equity, err := c.AggregateEquity()
+	if err != nil {
+		return model.EquitySnapshot{}, err
+	}
+
+	snapshot := model.EquitySnapshot{
+		Time:  time.Now(),
+		Value: equity,
+	}
+	if err := equityStorage.CreateEquity(&snapshot); err != nil {
+		return model.EquitySnapshot{}, err
+	}
+
+	log.Debugf("[EQUITY] %f", equity)
+	return snapshot, nil
+}
+
+// EquityHistory returns the equity snapshots with time in [start, end], sorted by time, see SetEquitySampling
+func (c *Controller) EquityHistory(start, end time.Time) ([]model.EquitySnapshot, error) {
+	equityStorage, ok := c.storage.(storage.EquityStorage)
+	if !ok {
+		return nil, ErrEquityNotSupported
+	}
+	return equityStorage.Equities(start, end)
+}
+
+// equityTicker returns the channel of the equity sampling, nil if it is disabled
+func (c *Controller) equityTicker() (<-chan time.Time, func()) {
+	c.mtx.Lock()
+	interval := c.equityInterval
+	c.unlock()
+
+	if interval <= 0 {
+		return nil, func() {}
+	}
+
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// sampleEquity stores a snapshot of the equity, the errors are notified
+func (c *Controller) sampleEquity() {
+	if _, err := c.SnapshotEquity(); err != nil {
+		c.notifyError(fmt.Errorf("equity snapshot: %w", err))
+	}
+}
@@ -0,0 +1,71 @@
+package order
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// exportHeader is the header of the orders exported to CSV, see ExportCSV
+var exportHeader = []string{
+	"pair", "side", "type", "quantity", "price", "created_at", "updated_at", "profit", "profit_percent",
+}
+
+// ExportCSV writes the filled orders of the account to CSV, in the sequence of execution, e.g. to analyze a
+// backtest in a spreadsheet. The profit is the realized profit in the quote of the pair of the orders that
+// close a position, and zero for the others. The times are in RFC 3339.
+func (c *Controller) ExportCSV(w io.Writer) error {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	orders, err := c.storage.Orders(
+		storage.WithAccount(c.account),
+		storage.WithStatus(model.OrderStatusTypeFilled),
+	)
+	if err != nil {
+		return err
+	}
+
+	// orders filled at the same time are sorted by creation, as processed by the controller
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].UpdatedAt.Equal(orders[j].UpdatedAt) {
+			return orders[i].ExchangeID < orders[j].ExchangeID
+		}
+		return orders[i].UpdatedAt.Before(orders[j].UpdatedAt)
+	})
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportHeader); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		value, percent, err := c.calculateProfit(order)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write([]string{
+			order.Pair,
+			string(order.Side),
+			string(order.Type),
+			strconv.FormatFloat(order.Quantity, 'f', -1, 64),
+			strconv.FormatFloat(order.Price, 'f', -1, 64),
+			order.CreatedAt.Format(time.RFC3339),
+			order.UpdatedAt.Format(time.RFC3339),
+			strconv.FormatFloat(value, 'f', -1, 64),
+			strconv.FormatFloat(percent, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -1,61 +1,165 @@
 package order
 
 import (
+	"sync"
+
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
 )
 
-type DataFeed struct {
-	Data chan model.Order
-	Err  chan error
-}
+const defaultFeedBufferSize = 100
+
+// BackpressurePolicy defines the behavior of Publish when a subscriber buffer is full
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits until the subscriber consumes an order, no order is lost
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDrop discards the order for the slow subscriber and logs a warning
+	BackpressureDrop
+)
 
 type FeedConsumer func(order model.Order)
 
+type FeedOption func(*Feed)
+
+// Feed delivers orders to subscribers of each pair. Each subscriber has its own buffered channel,
+// consumed by a single goroutine, so orders are received in the same order of publication.
 type Feed struct {
-	OrderFeeds            map[string]*DataFeed
-	SubscriptionsBySymbol map[string][]Subscription
+	SubscriptionsBySymbol map[string][]*Subscription
+
+	mtx        sync.Mutex
+	ready      bool
+	started    bool
+	bufferSize int
+	policy     BackpressurePolicy
 }
 
 type Subscription struct {
 	onlyNewOrder bool
 	consumer     FeedConsumer
+	data         chan model.Order
 }
 
-func NewOrderFeed() *Feed {
-	return &Feed{
-		OrderFeeds:            make(map[string]*DataFeed),
-		SubscriptionsBySymbol: make(map[string][]Subscription),
+// WithFeedBufferSize sets the number of orders buffered for each subscriber, default is 100
+func WithFeedBufferSize(size int) FeedOption {
+	return func(feed *Feed) {
+		feed.bufferSize = size
 	}
 }
 
-func (d *Feed) Subscribe(pair string, consumer FeedConsumer, onlyNewOrder bool) {
-	if _, ok := d.OrderFeeds[pair]; !ok {
-		d.OrderFeeds[pair] = &DataFeed{
-			Data: make(chan model.Order),
-			Err:  make(chan error),
-		}
+// WithFeedBackpressure sets the policy used when a subscriber buffer is full, default is BackpressureBlock
+func WithFeedBackpressure(policy BackpressurePolicy) FeedOption {
+	return func(feed *Feed) {
+		feed.policy = policy
+	}
+}
+
+func NewOrderFeed(options ...FeedOption) *Feed {
+	feed := &Feed{
+		SubscriptionsBySymbol: make(map[string][]*Subscription),
+		bufferSize:            defaultFeedBufferSize,
+		policy:                BackpressureBlock,
 	}
 
-	d.SubscriptionsBySymbol[pair] = append(d.SubscriptionsBySymbol[pair], Subscription{
+	for _, option := range options {
+		option(feed)
+	}
+
+	return feed
+}
+
+// Subscribe registers a consumer for the orders of a pair. A consumer subscribed after Start is started
+// immediately and receives the orders published after the subscription.
+func (d *Feed) Subscribe(pair string, consumer FeedConsumer, onlyNewOrder bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	subscription := &Subscription{
 		onlyNewOrder: onlyNewOrder,
 		consumer:     consumer,
-	})
+	}
+
+	if d.ready {
+		subscription.data = make(chan model.Order, d.bufferSize)
+	}
+
+	if d.started {
+		go subscription.consume()
+	}
+
+	d.SubscriptionsBySymbol[pair] = append(d.SubscriptionsBySymbol[pair], subscription)
+}
+
+// init allocates the subscriber buffers on the first publication or start,
+// so options may be changed while the feed is not in use
+func (d *Feed) init() {
+	if d.ready {
+		return
+	}
+
+	for _, subscriptions := range d.SubscriptionsBySymbol {
+		for _, subscription := range subscriptions {
+			subscription.data = make(chan model.Order, d.bufferSize)
+		}
+	}
+	d.ready = true
+}
+
+// Configure applies options to the feed, it has no effect after the first publication or start
+func (d *Feed) Configure(options ...FeedOption) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for _, option := range options {
+		option(d)
+	}
 }
 
-func (d *Feed) Publish(order model.Order, _ bool) {
-	if _, ok := d.OrderFeeds[order.Pair]; ok {
-		d.OrderFeeds[order.Pair].Data <- order
+// Publish sends the order to the subscribers of its pair. It must be called synchronously
+// to keep the orders in the same sequence of creation for all subscribers. The feed lock is released
+// before sending, so a blocked publication does not block Subscribe or the other feed methods.
+func (d *Feed) Publish(order model.Order, newOrder bool) {
+	d.mtx.Lock()
+	d.init()
+	subscriptions := append([]*Subscription(nil), d.SubscriptionsBySymbol[order.Pair]...)
+	policy := d.policy
+	d.mtx.Unlock()
+
+	for _, subscription := range subscriptions {
+		if subscription.onlyNewOrder && !newOrder {
+			continue
+		}
+
+		if policy == BackpressureBlock {
+			subscription.data <- order
+			continue
+		}
+
+		select {
+		case subscription.data <- order:
+		default:
+			log.Warnf("order feed: subscriber buffer is full, order %d of %s dropped", order.ExchangeID, order.Pair)
+		}
 	}
 }
 
 func (d *Feed) Start() {
-	for pair := range d.OrderFeeds {
-		go func(pair string, feed *DataFeed) {
-			for order := range feed.Data {
-				for _, subscription := range d.SubscriptionsBySymbol[pair] {
-					subscription.consumer(order)
-				}
-			}
-		}(pair, d.OrderFeeds[pair])
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.init()
+	d.started = true
+	for _, subscriptions := range d.SubscriptionsBySymbol {
+		for _, subscription := range subscriptions {
+			go subscription.consume()
+		}
+	}
+}
+
+// consume calls the consumer for each order of the subscription, in the sequence of publication
+func (s *Subscription) consume() {
+	for order := range s.data {
+		s.consumer(order)
 	}
 }
@@ -2,6 +2,7 @@ package order
 
 import (
 	"testing"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/stretchr/testify/require"
@@ -24,3 +25,88 @@ func TestFeed_Subscribe(t *testing.T) {
 	feed.Publish(model.Order{Pair: pair}, false)
 	require.True(t, <-called)
 }
+
+func TestFeed_SubscribeAfterStart(t *testing.T) {
+	feed, pair := NewOrderFeed(), "blaus"
+	feed.Start()
+
+	received := make(chan int64, 1)
+	feed.Subscribe(pair, func(order model.Order) {
+		received <- order.ExchangeID
+	}, false)
+
+	feed.Publish(model.Order{ExchangeID: 1, Pair: pair}, true)
+	require.Equal(t, int64(1), <-received)
+}
+
+func TestFeed_Order(t *testing.T) {
+	feed, pair := NewOrderFeed(WithFeedBufferSize(1)), "blaus"
+	received := make(chan int64, 100)
+
+	feed.Subscribe(pair, func(order model.Order) {
+		received <- order.ExchangeID
+	}, false)
+	feed.Start()
+
+	for i := int64(1); i <= 100; i++ {
+		feed.Publish(model.Order{ExchangeID: i, Pair: pair}, true)
+	}
+
+	for i := int64(1); i <= 100; i++ {
+		require.Equal(t, i, <-received)
+	}
+}
+
+func TestFeed_Backpressure(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		feed, pair := NewOrderFeed(WithFeedBufferSize(2), WithFeedBackpressure(BackpressureDrop)), "blaus"
+		received := make(chan int64, 10)
+
+		feed.Subscribe(pair, func(order model.Order) {
+			received <- order.ExchangeID
+		}, false)
+
+		// subscriber is not started, orders above the buffer size are discarded
+		for i := int64(1); i <= 5; i++ {
+			feed.Publish(model.Order{ExchangeID: i, Pair: pair}, true)
+		}
+		feed.Start()
+
+		require.Equal(t, int64(1), <-received)
+		require.Equal(t, int64(2), <-received)
+		require.Never(t, func() bool { return len(received) > 0 }, 50*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		feed, pair := NewOrderFeed(WithFeedBufferSize(1)), "blaus"
+		feed.Subscribe(pair, func(order model.Order) {}, false)
+
+		// subscriber is not started, the second order blocks until it is consumed
+		feed.Publish(model.Order{ExchangeID: 1, Pair: pair}, true)
+		published := make(chan bool, 1)
+		go func() {
+			feed.Publish(model.Order{ExchangeID: 2, Pair: pair}, true)
+			published <- true
+		}()
+
+		// the blocked publication does not hold the feed lock
+		require.Never(t, func() bool { return len(published) > 0 }, 50*time.Millisecond, 10*time.Millisecond)
+		feed.Subscribe("other", func(order model.Order) {}, false)
+		feed.Start()
+		require.True(t, <-published)
+	})
+
+	t.Run("only new orders", func(t *testing.T) {
+		feed, pair := NewOrderFeed(), "blaus"
+		received := make(chan int64, 10)
+
+		feed.Subscribe(pair, func(order model.Order) {
+			received <- order.ExchangeID
+		}, true)
+		feed.Start()
+
+		feed.Publish(model.Order{ExchangeID: 1, Pair: pair}, false)
+		feed.Publish(model.Order{ExchangeID: 2, Pair: pair}, true)
+		require.Equal(t, int64(2), <-received)
+	})
+}
@@ -0,0 +1,110 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrHalted = errors.New("orders halted after consecutive errors")
+
+// SetMaxConsecutiveErrors halts the order creation of a pair after the given number of consecutive failures
+// of its orders, e.g. rejections of the exchange or the checks of the controller, to surface strategies that
+// keep sending invalid orders. With haltAll, the orders of all pairs are halted. The halt is notified and the
+// following orders fail with ErrHalted until Resume. A successful order resets the count of the pair, orders
+// blocked by the minimum profit are not failures. Zero disables the limit, the default.
+func (c *Controller) SetMaxConsecutiveErrors(limit int, haltAll bool) {
+	c.maxErrors = limit
+	c.haltAll = haltAll
+}
+
+// Resume allows the orders of halted pairs again, or of all pairs when no pair is given
+func (c *Controller) Resume(pairs ...string) {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	if len(pairs) == 0 {
+		c.halted = nil
+		c.orderErrors = nil
+		c.haltedAll = false
+		return
+	}
+
+	for _, pair := range pairs {
+		delete(c.halted, pair)
+		delete(c.orderErrors, pair)
+	}
+}
+
+// Halted returns true if the orders of the pair are halted, see SetMaxConsecutiveErrors
+func (c *Controller) Halted(pair string) bool {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	return c.haltedAll || c.halted[pair]
+}
+
+// checkHalted returns ErrHalted if the orders of the pair are halted, ErrMaintenance if the orders are paused
+// by a maintenance of the exchange, or ErrProfitTarget if the profit target was reached. The controller lock
+// must be held.
+func (c *Controller) checkHalted(pair string) error {
+	if err := c.checkMaintenance(); err != nil {
+		return err
+	}
+
+	if err := c.checkProfitTarget(); err != nil {
+		return err
+	}
+
+	if c.haltedAll || c.halted[pair] {
+		return fmt.Errorf("%w: %s", ErrHalted, pair)
+	}
+	return nil
+}
+
+// rejectOrder notifies the failure of an order and counts it in the consecutive errors of the pair, a maintenance
+// of the exchange pauses the orders instead, see SetMaintenancePause. The controller lock must be held.
+func (c *Controller) rejectOrder(side model.SideType, pair string, err error) {
+	if c.enterMaintenance(err) {
+		c.reportSkip(side, pair, err)
+		return
+	}
+
+	c.notifyError(err)
+	c.reportSkip(side, pair, err)
+	if c.maxErrors <= 0 {
+		return
+	}
+
+	if c.orderErrors == nil {
+		c.orderErrors = make(map[string]int)
+	}
+	c.orderErrors[pair]++
+	if c.orderErrors[pair] < c.maxErrors {
+		return
+	}
+
+	if c.haltAll {
+		if !c.haltedAll {
+			c.haltedAll = true
+			c.notify(fmt.Sprintf("[HALT] orders of all pairs halted after %d consecutive errors of %s: %v",
+				c.orderErrors[pair], pair, err))
+		}
+		return
+	}
+
+	if !c.halted[pair] {
+		if c.halted == nil {
+			c.halted = make(map[string]bool)
+		}
+		c.halted[pair] = true
+		c.notify(fmt.Sprintf("[HALT] orders of %s halted after %d consecutive errors: %v", pair,
+			c.orderErrors[pair], err))
+	}
+}
+
+// acceptOrder resets the consecutive errors of the pair, the controller lock must be held
+func (c *Controller) acceptOrder(pair string) {
+	delete(c.orderErrors, pair)
+}
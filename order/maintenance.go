@@ -0,0 +1,72 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+)
+
+// SetMaintenancePause pauses the orders of all pairs when the exchange reports a maintenance, see
+// exchange.ErrMaintenance, instead of failing each order. While paused, the orders fail with ErrMaintenance
+// without reaching the exchange and are not counted as consecutive errors. The exchange is probed at each
+// interval, in the ticker of the pending orders, and the orders resume when it responds again. The pause and the
+// resume are notified. Zero disables the pause, the default.
+func (c *Controller) SetMaintenancePause(probeInterval time.Duration) {
+	c.mtx.Lock()
+	defer c.unlock()
+	c.maintenanceProbe = probeInterval
+}
+
+// Maintenance returns true if the orders are paused by a maintenance of the exchange, see SetMaintenancePause
+func (c *Controller) Maintenance() bool {
+	c.mtx.Lock()
+	defer c.unlock()
+	return !c.maintenanceSince.IsZero()
+}
+
+// checkMaintenance returns ErrMaintenance if the orders are paused, the controller lock must be held
+func (c *Controller) checkMaintenance() error {
+	if c.maintenanceSince.IsZero() {
+		return nil
+	}
+	return fmt.Errorf("%w: paused since %s", exchange.ErrMaintenance, c.maintenanceSince.Format(time.RFC3339))
+}
+
+// enterMaintenance pauses the orders if the error is a maintenance of the exchange and the pause is enabled.
+// It returns true if the error is handled by the pause. The controller lock must be held.
+func (c *Controller) enterMaintenance(err error) bool {
+	if c.maintenanceProbe <= 0 || !errors.Is(err, exchange.ErrMaintenance) {
+		return false
+	}
+
+	if c.maintenanceSince.IsZero() {
+		c.maintenanceSince = time.Now()
+		c.maintenanceChecked = c.maintenanceSince
+		c.notify(fmt.Sprintf("[MAINTENANCE] orders paused: %v", err))
+	}
+	return true
+}
+
+// probeMaintenance checks if the exchange recovered from the maintenance, at most once per probe interval. It
+// returns true if the orders are still paused. The controller lock must be held.
+func (c *Controller) probeMaintenance() bool {
+	if c.maintenanceSince.IsZero() {
+		return false
+	}
+
+	if time.Since(c.maintenanceChecked) < c.maintenanceProbe {
+		return true
+	}
+
+	c.maintenanceChecked = time.Now()
+	if _, err := c.exchange.Account(); errors.Is(err, exchange.ErrMaintenance) {
+		return true
+	}
+
+	c.notify(fmt.Sprintf("[MAINTENANCE] orders resumed after %s",
+		time.Since(c.maintenanceSince).Round(time.Second)))
+	c.maintenanceSince = time.Time{}
+	return false
+}
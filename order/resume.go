@@ -0,0 +1,134 @@
+package order
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// ResumeTag is the tag of the orders stored by ResumePositions to represent positions opened before the session
+const ResumeTag = "resume"
+
+// resumeHistoryLimit is the number of recent orders of the exchange used to rebuild the entry price
+const resumeHistoryLimit = 500
+
+// ResumePositions seeds the storage with the positions already open in the exchange, e.g. when a live bot is
+// restarted with an empty storage, so the profit of the next orders is calculated from the entry price of the
+// positions instead of being ignored.
+//
+// For each pair, the difference between the position in the exchange and the position of the stored orders is
+// stored as a filled order tagged ResumeTag, at the initial time of the session. The entry price is rebuilt with
+// the recent filled orders of the exchange until the initial time, see service.OrderHistory, or is the last price
+// if the history is unavailable or does not explain the position. The seed orders are not included in the results.
+// A zero initial time is the current time.
+func (c *Controller) ResumePositions(pairs []string, since time.Time) error {
+	if since.IsZero() {
+		since = time.Now()
+	}
+
+	for _, pair := range pairs {
+		if err := c.resumePosition(pair, since); err != nil {
+			return fmt.Errorf("resume %s: %w", pair, err)
+		}
+	}
+
+	for _, account := range c.accounts {
+		if err := account.ResumePositions(pairs, since); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) resumePosition(pair string, since time.Time) error {
+	asset, _, err := c.exchange.Position(pair)
+	if err != nil {
+		return err
+	}
+
+	orders, err := c.storage.Orders(
+		storage.WithAccount(c.account),
+		storage.WithStatus(model.OrderStatusTypeFilled),
+		storage.WithPair(pair),
+	)
+	if err != nil {
+		return err
+	}
+
+	stored, _, _ := replayPosition(orders)
+	quantity := c.withoutDust(pair, asset) - stored
+	if quantity == 0 {
+		return nil
+	}
+
+	price, err := c.entryPrice(pair, quantity, since)
+	if err != nil {
+		return err
+	}
+
+	if !c.tradable(pair, math.Abs(quantity), price) {
+		return nil
+	}
+
+	order := model.Order{
+		Pair:      pair,
+		Side:      model.SideTypeBuy,
+		Type:      model.OrderTypeMarket,
+		Status:    model.OrderStatusTypeFilled,
+		Price:     price,
+		Quantity:  math.Abs(quantity),
+		CreatedAt: since,
+		UpdatedAt: since,
+		Tag:       ResumeTag,
+		Account:   c.account,
+	}
+	if quantity < 0 {
+		order.Side = model.SideTypeSell
+	}
+
+	if err := c.storage.CreateOrder(&order); err != nil {
+		return err
+	}
+
+	log.Infof("[RESUME] %s position of %f at %f", pair, quantity, price)
+	return nil
+}
+
+// entryPrice returns the average entry price of a position of the given quantity, rebuilt with the recent
+// orders of the exchange filled until the given time, or the last price
+func (c *Controller) entryPrice(pair string, quantity float64, until time.Time) (float64, error) {
+	if history, ok := c.exchange.(service.OrderHistory); ok {
+		orders, err := history.Orders(pair, resumeHistoryLimit)
+		if err != nil {
+			return 0, err
+		}
+
+		filled := make([]*model.Order, 0, len(orders))
+		for i, order := range orders {
+			if order.Status == model.OrderStatusTypeFilled && !order.UpdatedAt.After(until) {
+				filled = append(filled, &orders[i])
+			}
+		}
+		sort.SliceStable(filled, func(i, j int) bool {
+			return filled[i].UpdatedAt.Before(filled[j].UpdatedAt)
+		})
+
+		// the history must include the whole position, with the same side
+		position, avgPriceLong, avgPriceShort := replayPosition(filled)
+		if quantity > 0 && position >= quantity && avgPriceLong > 0 {
+			return avgPriceLong, nil
+		}
+		if quantity < 0 && position <= quantity && avgPriceShort > 0 {
+			return avgPriceShort, nil
+		}
+	}
+
+	return c.marketPrice(pair)
+}
@@ -0,0 +1,137 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// scaleOutTolerance absorbs floating point errors in the sum of the fractions, e.g. 0.1 + 0.2 + 0.7
+const scaleOutTolerance = 1e-9
+
+var ErrInvalidScaleOut = errors.New("invalid scale out")
+
+// ScaleLevel is a take-profit level of ScaleOut, a fraction of the position (e.g. 0.5 for 50%) closed at a price
+type ScaleLevel struct {
+	Price    float64
+	Fraction float64
+}
+
+// ScaleOut closes a position in parts with a take-profit limit order for each level, e.g. 50% at the first
+// target and the rest at a second one. The fractions are resolved against the position at creation time and
+// rounded down to the step size, so the fills of the first levels do not change the quantity of the others.
+// The fractions must sum to at most 1, when they sum to 1 the last level closes the remainder of the position.
+// Long positions are closed with sell orders and short positions with buy orders. If an order fails, the
+// orders already created are canceled. It returns exchange.ErrNoPosition without position.
+func (c *Controller) ScaleOut(pair string, levels []ScaleLevel) ([]model.Order, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("%w: no levels", ErrInvalidScaleOut)
+	}
+
+	var total float64
+	for _, level := range levels {
+		if level.Price <= 0 || level.Fraction <= 0 {
+			return nil, fmt.Errorf("%w: price %f and fraction %f must be positive", ErrInvalidScaleOut,
+				level.Price, level.Fraction)
+		}
+		total += level.Fraction
+	}
+
+	if total > 1+scaleOutTolerance {
+		return nil, fmt.Errorf("%w: fractions sum to %f, above 1", ErrInvalidScaleOut, total)
+	}
+
+	position, _, err := c.Position(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	if position == 0 {
+		return nil, exchange.ErrNoPosition
+	}
+
+	side := model.SideTypeSell
+	if position < 0 {
+		side = model.SideTypeBuy
+	}
+
+	// quantities of all levels from the position at creation time, the side of PercentQuantity is always a
+	// sell to use a fraction of the asset
+	size := math.Abs(position)
+	info := c.exchange.AssetsInfo(pair)
+	quantities := make([]float64, len(levels))
+	var allocated float64
+	for i, level := range levels {
+		available, fraction := size, level.Fraction
+		if i == len(levels)-1 && total >= 1-scaleOutTolerance {
+			available, fraction = size-allocated, 1
+		}
+
+		quantities[i], err = exchange.PercentQuantity(info, model.SideTypeSell, available, 0, level.Price, fraction)
+		if err != nil {
+			return nil, fmt.Errorf("scale out level %d: %w", i+1, err)
+		}
+		allocated += quantities[i]
+	}
+
+	orders := make([]model.Order, 0, len(levels))
+	for i, level := range levels {
+		order, err := c.createOrderLimit(side, pair, quantities[i], level.Price, model.TimeInForceGTC)
+		if err != nil {
+			for _, created := range orders {
+				if cancelErr := c.Cancel(created); cancelErr != nil {
+					log.Errorf("[SCALE OUT] %s: cancel order %d: %v", pair, created.ExchangeID, cancelErr)
+				}
+			}
+			return nil, fmt.Errorf("scale out level %d: %w", i+1, err)
+		}
+		orders = append(orders, order)
+	}
+
+	log.Infof("[SCALE OUT] %s position of %f in %d levels", pair, position, len(orders))
+	return orders, nil
+}
+
+// ClosePosition closes a fraction of the position of the pair with a market order, e.g. 0.5 to close half of
+// the position or 1 to close it entirely. Long positions are closed with a sell order and short positions with
+// a buy order. The quantity is rounded down to the step size and validated with the minimum notional of the
+// pair. It returns exchange.ErrNoPosition without position.
+func (c *Controller) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	if fraction <= 0 || fraction > 1 {
+		return model.Order{}, fmt.Errorf("%w: fraction %f out of range (0, 1]", exchange.ErrInvalidQuantity,
+			fraction)
+	}
+
+	position, _, err := c.Position(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if position == 0 {
+		return model.Order{}, exchange.ErrNoPosition
+	}
+
+	side := model.SideTypeSell
+	if position < 0 {
+		side = model.SideTypeBuy
+	}
+
+	price, err := c.marketPrice(pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	// the side of PercentQuantity is always a sell to use a fraction of the asset
+	quantity, err := exchange.PercentQuantity(c.exchange.AssetsInfo(pair), model.SideTypeSell,
+		math.Abs(position), 0, price, fraction)
+	if err != nil {
+		return model.Order{}, fmt.Errorf("close position %s: %w", pair, err)
+	}
+
+	log.Infof("[CLOSE POSITION] %s %.2f%% of %f", pair, fraction*100, position)
+	return c.CreateOrderMarket(side, pair, quantity)
+}
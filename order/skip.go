@@ -0,0 +1,98 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// SkipReason is the reason code of an order of a strategy that was not created, see SetSkipVerbosity
+type SkipReason string
+
+const (
+	SkipInsufficientFunds  SkipReason = "insufficient_funds"
+	SkipMinNotional        SkipReason = "min_notional"
+	SkipInvalidQuantity    SkipReason = "invalid_quantity"
+	SkipShortingNotAllowed SkipReason = "shorting_not_allowed"
+	SkipMinProfit          SkipReason = "min_profit"
+	SkipHalted             SkipReason = "halted"
+	SkipNoMarketData       SkipReason = "no_market_data"
+	SkipMaintenance        SkipReason = "maintenance"
+	SkipProfitTarget       SkipReason = "profit_target"
+	// SkipRejected is an order rejected for another reason, e.g. by the exchange
+	SkipRejected SkipReason = "rejected"
+)
+
+// SkipVerbosity is the level of the reports of skipped orders, see SetSkipVerbosity
+type SkipVerbosity int
+
+const (
+	// SkipSilent does not report skipped orders, the errors are still logged, the default
+	SkipSilent SkipVerbosity = iota
+	// SkipLog logs each skipped order with its reason code
+	SkipLog
+	// SkipNotify also sends the skipped orders to the notifier, e.g. Telegram
+	SkipNotify
+)
+
+// SkipReasonOf returns the reason code of the error of an order
+func SkipReasonOf(err error) SkipReason {
+	var minProfit *MinProfitError
+	switch {
+	case errors.As(err, &minProfit):
+		return SkipMinProfit
+	case errors.Is(err, ErrHalted):
+		return SkipHalted
+	case errors.Is(err, exchange.ErrInsufficientFunds):
+		return SkipInsufficientFunds
+	case errors.Is(err, exchange.ErrMinNotional):
+		return SkipMinNotional
+	case errors.Is(err, exchange.ErrInvalidQuantity):
+		return SkipInvalidQuantity
+	case errors.Is(err, exchange.ErrShortingNotAllowed):
+		return SkipShortingNotAllowed
+	case errors.Is(err, exchange.ErrNoMarketData):
+		return SkipNoMarketData
+	case errors.Is(err, exchange.ErrMaintenance):
+		return SkipMaintenance
+	case errors.Is(err, ErrProfitTarget):
+		return SkipProfitTarget
+	default:
+		return SkipRejected
+	}
+}
+
+// SetSkipVerbosity reports the orders of the strategy that were not created, with the reason code of the
+// failure, e.g. insufficient funds, minimum notional, minimum profit or halted pair, to diagnose why a bot did
+// not trade. The orders blocked by the checks of the controller are otherwise only logged as warnings.
+func (c *Controller) SetSkipVerbosity(verbosity SkipVerbosity) {
+	c.skipVerbosity = verbosity
+}
+
+// skipOrder reports an order blocked by the checks of the controller, it is not counted as a failure
+func (c *Controller) skipOrder(side model.SideType, pair string, err error) {
+	log.Warn(err)
+	c.reportSkip(side, pair, err)
+}
+
+// reportSkip reports an order not created with its reason code, see SetSkipVerbosity
+func (c *Controller) reportSkip(side model.SideType, pair string, err error) {
+	if c.skipVerbosity == SkipSilent {
+		return
+	}
+
+	reason := SkipReasonOf(err)
+	log.WithFields(log.Fields{
+		"pair":   pair,
+		"side":   side,
+		"reason": reason,
+	}).Infof("[SKIPPED] %s %s: %v", side, pair, err)
+
+	if c.skipVerbosity >= SkipNotify && c.notifier != nil {
+		c.notifier.Notify(fmt.Sprintf("[SKIPPED] %s %s (%s): %v", side, pair, reason, err))
+	}
+}
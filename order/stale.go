@@ -0,0 +1,59 @@
+package order
+
+import (
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// SetOrderStaleCancel cancels the open limit orders of a pair when their price is more than the given distance
+// from the market (e.g. 0.05 for 5%), so a resting order left behind by a big move is not filled at a bad price
+// when the market comes back. The market price is the close of the last candle of the pair, and the orders are
+// checked on each update of the pending orders, in the ticker of live sessions and in Reconcile in backtests.
+// The orders of OCO groups are not canceled, to keep their stop. Zero disables the check of the pair.
+func (c *Controller) SetOrderStaleCancel(pair string, maxDistance float64) {
+	c.mtx.Lock()
+	defer c.unlock()
+
+	if maxDistance <= 0 {
+		delete(c.staleDistance, pair)
+		return
+	}
+
+	if c.staleDistance == nil {
+		c.staleDistance = make(map[string]float64)
+	}
+	c.staleDistance[pair] = maxDistance
+}
+
+// cancelStaleOrders cancels the open orders too far from the market, see SetOrderStaleCancel. The controller
+// lock must be held.
+func (c *Controller) cancelStaleOrders(orders []model.Order) {
+	for _, order := range orders {
+		maxDistance := c.staleDistance[order.Pair]
+		price := c.lastPrice[order.Pair]
+		if maxDistance <= 0 || price <= 0 || order.GroupID != nil {
+			continue
+		}
+
+		if order.Type != model.OrderTypeLimit && order.Type != model.OrderTypeLimitMaker {
+			continue
+		}
+
+		if order.Status != model.OrderStatusTypeNew && order.Status != model.OrderStatusTypePartiallyFilled {
+			continue
+		}
+
+		distance := math.Abs(order.Price-price) / price
+		if distance <= maxDistance {
+			continue
+		}
+
+		log.Warnf("[ORDER] %s %s limit %f stale: %.2f%% from the market price %f", order.Side, order.Pair,
+			order.Price, distance*100, price)
+		if err := c.cancel(order); err != nil {
+			c.notifyError(err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrProfitTarget = errors.New("orders stopped after the profit target")
+
+const (
+	ProfitTargetDaily = "daily"
+	ProfitTargetTotal = "total"
+)
+
+// SetProfitTarget stops the bot once the realized profit reaches the amount, to lock in the gains. The scope is
+// ProfitTargetDaily, the profit of the day in the timezone, nil is UTC, or ProfitTargetTotal, the profit since the
+// start. The profit is in the reporting currency if set, see SetReportingCurrency, otherwise the sum of the
+// profits in the quote of each pair. When reached, it is notified, the following orders fail with
+// ErrProfitTarget and the controller stops after the update of the orders, see Reconcile. The first candle of a
+// new day resets the daily target and starts the controller again. Zero disables the target, the default.
+func (c *Controller) SetProfitTarget(amount float64, scope string, loc *time.Location) error {
+	if scope != ProfitTargetDaily && scope != ProfitTargetTotal {
+		return fmt.Errorf("invalid profit target scope: %s", scope)
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	c.mtx.Lock()
+	defer c.unlock()
+	c.profitTarget = amount
+	c.profitTargetScope = scope
+	c.profitTargetLocation = loc
+	return nil
+}
+
+// TargetProfit returns the realized profit accumulated for the profit target, see SetProfitTarget
+func (c *Controller) TargetProfit() float64 {
+	c.mtx.Lock()
+	defer c.unlock()
+	return c.targetProfit
+}
+
+// checkProfitTarget returns ErrProfitTarget if the profit target was reached, the controller lock must be held
+func (c *Controller) checkProfitTarget() error {
+	if !c.targetReached {
+		return nil
+	}
+	return fmt.Errorf("%w: %.2f of %.2f", ErrProfitTarget, c.targetProfit, c.profitTarget)
+}
+
+// addTargetProfit accumulates the realized profit of a trade and requests the stop of the controller when the
+// target is reached, see stopOnProfitTarget. The controller lock must be held.
+func (c *Controller) addTargetProfit(profit float64) {
+	if c.profitTarget <= 0 {
+		return
+	}
+
+	c.targetProfit += profit
+	if c.targetReached || c.targetProfit < c.profitTarget {
+		return
+	}
+
+	c.targetReached = true
+	c.targetStop = true
+	c.notify(fmt.Sprintf("[TARGET] %s profit target of %.2f reached: %.2f, stopping the bot",
+		c.profitTargetScope, c.profitTarget, c.targetProfit))
+}
+
+// stopOnProfitTarget stops the controller if the profit target was reached while processing the trades, the
+// controller lock must not be held
+func (c *Controller) stopOnProfitTarget() {
+	c.mtx.Lock()
+	stop := c.targetStop
+	c.targetStop = false
+	c.unlock()
+
+	if stop {
+		c.Stop()
+	}
+}
+
+// rollProfitTarget resets the daily target on a new day, the time of the candle in backtest or the current time.
+// It returns true if the controller was stopped by the target and must be started again. The controller lock must
+// be held.
+func (c *Controller) rollProfitTarget(candle model.Candle) bool {
+	if c.profitTarget <= 0 || c.profitTargetScope != ProfitTargetDaily {
+		return false
+	}
+
+	now := time.Now()
+	if c.backtest {
+		now = candle.Time
+	}
+
+	day := now.In(c.profitTargetLocation).Format("2006-01-02")
+	if day == c.targetDay {
+		return false
+	}
+
+	first := c.targetDay == ""
+	c.targetDay = day
+	if first {
+		return false
+	}
+
+	resume := c.targetReached
+	c.targetProfit = 0
+	c.targetReached = false
+	c.targetStop = false
+	if resume {
+		c.notify("[TARGET] new day, resuming the bot")
+	}
+	return resume
+}
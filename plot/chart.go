@@ -39,7 +39,9 @@ type Chart struct {
 	paperWallet     *exchange.PaperWallet
 	scriptContent   string
 	indexHTML       *template.Template
+	reportHTML      *template.Template
 	strategy        strategy.Strategy
+	format          *model.DisplayFormat
 	lastUpdate      time.Time
 }
 
@@ -87,6 +89,9 @@ type drawdown struct {
 	End   time.Time `json:"end"`
 }
 
+// tradeHistoryHeader is the header of the trade list, see orderStringByPair
+var tradeHistoryHeader = []string{"status", "side", "id", "type", "quantity", "price", "total", "created_at"}
+
 type Indicator interface {
 	Name() string
 	Overlay() bool
@@ -338,28 +343,29 @@ func (c *Chart) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (c *Chart) handleData(w http.ResponseWriter, r *http.Request) {
-	pair := r.URL.Query().Get("pair")
-	if pair == "" {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-type", "text/json")
-
+// chartData returns the data of the pair used to render the chart
+func (c *Chart) chartData(pair string) map[string]interface{} {
 	var maxDrawdown *drawdown
+	drawdownValues := make([]assetValue, 0)
 	if c.paperWallet != nil {
 		value, start, end := c.paperWallet.MaxDrawdown()
 		maxDrawdown = &drawdown{
 			Start: start,
 			End:   end,
-			Value: fmt.Sprintf("%.1f", value*100),
+			Value: c.displayFormat().FormatNumber(value*100, 1),
+		}
+
+		for _, value := range c.paperWallet.DrawdownSeries() {
+			drawdownValues = append(drawdownValues, assetValue{
+				Time:  value.Time,
+				Value: value.Value * 100,
+			})
 		}
 	}
 
 	asset, quote := exchange.SplitAssetQuote(pair)
 	assetValues, equityValues := c.equityValuesByPair(pair)
-	err := json.NewEncoder(w).Encode(map[string]interface{}{
+	return map[string]interface{}{
 		"candles":       c.candlesByPair(pair),
 		"indicators":    c.indicatorsByPair(pair),
 		"shapes":        c.shapesByPair(pair),
@@ -368,7 +374,19 @@ func (c *Chart) handleData(w http.ResponseWriter, r *http.Request) {
 		"quote":         quote,
 		"asset":         asset,
 		"max_drawdown":  maxDrawdown,
-	})
+		"drawdown":      drawdownValues,
+	}
+}
+
+func (c *Chart) handleData(w http.ResponseWriter, r *http.Request) {
+	pair := r.URL.Query().Get("pair")
+	if pair == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-type", "text/json")
+	err := json.NewEncoder(w).Encode(c.chartData(pair))
 	if err != nil {
 		log.Error(err)
 	}
@@ -389,7 +407,7 @@ func (c *Chart) handleTradingHistoryData(w http.ResponseWriter, r *http.Request)
 
 	buffer := bytes.NewBuffer(nil)
 	csvWriter := csv.NewWriter(buffer)
-	err := csvWriter.Write([]string{"status", "side", "id", "type", "quantity", "price", "total", "created_at"})
+	err := csvWriter.Write(tradeHistoryHeader)
 	if err != nil {
 		log.Errorf("failed writing header file: %s", err.Error())
 		w.WriteHeader(http.StatusBadRequest)
@@ -466,6 +484,22 @@ func WithCustomIndicators(indicators ...Indicator) Option {
 	}
 }
 
+// WithDisplayFormat sets the format of the values of the chart and the report, the default is the
+// package-level format, see `model.SetDisplayFormat`
+func WithDisplayFormat(format model.DisplayFormat) Option {
+	return func(chart *Chart) {
+		chart.format = &format
+	}
+}
+
+// displayFormat returns the display format of the chart, see WithDisplayFormat
+func (c *Chart) displayFormat() model.DisplayFormat {
+	if c.format != nil {
+		return *c.format
+	}
+	return model.CurrentDisplayFormat()
+}
+
 func NewChart(options ...Option) (*Chart, error) {
 	chart := &Chart{
 		port:            8080,
@@ -489,6 +523,11 @@ func NewChart(options ...Option) (*Chart, error) {
 		return nil, err
 	}
 
+	chart.reportHTML, err = template.ParseFS(staticFiles, "assets/report.html")
+	if err != nil {
+		return nil, err
+	}
+
 	transpileChartJS := api.Transform(string(chartJS), api.TransformOptions{
 		Loader:            api.LoaderJS,
 		Target:            api.ES2015,
@@ -0,0 +1,58 @@
+package indicator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/indicator"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/plot"
+)
+
+// PercentRank plots the percentile of the close within its trailing window, see indicator.PercentRank
+func PercentRank(period int, color string) plot.Indicator {
+	return &percentRank{
+		Period: period,
+		Color:  color,
+	}
+}
+
+type percentRank struct {
+	Period int
+	Color  string
+	Values model.Series[float64]
+	Time   []time.Time
+}
+
+func (p percentRank) Warmup() int {
+	return p.Period
+}
+
+func (p percentRank) Name() string {
+	return fmt.Sprintf("PercentRank(%d)", p.Period)
+}
+
+func (p percentRank) Overlay() bool {
+	return false
+}
+
+func (p *percentRank) Load(dataframe *model.Dataframe) {
+	if len(dataframe.Time) < p.Period {
+		return
+	}
+
+	warmup := p.Period - 1
+	p.Values = indicator.PercentRank(dataframe.Close, p.Period)[warmup:]
+	p.Time = dataframe.Time[warmup:]
+}
+
+func (p percentRank) Metrics() []plot.IndicatorMetric {
+	return []plot.IndicatorMetric{
+		{
+			Style:  "line",
+			Color:  p.Color,
+			Values: p.Values,
+			Time:   p.Time,
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package plot
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/order"
+)
+
+type reportMetric struct {
+	Name  string
+	Value string
+}
+
+type reportPair struct {
+	Pair    string
+	Summary string
+	Chart   string
+	Orders  [][]string
+}
+
+// ExportReport writes a single HTML report with the chart, the summary and the trade list of each pair.
+// The summaries are taken from the results of the controller, which is optional. With a paper wallet,
+// the report also includes the portfolio metrics and the equity and drawdown curves.
+// Plotly is loaded from its CDN, all the other data and scripts are embedded in the file.
+func (c *Chart) ExportReport(w io.Writer, controller *order.Controller) error {
+	c.Lock()
+	defer c.Unlock()
+
+	pairs := make([]string, 0, len(c.candles))
+	for pair := range c.candles {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	sections := make([]reportPair, 0, len(pairs))
+	for _, pair := range pairs {
+		data, err := json.Marshal(c.chartData(pair))
+		if err != nil {
+			return err
+		}
+
+		section := reportPair{
+			Pair:   pair,
+			Chart:  string(data),
+			Orders: c.orderStringByPair(pair),
+		}
+
+		if controller != nil {
+			if result, ok := controller.Results[pair]; ok {
+				section.Summary = result.String()
+			}
+		}
+
+		sections = append(sections, section)
+	}
+
+	return c.reportHTML.Execute(w, map[string]interface{}{
+		"metrics": c.reportMetrics(),
+		"pairs":   sections,
+		"header":  tradeHistoryHeader,
+		"script":  template.JS(c.scriptContent),
+	})
+}
+
+// reportMetrics returns the portfolio metrics of the paper wallet, if any
+func (c *Chart) reportMetrics() []reportMetric {
+	if c.paperWallet == nil {
+		return nil
+	}
+
+	equity := c.paperWallet.EquityValues()
+	if len(equity) == 0 {
+		return nil
+	}
+
+	initial, final := equity[0].Value, equity[len(equity)-1].Value
+	maxDrawdown, start, end := c.paperWallet.MaxDrawdown()
+	format := c.displayFormat()
+
+	profit := "-"
+	if initial > 0 {
+		profit = format.FormatPercent((final/initial-1)*100, 2)
+	}
+
+	return []reportMetric{
+		{Name: "Start", Value: equity[0].Time.String()},
+		{Name: "End", Value: equity[len(equity)-1].Time.String()},
+		{Name: "Initial equity", Value: format.FormatValue(initial, 2, "")},
+		{Name: "Final equity", Value: format.FormatValue(final, 2, "")},
+		{Name: "Return", Value: profit},
+		{Name: "Max drawdown", Value: fmt.Sprintf("%s (%s - %s)", format.FormatPercent(maxDrawdown*100, 1), start,
+			end)},
+	}
+}
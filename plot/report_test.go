@@ -0,0 +1,88 @@
+package plot
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var (
+	scriptRegexp    = regexp.MustCompile(`(?s)<script>.*?</script>`)
+	chartDataRegexp = regexp.MustCompile(`data-chart="[^"]*"`)
+	tagRegexp       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// htmlStructure returns the tags of the document, ignoring the text, the inline script and the chart data
+func htmlStructure(document string) string {
+	document = scriptRegexp.ReplaceAllString(document, "<script></script>")
+	document = chartDataRegexp.ReplaceAllString(document, "data-chart")
+	return strings.Join(tagRegexp.FindAllString(document, -1), "\n") + "\n"
+}
+
+func TestChart_ExportReport(t *testing.T) {
+	ctx := context.Background()
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := order.NewController(ctx, wallet, orderStorage, order.NewOrderFeed())
+	c, err := NewChart(WithPaperWallet(wallet),
+		WithDisplayFormat(model.DisplayFormat{Precision: -1, ThousandsSeparator: ","}))
+	require.NoError(t, err)
+
+	start := time.Date(2021, 9, 26, 20, 0, 0, 0, time.UTC)
+	for i, trade := range []struct {
+		side  model.SideType
+		price float64
+	}{
+		{side: model.SideTypeBuy, price: 3000},
+		{side: model.SideTypeSell, price: 3100},
+	} {
+		candle := model.Candle{
+			Pair:     "ETHUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Open:     trade.price,
+			Close:    trade.price,
+			Low:      trade.price,
+			High:     trade.price,
+			Complete: true,
+		}
+		wallet.OnCandle(candle)
+		c.OnCandle(candle)
+
+		o, err := controller.CreateOrderMarket(trade.side, "ETHUSDT", 0.5)
+		require.NoError(t, err)
+		c.OnOrder(o)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, c.ExportReport(buffer, controller))
+
+	report := buffer.String()
+	require.Contains(t, report, "% Win")
+	require.Contains(t, report, "data-chart=")
+	require.Contains(t, report, "3,000.00")
+
+	golden := "testdata/report.golden"
+	if *update {
+		require.NoError(t, os.WriteFile(golden, []byte(htmlStructure(report)), 0600))
+	}
+
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), htmlStructure(report))
+}
@@ -22,15 +22,52 @@ type Feeder interface {
 	CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle, chan error)
 }
 
+// OrderBook is implemented by exchanges that provide the best prices of the order book,
+// e.g. to peg limit orders with `order.Controller.CreateOrderLimitPegged`
+type OrderBook interface {
+	BestBidAsk(ctx context.Context, pair string) (bid, ask float64, err error)
+}
+
+// MarkPrice is implemented by futures exchanges that stream the mark price of a contract, the price used for
+// the liquidations and the funding instead of the last trade
+type MarkPrice interface {
+	MarkPriceSubscription(ctx context.Context, pair string) (chan float64, chan error)
+}
+
+// OrderHistory is implemented by exchanges that list the recent orders of a pair, from the oldest to the newest,
+// e.g. to rebuild the entry price of a position with `order.Controller.ResumePositions`
+type OrderHistory interface {
+	Orders(pair string, limit int) ([]model.Order, error)
+}
+
+// BreakEven is implemented by brokers that calculate the price at which the position of a pair breaks even
+// after the fees of the entry and the exit, e.g. the paper wallet and `order.Controller`
+type BreakEven interface {
+	BreakEvenPrice(pair string) (float64, error)
+}
+
+// Positions is implemented by brokers that list the open positions of all pairs, e.g. for a strategy that
+// limits the number of concurrent positions. The quantities are negative for short positions.
+type Positions interface {
+	OpenPositionCount() int
+	OpenPositions() map[string]float64
+}
+
 type Broker interface {
 	Account() (model.Account, error)
 	Position(pair string) (asset, quote float64, err error)
 	Order(pair string, id int64) (model.Order, error)
 	CreateOrderOCO(side model.SideType, pair string, size, price, stop, stopLimit float64) ([]model.Order, error)
 	CreateOrderLimit(side model.SideType, pair string, size float64, limit float64) (model.Order, error)
+	CreateOrderLimitTIF(side model.SideType, pair string, size float64, limit float64,
+		tif model.TimeInForceType) (model.Order, error)
 	CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error)
 	CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order, error)
-	CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error)
+	CreateOrderMarketTagged(side model.SideType, pair string, size float64, tag string) (model.Order, error)
+	BuyPercent(pair string, percent float64) (model.Order, error)
+	SellPercent(pair string, percent float64) (model.Order, error)
+	ClosePosition(pair string, fraction float64) (model.Order, error)
+	CreateOrderStop(side model.SideType, pair string, quantity float64, limit float64) (model.Order, error)
 	Cancel(model.Order) error
 }
 
@@ -0,0 +1,121 @@
+package ninjabot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// SnapshotVersion is the version of the snapshot format written by SaveSnapshot.
+// It must be increased on incompatible changes of the format.
+const SnapshotVersion = 1
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+
+// Snapshot is the state at the end of a run, used to start another run without the warmup period,
+// e.g. a live run that continues a backtest. It is stored as JSON.
+//
+// The candles of each pair are limited to the history size of the strategy, the indicators are
+// recomputed from the candles when the snapshot is loaded. The wallet is only set with a paper wallet.
+type Snapshot struct {
+	Version   int                       `json:"version"`
+	CreatedAt time.Time                 `json:"created_at"`
+	Timeframe string                    `json:"timeframe"`
+	Candles   map[string][]model.Candle `json:"candles"`
+	Wallet    *exchange.WalletState     `json:"wallet,omitempty"`
+}
+
+// Snapshot returns the current state of the bot, see SaveSnapshot
+func (n *NinjaBot) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now(),
+		Timeframe: n.strategy.Timeframe(),
+		Candles:   make(map[string][]model.Candle, len(n.settings.Pairs)),
+	}
+
+	for _, f := range n.feeds {
+		if !f.primary {
+			continue
+		}
+
+		pair, controller := f.pair, f.controller
+		size := controller.HistorySize()
+		if n.warmupCandles > size {
+			size = n.warmupCandles
+		}
+
+		df := controller.Dataframe()
+		start := len(df.Time) - size
+		if start < 0 {
+			start = 0
+		}
+
+		candles := make([]model.Candle, 0, len(df.Time)-start)
+		for i := start; i < len(df.Time); i++ {
+			candles = append(candles, model.Candle{
+				Pair:     pair,
+				Time:     df.Time[i],
+				Open:     df.Open[i],
+				Close:    df.Close[i],
+				Low:      df.Low[i],
+				High:     df.High[i],
+				Volume:   df.Volume[i],
+				Complete: true,
+			})
+		}
+		snapshot.Candles[pair] = candles
+	}
+
+	if n.paperWallet != nil {
+		state := n.paperWallet.State()
+		snapshot.Wallet = &state
+	}
+
+	return snapshot
+}
+
+// SaveSnapshot writes the state of the bot to a file, e.g. at the end of a backtest.
+// The file can be loaded with LoadSnapshot and used in the next run with WithSnapshot.
+func (n *NinjaBot) SaveSnapshot(path string) error {
+	content, err := json.MarshalIndent(n.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot, it returns ErrSnapshotVersion if the file
+// was written by an incompatible version
+func LoadSnapshot(path string) (*Snapshot, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: %d, expected %d", ErrSnapshotVersion, snapshot.Version, SnapshotVersion)
+	}
+
+	return snapshot, nil
+}
+
+// WithSnapshot fills the strategy with the candles of a snapshot instead of the warmup candles of the
+// exchange. Candles between the end of the snapshot and the startup are fetched from the exchange.
+// To restore the positions of a paper wallet, use `exchange.WithPaperState(*snapshot.Wallet)`.
+func WithSnapshot(snapshot *Snapshot) Option {
+	return func(bot *NinjaBot) {
+		bot.snapshot = snapshot
+	}
+}
@@ -3,16 +3,23 @@ package storage
 import (
 	"encoding/json"
 	"log"
+	"sort"
 	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/tidwall/buntdb"
 )
 
+// equityPrefix is the prefix of the keys of the equity snapshots, the orders are keyed by their ID
+const equityPrefix = "equity:"
+
 type Bunt struct {
-	lastID int64
-	db     *buntdb.DB
+	lastID       int64
+	lastEquityID int64
+	db           *buntdb.DB
 }
 
 func FromMemory() (Storage, error) {
@@ -74,6 +81,10 @@ func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	orders := make([]*model.Order, 0)
 	err := b.db.View(func(tx *buntdb.Tx) error {
 		err := tx.Ascend("update_index", func(key, value string) bool {
+			if strings.HasPrefix(key, equityPrefix) {
+				return true
+			}
+
 			var order model.Order
 			err := json.Unmarshal([]byte(value), &order)
 			if err != nil {
@@ -98,3 +109,45 @@ func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	}
 	return orders, nil
 }
+
+// CreateEquity stores a snapshot of the equity, see EquityStorage
+func (b *Bunt) CreateEquity(snapshot *model.EquitySnapshot) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		snapshot.ID = atomic.AddInt64(&b.lastEquityID, 1)
+		content, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(equityPrefix+strconv.FormatInt(snapshot.ID, 10), string(content), nil)
+		return err
+	})
+}
+
+// Equities returns the snapshots of the equity with time in [start, end], sorted by time
+func (b *Bunt) Equities(start, end time.Time) ([]model.EquitySnapshot, error) {
+	snapshots := make([]model.EquitySnapshot, 0)
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(equityPrefix+"*", func(_, value string) bool {
+			var snapshot model.EquitySnapshot
+			if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
+				log.Println(err)
+				return true
+			}
+
+			if !snapshot.Time.Before(start) && !snapshot.Time.After(end) {
+				snapshots = append(snapshots, snapshot)
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the times are compared after parsing, their text is not sortable across time zones
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
@@ -23,4 +23,5 @@ func TestNewBunt(t *testing.T) {
 	require.NoError(t, err)
 
 	storageUseCase(repo, t)
+	equityUseCase(repo, t)
 }
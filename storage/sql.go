@@ -35,7 +35,7 @@ func FromSQL(dialect gorm.Dialector, opts ...gorm.Option) (Storage, error) {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	err = db.AutoMigrate(&model.Order{})
+	err = db.AutoMigrate(&model.Order{}, &model.EquitySnapshot{})
 	if err != nil {
 		return nil, err
 	}
@@ -78,3 +78,18 @@ func (s *SQL) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 		return true
 	}), nil
 }
+
+// CreateEquity stores a snapshot of the equity, see EquityStorage
+func (s *SQL) CreateEquity(snapshot *model.EquitySnapshot) error {
+	return s.db.Create(snapshot).Error
+}
+
+// Equities returns the snapshots of the equity with time in [start, end], sorted by time
+func (s *SQL) Equities(start, end time.Time) ([]model.EquitySnapshot, error) {
+	snapshots := make([]model.EquitySnapshot, 0)
+	result := s.db.Where("time >= ? AND time <= ?", start, end).Order("time").Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return snapshots, nil
+}
@@ -14,6 +14,14 @@ type Storage interface {
 	Orders(filters ...OrderFilter) ([]*model.Order, error)
 }
 
+// EquityStorage is implemented by storages that keep the equity history of live bots, see
+// order.Controller.SetEquitySampling
+type EquityStorage interface {
+	CreateEquity(snapshot *model.EquitySnapshot) error
+	// Equities returns the snapshots with time in [start, end], sorted by time
+	Equities(start, end time.Time) ([]model.EquitySnapshot, error)
+}
+
 func WithStatusIn(status ...model.OrderStatusType) OrderFilter {
 	return func(order model.Order) bool {
 		for _, s := range status {
@@ -37,6 +45,13 @@ func WithPair(pair string) OrderFilter {
 	}
 }
 
+// WithAccount filters the orders of an account, the default account has an empty name
+func WithAccount(account string) OrderFilter {
+	return func(order model.Order) bool {
+		return order.Account == account
+	}
+}
+
 func WithUpdateAtBeforeOrEqual(time time.Time) OrderFilter {
 	return func(order model.Order) bool {
 		return !order.UpdatedAt.After(time)
@@ -36,6 +36,7 @@ func storageUseCase(repo Storage, t *testing.T) {
 		Quantity:   1,
 		CreatedAt:  now.Add(time.Minute),
 		UpdatedAt:  now.Add(time.Minute),
+		Tag:        "EMA8>SMA21",
 	}
 	err = repo.CreateOrder(secondOrder)
 	require.NoError(t, err)
@@ -69,6 +70,13 @@ func storageUseCase(repo Storage, t *testing.T) {
 		require.Equal(t, orders[0].ID, secondOrder.ID)
 	})
 
+	t.Run("tag", func(t *testing.T) {
+		orders, err := repo.Orders(WithPair("ETHUSDT"))
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, "EMA8>SMA21", orders[0].Tag)
+	})
+
 	t.Run("update", func(t *testing.T) {
 		firstOrder.Status = model.OrderStatusTypeCanceled
 		err := repo.UpdateOrder(firstOrder)
@@ -82,3 +90,30 @@ func storageUseCase(repo Storage, t *testing.T) {
 		require.Equal(t, firstOrder.Quantity, orders[0].Quantity)
 	})
 }
+
+func equityUseCase(repo Storage, t *testing.T) {
+	t.Helper()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	equityStorage, ok := repo.(EquityStorage)
+	require.True(t, ok)
+	for i, value := range []float64{1000, 1100, 1050} {
+		snapshot := &model.EquitySnapshot{Time: start.Add(time.Duration(i) * time.Hour), Value: value}
+		require.NoError(t, equityStorage.CreateEquity(snapshot))
+		require.NotZero(t, snapshot.ID)
+	}
+
+	snapshots, err := equityStorage.Equities(start.Add(time.Hour), start.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, 1100.0, snapshots[0].Value)
+	require.True(t, snapshots[0].Time.Equal(start.Add(time.Hour)))
+	require.Equal(t, 1050.0, snapshots[1].Value)
+
+	// the snapshots are not orders
+	orders, err := repo.Orders()
+	require.NoError(t, err)
+	for _, order := range orders {
+		require.NotZero(t, order.ExchangeID)
+	}
+}
@@ -0,0 +1,43 @@
+package strategy
+
+import (
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// DefaultTimeframe is the timeframe of a Base strategy without interval
+const DefaultTimeframe = "1h"
+
+// Base implements the configuration methods of Strategy with defaults, so a simple strategy embeds it and only
+// implements `OnCandle`, eg:
+//
+//	type MyStrategy struct {
+//		strategy.Base
+//	}
+//
+//	s := &MyStrategy{Base: strategy.Base{Interval: "4h", Warmup: 21}}
+//
+// The methods can still be overridden by the strategy, eg: `Indicators` to plot its indicators.
+type Base struct {
+	// Interval is the timeframe of the strategy, eg: 1h, 1d, 1w. Default is DefaultTimeframe.
+	Interval string
+	// Warmup is the number of candles loaded before the strategy is executed, see `Strategy.WarmupPeriod`.
+	Warmup int
+}
+
+// Timeframe returns the interval of the strategy, or DefaultTimeframe if it is not set
+func (b Base) Timeframe() string {
+	if b.Interval == "" {
+		return DefaultTimeframe
+	}
+	return b.Interval
+}
+
+// WarmupPeriod returns the warmup of the strategy, zero to execute it from the first candle
+func (b Base) WarmupPeriod() int {
+	return b.Warmup
+}
+
+// Indicators does not fill indicators or plot charts
+func (b Base) Indicators(_ *model.Dataframe) []ChartIndicator {
+	return nil
+}
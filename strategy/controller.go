@@ -1,6 +1,8 @@
 package strategy
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -12,6 +14,13 @@ type Controller struct {
 	dataframe *model.Dataframe
 	broker    service.Broker
 	started   bool
+	observers []Observer
+	policy    model.CandlePolicy
+	buffer    int
+
+	// last candle used to compute the indicators
+	indicatorsCandle   model.Candle
+	indicatorsComputed bool
 }
 
 func NewStrategyController(pair string, strategy Strategy, broker service.Broker) *Controller {
@@ -27,20 +36,148 @@ func NewStrategyController(pair string, strategy Strategy, broker service.Broker
 	}
 }
 
+// HistorySize returns the number of candles necessary to fill the strategy indicators,
+// the greater value between `WarmupPeriod` and `MaxHistory`
+func (s *Controller) HistorySize() int {
+	size := s.strategy.WarmupPeriod()
+	if str, ok := s.strategy.(HistoryStrategy); ok && str.MaxHistory() > size {
+		size = str.MaxHistory()
+	}
+	return size
+}
+
+// AddObserver registers observers of the candles and orders of the pair, they are executed
+// after the strategy in the order of registration. It must be called before Start.
+func (s *Controller) AddObserver(observers ...Observer) {
+	s.observers = append(s.observers, observers...)
+}
+
+// OnOrder dispatches an order of the pair to the observers that implement OrderObserver
+func (s *Controller) OnOrder(order model.Order) {
+	for _, observer := range s.observers {
+		if orderObserver, ok := observer.(OrderObserver); ok {
+			orderObserver.OnOrder(order)
+		}
+	}
+}
+
+// SetCandlePolicy sets how candles with zero, negative or NaN prices are handled, default is
+// model.CandlePolicySkip. Invalid candles are logged and never reach the indicators.
+func (s *Controller) SetCandlePolicy(policy model.CandlePolicy) {
+	s.policy = policy
+}
+
+// SetWarmupBuffer requires a number of candles after the warmup period before the strategy trades, e.g. to
+// give the indicators a minimum history after a restart. A positive value replaces the buffer of a
+// BufferStrategy.
+func (s *Controller) SetWarmupBuffer(candles int) {
+	s.buffer = candles
+}
+
+// tradingPeriod returns the number of candles necessary to call the trading logic, the warmup period plus
+// the buffer, see SetWarmupBuffer
+func (s *Controller) tradingPeriod() int {
+	buffer := s.buffer
+	if str, ok := s.strategy.(BufferStrategy); ok && buffer == 0 {
+		buffer = str.WarmupBuffer()
+	}
+	return s.strategy.WarmupPeriod() + buffer
+}
+
+// SetTimezone sets the timezone of the daily sessions in the dataframe, see `model.Dataframe.Location`
+func (s *Controller) SetTimezone(loc *time.Location) {
+	s.dataframe.Location = loc
+}
+
+// Dataframe returns the candles and indicators of the pair, it must not be modified
+func (s *Controller) Dataframe() *model.Dataframe {
+	return s.dataframe
+}
+
 func (s *Controller) Start() {
 	s.started = true
 }
 
 func (s *Controller) OnPartialCandle(candle model.Candle) {
+	candle, ok := s.sanitize(candle)
+	if !ok {
+		return
+	}
+
 	if !candle.Complete && len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		if str, ok := s.strategy.(HighFrequencyStrategy); ok {
 			s.updateDataFrame(candle)
-			str.Indicators(s.dataframe)
-			str.OnPartialCandle(s.dataframe, s.broker)
+			s.updateIndicators(candle)
+			if len(s.dataframe.Close) >= s.tradingPeriod() {
+				str.OnPartialCandle(s.dataframe, s.broker)
+			}
 		}
 	}
 }
 
+// sanitize applies the candle policy to an invalid candle, filling it with the close of the last bar
+// of the dataframe. It returns false if the candle must be discarded.
+func (s *Controller) sanitize(candle model.Candle) (model.Candle, bool) {
+	if candle.Valid() {
+		return candle, true
+	}
+
+	var previous *model.Candle
+	if last := len(s.dataframe.Close) - 1; last >= 0 {
+		previous = &model.Candle{
+			Pair:   s.dataframe.Pair,
+			Time:   s.dataframe.Time[last],
+			Open:   s.dataframe.Open[last],
+			Close:  s.dataframe.Close[last],
+			Low:    s.dataframe.Low[last],
+			High:   s.dataframe.High[last],
+			Volume: s.dataframe.Volume[last],
+		}
+	}
+
+	result, ok, err := s.policy.Apply(candle, previous)
+	switch {
+	case err != nil:
+		log.Errorf("%v: %#v", err, candle)
+	case ok:
+		log.Warnf("invalid candle filled: %#v", candle)
+	default:
+		log.Warnf("invalid candle skipped: %#v", candle)
+	}
+
+	return result, ok
+}
+
+// updateIndicators fills the indicators of the dataframe, skipping the computation when the candle
+// has the same values of the last computation, eg: repeated partial candles in live mode.
+func (s *Controller) updateIndicators(candle model.Candle) {
+	if str, ok := s.strategy.(RecomputeStrategy); !ok || !str.RecomputeIndicators() {
+		if s.indicatorsComputed && sameBar(s.indicatorsCandle, candle) {
+			return
+		}
+	}
+
+	s.strategy.Indicators(s.dataframe)
+	s.indicatorsCandle = candle
+	s.indicatorsComputed = true
+}
+
+// sameBar returns true if both candles have the same time and values
+func sameBar(a, b model.Candle) bool {
+	if !a.Time.Equal(b.Time) || a.Open != b.Open || a.Close != b.Close ||
+		a.Low != b.Low || a.High != b.High || a.Volume != b.Volume || len(a.Metadata) != len(b.Metadata) {
+		return false
+	}
+
+	for key, value := range a.Metadata {
+		if other, ok := b.Metadata[key]; !ok || other != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (s *Controller) updateDataFrame(candle model.Candle) {
 	if len(s.dataframe.Time) > 0 && candle.Time.Equal(s.dataframe.Time[len(s.dataframe.Time)-1]) {
 		last := len(s.dataframe.Time) - 1
@@ -73,12 +210,20 @@ func (s *Controller) OnCandle(candle model.Candle) {
 		return
 	}
 
+	candle, ok := s.sanitize(candle)
+	if !ok {
+		return
+	}
+
 	s.updateDataFrame(candle)
 
 	if len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
-		s.strategy.Indicators(s.dataframe)
-		if s.started {
+		s.updateIndicators(candle)
+		if s.started && len(s.dataframe.Close) >= s.tradingPeriod() {
 			s.strategy.OnCandle(s.dataframe, s.broker)
+			for _, observer := range s.observers {
+				observer.OnCandle(s.dataframe, s.broker)
+			}
 		}
 	}
 }
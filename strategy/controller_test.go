@@ -0,0 +1,225 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type fakeStrategy struct {
+	recompute  bool
+	indicators int
+}
+
+func (f *fakeStrategy) Timeframe() string {
+	return "1m"
+}
+
+func (f *fakeStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (f *fakeStrategy) Indicators(df *model.Dataframe) []ChartIndicator {
+	// only counts the computations, the indicators of talib require a longer history than the tests
+	f.indicators++
+	return nil
+}
+
+func (f *fakeStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {}
+
+func (f *fakeStrategy) OnPartialCandle(_ *model.Dataframe, _ service.Broker) {}
+
+func (f *fakeStrategy) RecomputeIndicators() bool {
+	return f.recompute
+}
+
+func newCandle(t time.Time, price float64, complete bool) model.Candle {
+	return model.Candle{Time: t, Open: price, Close: price, Low: price, High: price, Complete: complete}
+}
+
+func TestController_IndicatorsCache(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("unchanged bar", func(t *testing.T) {
+		strategy := &fakeStrategy{}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+		controller.Start()
+
+		controller.OnCandle(newCandle(start, 10, true))
+		require.Equal(t, 1, strategy.indicators)
+
+		partial := newCandle(start.Add(time.Minute), 11, false)
+		controller.OnPartialCandle(partial)
+		controller.OnPartialCandle(partial)
+		require.Equal(t, 2, strategy.indicators)
+
+		// changed partial candle
+		partial.Close = 12
+		controller.OnPartialCandle(partial)
+		require.Equal(t, 3, strategy.indicators)
+
+		// candle closed with the same values of the last partial candle
+		partial.Complete = true
+		controller.OnCandle(partial)
+		require.Equal(t, 3, strategy.indicators)
+		require.Len(t, controller.dataframe.Close, 2)
+	})
+
+	t.Run("recompute", func(t *testing.T) {
+		strategy := &fakeStrategy{recompute: true}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+		controller.OnCandle(newCandle(start, 10, true))
+
+		partial := newCandle(start.Add(time.Minute), 11, false)
+		controller.OnPartialCandle(partial)
+		controller.OnPartialCandle(partial)
+		require.Equal(t, 3, strategy.indicators)
+	})
+}
+
+func TestController_CandlePolicy(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := []model.Candle{
+		newCandle(start, 10, true),
+		{Time: start.Add(time.Minute), Open: 10, Close: math.NaN(), Low: 10, High: 10, Complete: true},
+		newCandle(start.Add(2*time.Minute), 0, true),
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		strategy := &fakeStrategy{}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+		for _, candle := range candles {
+			controller.OnCandle(candle)
+			controller.OnPartialCandle(candle)
+		}
+
+		require.Equal(t, []float64{10}, controller.dataframe.Close.Values())
+		require.Equal(t, 1, strategy.indicators)
+	})
+
+	t.Run("forward fill", func(t *testing.T) {
+		strategy := &fakeStrategy{}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+		controller.SetCandlePolicy(model.CandlePolicyFill)
+		for _, candle := range candles {
+			controller.OnCandle(candle)
+		}
+
+		require.Equal(t, []float64{10, 10, 10}, controller.dataframe.Close.Values())
+		require.Equal(t, 3, strategy.indicators)
+	})
+}
+
+func benchmarkPartialCandles(b *testing.B, recompute bool) {
+	strategy := &fakeStrategy{recompute: recompute}
+	controller := NewStrategyController("BTCUSDT", strategy, nil)
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 500; i++ {
+		controller.OnCandle(newCandle(start.Add(time.Duration(i)*time.Minute), float64(i+1), false))
+	}
+
+	// ticks of a live bar, most of them without changes in the price
+	partial := newCandle(start.Add(500*time.Minute), 500, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		partial.Close = float64(500 + i/10)
+		controller.OnPartialCandle(partial)
+	}
+}
+
+func BenchmarkController_PartialCandle(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		benchmarkPartialCandles(b, false)
+	})
+	b.Run("recompute", func(b *testing.B) {
+		benchmarkPartialCandles(b, true)
+	})
+}
+
+type baseStrategy struct {
+	Base
+	candles int
+}
+
+func (b *baseStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {
+	b.candles++
+}
+
+func TestBase(t *testing.T) {
+	var _ Strategy = &baseStrategy{}
+
+	require.Equal(t, DefaultTimeframe, Base{}.Timeframe())
+	require.Equal(t, 0, Base{}.WarmupPeriod())
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	strategy := &baseStrategy{Base: Base{Interval: "4h", Warmup: 3}}
+	require.Equal(t, "4h", strategy.Timeframe())
+
+	controller := NewStrategyController("BTCUSDT", strategy, nil)
+	controller.Start()
+	for i := 0; i < 5; i++ {
+		controller.OnCandle(newCandle(start.Add(time.Duration(i)*4*time.Hour), 10, true))
+	}
+
+	// executed after the warmup of 3 candles
+	require.Equal(t, 3, strategy.candles)
+}
+
+type bufferStrategy struct {
+	baseStrategy
+	buffer int
+}
+
+func (b *bufferStrategy) WarmupBuffer() int {
+	return b.buffer
+}
+
+func TestController_WarmupBuffer(t *testing.T) {
+	var _ BufferStrategy = &bufferStrategy{}
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	run := func(controller *Controller, live int) {
+		// restart: the preload stops at the warmup period
+		for i := 0; i < 3; i++ {
+			controller.OnCandle(newCandle(start.Add(time.Duration(i)*time.Hour), 10, true))
+		}
+		controller.Start()
+		for i := 3; i < 3+live; i++ {
+			controller.OnCandle(newCandle(start.Add(time.Duration(i)*time.Hour), 10, true))
+		}
+	}
+
+	t.Run("strategy buffer", func(t *testing.T) {
+		strategy := &bufferStrategy{baseStrategy: baseStrategy{Base: Base{Warmup: 3}}, buffer: 2}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+
+		// trading is disabled until warmup + buffer candles
+		run(controller, 1)
+		require.Zero(t, strategy.candles)
+		controller.OnCandle(newCandle(start.Add(4*time.Hour), 10, true))
+		require.Equal(t, 1, strategy.candles)
+	})
+
+	t.Run("controller buffer", func(t *testing.T) {
+		strategy := &bufferStrategy{baseStrategy: baseStrategy{Base: Base{Warmup: 3}}, buffer: 2}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+		controller.SetWarmupBuffer(3)
+
+		run(controller, 3)
+		require.Equal(t, 1, strategy.candles)
+	})
+
+	t.Run("without buffer", func(t *testing.T) {
+		strategy := &baseStrategy{Base: Base{Warmup: 3}}
+		controller := NewStrategyController("BTCUSDT", strategy, nil)
+
+		run(controller, 1)
+		require.Equal(t, 1, strategy.candles)
+	})
+}
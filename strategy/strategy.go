@@ -18,6 +18,50 @@ type Strategy interface {
 	OnCandle(df *model.Dataframe, broker service.Broker)
 }
 
+// HistoryStrategy is an optional interface for strategies with indicators that need more candles than the
+// warmup period, eg: a SMA(200) in a strategy that can trade after 5 candles.
+type HistoryStrategy interface {
+	Strategy
+
+	// MaxHistory is the number of candles loaded before the bot starts to fill indicators.
+	// Unlike `WarmupPeriod`, it does not delay the trading logic.
+	MaxHistory() int
+}
+
+// BufferStrategy is an optional interface for strategies that need more candles than the warmup period before
+// trading, e.g. indicators that whipsaw with minimal data after a restart where the preload stops at the warmup.
+type BufferStrategy interface {
+	Strategy
+
+	// WarmupBuffer is the number of candles after `WarmupPeriod` before `OnCandle` is called. The indicators
+	// are filled during the buffer.
+	WarmupBuffer() int
+}
+
+// Observer is a tool driven by the bot alongside the strategy, eg: a scheduler of orders.
+// OnCandle is executed for each closed candle of every pair, after the strategy `OnCandle`.
+type Observer interface {
+	OnCandle(df *model.Dataframe, broker service.Broker)
+}
+
+// OrderObserver is an optional interface for observers that also receive the orders of the pairs.
+// OnOrder is executed in the goroutine of the order feed, in the same sequence of creation of the orders.
+type OrderObserver interface {
+	Observer
+
+	OnOrder(order model.Order)
+}
+
+// RecomputeStrategy is an optional interface for strategies with indicators that depend on data outside
+// the dataframe, eg: the clock or an external API. By default, the indicators are not recomputed for
+// candle updates that do not change the last bar of the dataframe.
+type RecomputeStrategy interface {
+	Strategy
+
+	// RecomputeIndicators disables the cache of indicators, calling `Indicators` for every candle update.
+	RecomputeIndicators() bool
+}
+
 type HighFrequencyStrategy interface {
 	Strategy
 
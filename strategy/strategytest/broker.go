@@ -0,0 +1,297 @@
+package strategytest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// Broker is a broker for the tests of strategies, it records the orders of the strategy. The market orders are
+// filled at the last price of the pair, see OnCandle, and change the balances without checking the funds. The
+// limit, stop and OCO orders are recorded as new and never filled.
+type Broker struct {
+	mtx      sync.Mutex
+	counter  int64
+	orders   []model.Order
+	balances map[string]float64
+	prices   map[string]float64
+	time     time.Time
+	err      error
+}
+
+// NewBroker creates a broker with the initial balance of each asset, e.g. {"USDT": 1000}
+func NewBroker(balances map[string]float64) *Broker {
+	broker := &Broker{
+		balances: make(map[string]float64, len(balances)),
+		prices:   make(map[string]float64),
+	}
+	for asset, amount := range balances {
+		broker.balances[asset] = amount
+	}
+	return broker
+}
+
+// OnCandle sets the close of the candle as the last price of the pair, the orders are created at the time
+// of the candle
+func (b *Broker) OnCandle(candle model.Candle) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.prices[candle.Pair] = candle.Close
+	b.time = candle.Time
+}
+
+// SetPrice sets the last price of the pair, the price of the market orders
+func (b *Broker) SetPrice(pair string, price float64) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.prices[pair] = price
+}
+
+// Fail makes the following orders fail with the error, e.g. to test how a strategy handles rejections,
+// nil restores the orders
+func (b *Broker) Fail(err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.err = err
+}
+
+// Orders returns the orders created by the strategy, in the order of creation
+func (b *Broker) Orders() []model.Order {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return append([]model.Order(nil), b.orders...)
+}
+
+// Balance returns the balance of an asset
+func (b *Broker) Balance(asset string) float64 {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.balances[asset]
+}
+
+func (b *Broker) Account() (model.Account, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	balances := make([]model.Balance, 0, len(b.balances))
+	for asset, amount := range b.balances {
+		balances = append(balances, model.Balance{Asset: asset, Free: amount})
+	}
+	return model.Account{Balances: balances}, nil
+}
+
+func (b *Broker) Position(pair string) (asset, quote float64, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	return b.balances[assetTick], b.balances[quoteTick], nil
+}
+
+func (b *Broker) Order(_ string, id int64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for _, order := range b.orders {
+		if order.ExchangeID == id {
+			return order, nil
+		}
+	}
+	return model.Order{}, fmt.Errorf("%w: %d", ErrOrderNotFound, id)
+}
+
+func (b *Broker) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if err := b.validate(size); err != nil {
+		return nil, err
+	}
+
+	b.counter++
+	groupID := b.counter
+	limitMaker := b.newOrder(side, model.OrderTypeLimitMaker, pair, size, price)
+	limitMaker.GroupID = &groupID
+	stopOrder := b.newOrder(side, model.OrderTypeStopLoss, pair, size, stopLimit)
+	stopOrder.Stop = &stop
+	stopOrder.GroupID = &groupID
+	b.orders = append(b.orders, limitMaker, stopOrder)
+	return []model.Order{limitMaker, stopOrder}, nil
+}
+
+func (b *Broker) CreateOrderLimit(side model.SideType, pair string, size float64, limit float64) (model.Order, error) {
+	return b.CreateOrderLimitTIF(side, pair, size, limit, model.TimeInForceGTC)
+}
+
+// CreateOrderLimitTIF records a limit order, the time in force is ignored
+func (b *Broker) CreateOrderLimitTIF(side model.SideType, pair string, size float64, limit float64,
+	_ model.TimeInForceType) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if err := b.validate(size); err != nil {
+		return model.Order{}, err
+	}
+
+	order := b.newOrder(side, model.OrderTypeLimit, pair, size, limit)
+	b.orders = append(b.orders, order)
+	return order, nil
+}
+
+func (b *Broker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return b.CreateOrderMarketTagged(side, pair, size, "")
+}
+
+func (b *Broker) CreateOrderMarketTagged(side model.SideType, pair string, size float64,
+	tag string) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.createOrderMarket(side, pair, size, tag)
+}
+
+func (b *Broker) CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	price, ok := b.prices[pair]
+	if !ok || price <= 0 {
+		return model.Order{}, fmt.Errorf("%w: %s", exchange.ErrNoMarketData, pair)
+	}
+	return b.createOrderMarket(side, pair, quote/price, "")
+}
+
+func (b *Broker) BuyPercent(pair string, percent float64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	price, ok := b.prices[pair]
+	if !ok || price <= 0 {
+		return model.Order{}, fmt.Errorf("%w: %s", exchange.ErrNoMarketData, pair)
+	}
+
+	_, quote := exchange.SplitAssetQuote(pair)
+	return b.createOrderMarket(model.SideTypeBuy, pair, b.balances[quote]*percent/price, "")
+}
+
+func (b *Broker) SellPercent(pair string, percent float64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	asset, _ := exchange.SplitAssetQuote(pair)
+	return b.createOrderMarket(model.SideTypeSell, pair, b.balances[asset]*percent, "")
+}
+
+func (b *Broker) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if fraction <= 0 || fraction > 1 {
+		return model.Order{}, fmt.Errorf("%w: fraction %f out of range (0, 1]", exchange.ErrInvalidQuantity,
+			fraction)
+	}
+
+	asset, _ := exchange.SplitAssetQuote(pair)
+	position := b.balances[asset]
+	if position == 0 {
+		return model.Order{}, exchange.ErrNoPosition
+	}
+
+	side := model.SideTypeSell
+	if position < 0 {
+		side = model.SideTypeBuy
+	}
+	return b.createOrderMarket(side, pair, math.Abs(position)*fraction, "")
+}
+
+func (b *Broker) CreateOrderStop(side model.SideType, pair string, quantity float64,
+	limit float64) (model.Order, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if err := b.validate(quantity); err != nil {
+		return model.Order{}, err
+	}
+
+	order := b.newOrder(side, model.OrderTypeStopLoss, pair, quantity, limit)
+	order.Stop = &limit
+	b.orders = append(b.orders, order)
+	return order, nil
+}
+
+func (b *Broker) Cancel(order model.Order) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for i := range b.orders {
+		if b.orders[i].ExchangeID != order.ExchangeID {
+			continue
+		}
+		if b.orders[i].Status != model.OrderStatusTypeNew {
+			return fmt.Errorf("%w: %d is %s", ErrOrderNotFound, order.ExchangeID, b.orders[i].Status)
+		}
+		b.orders[i].Status = model.OrderStatusTypeCanceled
+		return nil
+	}
+	return fmt.Errorf("%w: %d", ErrOrderNotFound, order.ExchangeID)
+}
+
+func (b *Broker) validate(size float64) error {
+	if b.err != nil {
+		return b.err
+	}
+	if size <= 0 {
+		return exchange.ErrInvalidQuantity
+	}
+	return nil
+}
+
+func (b *Broker) newOrder(side model.SideType, orderType model.OrderType, pair string,
+	size, price float64) model.Order {
+	b.counter++
+	return model.Order{
+		ExchangeID: b.counter,
+		Pair:       pair,
+		Side:       side,
+		Type:       orderType,
+		Status:     model.OrderStatusTypeNew,
+		Price:      price,
+		Quantity:   size,
+		CreatedAt:  b.time,
+		UpdatedAt:  b.time,
+	}
+}
+
+func (b *Broker) createOrderMarket(side model.SideType, pair string, size float64, tag string) (model.Order, error) {
+	if err := b.validate(size); err != nil {
+		return model.Order{}, err
+	}
+
+	price, ok := b.prices[pair]
+	if !ok {
+		return model.Order{}, fmt.Errorf("%w: %s", exchange.ErrNoMarketData, pair)
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	if side == model.SideTypeBuy {
+		b.balances[asset] += size
+		b.balances[quote] -= size * price
+	} else {
+		b.balances[asset] -= size
+		b.balances[quote] += size * price
+	}
+
+	order := b.newOrder(side, model.OrderTypeMarket, pair, size, price)
+	order.Status = model.OrderStatusTypeFilled
+	order.Tag = tag
+	b.orders = append(b.orders, order)
+	return order, nil
+}
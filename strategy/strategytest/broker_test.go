@@ -0,0 +1,93 @@
+package strategytest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+func TestBroker(t *testing.T) {
+	var _ service.Broker = &Broker{}
+
+	broker := NewBroker(map[string]float64{"USDT": 1000})
+	_, err := broker.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, exchange.ErrNoMarketData)
+
+	broker.OnCandle(model.Candle{Pair: "BTCUSDT", Time: DefaultStart, Close: 100})
+
+	t.Run("market orders", func(t *testing.T) {
+		order, err := broker.CreateOrderMarketTagged(model.SideTypeBuy, "BTCUSDT", 2, "entry")
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 100.0, order.Price)
+		require.Equal(t, "entry", order.Tag)
+		require.Equal(t, DefaultStart, order.CreatedAt)
+
+		asset, quote, err := broker.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+		require.Equal(t, 800.0, quote)
+
+		_, err = broker.SellPercent("BTCUSDT", 0.5)
+		require.NoError(t, err)
+		_, err = broker.BuyPercent("BTCUSDT", 0.1)
+		require.NoError(t, err)
+		require.InDelta(t, 1.9, broker.Balance("BTC"), 1e-9)
+		require.InDelta(t, 810, broker.Balance("USDT"), 1e-9)
+
+		_, err = broker.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0)
+		require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+	})
+
+	t.Run("close position", func(t *testing.T) {
+		_, err := broker.ClosePosition("BTCUSDT", 1.5)
+		require.ErrorIs(t, err, exchange.ErrInvalidQuantity)
+		_, err = broker.ClosePosition("ETHUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrNoPosition)
+
+		order, err := broker.ClosePosition("BTCUSDT", 0.5)
+		require.NoError(t, err)
+		require.Equal(t, model.SideTypeSell, order.Side)
+		require.InDelta(t, 0.95, order.Quantity, 1e-9)
+		require.InDelta(t, 0.95, broker.Balance("BTC"), 1e-9)
+	})
+
+	t.Run("pending orders", func(t *testing.T) {
+		limit, err := broker.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, limit.Status)
+
+		oco, err := broker.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 120, 95, 94)
+		require.NoError(t, err)
+		require.Len(t, oco, 2)
+		require.Equal(t, *oco[0].GroupID, *oco[1].GroupID)
+		require.Equal(t, 95.0, *oco[1].Stop)
+
+		require.NoError(t, broker.Cancel(limit))
+		limit, err = broker.Order("BTCUSDT", limit.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, limit.Status)
+		require.ErrorIs(t, broker.Cancel(limit), ErrOrderNotFound)
+
+		_, err = broker.Order("BTCUSDT", 1000)
+		require.ErrorIs(t, err, ErrOrderNotFound)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		rejected := errors.New("rejected")
+		broker.Fail(rejected)
+		_, err := broker.CreateOrderStop(model.SideTypeSell, "BTCUSDT", 1, 90)
+		require.ErrorIs(t, err, rejected)
+
+		broker.Fail(nil)
+		_, err = broker.CreateOrderStop(model.SideTypeSell, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+	})
+
+	require.Len(t, broker.Orders(), 8)
+}
@@ -0,0 +1,200 @@
+// Package strategytest provides utilities to test strategies: a builder of dataframes from OHLCV slices, the
+// simulation of the candles of a strategy and a broker that records the orders.
+package strategytest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+var ErrInvalidData = errors.New("invalid data")
+
+// DefaultStart is the time of the first candle of the dataframes, see DataframeBuilder.Start
+var DefaultStart = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DataframeBuilder builds the dataframe or the candles of a pair from OHLCV slices, the candles are spaced by
+// the timeframe from the start time, e.g.
+//
+//	df, err := strategytest.NewDataframe("BTCUSDT").Close(10, 11, 12, 13).Indicators(strategy)
+type DataframeBuilder struct {
+	pair      string
+	start     time.Time
+	timeframe time.Duration
+	open      []float64
+	high      []float64
+	low       []float64
+	close     []float64
+	volume    []float64
+	metadata  map[string][]float64
+}
+
+// NewDataframe creates a builder of candles of 1h from DefaultStart
+func NewDataframe(pair string) *DataframeBuilder {
+	return &DataframeBuilder{
+		pair:      pair,
+		start:     DefaultStart,
+		timeframe: time.Hour,
+		metadata:  make(map[string][]float64),
+	}
+}
+
+// Start sets the time of the first candle
+func (b *DataframeBuilder) Start(start time.Time) *DataframeBuilder {
+	b.start = start
+	return b
+}
+
+// Timeframe sets the interval between the candles
+func (b *DataframeBuilder) Timeframe(timeframe time.Duration) *DataframeBuilder {
+	b.timeframe = timeframe
+	return b
+}
+
+// OHLCV sets the prices and volume of the candles, the slices must have the same length. A nil volume is zero.
+func (b *DataframeBuilder) OHLCV(open, high, low, close, volume []float64) *DataframeBuilder {
+	b.open, b.high, b.low, b.close, b.volume = open, high, low, close, volume
+	return b
+}
+
+// Close sets the close of the candles, the open, high and low are the close and the volume is zero
+func (b *DataframeBuilder) Close(values ...float64) *DataframeBuilder {
+	return b.OHLCV(values, values, values, values, nil)
+}
+
+// Metadata sets a custom value of the candles, e.g. the data of an external source
+func (b *DataframeBuilder) Metadata(key string, values ...float64) *DataframeBuilder {
+	b.metadata[key] = values
+	return b
+}
+
+// Candles returns the complete candles of the builder, it fails if the slices have different lengths
+func (b *DataframeBuilder) Candles() ([]model.Candle, error) {
+	size := len(b.close)
+	volume := b.volume
+	if volume == nil {
+		volume = make([]float64, size)
+	}
+
+	for name, values := range map[string][]float64{"open": b.open, "high": b.high, "low": b.low,
+		"volume": volume} {
+		if len(values) != size {
+			return nil, fmt.Errorf("%w: %d values of %s, expected %d", ErrInvalidData, len(values), name, size)
+		}
+	}
+
+	for key, values := range b.metadata {
+		if len(values) != size {
+			return nil, fmt.Errorf("%w: %d values of %s, expected %d", ErrInvalidData, len(values), key, size)
+		}
+	}
+
+	candles := make([]model.Candle, 0, size)
+	for i := 0; i < size; i++ {
+		candle := model.Candle{
+			Pair:      b.pair,
+			Time:      b.start.Add(time.Duration(i) * b.timeframe),
+			UpdatedAt: b.start.Add(time.Duration(i+1) * b.timeframe),
+			Open:      b.open[i],
+			Close:     b.close[i],
+			Low:       b.low[i],
+			High:      b.high[i],
+			Volume:    volume[i],
+			Complete:  true,
+		}
+
+		if len(b.metadata) > 0 {
+			candle.Metadata = make(map[string]float64, len(b.metadata))
+			for key, values := range b.metadata {
+				candle.Metadata[key] = values[i]
+			}
+		}
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
+
+// Build returns the dataframe of the candles, without indicators
+func (b *DataframeBuilder) Build() (*model.Dataframe, error) {
+	candles, err := b.Candles()
+	if err != nil {
+		return nil, err
+	}
+
+	df := &model.Dataframe{
+		Pair:     b.pair,
+		Metadata: make(map[string]model.Series[float64]),
+	}
+	for _, candle := range candles {
+		df.Close = append(df.Close, candle.Close)
+		df.Open = append(df.Open, candle.Open)
+		df.High = append(df.High, candle.High)
+		df.Low = append(df.Low, candle.Low)
+		df.Volume = append(df.Volume, candle.Volume)
+		df.Time = append(df.Time, candle.Time)
+		df.LastUpdate = candle.UpdatedAt
+		for key, value := range candle.Metadata {
+			df.Metadata[key] = append(df.Metadata[key], value)
+		}
+	}
+
+	return df, nil
+}
+
+// Indicators returns the dataframe with the indicators of the strategy, computed once over all candles
+func (b *DataframeBuilder) Indicators(str strategy.Strategy) (*model.Dataframe, error) {
+	df, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	str.Indicators(df)
+	return df, nil
+}
+
+// Run feeds the candles to the strategy one by one, as the bot: the indicators are computed with the candles
+// received so far and OnCandle is called after the warmup period. The market orders of the strategy are filled
+// by the broker at the close of each candle. It returns the final dataframe.
+func (b *DataframeBuilder) Run(str strategy.Strategy, broker *Broker) (*model.Dataframe, error) {
+	candles, err := b.Candles()
+	if err != nil {
+		return nil, err
+	}
+
+	controller := strategy.NewStrategyController(b.pair, str, broker)
+	controller.Start()
+	for _, candle := range candles {
+		broker.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	return controller.Dataframe(), nil
+}
+
+// RequireIndicator asserts the last values of an indicator in the metadata of the dataframe, within the delta.
+// A NaN expected value matches only NaN.
+func RequireIndicator(t testing.TB, df *model.Dataframe, name string, delta float64, expected ...float64) {
+	t.Helper()
+
+	values, ok := df.Metadata[name]
+	require.Truef(t, ok, "indicator %s not found", name)
+	require.GreaterOrEqualf(t, len(values), len(expected), "indicator %s has %d values, expected %d",
+		name, len(values), len(expected))
+
+	last := values[len(values)-len(expected):]
+	for i, value := range expected {
+		if math.IsNaN(value) {
+			require.Truef(t, math.IsNaN(last[i]), "indicator %s[%d]: expected NaN, got %f", name, i, last[i])
+			continue
+		}
+		require.InDeltaf(t, value, last[i], delta, "indicator %s[%d]", name, i)
+	}
+}
@@ -0,0 +1,112 @@
+package strategytest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/indicator"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+// crossStrategy buys when the close crosses over the SMA(3) and sells the position when it crosses under
+type crossStrategy struct{}
+
+func (c crossStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (c crossStrategy) WarmupPeriod() int {
+	return 3
+}
+
+func (c crossStrategy) Indicators(df *model.Dataframe) []strategy.ChartIndicator {
+	df.Metadata["sma"] = indicator.SMA(df.Close, 3)
+	return nil
+}
+
+func (c crossStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	asset, _, err := broker.Position(df.Pair)
+	if err != nil {
+		return
+	}
+
+	if asset == 0 && df.Close.Crossover(df.Metadata["sma"]) {
+		_, _ = broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1)
+	}
+	if asset > 0 && df.Close.Crossunder(df.Metadata["sma"]) {
+		_, _ = broker.CreateOrderMarket(model.SideTypeSell, df.Pair, asset)
+	}
+}
+
+func TestDataframeBuilder_Build(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	df, err := NewDataframe("BTCUSDT").
+		Start(start).
+		Timeframe(15*time.Minute).
+		OHLCV([]float64{1, 2}, []float64{3, 4}, []float64{0.5, 1.5}, []float64{2, 3}, []float64{10, 20}).
+		Metadata("signal", 0, 1).
+		Build()
+	require.NoError(t, err)
+
+	require.Equal(t, "BTCUSDT", df.Pair)
+	require.Equal(t, model.Series[float64]{1, 2}, df.Open)
+	require.Equal(t, model.Series[float64]{3, 4}, df.High)
+	require.Equal(t, model.Series[float64]{0.5, 1.5}, df.Low)
+	require.Equal(t, model.Series[float64]{2, 3}, df.Close)
+	require.Equal(t, model.Series[float64]{10, 20}, df.Volume)
+	require.Equal(t, []time.Time{start, start.Add(15 * time.Minute)}, df.Time)
+	require.Equal(t, model.Series[float64]{0, 1}, df.Metadata["signal"])
+
+	t.Run("close only", func(t *testing.T) {
+		candles, err := NewDataframe("BTCUSDT").Close(10, 11).Candles()
+		require.NoError(t, err)
+		require.Len(t, candles, 2)
+		require.Equal(t, DefaultStart.Add(time.Hour), candles[1].Time)
+		require.Equal(t, 11.0, candles[1].High)
+		require.Zero(t, candles[1].Volume)
+		require.True(t, candles[1].Complete)
+	})
+
+	t.Run("different lengths", func(t *testing.T) {
+		_, err := NewDataframe("BTCUSDT").
+			OHLCV([]float64{1, 2}, []float64{3}, []float64{0.5, 1.5}, []float64{2, 3}, nil).
+			Build()
+		require.ErrorIs(t, err, ErrInvalidData)
+
+		_, err = NewDataframe("BTCUSDT").Close(1, 2).Metadata("signal", 1).Build()
+		require.ErrorIs(t, err, ErrInvalidData)
+	})
+}
+
+func TestDataframeBuilder_Indicators(t *testing.T) {
+	df, err := NewDataframe("BTCUSDT").Close(1, 2, 3, 4, 5).Indicators(crossStrategy{})
+	require.NoError(t, err)
+	RequireIndicator(t, df, "sma", 1e-9, 2, 3, 4)
+}
+
+func TestDataframeBuilder_Run(t *testing.T) {
+	broker := NewBroker(map[string]float64{"USDT": 1000})
+	df, err := NewDataframe("BTCUSDT").Close(10, 9, 8, 12, 13, 9, 8).Run(crossStrategy{}, broker)
+	require.NoError(t, err)
+	require.Len(t, df.Close, 7)
+
+	orders := broker.Orders()
+	require.Len(t, orders, 2)
+	require.Equal(t, model.SideTypeBuy, orders[0].Side)
+	require.Equal(t, 12.0, orders[0].Price)
+	require.Equal(t, DefaultStart.Add(3*time.Hour), orders[0].CreatedAt)
+	require.Equal(t, model.SideTypeSell, orders[1].Side)
+	require.Equal(t, 9.0, orders[1].Price)
+	require.Equal(t, 997.0, broker.Balance("USDT"))
+}
+
+func TestRequireIndicator(t *testing.T) {
+	df := &model.Dataframe{Metadata: map[string]model.Series[float64]{"rsi": {math.NaN(), 30, 70}}}
+	RequireIndicator(t, df, "rsi", 0, math.NaN(), 30, 70)
+	RequireIndicator(t, df, "rsi", 0.5, 70.1)
+}
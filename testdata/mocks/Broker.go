@@ -63,6 +63,51 @@ func (_c *Broker_Account_Call) Return(_a0 model.Account, _a1 error) *Broker_Acco
 	return _c
 }
 
+// BuyPercent provides a mock function with given fields: pair, percent
+func (_m *Broker) BuyPercent(pair string, percent float64) (model.Order, error) {
+	ret := _m.Called(pair, percent)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, percent)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, percent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Broker_BuyPercent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BuyPercent'
+type Broker_BuyPercent_Call struct {
+	*mock.Call
+}
+
+// BuyPercent is a helper method to define mock.On call
+//   - pair string
+//   - percent float64
+func (_e *Broker_Expecter) BuyPercent(pair interface{}, percent interface{}) *Broker_BuyPercent_Call {
+	return &Broker_BuyPercent_Call{Call: _e.mock.On("BuyPercent", pair, percent)}
+}
+
+func (_c *Broker_BuyPercent_Call) Run(run func(pair string, percent float64)) *Broker_BuyPercent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Broker_BuyPercent_Call) Return(_a0 model.Order, _a1 error) *Broker_BuyPercent_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // Cancel provides a mock function with given fields: _a0
 func (_m *Broker) Cancel(_a0 model.Order) error {
 	ret := _m.Called(_a0)
@@ -100,6 +145,51 @@ func (_c *Broker_Cancel_Call) Return(_a0 error) *Broker_Cancel_Call {
 	return _c
 }
 
+// ClosePosition provides a mock function with given fields: pair, fraction
+func (_m *Broker) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	ret := _m.Called(pair, fraction)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, fraction)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, fraction)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Broker_ClosePosition_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ClosePosition'
+type Broker_ClosePosition_Call struct {
+	*mock.Call
+}
+
+// ClosePosition is a helper method to define mock.On call
+//   - pair string
+//   - fraction float64
+func (_e *Broker_Expecter) ClosePosition(pair interface{}, fraction interface{}) *Broker_ClosePosition_Call {
+	return &Broker_ClosePosition_Call{Call: _e.mock.On("ClosePosition", pair, fraction)}
+}
+
+func (_c *Broker_ClosePosition_Call) Run(run func(pair string, fraction float64)) *Broker_ClosePosition_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Broker_ClosePosition_Call) Return(_a0 model.Order, _a1 error) *Broker_ClosePosition_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderLimit provides a mock function with given fields: side, pair, size, limit
 func (_m *Broker) CreateOrderLimit(side model.SideType, pair string, size float64, limit float64) (model.Order, error) {
 	ret := _m.Called(side, pair, size, limit)
@@ -147,6 +237,54 @@ func (_c *Broker_CreateOrderLimit_Call) Return(_a0 model.Order, _a1 error) *Brok
 	return _c
 }
 
+// CreateOrderLimitTIF provides a mock function with given fields: side, pair, size, limit, tif
+func (_m *Broker) CreateOrderLimitTIF(side model.SideType, pair string, size float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
+	ret := _m.Called(side, pair, size, limit, tif)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, float64, model.TimeInForceType) model.Order); ok {
+		r0 = rf(side, pair, size, limit, tif)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, float64, model.TimeInForceType) error); ok {
+		r1 = rf(side, pair, size, limit, tif)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Broker_CreateOrderLimitTIF_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateOrderLimitTIF'
+type Broker_CreateOrderLimitTIF_Call struct {
+	*mock.Call
+}
+
+// CreateOrderLimitTIF is a helper method to define mock.On call
+//   - side model.SideType
+//   - pair string
+//   - size float64
+//   - limit float64
+//   - tif model.TimeInForceType
+func (_e *Broker_Expecter) CreateOrderLimitTIF(side interface{}, pair interface{}, size interface{}, limit interface{}, tif interface{}) *Broker_CreateOrderLimitTIF_Call {
+	return &Broker_CreateOrderLimitTIF_Call{Call: _e.mock.On("CreateOrderLimitTIF", side, pair, size, limit, tif)}
+}
+
+func (_c *Broker_CreateOrderLimitTIF_Call) Run(run func(side model.SideType, pair string, size float64, limit float64, tif model.TimeInForceType)) *Broker_CreateOrderLimitTIF_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(float64), args[4].(model.TimeInForceType))
+	})
+	return _c
+}
+
+func (_c *Broker_CreateOrderLimitTIF_Call) Return(_a0 model.Order, _a1 error) *Broker_CreateOrderLimitTIF_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderMarket provides a mock function with given fields: side, pair, size
 func (_m *Broker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
 	ret := _m.Called(side, pair, size)
@@ -239,6 +377,53 @@ func (_c *Broker_CreateOrderMarketQuote_Call) Return(_a0 model.Order, _a1 error)
 	return _c
 }
 
+// CreateOrderMarketTagged provides a mock function with given fields: side, pair, size, tag
+func (_m *Broker) CreateOrderMarketTagged(side model.SideType, pair string, size float64, tag string) (model.Order, error) {
+	ret := _m.Called(side, pair, size, tag)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, string) model.Order); ok {
+		r0 = rf(side, pair, size, tag)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, string) error); ok {
+		r1 = rf(side, pair, size, tag)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Broker_CreateOrderMarketTagged_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateOrderMarketTagged'
+type Broker_CreateOrderMarketTagged_Call struct {
+	*mock.Call
+}
+
+// CreateOrderMarketTagged is a helper method to define mock.On call
+//   - side model.SideType
+//   - pair string
+//   - size float64
+//   - tag string
+func (_e *Broker_Expecter) CreateOrderMarketTagged(side interface{}, pair interface{}, size interface{}, tag interface{}) *Broker_CreateOrderMarketTagged_Call {
+	return &Broker_CreateOrderMarketTagged_Call{Call: _e.mock.On("CreateOrderMarketTagged", side, pair, size, tag)}
+}
+
+func (_c *Broker_CreateOrderMarketTagged_Call) Run(run func(side model.SideType, pair string, size float64, tag string)) *Broker_CreateOrderMarketTagged_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *Broker_CreateOrderMarketTagged_Call) Return(_a0 model.Order, _a1 error) *Broker_CreateOrderMarketTagged_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderOCO provides a mock function with given fields: side, pair, size, price, stop, stopLimit
 func (_m *Broker) CreateOrderOCO(side model.SideType, pair string, size float64, price float64, stop float64, stopLimit float64) ([]model.Order, error) {
 	ret := _m.Called(side, pair, size, price, stop, stopLimit)
@@ -290,20 +475,20 @@ func (_c *Broker_CreateOrderOCO_Call) Return(_a0 []model.Order, _a1 error) *Brok
 	return _c
 }
 
-// CreateOrderStop provides a mock function with given fields: pair, quantity, limit
-func (_m *Broker) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
-	ret := _m.Called(pair, quantity, limit)
+// CreateOrderStop provides a mock function with given fields: side, pair, quantity, limit
+func (_m *Broker) CreateOrderStop(side model.SideType, pair string, quantity float64, limit float64) (model.Order, error) {
+	ret := _m.Called(side, pair, quantity, limit)
 
 	var r0 model.Order
-	if rf, ok := ret.Get(0).(func(string, float64, float64) model.Order); ok {
-		r0 = rf(pair, quantity, limit)
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, float64) model.Order); ok {
+		r0 = rf(side, pair, quantity, limit)
 	} else {
 		r0 = ret.Get(0).(model.Order)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, float64, float64) error); ok {
-		r1 = rf(pair, quantity, limit)
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, float64) error); ok {
+		r1 = rf(side, pair, quantity, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -317,16 +502,17 @@ type Broker_CreateOrderStop_Call struct {
 }
 
 // CreateOrderStop is a helper method to define mock.On call
+//   - side model.SideType
 //   - pair string
 //   - quantity float64
 //   - limit float64
-func (_e *Broker_Expecter) CreateOrderStop(pair interface{}, quantity interface{}, limit interface{}) *Broker_CreateOrderStop_Call {
-	return &Broker_CreateOrderStop_Call{Call: _e.mock.On("CreateOrderStop", pair, quantity, limit)}
+func (_e *Broker_Expecter) CreateOrderStop(side interface{}, pair interface{}, quantity interface{}, limit interface{}) *Broker_CreateOrderStop_Call {
+	return &Broker_CreateOrderStop_Call{Call: _e.mock.On("CreateOrderStop", side, pair, quantity, limit)}
 }
 
-func (_c *Broker_CreateOrderStop_Call) Run(run func(pair string, quantity float64, limit float64)) *Broker_CreateOrderStop_Call {
+func (_c *Broker_CreateOrderStop_Call) Run(run func(side model.SideType, pair string, quantity float64, limit float64)) *Broker_CreateOrderStop_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(float64), args[2].(float64))
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(float64))
 	})
 	return _c
 }
@@ -432,6 +618,51 @@ func (_c *Broker_Position_Call) Return(asset float64, quote float64, err error)
 	return _c
 }
 
+// SellPercent provides a mock function with given fields: pair, percent
+func (_m *Broker) SellPercent(pair string, percent float64) (model.Order, error) {
+	ret := _m.Called(pair, percent)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, percent)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, percent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Broker_SellPercent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SellPercent'
+type Broker_SellPercent_Call struct {
+	*mock.Call
+}
+
+// SellPercent is a helper method to define mock.On call
+//   - pair string
+//   - percent float64
+func (_e *Broker_Expecter) SellPercent(pair interface{}, percent interface{}) *Broker_SellPercent_Call {
+	return &Broker_SellPercent_Call{Call: _e.mock.On("SellPercent", pair, percent)}
+}
+
+func (_c *Broker_SellPercent_Call) Run(run func(pair string, percent float64)) *Broker_SellPercent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Broker_SellPercent_Call) Return(_a0 model.Order, _a1 error) *Broker_SellPercent_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 type mockConstructorTestingTNewBroker interface {
 	mock.TestingT
 	Cleanup(func())
@@ -104,6 +104,51 @@ func (_c *Exchange_AssetsInfo_Call) Return(_a0 model.AssetInfo) *Exchange_Assets
 	return _c
 }
 
+// BuyPercent provides a mock function with given fields: pair, percent
+func (_m *Exchange) BuyPercent(pair string, percent float64) (model.Order, error) {
+	ret := _m.Called(pair, percent)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, percent)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, percent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exchange_BuyPercent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BuyPercent'
+type Exchange_BuyPercent_Call struct {
+	*mock.Call
+}
+
+// BuyPercent is a helper method to define mock.On call
+//   - pair string
+//   - percent float64
+func (_e *Exchange_Expecter) BuyPercent(pair interface{}, percent interface{}) *Exchange_BuyPercent_Call {
+	return &Exchange_BuyPercent_Call{Call: _e.mock.On("BuyPercent", pair, percent)}
+}
+
+func (_c *Exchange_BuyPercent_Call) Run(run func(pair string, percent float64)) *Exchange_BuyPercent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Exchange_BuyPercent_Call) Return(_a0 model.Order, _a1 error) *Exchange_BuyPercent_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // Cancel provides a mock function with given fields: _a0
 func (_m *Exchange) Cancel(_a0 model.Order) error {
 	ret := _m.Called(_a0)
@@ -290,6 +335,51 @@ func (_c *Exchange_CandlesSubscription_Call) Return(_a0 chan model.Candle, _a1 c
 	return _c
 }
 
+// ClosePosition provides a mock function with given fields: pair, fraction
+func (_m *Exchange) ClosePosition(pair string, fraction float64) (model.Order, error) {
+	ret := _m.Called(pair, fraction)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, fraction)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, fraction)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exchange_ClosePosition_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ClosePosition'
+type Exchange_ClosePosition_Call struct {
+	*mock.Call
+}
+
+// ClosePosition is a helper method to define mock.On call
+//   - pair string
+//   - fraction float64
+func (_e *Exchange_Expecter) ClosePosition(pair interface{}, fraction interface{}) *Exchange_ClosePosition_Call {
+	return &Exchange_ClosePosition_Call{Call: _e.mock.On("ClosePosition", pair, fraction)}
+}
+
+func (_c *Exchange_ClosePosition_Call) Run(run func(pair string, fraction float64)) *Exchange_ClosePosition_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Exchange_ClosePosition_Call) Return(_a0 model.Order, _a1 error) *Exchange_ClosePosition_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderLimit provides a mock function with given fields: side, pair, size, limit
 func (_m *Exchange) CreateOrderLimit(side model.SideType, pair string, size float64, limit float64) (model.Order, error) {
 	ret := _m.Called(side, pair, size, limit)
@@ -337,6 +427,54 @@ func (_c *Exchange_CreateOrderLimit_Call) Return(_a0 model.Order, _a1 error) *Ex
 	return _c
 }
 
+// CreateOrderLimitTIF provides a mock function with given fields: side, pair, size, limit, tif
+func (_m *Exchange) CreateOrderLimitTIF(side model.SideType, pair string, size float64, limit float64, tif model.TimeInForceType) (model.Order, error) {
+	ret := _m.Called(side, pair, size, limit, tif)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, float64, model.TimeInForceType) model.Order); ok {
+		r0 = rf(side, pair, size, limit, tif)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, float64, model.TimeInForceType) error); ok {
+		r1 = rf(side, pair, size, limit, tif)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exchange_CreateOrderLimitTIF_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateOrderLimitTIF'
+type Exchange_CreateOrderLimitTIF_Call struct {
+	*mock.Call
+}
+
+// CreateOrderLimitTIF is a helper method to define mock.On call
+//   - side model.SideType
+//   - pair string
+//   - size float64
+//   - limit float64
+//   - tif model.TimeInForceType
+func (_e *Exchange_Expecter) CreateOrderLimitTIF(side interface{}, pair interface{}, size interface{}, limit interface{}, tif interface{}) *Exchange_CreateOrderLimitTIF_Call {
+	return &Exchange_CreateOrderLimitTIF_Call{Call: _e.mock.On("CreateOrderLimitTIF", side, pair, size, limit, tif)}
+}
+
+func (_c *Exchange_CreateOrderLimitTIF_Call) Run(run func(side model.SideType, pair string, size float64, limit float64, tif model.TimeInForceType)) *Exchange_CreateOrderLimitTIF_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(float64), args[4].(model.TimeInForceType))
+	})
+	return _c
+}
+
+func (_c *Exchange_CreateOrderLimitTIF_Call) Return(_a0 model.Order, _a1 error) *Exchange_CreateOrderLimitTIF_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderMarket provides a mock function with given fields: side, pair, size
 func (_m *Exchange) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
 	ret := _m.Called(side, pair, size)
@@ -429,6 +567,53 @@ func (_c *Exchange_CreateOrderMarketQuote_Call) Return(_a0 model.Order, _a1 erro
 	return _c
 }
 
+// CreateOrderMarketTagged provides a mock function with given fields: side, pair, size, tag
+func (_m *Exchange) CreateOrderMarketTagged(side model.SideType, pair string, size float64, tag string) (model.Order, error) {
+	ret := _m.Called(side, pair, size, tag)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, string) model.Order); ok {
+		r0 = rf(side, pair, size, tag)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, string) error); ok {
+		r1 = rf(side, pair, size, tag)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exchange_CreateOrderMarketTagged_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateOrderMarketTagged'
+type Exchange_CreateOrderMarketTagged_Call struct {
+	*mock.Call
+}
+
+// CreateOrderMarketTagged is a helper method to define mock.On call
+//   - side model.SideType
+//   - pair string
+//   - size float64
+//   - tag string
+func (_e *Exchange_Expecter) CreateOrderMarketTagged(side interface{}, pair interface{}, size interface{}, tag interface{}) *Exchange_CreateOrderMarketTagged_Call {
+	return &Exchange_CreateOrderMarketTagged_Call{Call: _e.mock.On("CreateOrderMarketTagged", side, pair, size, tag)}
+}
+
+func (_c *Exchange_CreateOrderMarketTagged_Call) Run(run func(side model.SideType, pair string, size float64, tag string)) *Exchange_CreateOrderMarketTagged_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *Exchange_CreateOrderMarketTagged_Call) Return(_a0 model.Order, _a1 error) *Exchange_CreateOrderMarketTagged_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 // CreateOrderOCO provides a mock function with given fields: side, pair, size, price, stop, stopLimit
 func (_m *Exchange) CreateOrderOCO(side model.SideType, pair string, size float64, price float64, stop float64, stopLimit float64) ([]model.Order, error) {
 	ret := _m.Called(side, pair, size, price, stop, stopLimit)
@@ -480,20 +665,20 @@ func (_c *Exchange_CreateOrderOCO_Call) Return(_a0 []model.Order, _a1 error) *Ex
 	return _c
 }
 
-// CreateOrderStop provides a mock function with given fields: pair, quantity, limit
-func (_m *Exchange) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
-	ret := _m.Called(pair, quantity, limit)
+// CreateOrderStop provides a mock function with given fields: side, pair, quantity, limit
+func (_m *Exchange) CreateOrderStop(side model.SideType, pair string, quantity float64, limit float64) (model.Order, error) {
+	ret := _m.Called(side, pair, quantity, limit)
 
 	var r0 model.Order
-	if rf, ok := ret.Get(0).(func(string, float64, float64) model.Order); ok {
-		r0 = rf(pair, quantity, limit)
+	if rf, ok := ret.Get(0).(func(model.SideType, string, float64, float64) model.Order); ok {
+		r0 = rf(side, pair, quantity, limit)
 	} else {
 		r0 = ret.Get(0).(model.Order)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, float64, float64) error); ok {
-		r1 = rf(pair, quantity, limit)
+	if rf, ok := ret.Get(1).(func(model.SideType, string, float64, float64) error); ok {
+		r1 = rf(side, pair, quantity, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -507,16 +692,17 @@ type Exchange_CreateOrderStop_Call struct {
 }
 
 // CreateOrderStop is a helper method to define mock.On call
+//   - side model.SideType
 //   - pair string
 //   - quantity float64
 //   - limit float64
-func (_e *Exchange_Expecter) CreateOrderStop(pair interface{}, quantity interface{}, limit interface{}) *Exchange_CreateOrderStop_Call {
-	return &Exchange_CreateOrderStop_Call{Call: _e.mock.On("CreateOrderStop", pair, quantity, limit)}
+func (_e *Exchange_Expecter) CreateOrderStop(side interface{}, pair interface{}, quantity interface{}, limit interface{}) *Exchange_CreateOrderStop_Call {
+	return &Exchange_CreateOrderStop_Call{Call: _e.mock.On("CreateOrderStop", side, pair, quantity, limit)}
 }
 
-func (_c *Exchange_CreateOrderStop_Call) Run(run func(pair string, quantity float64, limit float64)) *Exchange_CreateOrderStop_Call {
+func (_c *Exchange_CreateOrderStop_Call) Run(run func(side model.SideType, pair string, quantity float64, limit float64)) *Exchange_CreateOrderStop_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string), args[1].(float64), args[2].(float64))
+		run(args[0].(model.SideType), args[1].(string), args[2].(float64), args[3].(float64))
 	})
 	return _c
 }
@@ -667,6 +853,51 @@ func (_c *Exchange_Position_Call) Return(asset float64, quote float64, err error
 	return _c
 }
 
+// SellPercent provides a mock function with given fields: pair, percent
+func (_m *Exchange) SellPercent(pair string, percent float64) (model.Order, error) {
+	ret := _m.Called(pair, percent)
+
+	var r0 model.Order
+	if rf, ok := ret.Get(0).(func(string, float64) model.Order); ok {
+		r0 = rf(pair, percent)
+	} else {
+		r0 = ret.Get(0).(model.Order)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, float64) error); ok {
+		r1 = rf(pair, percent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Exchange_SellPercent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SellPercent'
+type Exchange_SellPercent_Call struct {
+	*mock.Call
+}
+
+// SellPercent is a helper method to define mock.On call
+//   - pair string
+//   - percent float64
+func (_e *Exchange_Expecter) SellPercent(pair interface{}, percent interface{}) *Exchange_SellPercent_Call {
+	return &Exchange_SellPercent_Call{Call: _e.mock.On("SellPercent", pair, percent)}
+}
+
+func (_c *Exchange_SellPercent_Call) Run(run func(pair string, percent float64)) *Exchange_SellPercent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *Exchange_SellPercent_Call) Return(_a0 model.Order, _a1 error) *Exchange_SellPercent_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
 type mockConstructorTestingTNewExchange interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,359 @@
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// Discrepancy is a difference between a recorded live order and its replica in the paper wallet
+type Discrepancy struct {
+	Pair       string
+	ExchangeID int64
+	Field      string
+	Live       string
+	Paper      string
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("[%s] order %d: %s live=%s paper=%s", d.Pair, d.ExchangeID, d.Field, d.Live, d.Paper)
+}
+
+type Report struct {
+	Discrepancies []Discrepancy
+	LiveProfit    map[string]float64
+	PaperProfit   map[string]float64
+	LiveFees      float64
+	PaperFees     float64
+}
+
+// Consistent returns true when no discrepancy was found
+func (r Report) Consistent() bool {
+	return len(r.Discrepancies) == 0
+}
+
+func (r Report) String() string {
+	buffer := &strings.Builder{}
+	table := tablewriter.NewWriter(buffer)
+	table.SetHeader([]string{"Pair", "Order", "Field", "Live", "Paper"})
+	for _, d := range r.Discrepancies {
+		table.Append([]string{d.Pair, fmt.Sprintf("%d", d.ExchangeID), d.Field, d.Live, d.Paper})
+	}
+	table.Render()
+
+	pairs := make([]string, 0, len(r.LiveProfit))
+	for pair := range r.LiveProfit {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		fmt.Fprintf(buffer, "PROFIT %s = live %s / paper %s\n", pair,
+			model.FormatValue(r.LiveProfit[pair], 4, ""), model.FormatValue(r.PaperProfit[pair], 4, ""))
+	}
+	fmt.Fprintf(buffer, "FEES = live %s / paper %s\n",
+		model.FormatValue(r.LiveFees, 4, ""), model.FormatValue(r.PaperFees, 4, ""))
+	return buffer.String()
+}
+
+type Checker struct {
+	baseCoin      string
+	makerFee      float64
+	takerFee      float64
+	tolerance     float64
+	walletOptions []exchange.PaperWalletOption
+}
+
+type Option func(*Checker)
+
+// WithFees sets the fee rates used to estimate the costs of each fill, eg: 0.001 for 0.1%
+func WithFees(maker, taker float64) Option {
+	return func(checker *Checker) {
+		checker.makerFee = maker
+		checker.takerFee = taker
+	}
+}
+
+// WithTolerance sets the relative difference accepted between live and paper values, default 0.0001 (0.01%)
+func WithTolerance(tolerance float64) Option {
+	return func(checker *Checker) {
+		checker.tolerance = tolerance
+	}
+}
+
+// WithPaperWalletOptions sets the options of the paper wallet used in the simulation, eg: initial assets
+func WithPaperWalletOptions(options ...exchange.PaperWalletOption) Option {
+	return func(checker *Checker) {
+		checker.walletOptions = append(checker.walletOptions, options...)
+	}
+}
+
+func NewChecker(baseCoin string, options ...Option) *Checker {
+	checker := &Checker{
+		baseCoin:  baseCoin,
+		tolerance: 0.0001,
+	}
+
+	for _, option := range options {
+		option(checker)
+	}
+
+	return checker
+}
+
+// Check replays the candles in a paper wallet, submitting each live order in the same candle it was
+// created, then compares the fills, fees and realized profit of both executions.
+func (c *Checker) Check(ctx context.Context, candles []model.Candle, live []model.Order) (*Report, error) {
+	if len(candles) == 0 {
+		return nil, exchange.ErrInsufficientData
+	}
+
+	live = append([]model.Order(nil), live...)
+	sort.SliceStable(live, func(i, j int) bool {
+		return live[i].CreatedAt.Before(live[j].CreatedAt)
+	})
+
+	wallet := exchange.NewPaperWallet(ctx, c.baseCoin, c.walletOptions...)
+	report := &Report{}
+
+	// live exchange ID -> paper exchange ID
+	replicas := make(map[int64]int64)
+	next := 0
+	for i, candle := range candles {
+		wallet.OnCandle(candle)
+
+		nextTime := time.Unix(math.MaxInt32, 0)
+		if i+1 < len(candles) {
+			nextTime = candles[i+1].Time
+		}
+
+		for ; next < len(live) && live[next].CreatedAt.Before(nextTime); next++ {
+			order := live[next]
+			if _, ok := replicas[order.ExchangeID]; ok {
+				continue // already submitted as part of an OCO group
+			}
+
+			err := c.replicate(wallet, order, live, replicas)
+			if err != nil {
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{
+					Pair:       order.Pair,
+					ExchangeID: order.ExchangeID,
+					Field:      "submit",
+					Live:       string(order.Status),
+					Paper:      err.Error(),
+				})
+			}
+		}
+	}
+
+	paperOrders := make([]model.Order, 0, len(replicas))
+	for _, order := range live {
+		paperID, ok := replicas[order.ExchangeID]
+		if !ok {
+			continue
+		}
+
+		paper, err := wallet.Order(order.Pair, paperID)
+		if err != nil {
+			return nil, err
+		}
+		paperOrders = append(paperOrders, paper)
+
+		liveFee, paperFee := c.fee(order), c.fee(paper)
+		report.LiveFees += liveFee
+		report.PaperFees += paperFee
+		report.Discrepancies = append(report.Discrepancies, c.compare(order, paper, liveFee, paperFee)...)
+	}
+
+	report.LiveProfit = realizedProfit(live)
+	report.PaperProfit = realizedProfit(paperOrders)
+	for pair, profit := range report.LiveProfit {
+		if !c.equal(profit, report.PaperProfit[pair]) {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Pair:  pair,
+				Field: "profit",
+				Live:  model.FormatValue(profit, 4, ""),
+				Paper: model.FormatValue(report.PaperProfit[pair], 4, ""),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+func (c *Checker) replicate(wallet *exchange.PaperWallet, order model.Order, live []model.Order,
+	replicas map[int64]int64) error {
+
+	if order.GroupID != nil {
+		var limit, stop *model.Order
+		for i := range live {
+			if live[i].GroupID == nil || *live[i].GroupID != *order.GroupID {
+				continue
+			}
+			if live[i].Type == model.OrderTypeLimitMaker {
+				limit = &live[i]
+			} else {
+				stop = &live[i]
+			}
+		}
+
+		if limit == nil || stop == nil || stop.Stop == nil {
+			return fmt.Errorf("incomplete OCO group: %d", *order.GroupID)
+		}
+
+		orders, err := wallet.CreateOrderOCO(order.Side, order.Pair, order.Quantity, limit.Price, *stop.Stop,
+			stop.Price)
+		if err != nil {
+			return err
+		}
+		replicas[limit.ExchangeID] = orders[0].ExchangeID
+		replicas[stop.ExchangeID] = orders[1].ExchangeID
+		return nil
+	}
+
+	switch order.Type {
+	case model.OrderTypeMarket:
+		paper, err := wallet.CreateOrderMarket(order.Side, order.Pair, order.Quantity)
+		if err != nil {
+			return err
+		}
+		replicas[order.ExchangeID] = paper.ExchangeID
+	case model.OrderTypeLimit:
+		paper, err := wallet.CreateOrderLimit(order.Side, order.Pair, order.Quantity, order.Price)
+		if err != nil {
+			return err
+		}
+		replicas[order.ExchangeID] = paper.ExchangeID
+	case model.OrderTypeStopLossLimit:
+		paper, err := wallet.CreateOrderStop(order.Side, order.Pair, order.Quantity, fillPrice(order))
+		if err != nil {
+			return err
+		}
+		replicas[order.ExchangeID] = paper.ExchangeID
+	default:
+		return fmt.Errorf("unsupported order type: %s", order.Type)
+	}
+
+	return nil
+}
+
+func (c *Checker) compare(live, paper model.Order, liveFee, paperFee float64) []Discrepancy {
+	var discrepancies []Discrepancy
+	add := func(field, liveValue, paperValue string) {
+		discrepancies = append(discrepancies, Discrepancy{
+			Pair:       live.Pair,
+			ExchangeID: live.ExchangeID,
+			Field:      field,
+			Live:       liveValue,
+			Paper:      paperValue,
+		})
+	}
+
+	if live.Status != paper.Status {
+		add("status", string(live.Status), string(paper.Status))
+		return discrepancies
+	}
+
+	if live.Status != model.OrderStatusTypeFilled {
+		return nil
+	}
+
+	if !c.equal(fillPrice(live), fillPrice(paper)) {
+		add("price", model.FormatValue(fillPrice(live), 8, ""), model.FormatValue(fillPrice(paper), 8, ""))
+	}
+
+	if !c.equal(live.Quantity, paper.Quantity) {
+		add("quantity", model.FormatValue(live.Quantity, 8, ""), model.FormatValue(paper.Quantity, 8, ""))
+	}
+
+	if !c.equal(liveFee, paperFee) {
+		add("fee", model.FormatValue(liveFee, 8, ""), model.FormatValue(paperFee, 8, ""))
+	}
+
+	return discrepancies
+}
+
+func (c *Checker) fee(order model.Order) float64 {
+	if order.Status != model.OrderStatusTypeFilled {
+		return 0
+	}
+
+	rate := c.takerFee
+	if order.Type == model.OrderTypeLimit || order.Type == model.OrderTypeLimitMaker {
+		rate = c.makerFee
+	}
+	return fillPrice(order) * order.Quantity * rate
+}
+
+func (c *Checker) equal(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= c.tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func fillPrice(order model.Order) float64 {
+	if (order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit) && order.Stop != nil {
+		return *order.Stop
+	}
+	return order.Price
+}
+
+// realizedProfit calculates the realized profit by pair with the average price of the open position
+func realizedProfit(orders []model.Order) map[string]float64 {
+	filled := make([]model.Order, 0, len(orders))
+	for _, order := range orders {
+		if order.Status == model.OrderStatusTypeFilled {
+			filled = append(filled, order)
+		}
+	}
+	sort.SliceStable(filled, func(i, j int) bool {
+		return filled[i].UpdatedAt.Before(filled[j].UpdatedAt)
+	})
+
+	profit := make(map[string]float64)
+	quantity := make(map[string]float64)
+	avgPrice := make(map[string]float64)
+	for _, order := range orders {
+		if _, ok := profit[order.Pair]; !ok {
+			profit[order.Pair] = 0
+		}
+	}
+
+	for _, order := range filled {
+		price := fillPrice(order)
+		diff := order.Quantity
+		if order.Side == model.SideTypeSell {
+			diff = -order.Quantity
+		}
+
+		position := quantity[order.Pair]
+		if position == 0 || (position > 0) == (diff > 0) {
+			avgPrice[order.Pair] = (avgPrice[order.Pair]*math.Abs(position) + price*order.Quantity) /
+				(math.Abs(position) + order.Quantity)
+			quantity[order.Pair] += diff
+			continue
+		}
+
+		closed := math.Min(math.Abs(diff), math.Abs(position))
+		if position > 0 {
+			profit[order.Pair] += closed * (price - avgPrice[order.Pair])
+		} else {
+			profit[order.Pair] += closed * (avgPrice[order.Pair] - price)
+		}
+
+		quantity[order.Pair] += diff
+		if quantity[order.Pair] != 0 && (quantity[order.Pair] > 0) != (position > 0) {
+			avgPrice[order.Pair] = price // position reversed
+		}
+	}
+
+	return profit
+}
@@ -0,0 +1,91 @@
+package consistency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestChecker_Check(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := []model.Candle{
+		{Pair: "BTCUSDT", Time: start, Close: 100, High: 100, Low: 100, Complete: true},
+		{Pair: "BTCUSDT", Time: start.Add(time.Hour), Close: 150, High: 150, Low: 150, Complete: true},
+		{Pair: "BTCUSDT", Time: start.Add(2 * time.Hour), Close: 210, High: 210, Low: 150, Complete: true},
+	}
+
+	live := []model.Order{
+		{
+			ExchangeID: 1,
+			Pair:       "BTCUSDT",
+			Side:       model.SideTypeBuy,
+			Type:       model.OrderTypeMarket,
+			Status:     model.OrderStatusTypeFilled,
+			Price:      100,
+			Quantity:   1,
+			CreatedAt:  start.Add(time.Minute),
+			UpdatedAt:  start.Add(time.Minute),
+		},
+		{
+			ExchangeID: 2,
+			Pair:       "BTCUSDT",
+			Side:       model.SideTypeSell,
+			Type:       model.OrderTypeLimit,
+			Status:     model.OrderStatusTypeFilled,
+			Price:      200,
+			Quantity:   1,
+			CreatedAt:  start.Add(time.Hour + time.Minute),
+			UpdatedAt:  start.Add(2 * time.Hour),
+		},
+	}
+
+	checker := NewChecker("USDT",
+		WithFees(0.001, 0.001),
+		WithPaperWalletOptions(exchange.WithPaperAsset("USDT", 1000)),
+	)
+
+	t.Run("consistent", func(t *testing.T) {
+		report, err := checker.Check(context.Background(), candles, live)
+		require.NoError(t, err)
+		require.True(t, report.Consistent(), report.String())
+		require.Equal(t, 100.0, report.LiveProfit["BTCUSDT"])
+		require.Equal(t, 100.0, report.PaperProfit["BTCUSDT"])
+		require.InDelta(t, 0.3, report.PaperFees, 1e-9)
+	})
+
+	t.Run("different fill", func(t *testing.T) {
+		slipped := append([]model.Order(nil), live...)
+		slipped[0].Price = 101
+
+		report, err := checker.Check(context.Background(), candles, slipped)
+		require.NoError(t, err)
+		require.False(t, report.Consistent())
+
+		fields := make([]string, 0)
+		for _, d := range report.Discrepancies {
+			fields = append(fields, d.Field)
+		}
+		require.ElementsMatch(t, []string{"price", "fee", "profit"}, fields)
+	})
+
+	t.Run("order not filled in paper wallet", func(t *testing.T) {
+		unfilled := append([]model.Order(nil), live...)
+		unfilled[1].Price = 220
+
+		report, err := checker.Check(context.Background(), candles, unfilled)
+		require.NoError(t, err)
+		require.Len(t, report.Discrepancies, 2)
+		require.Equal(t, "status", report.Discrepancies[0].Field)
+		require.Equal(t, string(model.OrderStatusTypeNew), report.Discrepancies[0].Paper)
+	})
+
+	t.Run("without candles", func(t *testing.T) {
+		_, err := checker.Check(context.Background(), nil, live)
+		require.ErrorIs(t, err, exchange.ErrInsufficientData)
+	})
+}
@@ -0,0 +1,141 @@
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// DefaultTolerance is the weight gap ignored by default, 1% of the equity
+const DefaultTolerance = 0.01
+
+var ErrInvalidTargets = errors.New("invalid target weights")
+
+// Broker is the broker of the rebalance, with the weights and equity of the portfolio, e.g. `order.Controller`
+type Broker interface {
+	service.Broker
+	Weights() (map[string]float64, error)
+	Equity() (float64, error)
+	LastPrice(pair string) (float64, error)
+}
+
+// Order is an order of a rebalance plan, the quantity in asset and its value in quote
+type Order struct {
+	Pair     string
+	Side     model.SideType
+	Quantity float64
+	Value    float64
+}
+
+type config struct {
+	tolerance float64
+}
+
+type Option func(*config)
+
+// WithTolerance sets the band around the target weights without orders, e.g. 0.02 to trade only pairs more than
+// 2% of the equity away from their targets. It avoids the churn of small orders, default is DefaultTolerance.
+func WithTolerance(tolerance float64) Option {
+	return func(c *config) {
+		c.tolerance = tolerance
+	}
+}
+
+// Plan returns the orders to move the current weights to the target weights, see `order.Controller.Weights`.
+// The quantity of each order is the weight gap of the pair times the equity, at the given price. Pairs with a
+// gap within the tolerance are not traded, as pairs without target or price. The sell orders come first, to free
+// the capital of overweight pairs before buying the underweight ones, and the orders of each side are sorted by
+// the value, from the largest to the smallest.
+func Plan(weights, targets map[string]float64, equity float64, prices map[string]float64,
+	tolerance float64) []Order {
+	var sells, buys []Order
+	for pair, target := range targets {
+		price := prices[pair]
+		gap := target - weights[pair]
+		if price <= 0 || math.Abs(gap) <= tolerance {
+			continue
+		}
+
+		value := math.Abs(gap) * equity
+		order := Order{Pair: pair, Side: model.SideTypeBuy, Quantity: value / price, Value: value}
+		if gap < 0 {
+			order.Side = model.SideTypeSell
+			sells = append(sells, order)
+			continue
+		}
+		buys = append(buys, order)
+	}
+
+	for _, orders := range [][]Order{sells, buys} {
+		sort.Slice(orders, func(i, j int) bool {
+			if orders[i].Value != orders[j].Value {
+				return orders[i].Value > orders[j].Value
+			}
+			return orders[i].Pair < orders[j].Pair
+		})
+	}
+
+	return append(sells, buys...)
+}
+
+// ToTargets rebalances the portfolio to the target weights of the pairs (e.g. 0.5 for 50% of the equity) with
+// market orders, see Plan. A negative target is a short position, the sum of the positive targets must be at
+// most 1 and the remaining equity is kept in cash. The pairs without target are not traded, a zero target closes
+// the position. The sell orders are created first, if an order fails the rebalance stops, returning the orders
+// already created, so the buys are not placed without the capital of the sells. The buys may exceed the free
+// balance by the fees of the sells, see `ninjabot.WithOrderSizeClamp`.
+func ToTargets(broker Broker, targets map[string]float64, options ...Option) ([]model.Order, error) {
+	cfg := config{tolerance: DefaultTolerance}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	var total float64
+	for pair, target := range targets {
+		if target < -1 || target > 1 {
+			return nil, fmt.Errorf("%w: %s target %f out of range [-1, 1]", ErrInvalidTargets, pair, target)
+		}
+		total += math.Max(target, 0)
+	}
+
+	if total > 1+1e-9 {
+		return nil, fmt.Errorf("%w: targets sum to %f, above 1", ErrInvalidTargets, total)
+	}
+
+	weights, err := broker.Weights()
+	if err != nil {
+		return nil, err
+	}
+
+	equity, err := broker.Equity()
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]float64, len(targets))
+	for pair := range targets {
+		prices[pair], err = broker.LastPrice(pair)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plan := Plan(weights, targets, equity, prices, cfg.tolerance)
+	orders := make([]model.Order, 0, len(plan))
+	for _, item := range plan {
+		log.Infof("[REBALANCE] %s %s: %f (%.2f%% of equity)", item.Side, item.Pair, item.Quantity,
+			item.Value/equity*100)
+		order, err := broker.CreateOrderMarket(item.Side, item.Pair, item.Quantity)
+		if err != nil {
+			return orders, fmt.Errorf("rebalance %s: %w", item.Pair, err)
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,112 @@
+package rebalance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestPlan(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 20000, "ETHUSDT": 1000, "BNBUSDT": 200, "SOLUSDT": 20}
+
+	t.Run("sells before buys", func(t *testing.T) {
+		weights := map[string]float64{"BTCUSDT": 0.6, "ETHUSDT": 0.1, "BNBUSDT": 0.2}
+		targets := map[string]float64{"BTCUSDT": 0.3, "ETHUSDT": 0.35, "BNBUSDT": 0.1, "SOLUSDT": 0.2}
+
+		plan := Plan(weights, targets, 10000, prices, 0.01)
+		require.Len(t, plan, 4)
+
+		expected := []Order{
+			{Pair: "BTCUSDT", Side: model.SideTypeSell, Quantity: 0.15, Value: 3000},
+			{Pair: "BNBUSDT", Side: model.SideTypeSell, Quantity: 5, Value: 1000},
+			{Pair: "ETHUSDT", Side: model.SideTypeBuy, Quantity: 2.5, Value: 2500},
+			{Pair: "SOLUSDT", Side: model.SideTypeBuy, Quantity: 100, Value: 2000},
+		}
+		for i, order := range expected {
+			require.Equal(t, order.Pair, plan[i].Pair)
+			require.Equal(t, order.Side, plan[i].Side)
+			require.InDelta(t, order.Quantity, plan[i].Quantity, 1e-9)
+			require.InDelta(t, order.Value, plan[i].Value, 1e-9)
+		}
+	})
+
+	t.Run("tolerance band", func(t *testing.T) {
+		weights := map[string]float64{"BTCUSDT": 0.52, "ETHUSDT": 0.2}
+		targets := map[string]float64{"BTCUSDT": 0.5, "ETHUSDT": 0.3}
+
+		plan := Plan(weights, targets, 10000, prices, 0.05)
+		require.Len(t, plan, 1)
+		require.Equal(t, "ETHUSDT", plan[0].Pair)
+
+		require.Len(t, Plan(weights, targets, 10000, prices, 0.01), 2)
+	})
+
+	t.Run("short target", func(t *testing.T) {
+		weights := map[string]float64{"BTCUSDT": 0.2}
+		targets := map[string]float64{"BTCUSDT": -0.3}
+
+		plan := Plan(weights, targets, 10000, prices, 0.01)
+		require.Len(t, plan, 1)
+		require.Equal(t, model.SideTypeSell, plan[0].Side)
+		require.InDelta(t, 0.25, plan[0].Quantity, 1e-9)
+	})
+
+	t.Run("without price", func(t *testing.T) {
+		plan := Plan(nil, map[string]float64{"ADAUSDT": 0.5}, 10000, prices, 0.01)
+		require.Empty(t, plan)
+	})
+}
+
+func TestToTargets(t *testing.T) {
+	orderStorage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := order.NewController(ctx, wallet, orderStorage, order.NewOrderFeed())
+
+	for _, candle := range []model.Candle{
+		{Time: time.Now(), Pair: "BTCUSDT", Close: 20000, Low: 20000, High: 20000},
+		{Time: time.Now(), Pair: "ETHUSDT", Close: 1000, Low: 1000, High: 1000},
+	} {
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.3)
+	require.NoError(t, err)
+
+	// from 60% BTC and 40% cash to 40% BTC, 40% ETH and 20% cash
+	orders, err := ToTargets(controller, map[string]float64{"BTCUSDT": 0.4, "ETHUSDT": 0.4})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, model.SideTypeSell, orders[0].Side)
+	require.Equal(t, "BTCUSDT", orders[0].Pair)
+	require.InDelta(t, 0.1, orders[0].Quantity, 1e-9)
+	require.Equal(t, model.SideTypeBuy, orders[1].Side)
+	require.Equal(t, "ETHUSDT", orders[1].Pair)
+	require.InDelta(t, 4.0, orders[1].Quantity, 1e-9)
+
+	weights, err := controller.Weights()
+	require.NoError(t, err)
+	require.InDelta(t, 0.4, weights["BTCUSDT"], 1e-9)
+	require.InDelta(t, 0.4, weights["ETHUSDT"], 1e-9)
+	require.InDelta(t, 0.2, weights[order.CashWeight], 1e-9)
+
+	// already balanced
+	orders, err = ToTargets(controller, map[string]float64{"BTCUSDT": 0.4, "ETHUSDT": 0.4})
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	_, err = ToTargets(controller, map[string]float64{"BTCUSDT": 0.7, "ETHUSDT": 0.4})
+	require.ErrorIs(t, err, ErrInvalidTargets)
+
+	_, err = ToTargets(controller, map[string]float64{"BTCUSDT": 1.5})
+	require.ErrorIs(t, err, ErrInvalidTargets)
+}
@@ -0,0 +1,144 @@
+package regime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/rodrigo-brito/ninjabot"
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+var ErrNoDatasets = errors.New("no datasets")
+
+// Dataset is a named market regime, e.g. bull, bear or sideways, with the CSV files of its pairs
+type Dataset struct {
+	Name  string
+	Feeds []exchange.PairFeed
+}
+
+// Result is the result of the backtest of a dataset
+type Result struct {
+	Name string
+	ninjabot.BacktestResult
+}
+
+// Results are the results of the datasets, in the order of the datasets
+type Results []Result
+
+// String returns a table comparing the metrics of each dataset
+func (r Results) String() string {
+	buffer := &strings.Builder{}
+	table := tablewriter.NewWriter(buffer)
+	table.SetHeader([]string{"Regime", "Trades", "% Win", "Payoff", "SQN", "Profit", "Return", "Market",
+		"Max Drawdown", "Sharpe/Year"})
+	for _, result := range r {
+		row := []string{
+			result.Name,
+			strconv.Itoa(result.Trades),
+			model.FormatPercent(result.WinPercentage, 1),
+			model.FormatNumber(result.Payoff, 3),
+			fmt.Sprintf("%.1f", result.SQN),
+			model.FormatValue(result.Profit, 2, result.Currency),
+			"-", "-", "-", "-",
+		}
+		if wallet := result.Wallet; wallet != nil {
+			row[6] = model.FormatPercent(wallet.ProfitPercent*100, 2)
+			row[7] = model.FormatPercent(wallet.MarketChange*100, 2)
+			row[8] = model.FormatPercent(wallet.MaxDrawdown*100, 2)
+			row[9] = fmt.Sprintf("%.3f", wallet.AnnualSharpe)
+		}
+		table.Append(row)
+	}
+	table.Render()
+	return buffer.String()
+}
+
+type Runner struct {
+	baseCoin      string
+	walletOptions []exchange.PaperWalletOption
+	botOptions    []ninjabot.Option
+}
+
+type Option func(*Runner)
+
+// WithPaperWalletOptions sets the options of the paper wallet of each backtest, eg: initial assets and fees
+func WithPaperWalletOptions(options ...exchange.PaperWalletOption) Option {
+	return func(runner *Runner) {
+		runner.walletOptions = append(runner.walletOptions, options...)
+	}
+}
+
+// WithBotOptions sets the options of the bot of each backtest, eg: `ninjabot.WithLogLevel`
+func WithBotOptions(options ...ninjabot.Option) Option {
+	return func(runner *Runner) {
+		runner.botOptions = append(runner.botOptions, options...)
+	}
+}
+
+func NewRunner(baseCoin string, options ...Option) *Runner {
+	runner := &Runner{baseCoin: baseCoin}
+	for _, option := range options {
+		option(runner)
+	}
+	return runner
+}
+
+// Run backtests the strategy in each dataset, to compare its behavior in different market regimes. Each
+// backtest is isolated: a new paper wallet with the same options, a storage in memory and a new strategy from
+// newStrategy, so a stateful strategy does not carry its state between datasets. The backtests run in the
+// order of the datasets, the first failure stops the run.
+func (r *Runner) Run(ctx context.Context, settings ninjabot.Settings, newStrategy func() strategy.Strategy,
+	datasets ...Dataset) (Results, error) {
+
+	if len(datasets) == 0 {
+		return nil, ErrNoDatasets
+	}
+
+	results := make(Results, 0, len(datasets))
+	for _, dataset := range datasets {
+		result, err := r.backtest(ctx, settings, newStrategy(), dataset)
+		if err != nil {
+			return nil, fmt.Errorf("regime %s: %w", dataset.Name, err)
+		}
+		results = append(results, Result{Name: dataset.Name, BacktestResult: result})
+	}
+
+	return results, nil
+}
+
+func (r *Runner) backtest(ctx context.Context, settings ninjabot.Settings, str strategy.Strategy,
+	dataset Dataset) (ninjabot.BacktestResult, error) {
+
+	feed, err := exchange.NewCSVFeed(str.Timeframe(), dataset.Feeds...)
+	if err != nil {
+		return ninjabot.BacktestResult{}, err
+	}
+
+	orderStorage, err := storage.FromMemory()
+	if err != nil {
+		return ninjabot.BacktestResult{}, err
+	}
+
+	walletOptions := append([]exchange.PaperWalletOption{}, r.walletOptions...)
+	wallet := exchange.NewPaperWallet(ctx, r.baseCoin, append(walletOptions, exchange.WithDataFeed(feed))...)
+
+	options := append([]ninjabot.Option{ninjabot.WithStorage(orderStorage)}, r.botOptions...)
+	bot, err := ninjabot.NewBot(ctx, settings, wallet, str, append(options, ninjabot.WithBacktest(wallet))...)
+	if err != nil {
+		return ninjabot.BacktestResult{}, err
+	}
+
+	if err := bot.Run(ctx); err != nil {
+		return ninjabot.BacktestResult{}, err
+	}
+
+	return bot.Result(), nil
+}
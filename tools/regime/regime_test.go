@@ -0,0 +1,114 @@
+package regime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot"
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+)
+
+// flipStrategy buys one unit when flat and sells it in the next candle
+type flipStrategy struct{}
+
+func (f flipStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (f flipStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (f flipStrategy) Indicators(_ *model.Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (f flipStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	assetPosition, _, err := broker.Position(df.Pair)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	side, quantity := model.SideTypeBuy, 1.0
+	if assetPosition > 0 {
+		side, quantity = model.SideTypeSell, assetPosition
+	}
+	if _, err := broker.CreateOrderMarket(side, df.Pair, quantity); err != nil {
+		log.Error(err)
+	}
+}
+
+// writeDataset writes daily candles with the given closes to a CSV file
+func writeDataset(t *testing.T, name string, closes ...float64) exchange.PairFeed {
+	t.Helper()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	lines := make([]string, 0, len(closes))
+	for i, price := range closes {
+		lines = append(lines, fmt.Sprintf("%d,%f,%f,%f,%f,1000", start.AddDate(0, 0, i).Unix(),
+			price, price, price, price))
+	}
+
+	file := filepath.Join(t.TempDir(), name+".csv")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0600))
+	return exchange.PairFeed{Pair: "BTCUSDT", File: file, Timeframe: "1d"}
+}
+
+func TestRunner_Run(t *testing.T) {
+	runner := NewRunner("USDT",
+		WithPaperWalletOptions(exchange.WithPaperAsset("USDT", 1000)),
+		WithBotOptions(ninjabot.WithLogLevel(log.ErrorLevel)),
+	)
+
+	strategies := 0
+	newStrategy := func() strategy.Strategy {
+		strategies++
+		return flipStrategy{}
+	}
+
+	settings := ninjabot.Settings{Pairs: []string{"BTCUSDT"}}
+	results, err := runner.Run(context.Background(), settings, newStrategy,
+		Dataset{Name: "bull", Feeds: []exchange.PairFeed{writeDataset(t, "bull", 100, 110, 120, 130, 140, 150)}},
+		Dataset{Name: "bear", Feeds: []exchange.PairFeed{writeDataset(t, "bear", 150, 140, 130, 120, 110, 100)}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, strategies)
+	require.Len(t, results, 2)
+
+	bull, bear := results[0], results[1]
+	require.Equal(t, "bull", bull.Name)
+	require.Positive(t, bull.Wins)
+	require.Zero(t, bull.Losses)
+	require.Positive(t, bull.Profit)
+	require.Positive(t, bull.Wallet.MarketChange)
+
+	require.Equal(t, "bear", bear.Name)
+	require.Zero(t, bear.Wins)
+	require.Positive(t, bear.Losses)
+	require.Negative(t, bear.Profit)
+	require.Negative(t, bear.Wallet.MarketChange)
+
+	// each wallet starts from the initial assets
+	require.Equal(t, 1000.0, bull.Wallet.InitialValue)
+	require.Equal(t, 1000.0, bear.Wallet.InitialValue)
+
+	table := results.String()
+	require.Contains(t, table, "bull")
+	require.Contains(t, table, "bear")
+	require.Contains(t, table, model.FormatValue(bear.Profit, 2, ""))
+
+	_, err = runner.Run(context.Background(), settings, newStrategy)
+	require.ErrorIs(t, err, ErrNoDatasets)
+}
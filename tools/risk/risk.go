@@ -0,0 +1,132 @@
+package risk
+
+import (
+	"math"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+const (
+	// DefaultLookback is the number of returns used to estimate the volatility
+	DefaultLookback = 20
+	// DefaultTimeframe is the timeframe of the prices, used to annualize the volatility
+	DefaultTimeframe = "1d"
+)
+
+type volTarget struct {
+	lookback    int
+	periods     float64
+	maxLeverage float64
+	err         error
+}
+
+type VolTargetOption func(*volTarget)
+
+// WithLookback sets the number of recent returns used to estimate the volatility, default is 20
+func WithLookback(returns int) VolTargetOption {
+	return func(v *volTarget) {
+		v.lookback = returns
+	}
+}
+
+// WithTimeframe sets the timeframe of the prices, e.g. 1h, used to annualize the volatility.
+// Default is 1d. With an invalid timeframe, the position size is zero.
+func WithTimeframe(timeframe string) VolTargetOption {
+	return func(v *volTarget) {
+		v.periods, v.err = PeriodsPerYear(timeframe)
+	}
+}
+
+// WithMaxLeverage limits the position value to a multiple of the equity, default is 1 (no leverage).
+// Without a limit, assets with very low volatility result in large positions.
+func WithMaxLeverage(leverage float64) VolTargetOption {
+	return func(v *volTarget) {
+		v.maxLeverage = leverage
+	}
+}
+
+// PeriodsPerYear returns the number of candles of a timeframe in a year, crypto markets trade all days
+func PeriodsPerYear(timeframe string) (float64, error) {
+	duration, err := str2duration.ParseDuration(timeframe)
+	if err != nil {
+		return 0, err
+	}
+	return float64(365*24*time.Hour) / float64(duration), nil
+}
+
+// Volatility returns the standard deviation of the last log returns of the prices, per period.
+// It returns zero without enough prices.
+func Volatility(prices []float64, lookback int) float64 {
+	if lookback < 2 || len(prices) < lookback+1 {
+		return 0
+	}
+
+	returns := make([]float64, 0, lookback)
+	for i := len(prices) - lookback; i < len(prices); i++ {
+		if prices[i-1] <= 0 || prices[i] <= 0 {
+			return 0
+		}
+		returns = append(returns, math.Log(prices[i]/prices[i-1]))
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	return math.Sqrt(variance)
+}
+
+// RollingVolatility returns the volatility of each price, computed with the last returns as in Volatility,
+// e.g. to plot the volatility. The first values without enough prices are zero.
+func RollingVolatility(prices []float64, lookback int) []float64 {
+	result := make([]float64, len(prices))
+	for i := range prices {
+		result[i] = Volatility(prices[:i+1], lookback)
+	}
+	return result
+}
+
+// VolTarget returns the quantity of a position sized to a target annual volatility, e.g. 0.2 for 20%.
+// The realized volatility is estimated with the recent returns of the prices and annualized with the
+// timeframe. Positions of volatile assets are smaller, so the volatility contribution of each position
+// is constant. It returns zero without enough prices to estimate the volatility.
+//
+//	quantity := risk.VolTarget(0.2, df.Close, equity, risk.WithTimeframe("4h"))
+func VolTarget(targetAnnualVol float64, priceSeries model.Series[float64], equity float64,
+	options ...VolTargetOption) float64 {
+
+	config := volTarget{
+		lookback:    DefaultLookback,
+		maxLeverage: 1,
+	}
+	config.periods, config.err = PeriodsPerYear(DefaultTimeframe)
+	for _, option := range options {
+		option(&config)
+	}
+
+	if config.err != nil {
+		log.Errorf("risk: invalid timeframe: %v", config.err)
+		return 0
+	}
+
+	volatility := Volatility(priceSeries.Values(), config.lookback) * math.Sqrt(config.periods)
+	if volatility == 0 || equity <= 0 || targetAnnualVol <= 0 {
+		return 0
+	}
+
+	price := priceSeries.Last(0)
+	exposure := math.Min(targetAnnualVol/volatility, config.maxLeverage)
+	return equity * exposure / price
+}
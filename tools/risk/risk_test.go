@@ -0,0 +1,87 @@
+package risk
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// alternating log returns of +r and -r, the last price is the first one
+func syntheticPrices(size int, r float64) model.Series[float64] {
+	prices := model.Series[float64]{100}
+	for i := 1; i < size; i++ {
+		sign := 1.0
+		if i%2 == 0 {
+			sign = -1
+		}
+		prices = append(prices, prices[i-1]*math.Exp(sign*r))
+	}
+	return prices
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	periods, err := PeriodsPerYear("1d")
+	require.NoError(t, err)
+	require.Equal(t, 365.0, periods)
+
+	periods, err = PeriodsPerYear("4h")
+	require.NoError(t, err)
+	require.Equal(t, 365.0*6, periods)
+
+	_, err = PeriodsPerYear("invalid")
+	require.Error(t, err)
+}
+
+func TestVolatility(t *testing.T) {
+	prices := syntheticPrices(21, 0.05)
+
+	// sample standard deviation of 10 returns of +5% and 10 of -5%
+	expected := 0.05 * math.Sqrt(20.0/19.0)
+	require.InDelta(t, expected, Volatility(prices, 20), 1e-9)
+	require.Equal(t, 0.0, Volatility(prices, 21))
+
+	rolling := RollingVolatility(prices, 20)
+	require.Len(t, rolling, 21)
+	require.Equal(t, 0.0, rolling[19])
+	require.InDelta(t, expected, rolling[20], 1e-9)
+}
+
+func TestVolTarget(t *testing.T) {
+	annualVol := func(r float64) float64 {
+		return r * math.Sqrt(20.0/19.0) * math.Sqrt(365)
+	}
+
+	t.Run("high volatility", func(t *testing.T) {
+		prices := syntheticPrices(41, 0.05)
+		quantity := VolTarget(0.2, prices, 10_000)
+		require.InDelta(t, 10_000*0.2/annualVol(0.05)/100, quantity, 1e-6)
+		require.Less(t, quantity*prices.Last(0), 10_000.0)
+	})
+
+	t.Run("low volatility", func(t *testing.T) {
+		prices := syntheticPrices(41, 0.005)
+
+		// limited to the equity without leverage
+		require.InDelta(t, 100, VolTarget(0.2, prices, 10_000), 1e-6)
+
+		quantity := VolTarget(0.2, prices, 10_000, WithMaxLeverage(3))
+		require.InDelta(t, 10_000*0.2/annualVol(0.005)/100, quantity, 1e-6)
+		require.Greater(t, quantity, VolTarget(0.2, syntheticPrices(41, 0.05), 10_000))
+	})
+
+	t.Run("timeframe", func(t *testing.T) {
+		prices := syntheticPrices(41, 0.005)
+		quantity := VolTarget(0.2, prices, 10_000, WithTimeframe("1h"))
+		require.InDelta(t, 10_000*0.2/(annualVol(0.005)*math.Sqrt(24))/100, quantity, 1e-6)
+
+		require.Equal(t, 0.0, VolTarget(0.2, prices, 10_000, WithTimeframe("invalid")))
+	})
+
+	t.Run("not enough prices", func(t *testing.T) {
+		require.Equal(t, 0.0, VolTarget(0.2, syntheticPrices(10, 0.05), 10_000))
+		require.Greater(t, VolTarget(0.2, syntheticPrices(10, 0.05), 10_000, WithLookback(5)), 0.0)
+	})
+}
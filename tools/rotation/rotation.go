@@ -0,0 +1,249 @@
+package rotation
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// Score ranks a pair by its dataframe, pairs with higher scores are preferred.
+// It returns false when the dataframe has not enough candles to score the pair.
+type Score func(df *model.Dataframe) (float64, bool)
+
+// Momentum scores a pair by the return of the last N periods, e.g. 0.1 for 10%
+func Momentum(period int) Score {
+	return func(df *model.Dataframe) (float64, bool) {
+		if period <= 0 || len(df.Close) <= period || df.Close.Last(period) <= 0 {
+			return 0, false
+		}
+		return df.Close.Last(0)/df.Close.Last(period) - 1, true
+	}
+}
+
+// Ranking is the score of a pair in a rotation
+type Ranking struct {
+	Pair  string
+	Score float64
+}
+
+// Signal is an order to rebalance the portfolio into the top pairs
+type Signal struct {
+	Pair string
+	Side model.SideType
+}
+
+type Option func(*Rotation)
+
+// WithScore sets the score used to rank the pairs, default is the momentum of 20 periods
+func WithScore(score Score) Option {
+	return func(r *Rotation) {
+		r.score = score
+	}
+}
+
+// WithRebalanceEvery rebalances the portfolio every N candles, default is every candle
+func WithRebalanceEvery(candles int) Option {
+	return func(r *Rotation) {
+		r.every = candles
+	}
+}
+
+// WithMinScore excludes pairs with a score below a minimum from the top pairs, e.g. 0 to hold only
+// pairs with positive momentum. The capital of excluded pairs remains in quote.
+func WithMinScore(score float64) Option {
+	return func(r *Rotation) {
+		r.minScore = score
+	}
+}
+
+// Rotation holds the top N pairs ranked by a score, e.g. momentum, and rotates the portfolio periodically.
+// Pairs that drop out of the top are sold and the free capital is split equally among the new top pairs.
+//
+// It is an observer of the candles of all pairs, registered with `ninjabot.WithObservers`. The portfolio is
+// rebalanced when the candles of all pairs are closed at the same time.
+type Rotation struct {
+	mtx        sync.Mutex
+	pairs      []string
+	top        int
+	score      Score
+	every      int
+	minScore   float64
+	dataframes map[string]*model.Dataframe
+	periods    int
+	last       time.Time
+}
+
+func New(pairs []string, top int, options ...Option) *Rotation {
+	rotation := &Rotation{
+		pairs:      pairs,
+		top:        top,
+		score:      Momentum(20),
+		every:      1,
+		minScore:   math.Inf(-1),
+		dataframes: make(map[string]*model.Dataframe),
+	}
+
+	for _, option := range options {
+		option(rotation)
+	}
+
+	return rotation
+}
+
+// OnCandle updates the dataframe of a pair and rebalances the portfolio when all pairs are updated
+func (r *Rotation) OnCandle(df *model.Dataframe, broker service.Broker) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if len(df.Time) == 0 {
+		return
+	}
+
+	r.dataframes[df.Pair] = df
+	current := df.Time[len(df.Time)-1]
+	if !current.After(r.last) || !r.synchronized(current) {
+		return
+	}
+
+	r.last = current
+	r.periods++
+	if r.every > 1 && (r.periods-1)%r.every != 0 {
+		return
+	}
+
+	held := make(map[string]bool)
+	for _, pair := range r.pairs {
+		asset, _, err := broker.Position(pair)
+		if err != nil {
+			log.Errorf("rotation: %v", err)
+			return
+		}
+		held[pair] = asset > 0
+	}
+
+	r.execute(broker, Rebalance(r.rank(), r.top, r.minScore, held))
+}
+
+// synchronized returns true if the last candles of all pairs closed at the given time
+func (r *Rotation) synchronized(current time.Time) bool {
+	for _, pair := range r.pairs {
+		df, ok := r.dataframes[pair]
+		if !ok || len(df.Time) == 0 || !df.Time[len(df.Time)-1].Equal(current) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rank returns the score of the pairs with enough candles, from the highest to the lowest score
+func (r *Rotation) Rank() []Ranking {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.rank()
+}
+
+func (r *Rotation) rank() []Ranking {
+	ranking := make([]Ranking, 0, len(r.pairs))
+	for _, pair := range r.pairs {
+		df, ok := r.dataframes[pair]
+		if !ok {
+			continue
+		}
+
+		if score, ok := r.score(df); ok {
+			ranking = append(ranking, Ranking{Pair: pair, Score: score})
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Score > ranking[j].Score
+	})
+	return ranking
+}
+
+// Rebalance returns the signals to rotate the held pairs into the top N pairs of the ranking: sell signals
+// for pairs that dropped out of the top, followed by buy signals for new pairs in the top. Pairs with a
+// score below the minimum are not included in the top.
+func Rebalance(ranking []Ranking, top int, minScore float64, held map[string]bool) []Signal {
+	target := make(map[string]bool, top)
+	for _, rank := range ranking {
+		if len(target) >= top {
+			break
+		}
+		if rank.Score >= minScore {
+			target[rank.Pair] = true
+		}
+	}
+
+	signals := make([]Signal, 0)
+	pairs := make([]string, 0, len(held))
+	for pair := range held {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	for _, pair := range pairs {
+		if held[pair] && !target[pair] {
+			signals = append(signals, Signal{Pair: pair, Side: model.SideTypeSell})
+		}
+	}
+
+	// buy in the order of the ranking
+	for _, rank := range ranking {
+		if target[rank.Pair] && !held[rank.Pair] {
+			signals = append(signals, Signal{Pair: rank.Pair, Side: model.SideTypeBuy})
+		}
+	}
+
+	return signals
+}
+
+// execute sells the whole position of dropouts and splits the free quote among the new pairs
+func (r *Rotation) execute(broker service.Broker, signals []Signal) {
+	buys := 0
+	for _, signal := range signals {
+		if signal.Side == model.SideTypeBuy {
+			buys++
+			continue
+		}
+
+		asset, _, err := broker.Position(signal.Pair)
+		if err != nil {
+			log.Errorf("rotation: %v", err)
+			continue
+		}
+
+		log.Infof("[ROTATION] %s dropped out of the top %d", signal.Pair, r.top)
+		if _, err := broker.CreateOrderMarket(model.SideTypeSell, signal.Pair, asset); err != nil {
+			log.Errorf("rotation: %v", err)
+		}
+	}
+
+	for _, signal := range signals {
+		if signal.Side != model.SideTypeBuy {
+			continue
+		}
+
+		_, quote, err := broker.Position(signal.Pair)
+		if err != nil {
+			log.Errorf("rotation: %v", err)
+			continue
+		}
+
+		amount := quote / float64(buys)
+		buys--
+		if amount <= 0 {
+			continue
+		}
+
+		log.Infof("[ROTATION] %s entered the top %d", signal.Pair, r.top)
+		if _, err := broker.CreateOrderMarketQuote(model.SideTypeBuy, signal.Pair, amount); err != nil {
+			log.Errorf("rotation: %v", err)
+		}
+	}
+}
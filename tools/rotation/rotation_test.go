@@ -0,0 +1,101 @@
+package rotation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func dataframe(pair string, t time.Time, closes ...float64) *model.Dataframe {
+	df := &model.Dataframe{Pair: pair}
+	for i, value := range closes {
+		df.Time = append(df.Time, t.Add(time.Duration(i-len(closes)+1)*time.Hour))
+		df.Close = append(df.Close, value)
+	}
+	return df
+}
+
+func TestMomentum(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	score, ok := Momentum(2)(dataframe("BTCUSDT", start, 100, 90, 110))
+	require.True(t, ok)
+	require.InDelta(t, 0.1, score, 1e-9)
+
+	_, ok = Momentum(3)(dataframe("BTCUSDT", start, 100, 90, 110))
+	require.False(t, ok)
+}
+
+func TestRebalance(t *testing.T) {
+	ranking := []Ranking{
+		{Pair: "ETHUSDT", Score: 0.3},
+		{Pair: "BTCUSDT", Score: 0.2},
+		{Pair: "BNBUSDT", Score: -0.1},
+	}
+
+	t.Run("rotate dropouts", func(t *testing.T) {
+		held := map[string]bool{"BTCUSDT": false, "ETHUSDT": false, "BNBUSDT": true}
+		signals := Rebalance(ranking, 2, -1, held)
+		require.Equal(t, []Signal{
+			{Pair: "BNBUSDT", Side: model.SideTypeSell},
+			{Pair: "ETHUSDT", Side: model.SideTypeBuy},
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy},
+		}, signals)
+	})
+
+	t.Run("top already held", func(t *testing.T) {
+		held := map[string]bool{"BTCUSDT": true, "ETHUSDT": true, "BNBUSDT": false}
+		require.Empty(t, Rebalance(ranking, 2, -1, held))
+	})
+
+	t.Run("min score", func(t *testing.T) {
+		held := map[string]bool{"BTCUSDT": false, "ETHUSDT": true, "BNBUSDT": false}
+		signals := Rebalance(ranking, 3, 0, held)
+		require.Equal(t, []Signal{{Pair: "BTCUSDT", Side: model.SideTypeBuy}}, signals)
+	})
+}
+
+func TestRotation_OnCandle(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	rotation := New([]string{"BTCUSDT", "ETHUSDT", "BNBUSDT"}, 1, WithScore(Momentum(1)))
+
+	update := func(df *model.Dataframe) {
+		wallet.OnCandle(model.Candle{Pair: df.Pair, Time: df.Time[len(df.Time)-1], Close: df.Close.Last(0)})
+		rotation.OnCandle(df, wallet)
+	}
+
+	update(dataframe("BTCUSDT", start, 100, 110))
+	update(dataframe("ETHUSDT", start, 10, 10.5))
+
+	// waiting for the candle of all pairs
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Zero(t, asset)
+
+	update(dataframe("BNBUSDT", start, 1, 0.9))
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 1000.0/110, asset, 1e-6)
+	require.InDelta(t, 0, quote, 1e-6)
+
+	// ETH takes the top of the ranking
+	next := start.Add(time.Hour)
+	update(dataframe("BTCUSDT", next, 110, 110))
+	update(dataframe("ETHUSDT", next, 10.5, 12))
+	update(dataframe("BNBUSDT", next, 0.9, 0.9))
+
+	asset, _, err = wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 0, asset, 1e-6)
+
+	asset, _, err = wallet.Position("ETHUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 1000.0/12, asset, 1e-3)
+
+	require.Equal(t, "ETHUSDT", rotation.Rank()[0].Pair)
+}
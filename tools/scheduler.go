@@ -36,6 +36,14 @@ func (s *Scheduler) BuyWhen(size float64, condition func(df *ninjabot.Dataframe)
 	)
 }
 
+// OnCandle updates the scheduler with the candles of its pair, so it can be registered with `ninjabot.WithObservers`
+func (s *Scheduler) OnCandle(df *ninjabot.Dataframe, broker service.Broker) {
+	if df.Pair != s.pair {
+		return
+	}
+	s.Update(df, broker)
+}
+
 func (s *Scheduler) Update(df *ninjabot.Dataframe, broker service.Broker) {
 	s.orderConditions = lo.Filter[OrderCondition](s.orderConditions, func(oc OrderCondition, _ int) bool {
 		if oc.Condition(df) {
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+const dateLayout = "2006-01-02"
+
+// window is a range of the time of day, the end is exclusive
+type window struct {
+	start, end time.Duration
+}
+
+func (w window) contains(offset time.Duration) bool {
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end
+	}
+	// overnight window, e.g. from 22:00 to 02:00
+	return offset >= w.start || offset < w.end
+}
+
+type Option func(*Gate)
+
+// WithTimezone sets the timezone of the weekdays, windows and holidays, default is the location of the
+// dataframe, see `ninjabot.WithTimezone`, or UTC
+func WithTimezone(loc *time.Location) Option {
+	return func(g *Gate) {
+		g.location = loc
+	}
+}
+
+// WithoutWeekdays blocks trading in the given days of the week
+func WithoutWeekdays(days ...time.Weekday) Option {
+	return func(g *Gate) {
+		for _, day := range days {
+			g.weekdays[day] = true
+		}
+	}
+}
+
+// WithoutWeekends blocks trading on Saturdays and Sundays
+func WithoutWeekends() Option {
+	return WithoutWeekdays(time.Saturday, time.Sunday)
+}
+
+// WithWindow allows trading in a range of the time of day, e.g. `WithWindow(8*time.Hour, 20*time.Hour)`
+// from 08:00 to 20:00. A start after the end is an overnight window. The option can be used more than once,
+// trading is allowed in any of the windows. Without windows, trading is allowed all day.
+func WithWindow(start, end time.Duration) Option {
+	return func(g *Gate) {
+		g.windows = append(g.windows, window{start: start, end: end})
+	}
+}
+
+// WithHolidays blocks trading in the whole day of the given dates
+func WithHolidays(dates ...time.Time) Option {
+	return func(g *Gate) {
+		for _, date := range dates {
+			g.holidays[date.Format(dateLayout)] = true
+		}
+	}
+}
+
+// WithVolumePercentile blocks trading when the volume of the last candle is below a percentile, e.g. 25,
+// of the volume of the previous candles in the lookback. Trading is blocked until the lookback is complete.
+func WithVolumePercentile(percentile float64, lookback int) Option {
+	return func(g *Gate) {
+		g.percentile = percentile
+		g.lookback = lookback
+	}
+}
+
+// Gate checks if a candle is in a period of enough liquidity to trade, by the time of day, day of the week,
+// holidays and volume. Strategies check the gate before placing orders, e.g. to avoid thin weekend hours:
+//
+//	gate := session.New(session.WithoutWeekends(), session.WithVolumePercentile(25, 48))
+//	if !gate.Check(df) {
+//		return
+//	}
+type Gate struct {
+	location   *time.Location
+	weekdays   map[time.Weekday]bool
+	holidays   map[string]bool
+	windows    []window
+	percentile float64
+	lookback   int
+}
+
+func New(options ...Option) *Gate {
+	gate := &Gate{
+		weekdays: make(map[time.Weekday]bool),
+		holidays: make(map[string]bool),
+	}
+
+	for _, option := range options {
+		option(gate)
+	}
+
+	return gate
+}
+
+// Check returns true if the last candle of the dataframe meets the time and volume criteria
+func (g *Gate) Check(df *model.Dataframe) bool {
+	if len(df.Time) == 0 {
+		return false
+	}
+
+	loc := g.location
+	if loc == nil {
+		loc = df.Location
+	}
+
+	return g.timeAllowed(df.Time[len(df.Time)-1], loc) && g.VolumeAllowed(df.Volume.Values())
+}
+
+// Allowed returns true if a candle with the given time and the last volume of the series meets the
+// time and volume criteria
+func (g *Gate) Allowed(t time.Time, volume []float64) bool {
+	return g.TimeAllowed(t) && g.VolumeAllowed(volume)
+}
+
+// TimeAllowed returns true if the time is in an allowed day and window
+func (g *Gate) TimeAllowed(t time.Time) bool {
+	return g.timeAllowed(t, g.location)
+}
+
+func (g *Gate) timeAllowed(t time.Time, loc *time.Location) bool {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	if g.weekdays[t.Weekday()] || g.holidays[t.Format(dateLayout)] {
+		return false
+	}
+
+	if len(g.windows) == 0 {
+		return true
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	for _, w := range g.windows {
+		if w.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// VolumeAllowed returns true if the last volume is at least the volume percentile of the previous candles,
+// see WithVolumePercentile. It is always true without a volume filter.
+func (g *Gate) VolumeAllowed(volume []float64) bool {
+	if g.lookback <= 0 {
+		return true
+	}
+
+	if len(volume) < g.lookback+1 {
+		return false
+	}
+
+	threshold := Percentile(volume[len(volume)-g.lookback-1:len(volume)-1], g.percentile)
+	return volume[len(volume)-1] >= threshold
+}
+
+// Percentile returns the percentile (0-100) of the values, with linear interpolation between the closest ranks
+func Percentile(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	percentile = math.Max(0, math.Min(100, percentile))
+	rank := percentile / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
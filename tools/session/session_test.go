@@ -0,0 +1,96 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestGate_TimeAllowed(t *testing.T) {
+	// 2021-01-01 is a Friday
+	friday := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("weekends", func(t *testing.T) {
+		gate := New(WithoutWeekends())
+		require.True(t, gate.TimeAllowed(friday.Add(23*time.Hour)))
+		require.False(t, gate.TimeAllowed(friday.Add(24*time.Hour)))
+		require.False(t, gate.TimeAllowed(friday.Add(71*time.Hour)))
+		require.True(t, gate.TimeAllowed(friday.Add(72*time.Hour)))
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		gate := New(WithWindow(8*time.Hour, 12*time.Hour), WithWindow(22*time.Hour, 2*time.Hour))
+		require.False(t, gate.TimeAllowed(friday.Add(7*time.Hour)))
+		require.True(t, gate.TimeAllowed(friday.Add(8*time.Hour)))
+		require.False(t, gate.TimeAllowed(friday.Add(12*time.Hour)))
+		require.True(t, gate.TimeAllowed(friday.Add(23*time.Hour)))
+		require.True(t, gate.TimeAllowed(friday.Add(time.Hour)))
+		require.False(t, gate.TimeAllowed(friday.Add(2*time.Hour)))
+	})
+
+	t.Run("timezone", func(t *testing.T) {
+		est := time.FixedZone("EST", -5*60*60)
+		gate := New(WithoutWeekends(), WithTimezone(est))
+
+		// Saturday in UTC, Friday in EST
+		require.True(t, gate.TimeAllowed(friday.Add(26*time.Hour)))
+		require.False(t, gate.TimeAllowed(friday.Add(29*time.Hour)))
+	})
+
+	t.Run("holidays", func(t *testing.T) {
+		gate := New(WithHolidays(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)))
+		require.False(t, gate.TimeAllowed(friday.Add(12*time.Hour)))
+		require.True(t, gate.TimeAllowed(friday.Add(24*time.Hour)))
+	})
+}
+
+func TestGate_VolumeAllowed(t *testing.T) {
+	gate := New(WithVolumePercentile(50, 5))
+
+	// not enough candles
+	require.False(t, gate.VolumeAllowed([]float64{10, 20, 30, 40, 50}))
+
+	// median of the previous candles is 30
+	require.True(t, gate.VolumeAllowed([]float64{10, 20, 30, 40, 50, 30}))
+	require.False(t, gate.VolumeAllowed([]float64{10, 20, 30, 40, 50, 29}))
+
+	// only the lookback is considered
+	require.True(t, gate.VolumeAllowed([]float64{1000, 10, 20, 30, 40, 50, 30}))
+
+	// without filter
+	require.True(t, New().VolumeAllowed(nil))
+}
+
+func TestGate_Check(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	df := &model.Dataframe{Pair: "BTCUSDT"}
+	for i := 0; i < 4; i++ {
+		df.Time = append(df.Time, start.Add(time.Duration(i)*time.Hour))
+		df.Volume = append(df.Volume, 100)
+	}
+
+	gate := New(WithWindow(2*time.Hour, 4*time.Hour), WithVolumePercentile(25, 3))
+	require.True(t, gate.Check(df))
+
+	df.Volume[3] = 10
+	require.False(t, gate.Check(df))
+
+	// the window is in the timezone of the dataframe
+	df.Volume[3] = 100
+	df.Location = time.FixedZone("UTC+2", 2*60*60)
+	require.False(t, gate.Check(df))
+
+	require.False(t, gate.Check(&model.Dataframe{}))
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{40, 10, 30, 20}
+	require.Equal(t, 10.0, Percentile(values, 0))
+	require.Equal(t, 40.0, Percentile(values, 100))
+	require.Equal(t, 25.0, Percentile(values, 50))
+	require.InDelta(t, 17.5, Percentile(values, 25), 1e-9)
+	require.Equal(t, 0.0, Percentile(nil, 50))
+}
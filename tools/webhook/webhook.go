@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// SecretHeader is the header with the shared secret, for senders that do not include it in the payload
+const SecretHeader = "X-Webhook-Secret"
+
+// maxPayloadSize is the maximum size of an alert, TradingView messages are limited to a few KB
+const maxPayloadSize = 64 << 10
+
+var (
+	ErrUnauthorized = errors.New("invalid secret")
+	ErrInvalidAlert = errors.New("invalid alert")
+)
+
+// Alert is the JSON message of a TradingView alert, e.g. with the placeholders of a strategy:
+//
+//	{"secret": "...", "pair": "{{ticker}}", "side": "{{strategy.order.action}}",
+//	"size": {{strategy.order.contracts}}}
+//
+// The order is a market order of Size in the asset, or of Quote in the quote asset, only one must be set.
+type Alert struct {
+	Secret string  `json:"secret"`
+	Pair   string  `json:"pair"`
+	Side   string  `json:"side"`
+	Size   float64 `json:"size"`
+	Quote  float64 `json:"quote"`
+	// Tag is an optional annotation of the order, e.g. the signal name, ignored by quote orders
+	Tag string `json:"tag"`
+}
+
+type Option func(*Server)
+
+// WithPairs restricts the alerts to the given pairs, e.g. the pairs of the bot settings.
+// By default, any pair is accepted.
+func WithPairs(pairs ...string) Option {
+	return func(s *Server) {
+		for _, pair := range pairs {
+			s.pairs[strings.ToUpper(pair)] = true
+		}
+	}
+}
+
+// WithSymbol maps a TradingView ticker to a pair, e.g. `WithSymbol("XBTUSD", "BTCUSDT")`.
+// Tickers without mapping are normalized, see Server.Pair.
+func WithSymbol(ticker, pair string) Option {
+	return func(s *Server) {
+		s.symbols[strings.ToUpper(ticker)] = strings.ToUpper(pair)
+	}
+}
+
+// Server receives TradingView webhook alerts and executes them as market orders with the broker,
+// e.g. the order controller of the bot. Alerts must include the shared secret, in the payload or in
+// the SecretHeader. It is an http.Handler, so it can be mounted in an existing server or started with Start.
+type Server struct {
+	broker  service.Broker
+	secret  string
+	pairs   map[string]bool
+	symbols map[string]string
+}
+
+// New creates a webhook server, an empty secret rejects all alerts
+func New(broker service.Broker, secret string, options ...Option) *Server {
+	server := &Server{
+		broker:  broker,
+		secret:  secret,
+		pairs:   make(map[string]bool),
+		symbols: make(map[string]string),
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	return server
+}
+
+// Start listens to the given address, e.g. ":8081", and serves the alerts in any path
+func (s *Server) Start(address string) error {
+	log.Infof("[WEBHOOK] Listening alerts at %s", address)
+	return http.ListenAndServe(address, s)
+}
+
+// Pair returns the pair of a TradingView ticker, with the mapping of WithSymbol or the ticker without the
+// exchange prefix and the perpetual suffix, e.g. BINANCE:BTCUSDT.P is BTCUSDT
+func (s *Server) Pair(ticker string) string {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if pair, ok := s.symbols[ticker]; ok {
+		return pair
+	}
+
+	if index := strings.LastIndex(ticker, ":"); index >= 0 {
+		ticker = ticker[index+1:]
+		if pair, ok := s.symbols[ticker]; ok {
+			return pair
+		}
+	}
+
+	return strings.TrimSuffix(ticker, ".P")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var alert Alert
+	if err := json.Unmarshal(content, &alert); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", ErrInvalidAlert, err), http.StatusBadRequest)
+		return
+	}
+
+	if alert.Secret == "" {
+		alert.Secret = r.Header.Get(SecretHeader)
+	}
+
+	order, err := s.Execute(alert)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			status = http.StatusUnauthorized
+		case errors.Is(err, ErrInvalidAlert):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		log.Error("webhook/encode: ", err)
+	}
+}
+
+// Execute validates an alert and creates its order
+func (s *Server) Execute(alert Alert) (model.Order, error) {
+	if s.secret == "" || subtle.ConstantTimeCompare([]byte(alert.Secret), []byte(s.secret)) != 1 {
+		log.Warnf("[WEBHOOK] alert for %s rejected: %v", alert.Pair, ErrUnauthorized)
+		return model.Order{}, ErrUnauthorized
+	}
+
+	pair := s.Pair(alert.Pair)
+	if pair == "" || (len(s.pairs) > 0 && !s.pairs[pair]) {
+		return model.Order{}, fmt.Errorf("%w: unknown pair %q", ErrInvalidAlert, alert.Pair)
+	}
+
+	var side model.SideType
+	switch strings.ToLower(strings.TrimSpace(alert.Side)) {
+	case "buy":
+		side = model.SideTypeBuy
+	case "sell":
+		side = model.SideTypeSell
+	default:
+		return model.Order{}, fmt.Errorf("%w: invalid side %q", ErrInvalidAlert, alert.Side)
+	}
+
+	if alert.Size < 0 || alert.Quote < 0 || (alert.Size > 0) == (alert.Quote > 0) {
+		return model.Order{}, fmt.Errorf("%w: one of size or quote must be positive", ErrInvalidAlert)
+	}
+
+	log.Infof("[WEBHOOK] %s %s: size %f, quote %f", side, pair, alert.Size, alert.Quote)
+
+	var (
+		order model.Order
+		err   error
+	)
+	switch {
+	case alert.Quote > 0:
+		order, err = s.broker.CreateOrderMarketQuote(side, pair, alert.Quote)
+	case alert.Tag != "":
+		order, err = s.broker.CreateOrderMarketTagged(side, pair, alert.Size, alert.Tag)
+	default:
+		order, err = s.broker.CreateOrderMarket(side, pair, alert.Size)
+	}
+	if err != nil {
+		log.Errorf("[WEBHOOK] %s %s order failed: %v", side, pair, err)
+		return model.Order{}, err
+	}
+
+	return order, nil
+}
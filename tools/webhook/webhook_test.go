@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/testdata/mocks"
+)
+
+func post(server *Server, payload string, header map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServer(t *testing.T) {
+	t.Run("market order", func(t *testing.T) {
+		broker := mocks.NewBroker(t)
+		broker.EXPECT().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.5).
+			Return(model.Order{ID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Quantity: 0.5}, nil).Once()
+		server := New(broker, "s3cr3t", WithPairs("BTCUSDT"))
+
+		response := post(server, `{"secret": "s3cr3t", "pair": "BINANCE:BTCUSDT", "side": "buy", "size": 0.5}`, nil)
+		require.Equal(t, http.StatusOK, response.Code)
+
+		var order model.Order
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &order))
+		require.Equal(t, int64(1), order.ID)
+		require.Equal(t, 0.5, order.Quantity)
+	})
+
+	t.Run("quote order with secret header", func(t *testing.T) {
+		broker := mocks.NewBroker(t)
+		broker.EXPECT().CreateOrderMarketQuote(model.SideTypeSell, "ETHUSDT", 100.0).
+			Return(model.Order{ID: 2}, nil).Once()
+		server := New(broker, "s3cr3t")
+
+		response := post(server, `{"pair": "BINANCE:ETHUSDT.P", "side": "SELL", "quote": 100}`,
+			map[string]string{SecretHeader: "s3cr3t"})
+		require.Equal(t, http.StatusOK, response.Code)
+	})
+
+	t.Run("tagged order with symbol mapping", func(t *testing.T) {
+		broker := mocks.NewBroker(t)
+		broker.EXPECT().CreateOrderMarketTagged(model.SideTypeBuy, "BTCUSDT", 1.0, "breakout").
+			Return(model.Order{ID: 3}, nil).Once()
+		server := New(broker, "s3cr3t", WithSymbol("XBTUSD", "BTCUSDT"))
+
+		response := post(server,
+			`{"secret": "s3cr3t", "pair": "BITMEX:XBTUSD", "side": "buy", "size": 1, "tag": "breakout"}`, nil)
+		require.Equal(t, http.StatusOK, response.Code)
+	})
+
+	t.Run("invalid alerts", func(t *testing.T) {
+		broker := mocks.NewBroker(t) // no order expected
+		server := New(broker, "s3cr3t", WithPairs("BTCUSDT"))
+
+		tcs := []struct {
+			name    string
+			payload string
+			status  int
+		}{
+			{"wrong secret", `{"secret": "wrong", "pair": "BTCUSDT", "side": "buy", "size": 1}`,
+				http.StatusUnauthorized},
+			{"without secret", `{"pair": "BTCUSDT", "side": "buy", "size": 1}`, http.StatusUnauthorized},
+			{"invalid json", `{"secret": "s3cr3t", "pair": `, http.StatusBadRequest},
+			{"unknown pair", `{"secret": "s3cr3t", "pair": "ETHUSDT", "side": "buy", "size": 1}`,
+				http.StatusBadRequest},
+			{"invalid side", `{"secret": "s3cr3t", "pair": "BTCUSDT", "side": "hold", "size": 1}`,
+				http.StatusBadRequest},
+			{"without size", `{"secret": "s3cr3t", "pair": "BTCUSDT", "side": "buy"}`, http.StatusBadRequest},
+			{"size and quote", `{"secret": "s3cr3t", "pair": "BTCUSDT", "side": "buy", "size": 1, "quote": 10}`,
+				http.StatusBadRequest},
+			{"negative size", `{"secret": "s3cr3t", "pair": "BTCUSDT", "side": "buy", "size": -1}`,
+				http.StatusBadRequest},
+		}
+
+		for _, tc := range tcs {
+			t.Run(tc.name, func(t *testing.T) {
+				response := post(server, tc.payload, nil)
+				require.Equal(t, tc.status, response.Code)
+			})
+		}
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+
+	t.Run("empty secret", func(t *testing.T) {
+		server := New(mocks.NewBroker(t), "")
+		response := post(server, `{"secret": "", "pair": "BTCUSDT", "side": "buy", "size": 1}`, nil)
+		require.Equal(t, http.StatusUnauthorized, response.Code)
+	})
+
+	t.Run("order error", func(t *testing.T) {
+		broker := mocks.NewBroker(t)
+		broker.EXPECT().CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1.0).
+			Return(model.Order{}, errors.New("insufficient funds")).Once()
+		server := New(broker, "s3cr3t")
+
+		response := post(server, `{"secret": "s3cr3t", "pair": "BTCUSDT", "side": "buy", "size": 1}`, nil)
+		require.Equal(t, http.StatusUnprocessableEntity, response.Code)
+		require.Contains(t, response.Body.String(), "insufficient funds")
+	})
+}
+
+func TestServer_Pair(t *testing.T) {
+	server := New(nil, "s3cr3t", WithSymbol("XBTUSD", "btcusdt"))
+	require.Equal(t, "BTCUSDT", server.Pair("BINANCE:BTCUSDT"))
+	require.Equal(t, "BTCUSDT", server.Pair("binance:btcusdt.p"))
+	require.Equal(t, "ETHUSDT", server.Pair("ETHUSDT"))
+	require.Equal(t, "BTCUSDT", server.Pair("XBTUSD"))
+	require.Equal(t, "BTCUSDT", server.Pair("BITMEX:XBTUSD"))
+}